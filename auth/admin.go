@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AdminList holds the usernames allowed to use admin endpoints
+// It is configured from the ADMIN_USERS environment variable (comma-separated)
+type AdminList map[string]bool
+
+// ParseAdminList builds an AdminList from a comma-separated list of usernames
+func ParseAdminList(usernames string) AdminList {
+	admins := AdminList{}
+	for _, username := range strings.Split(usernames, ",") {
+		if username = strings.TrimSpace(username); username != "" {
+			admins[username] = true
+		}
+	}
+	return admins
+}
+
+// IsAdmin reports whether a username is an administrator
+func (a AdminList) IsAdmin(username string) bool {
+	return a[username]
+}
+
+// WithAdminAuth is an HTTP middleware for admin-only endpoints.
+//
+// It authenticates the request like WithJWTAuth and then checks that the
+// authenticated user is in the admin list, responding with HTTP 403 Forbidden otherwise.
+//
+// Usage:
+//
+//	mux.Handle("POST /admin/fx/rates", auth.WithAdminAuth(jwtManager, admins, http.HandlerFunc(handler)))
+func WithAdminAuth(jwtManager JWTManager, admins AdminList, next http.Handler) http.Handler {
+	return WithJWTAuth(jwtManager, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(UsernameContextKey).(string)
+		if !admins.IsAdmin(username) {
+			http.Error(w, "Admin privileges required.", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,41 @@
+package blobstore // Package blobstore stores uploaded files such as product images.
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// Store saves and loads blobs by key
+// Keys are slash-separated paths such as "products/3/ab12cd.jpg"
+type Store interface {
+	Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error
+	// Get opens a blob; the caller must close Object.Body
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete removes a blob; deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a stored blob opened for reading
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+	Size        int64
+}
+
+// validKey rejects empty keys and keys that could escape the store, such as "../secret"
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
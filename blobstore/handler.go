@@ -0,0 +1,61 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+)
+
+// Handler serves stored blobs over HTTP, whichever Store keeps them
+type Handler struct {
+	store Store
+}
+
+// NewHandler creates a new blob handler
+func NewHandler(store Store) *Handler {
+	return &Handler{store: store}
+}
+
+// RegisterRoutes registers the public media route
+func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /media/{key...}", h.handleGetBlob())
+}
+
+// handleGetBlob handles GET /media/{key}
+// Keys are never reused for different content, so responses can be cached indefinitely
+func (h *Handler) handleGetBlob() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		if !validKey(key) {
+			helper.RespondError(w, http.StatusNotFound, "File not found")
+			return
+		}
+
+		object, err := h.store.Get(r.Context(), key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				helper.RespondError(w, http.StatusNotFound, "File not found")
+				return
+			}
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch file")
+			return
+		}
+		defer object.Body.Close()
+
+		if object.ContentType != "" {
+			w.Header().Set("Content-Type", object.ContentType)
+		}
+		if object.Size >= 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if _, err := io.Copy(w, object.Body); err != nil {
+			log.Printf("Failed to send file %s: %v", key, err)
+		}
+	}
+}
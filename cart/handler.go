@@ -0,0 +1,224 @@
+package cart
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/idempotency"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// Handler handles HTTP requests for cart operations
+type Handler struct {
+	service          CartService
+	userService      user.UserService
+	idempotencyStore idempotency.Store
+}
+
+// NewHandler creates a new cart handler
+func NewHandler(service CartService, userService user.UserService, idempotencyStore idempotency.Store) *Handler {
+	return &Handler{
+		service:          service,
+		userService:      userService,
+		idempotencyStore: idempotencyStore,
+	}
+}
+
+// RegisterRoutes registers all cart routes; every cart belongs to the authenticated user
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	mux.Handle("GET /cart", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetCart())))
+	mux.Handle("DELETE /cart", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleClearCart())))
+	mux.Handle("POST /cart/items", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleAddItem())))
+	mux.Handle("PUT /cart/items/{product_id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleUpdateItem())))
+	mux.Handle("DELETE /cart/items/{product_id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleRemoveItem())))
+	// Checkout honours the Idempotency-Key header like POST /orders
+	mux.Handle("POST /cart/checkout", auth.WithJWTAuth(jwtManager, idempotency.Middleware(h.idempotencyStore, http.HandlerFunc(h.handleCheckout()))))
+}
+
+// currentUserID resolves the authenticated user, writing an error response when it can't
+func (h *Handler) currentUserID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	username, ok := r.Context().Value(auth.UsernameContextKey).(string)
+	if !ok || username == "" {
+		helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+		return 0, false
+	}
+	user, err := h.userService.GetUserByUsername(r.Context(), username)
+	if err != nil || user == nil {
+		helper.RespondError(w, http.StatusNotFound, "User not found")
+		return 0, false
+	}
+	return user.UserID, true
+}
+
+// handleGetCart handles GET /cart
+func (h *Handler) handleGetCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		cart, err := h.service.GetCart(r.Context(), userID)
+		if err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch cart")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, cart)
+	}
+}
+
+// handleClearCart handles DELETE /cart
+func (h *Handler) handleClearCart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.Clear(r.Context(), userID); err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to clear cart")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Cart cleared successfully",
+		})
+	}
+}
+
+// handleAddItem handles POST /cart/items
+func (h *Handler) handleAddItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var req AddItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		cart, err := h.service.AddItem(r.Context(), userID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to add item to cart")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, cart)
+	}
+}
+
+// variantParam parses the optional variant_id query parameter that selects a variant line of a product
+func variantParam(r *http.Request) (uint64, error) {
+	value := r.URL.Query().Get("variant_id")
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// handleUpdateItem handles PUT /cart/items/{product_id}?variant_id=
+func (h *Handler) handleUpdateItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		productID, err := strconv.ParseUint(r.PathValue("product_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		variantID, err := variantParam(r)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid variant ID")
+			return
+		}
+
+		var req UpdateItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		cart, err := h.service.UpdateItem(r.Context(), userID, productID, variantID, req.Quantity)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update cart item")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, cart)
+	}
+}
+
+// handleRemoveItem handles DELETE /cart/items/{product_id}?variant_id=
+func (h *Handler) handleRemoveItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		productID, err := strconv.ParseUint(r.PathValue("product_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		variantID, err := variantParam(r)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid variant ID")
+			return
+		}
+
+		cart, err := h.service.RemoveItem(r.Context(), userID, productID, variantID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to remove cart item")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, cart)
+	}
+}
+
+// handleCheckout handles POST /cart/checkout
+func (h *Handler) handleCheckout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		var req CheckoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		order, err := h.service.Checkout(r.Context(), userID, req)
+		if err != nil {
+			log.Printf("Cart checkout failed: %v", err)
+			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "out of stock") || strings.Contains(err.Error(), "cannot be priced") || strings.Contains(err.Error(), "coupon") || strings.Contains(err.Error(), "is not valid") || strings.Contains(err.Error(), "cart is empty") {
+				helper.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to check out cart")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"message":      "Order created successfully",
+			"order":        order,
+			"total_amount": order.Total,
+			"items_count":  len(order.Items),
+		})
+	}
+}
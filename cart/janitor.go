@@ -0,0 +1,30 @@
+package cart
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunJanitor removes abandoned carts every interval until ctx is cancelled
+// It is meant to be started in its own goroutine
+func RunJanitor(ctx context.Context, service CartService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := service.ExpireAbandoned(ctx)
+			if err != nil {
+				log.Printf("Cart janitor: failed to expire abandoned carts: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Cart janitor: removed %d abandoned carts", expired)
+			}
+		}
+	}
+}
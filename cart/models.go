@@ -0,0 +1,79 @@
+package cart // Package cart keeps server-side shopping carts and checks them out into orders.
+
+import (
+	"context"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// DefaultTTL is how long a cart may sit untouched before it is considered abandoned
+const DefaultTTL = 7 * 24 * time.Hour
+
+// CartItem is a product line in a user's cart
+// VariantID is zero for products without variants
+type CartItem struct {
+	ProductID uint64 `json:"product_id"`
+	VariantID uint64 `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Repository defines the interface for cart data operations
+type CartRepository interface {
+	GetItems(ctx context.Context, userID uint64) ([]CartItem, error)
+	// SetItem sets the quantity of a product variant in the cart, adding the line if needed
+	SetItem(ctx context.Context, userID, productID, variantID uint64, quantity int) error
+	RemoveItem(ctx context.Context, userID, productID, variantID uint64) error
+	// ReduceItem takes a quantity off a line, removing the line when nothing is left
+	ReduceItem(ctx context.Context, userID, productID, variantID uint64, quantity int) error
+	Clear(ctx context.Context, userID uint64) error
+	// DeleteExpired removes carts with no activity since the given time and returns how many were removed
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// CartLine is a cart item with live product details
+type CartLine struct {
+	ProductID  uint64            `json:"product_id"`
+	VariantID  uint64            `json:"variant_id,omitempty"`
+	SKU        string            `json:"sku,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Quantity   int               `json:"quantity"`
+	UnitPrice  *money.Money      `json:"unit_price,omitempty"`
+	LineTotal  *money.Money      `json:"line_total,omitempty"`
+	InStock    bool              `json:"in_stock"`
+	Available  bool              `json:"available"`         // false when the product or variant no longer exists
+	Message    string            `json:"message,omitempty"` // why the line cannot be checked out
+}
+
+// Cart is a preview of a user's cart at current prices and availability
+// Subtotals holds one amount per currency; checkout converts everything to the order currency
+type Cart struct {
+	UserID      uint64        `json:"user_id"`
+	Lines       []CartLine    `json:"lines"`
+	ItemCount   int           `json:"item_count"`
+	Subtotals   []money.Money `json:"subtotals"`
+	CanCheckout bool          `json:"can_checkout"`
+	UpdatedAt   string        `json:"updated_at,omitempty"`
+}
+
+// AddItemRequest represents the request to add a product to the cart
+// VariantID is required for products that have variants
+type AddItemRequest struct {
+	ProductID uint64 `json:"product_id"`
+	VariantID uint64 `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// UpdateItemRequest represents the request to change the quantity of a cart line
+type UpdateItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// CheckoutRequest represents the order options chosen at checkout
+type CheckoutRequest struct {
+	Region      string   `json:"region,omitempty"`
+	Currency    string   `json:"currency,omitempty"`
+	CouponCodes []string `json:"coupon_codes,omitempty"`
+}
@@ -0,0 +1,114 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ClickHouseRepository implements CartRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db *sql.DB
+}
+
+// NewClickHouseRepository creates a new ClickHouse cart repository
+func NewClickHouseRepository(db *sql.DB) CartRepository {
+	return &ClickHouseRepository{db: db}
+}
+
+// ensureCartTable creates the cart_items table if it doesn't exist
+// Every change inserts a new version of the line; a quantity of zero marks it as removed.
+// Reads use FINAL so they always see the latest version without waiting for mutations.
+func (r *ClickHouseRepository) ensureCartTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS cart_items (
+			user_id UInt64,
+			product_id UInt64,
+			variant_id UInt64,
+			quantity Int32,
+			updated_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(updated_at)
+		ORDER BY (user_id, product_id, variant_id)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) GetItems(ctx context.Context, userID uint64) ([]CartItem, error) {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT product_id, variant_id, quantity, updated_at FROM cart_items FINAL WHERE user_id = ? AND quantity > 0 ORDER BY product_id, variant_id"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []CartItem
+	for rows.Next() {
+		var item CartItem
+		var quantity int32
+		var updatedAt time.Time
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &quantity, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.Quantity = int(quantity)
+		item.UpdatedAt = updatedAt.Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *ClickHouseRepository) SetItem(ctx context.Context, userID, productID, variantID uint64, quantity int) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	query := "INSERT INTO cart_items (user_id, product_id, variant_id, quantity, updated_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := r.db.ExecContext(ctx, query, userID, productID, variantID, int32(quantity), time.Now().UTC())
+	return err
+}
+
+func (r *ClickHouseRepository) RemoveItem(ctx context.Context, userID, productID, variantID uint64) error {
+	return r.SetItem(ctx, userID, productID, variantID, 0)
+}
+
+func (r *ClickHouseRepository) ReduceItem(ctx context.Context, userID, productID, variantID uint64, quantity int) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO cart_items (user_id, product_id, variant_id, quantity, updated_at)
+		SELECT user_id, product_id, variant_id, greatest(quantity - ?, 0), ? FROM cart_items FINAL
+		WHERE user_id = ? AND product_id = ? AND variant_id = ? AND quantity > 0`
+	_, err := r.db.ExecContext(ctx, query, int32(quantity), time.Now().UTC(), userID, productID, variantID)
+	return err
+}
+
+func (r *ClickHouseRepository) Clear(ctx context.Context, userID uint64) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO cart_items (user_id, product_id, variant_id, quantity, updated_at)
+		SELECT user_id, product_id, variant_id, 0, ? FROM cart_items FINAL WHERE user_id = ? AND quantity > 0`
+	_, err := r.db.ExecContext(ctx, query, time.Now().UTC(), userID)
+	return err
+}
+
+func (r *ClickHouseRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return 0, err
+	}
+	// A cart is abandoned when none of its lines changed since the cutoff; this also purges removed lines
+	var count uint64
+	countQuery := "SELECT count() FROM (SELECT user_id FROM cart_items GROUP BY user_id HAVING max(updated_at) < ? AND max(quantity) > 0)"
+	if err := r.db.QueryRowContext(ctx, countQuery, before).Scan(&count); err != nil {
+		return 0, err
+	}
+	query := "ALTER TABLE cart_items DELETE WHERE user_id IN (SELECT user_id FROM cart_items GROUP BY user_id HAVING max(updated_at) < ?)"
+	if _, err := r.db.ExecContext(ctx, query, before); err != nil {
+		return 0, err
+	}
+	return int64(count), nil
+}
@@ -0,0 +1,116 @@
+package cart
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresRepository implements CartRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL cart repository
+func NewPostgresRepository(db *sql.DB) CartRepository {
+	return &PostgresRepository{db: db}
+}
+
+// ensureCartTable creates the cart_items table if it doesn't exist
+func (r *PostgresRepository) ensureCartTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS cart_items (
+			user_id BIGINT NOT NULL,
+			product_id BIGINT NOT NULL,
+			variant_id BIGINT NOT NULL DEFAULT 0,
+			quantity INT NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, product_id, variant_id)
+		)`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *PostgresRepository) GetItems(ctx context.Context, userID uint64) ([]CartItem, error) {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT product_id, variant_id, quantity, updated_at FROM cart_items WHERE user_id = $1 ORDER BY product_id, variant_id"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []CartItem
+	for rows.Next() {
+		var item CartItem
+		var updatedAt time.Time
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.Quantity, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.UpdatedAt = updatedAt.Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *PostgresRepository) SetItem(ctx context.Context, userID, productID, variantID uint64, quantity int) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO cart_items (user_id, product_id, variant_id, quantity, updated_at) VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id, product_id, variant_id) DO UPDATE SET quantity = EXCLUDED.quantity, updated_at = NOW()`
+	_, err := r.db.ExecContext(ctx, query, userID, productID, variantID, quantity)
+	return err
+}
+
+func (r *PostgresRepository) RemoveItem(ctx context.Context, userID, productID, variantID uint64) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = $1 AND product_id = $2 AND variant_id = $3", userID, productID, variantID)
+	return err
+}
+
+func (r *PostgresRepository) ReduceItem(ctx context.Context, userID, productID, variantID uint64, quantity int) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	// Delete before updating, so a line reduced by the update isn't deleted too
+	deleteQuery := "DELETE FROM cart_items WHERE user_id = $1 AND product_id = $2 AND variant_id = $3 AND quantity <= $4"
+	if _, err := r.db.ExecContext(ctx, deleteQuery, userID, productID, variantID, quantity); err != nil {
+		return err
+	}
+	updateQuery := `
+		UPDATE cart_items SET quantity = quantity - $4, updated_at = NOW()
+		WHERE user_id = $1 AND product_id = $2 AND variant_id = $3 AND quantity > $4`
+	_, err := r.db.ExecContext(ctx, updateQuery, userID, productID, variantID, quantity)
+	return err
+}
+
+func (r *PostgresRepository) Clear(ctx context.Context, userID uint64) error {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = $1", userID)
+	return err
+}
+
+func (r *PostgresRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	if err := r.ensureCartTable(ctx); err != nil {
+		return 0, err
+	}
+	// A cart is abandoned when none of its lines changed since the cutoff
+	query := `
+		WITH deleted AS (
+			DELETE FROM cart_items WHERE user_id IN (
+				SELECT user_id FROM cart_items GROUP BY user_id HAVING MAX(updated_at) < $1
+			) RETURNING user_id
+		)
+		SELECT COUNT(DISTINCT user_id) FROM deleted`
+	var count int64
+	err := r.db.QueryRowContext(ctx, query, before).Scan(&count)
+	return count, err
+}
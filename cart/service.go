@@ -0,0 +1,256 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/order"
+	"github.com/rajindersingh041/go-auth-sessions/product"
+)
+
+// maxQuantity limits the quantity of a single cart line
+const maxQuantity = 1000
+
+// CartService defines the business logic interface for carts
+type CartService interface {
+	GetCart(ctx context.Context, userID uint64) (*Cart, error)
+	AddItem(ctx context.Context, userID uint64, req AddItemRequest) (*Cart, error)
+	UpdateItem(ctx context.Context, userID, productID, variantID uint64, quantity int) (*Cart, error)
+	RemoveItem(ctx context.Context, userID, productID, variantID uint64) (*Cart, error)
+	Clear(ctx context.Context, userID uint64) error
+	Checkout(ctx context.Context, userID uint64, req CheckoutRequest) (*order.Order, error)
+	// ExpireAbandoned removes carts that have not been touched within the TTL
+	ExpireAbandoned(ctx context.Context) (int64, error)
+}
+
+// cartService implements the CartService interface
+type cartService struct {
+	repo           CartRepository
+	productService product.ProductService
+	orderService   order.OrderService
+	ttl            time.Duration
+}
+
+// NewCartService creates a new cart service
+// Carts untouched for longer than ttl are removed by ExpireAbandoned
+func NewCartService(repo CartRepository, productService product.ProductService, orderService order.OrderService, ttl time.Duration) CartService {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &cartService{
+		repo:           repo,
+		productService: productService,
+		orderService:   orderService,
+		ttl:            ttl,
+	}
+}
+
+// GetCart returns the cart with live prices and availability
+func (s *cartService) GetCart(ctx context.Context, userID uint64) (*Cart, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	items, err := s.repo.GetItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cart := &Cart{UserID: userID, Lines: []CartLine{}, Subtotals: []money.Money{}, CanCheckout: len(items) > 0}
+	now := time.Now()
+	for _, item := range items {
+		line := CartLine{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+		prod, err := s.productService.GetProductAt(ctx, item.ProductID, now)
+		if err == nil && !prod.Archived && item.VariantID != 0 {
+			if variant, ok := prod.Variant(item.VariantID); ok {
+				line.SKU, line.Attributes = variant.SKU, variant.Attributes
+				sold := prod.ForVariant(*variant)
+				prod = &sold
+			} else {
+				err = fmt.Errorf("variant not found")
+			}
+		}
+		if err != nil || prod.Archived {
+			line.Message = "product is no longer available"
+			cart.CanCheckout = false
+		} else if item.VariantID == 0 && len(prod.Variants) > 0 {
+			// Added before the product had variants
+			line.Name = prod.Name
+			line.Message = "choose a variant of this product"
+			cart.CanCheckout = false
+		} else {
+			total := prod.Price.Mul(int64(item.Quantity))
+			line.Name = prod.Name
+			line.UnitPrice = &prod.Price
+			line.LineTotal = &total
+			line.InStock = prod.InStock
+			line.Available = true
+			if !prod.InStock {
+				line.Message = "product is currently out of stock"
+				cart.CanCheckout = false
+			}
+			cart.Subtotals = addSubtotal(cart.Subtotals, total)
+		}
+		cart.Lines = append(cart.Lines, line)
+		cart.ItemCount += item.Quantity
+		if item.UpdatedAt > cart.UpdatedAt {
+			cart.UpdatedAt = item.UpdatedAt
+		}
+	}
+	return cart, nil
+}
+
+// addSubtotal adds an amount to the subtotal of its currency
+func addSubtotal(subtotals []money.Money, amount money.Money) []money.Money {
+	for i := range subtotals {
+		if subtotals[i].Currency == amount.Currency {
+			subtotals[i] = subtotals[i].Add(amount)
+			return subtotals
+		}
+	}
+	return append(subtotals, amount)
+}
+
+// AddItem adds a product to the cart, increasing the quantity if it is already there
+func (s *cartService) AddItem(ctx context.Context, userID uint64, req AddItemRequest) (*Cart, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	if req.ProductID == 0 || req.Quantity <= 0 {
+		return nil, fmt.Errorf("valid product ID and positive quantity are required")
+	}
+	prod, err := s.productService.GetProductByID(ctx, req.ProductID)
+	if err != nil || prod.Archived {
+		return nil, fmt.Errorf("product not found")
+	}
+	if req.VariantID != 0 {
+		if _, ok := prod.Variant(req.VariantID); !ok {
+			return nil, fmt.Errorf("variant %d of product %d not found", req.VariantID, req.ProductID)
+		}
+	} else if len(prod.Variants) > 0 {
+		return nil, fmt.Errorf("a variant of product %d is required", req.ProductID)
+	}
+
+	items, err := s.repo.GetItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	quantity := req.Quantity
+	for _, item := range items {
+		if item.ProductID == req.ProductID && item.VariantID == req.VariantID {
+			quantity += item.Quantity
+		}
+	}
+	if quantity > maxQuantity {
+		return nil, fmt.Errorf("quantity must be at most %d", maxQuantity)
+	}
+	if err := s.repo.SetItem(ctx, userID, req.ProductID, req.VariantID, quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, userID)
+}
+
+// UpdateItem sets the quantity of a cart line; a quantity of zero removes it
+func (s *cartService) UpdateItem(ctx context.Context, userID, productID, variantID uint64, quantity int) (*Cart, error) {
+	if userID == 0 || productID == 0 {
+		return nil, fmt.Errorf("valid user ID and product ID are required")
+	}
+	if quantity < 0 || quantity > maxQuantity {
+		return nil, fmt.Errorf("quantity must be between 0 and %d", maxQuantity)
+	}
+	if quantity == 0 {
+		return s.RemoveItem(ctx, userID, productID, variantID)
+	}
+	if err := s.findItem(ctx, userID, productID, variantID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetItem(ctx, userID, productID, variantID, quantity); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, userID)
+}
+
+// RemoveItem removes a product from the cart
+func (s *cartService) RemoveItem(ctx context.Context, userID, productID, variantID uint64) (*Cart, error) {
+	if userID == 0 || productID == 0 {
+		return nil, fmt.Errorf("valid user ID and product ID are required")
+	}
+	if err := s.findItem(ctx, userID, productID, variantID); err != nil {
+		return nil, err
+	}
+	if err := s.repo.RemoveItem(ctx, userID, productID, variantID); err != nil {
+		return nil, err
+	}
+	return s.GetCart(ctx, userID)
+}
+
+// findItem checks that a product variant is in the cart
+func (s *cartService) findItem(ctx context.Context, userID, productID, variantID uint64) error {
+	items, err := s.repo.GetItems(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.ProductID == productID && item.VariantID == variantID {
+			return nil
+		}
+	}
+	if variantID != 0 {
+		return fmt.Errorf("variant %d of product %d not found in cart", variantID, productID)
+	}
+	return fmt.Errorf("product %d not found in cart", productID)
+}
+
+// Clear empties the cart
+func (s *cartService) Clear(ctx context.Context, userID uint64) error {
+	if userID == 0 {
+		return fmt.Errorf("valid user ID is required")
+	}
+	return s.repo.Clear(ctx, userID)
+}
+
+// Checkout places an order for the cart contents and then takes the ordered lines out of the cart
+// Items added while the order was being placed stay in the cart
+// Prices, availability, currency conversion, promotions and tax are all handled by the order service
+func (s *cartService) Checkout(ctx context.Context, userID uint64, req CheckoutRequest) (*order.Order, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	items, err := s.repo.GetItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("cart is empty")
+	}
+
+	orderReq := order.CreateOrderRequest{
+		Region:      req.Region,
+		Currency:    req.Currency,
+		CouponCodes: req.CouponCodes,
+	}
+	for _, item := range items {
+		orderReq.Items = append(orderReq.Items, order.OrderItemRequest{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity})
+	}
+
+	placed, err := s.orderService.CreateOrder(ctx, userID, orderReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// The order is placed at this point; a line that can't be taken out is only an inconvenience
+	for _, item := range items {
+		if err := s.repo.ReduceItem(ctx, userID, item.ProductID, item.VariantID, item.Quantity); err != nil {
+			log.Printf("Failed to take product %d (variant %d) out of the cart of user %d after order %d: %v",
+				item.ProductID, item.VariantID, userID, placed.OrderID, err)
+		}
+	}
+	return placed, nil
+}
+
+// ExpireAbandoned removes carts that have not been touched within the TTL
+func (s *cartService) ExpireAbandoned(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpired(ctx, time.Now().Add(-s.ttl))
+}
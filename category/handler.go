@@ -0,0 +1,133 @@
+package category
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+)
+
+// Handler handles HTTP requests for the category tree
+type Handler struct {
+	service CategoryService
+	admins  auth.AdminList
+}
+
+// NewHandler creates a new category handler
+func NewHandler(service CategoryService, admins auth.AdminList) *Handler {
+	return &Handler{
+		service: service,
+		admins:  admins,
+	}
+}
+
+// RegisterRoutes registers the public category routes and the admin routes that manage the tree
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	// Public routes (no authentication required)
+	mux.HandleFunc("GET /categories", h.handleGetTree())
+	mux.HandleFunc("GET /categories/{slug}", h.handleGetCategory())
+
+	// Admin routes
+	mux.Handle("POST /admin/categories", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleCreateCategory())))
+	mux.Handle("PUT /admin/categories/{id}", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleUpdateCategory())))
+	mux.Handle("DELETE /admin/categories/{id}", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleDeleteCategory())))
+}
+
+// handleGetTree handles GET /categories, returning the nested category tree
+func (h *Handler) handleGetTree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tree, err := h.service.Tree(r.Context())
+		if err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch categories")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"categories": tree,
+		})
+	}
+}
+
+// handleGetCategory handles GET /categories/{slug}
+func (h *Handler) handleGetCategory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		category, err := h.service.GetCategoryBySlug(r.Context(), r.PathValue("slug"))
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to fetch category", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, category)
+	}
+}
+
+// handleCreateCategory handles POST /admin/categories
+func (h *Handler) handleCreateCategory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		category, err := h.service.CreateCategory(r.Context(), req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create category", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, category)
+	}
+}
+
+// handleUpdateCategory handles PUT /admin/categories/{id}
+func (h *Handler) handleUpdateCategory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categoryID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid category ID")
+			return
+		}
+
+		var req CategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		category, err := h.service.UpdateCategory(r.Context(), categoryID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update category", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, category)
+	}
+}
+
+// handleDeleteCategory handles DELETE /admin/categories/{id}
+func (h *Handler) handleDeleteCategory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		categoryID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid category ID")
+			return
+		}
+
+		if err := h.service.DeleteCategory(r.Context(), categoryID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to delete category", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Category deleted successfully",
+		})
+	}
+}
+
+// errorRules answer the category service errors the shared rules don't; deleting a category that is still in use is a conflict
+var errorRules = []helper.ErrorRule{
+	{Fragment: "to be deleted", Status: http.StatusConflict},
+}
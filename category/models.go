@@ -0,0 +1,55 @@
+package category
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// Category is a node of the product category tree
+// Top-level categories have no parent; siblings are ordered by Position, then name
+type Category struct {
+	CategoryID uint64      `json:"category_id"`
+	ParentID   uint64      `json:"parent_id,omitempty"`
+	Name       string      `json:"name"`
+	Slug       string      `json:"slug"` // unique, used in URLs such as /products/category/{slug}
+	Position   int         `json:"position"`
+	CreatedAt  string      `json:"created_at"`
+	Children   []*Category `json:"children,omitempty"` // only filled in trees
+}
+
+// Repository defines the interface for category data operations
+type CategoryRepository interface {
+	Create(ctx context.Context, category *Category) error
+	Update(ctx context.Context, category *Category) error
+	Delete(ctx context.Context, categoryID uint64) error
+	GetByID(ctx context.Context, categoryID uint64) (*Category, error)
+	GetBySlug(ctx context.Context, slug string) (*Category, error)
+	// GetAll returns every category, ordered by position and name
+	GetAll(ctx context.Context) ([]Category, error)
+}
+
+// ProductCatalog is the part of the product repository that categories need
+// Products keep a copy of their category's name, so renames are written through to them
+type ProductCatalog interface {
+	CountByCategory(ctx context.Context, categoryID uint64) (int, error)
+	RenameCategory(ctx context.Context, categoryID uint64, name string) error
+}
+
+// CategoryRequest represents the request to create or update a category
+// Slug defaults to one derived from the name
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID uint64 `json:"parent_id"`
+	Position int    `json:"position"`
+}
+
+// Slugify turns a name into a URL slug: lower-case letters and digits separated by single dashes
+// Names that differ only in case or punctuation, such as "Electronics" and "electronics", get the same slug
+func Slugify(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,120 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+)
+
+// ClickHouseRepository implements CategoryRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db  *sql.DB
+	ids idgen.Generator
+}
+
+// NewClickHouseRepository creates a new ClickHouse category repository
+// ClickHouse has no auto-increment, so IDs come from the shared ID generator
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) CategoryRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
+}
+
+// clickHouseCategoryColumns lists the category columns in scan order
+const clickHouseCategoryColumns = "category_id, parent_id, name, slug, position, created_at"
+
+// ensureCategoriesTable creates the categories table if it doesn't exist
+// ClickHouse has no unique constraints; slugs are checked by the service
+func (r *ClickHouseRepository) ensureCategoriesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS categories (
+			category_id UInt64,
+			parent_id UInt64,
+			name String,
+			slug String,
+			position Int32,
+			created_at String
+		) ENGINE = MergeTree()
+		ORDER BY category_id
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) Create(ctx context.Context, category *Category) error {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return err
+	}
+	category.CategoryID = r.ids.NextID()
+	query := "INSERT INTO categories (category_id, parent_id, name, slug, position, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := r.db.ExecContext(ctx, query, category.CategoryID, category.ParentID, category.Name, category.Slug, category.Position, category.CreatedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) Update(ctx context.Context, category *Category) error {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return err
+	}
+	query := "ALTER TABLE categories UPDATE parent_id = ?, name = ?, slug = ?, position = ? WHERE category_id = ?"
+	_, err := r.db.ExecContext(ctx, query, category.ParentID, category.Name, category.Slug, category.Position, category.CategoryID)
+	return err
+}
+
+func (r *ClickHouseRepository) Delete(ctx context.Context, categoryID uint64) error {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE categories DELETE WHERE category_id = ?", categoryID)
+	return err
+}
+
+func (r *ClickHouseRepository) GetByID(ctx context.Context, categoryID uint64) (*Category, error) {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHouseCategoryColumns + " FROM categories WHERE category_id = ? LIMIT 1"
+	return r.scanCategory(r.db.QueryRowContext(ctx, query, categoryID))
+}
+
+func (r *ClickHouseRepository) GetBySlug(ctx context.Context, slug string) (*Category, error) {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHouseCategoryColumns + " FROM categories WHERE slug = ? LIMIT 1"
+	return r.scanCategory(r.db.QueryRowContext(ctx, query, slug))
+}
+
+func (r *ClickHouseRepository) GetAll(ctx context.Context) ([]Category, error) {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+clickHouseCategoryColumns+" FROM categories ORDER BY position, name, category_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		category, err := r.scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	return categories, rows.Err()
+}
+
+// scanCategory scans a category from a *sql.Row or *sql.Rows
+func (r *ClickHouseRepository) scanCategory(row interface{ Scan(...interface{}) error }) (*Category, error) {
+	var c Category
+	var position int32
+	if err := row.Scan(&c.CategoryID, &c.ParentID, &c.Name, &c.Slug, &position, &c.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category not found")
+		}
+		return nil, err
+	}
+	c.Position = int(position)
+	return &c, nil
+}
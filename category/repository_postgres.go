@@ -0,0 +1,125 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresRepository implements CategoryRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL category repository
+func NewPostgresRepository(db *sql.DB) CategoryRepository {
+	return &PostgresRepository{db: db}
+}
+
+// categoryColumns lists the category columns in scan order
+const categoryColumns = "category_id, parent_id, name, slug, position, created_at"
+
+// ensureCategoriesTable creates the categories table if it doesn't exist
+// parent_id is 0 for top-level categories, so it has no foreign key
+func (r *PostgresRepository) ensureCategoriesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS categories (
+			category_id SERIAL PRIMARY KEY,
+			parent_id BIGINT NOT NULL DEFAULT 0,
+			name TEXT NOT NULL,
+			slug TEXT NOT NULL UNIQUE,
+			position INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *PostgresRepository) Create(ctx context.Context, category *Category) error {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return err
+	}
+	query := "INSERT INTO categories (parent_id, name, slug, position, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING category_id"
+	return r.db.QueryRowContext(ctx, query, category.ParentID, category.Name, category.Slug, category.Position, category.CreatedAt).Scan(&category.CategoryID)
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, category *Category) error {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return err
+	}
+	query := "UPDATE categories SET parent_id = $1, name = $2, slug = $3, position = $4 WHERE category_id = $5"
+	result, err := r.db.ExecContext(ctx, query, category.ParentID, category.Name, category.Slug, category.Position, category.CategoryID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("category not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, categoryID uint64) error {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM categories WHERE category_id = $1", categoryID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("category not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetByID(ctx context.Context, categoryID uint64) (*Category, error) {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + categoryColumns + " FROM categories WHERE category_id = $1"
+	return r.scanCategory(r.db.QueryRowContext(ctx, query, categoryID))
+}
+
+func (r *PostgresRepository) GetBySlug(ctx context.Context, slug string) (*Category, error) {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + categoryColumns + " FROM categories WHERE slug = $1"
+	return r.scanCategory(r.db.QueryRowContext(ctx, query, slug))
+}
+
+func (r *PostgresRepository) GetAll(ctx context.Context) ([]Category, error) {
+	if err := r.ensureCategoriesTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories ORDER BY position, name, category_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []Category
+	for rows.Next() {
+		category, err := r.scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+	return categories, rows.Err()
+}
+
+// scanCategory scans a category from a *sql.Row or *sql.Rows
+func (r *PostgresRepository) scanCategory(row interface{ Scan(...interface{}) error }) (*Category, error) {
+	var c Category
+	var createdAt time.Time
+	if err := row.Scan(&c.CategoryID, &c.ParentID, &c.Name, &c.Slug, &c.Position, &createdAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category not found")
+		}
+		return nil, err
+	}
+	c.CreatedAt = createdAt.Format(time.RFC3339)
+	return &c, nil
+}
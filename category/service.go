@@ -0,0 +1,222 @@
+package category
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CategoryService defines the business logic interface for the category tree
+type CategoryService interface {
+	CreateCategory(ctx context.Context, req CategoryRequest) (*Category, error)
+	UpdateCategory(ctx context.Context, categoryID uint64, req CategoryRequest) (*Category, error)
+	DeleteCategory(ctx context.Context, categoryID uint64) error
+	GetCategory(ctx context.Context, categoryID uint64) (*Category, error)
+	// GetCategoryBySlug also accepts category names, e.g. "Electronics" for the slug "electronics"
+	GetCategoryBySlug(ctx context.Context, slug string) (*Category, error)
+	ListCategories(ctx context.Context) ([]Category, error)
+	// Tree returns the top-level categories with their children nested
+	Tree(ctx context.Context) ([]*Category, error)
+	// Descendants returns the IDs of a category and all categories below it
+	Descendants(ctx context.Context, categoryID uint64) ([]uint64, error)
+	// EnsureCategory returns the category with the name's slug, creating a top-level category if there is none
+	EnsureCategory(ctx context.Context, name string) (*Category, error)
+}
+
+// categoryService implements the CategoryService interface
+type categoryService struct {
+	repo     CategoryRepository
+	products ProductCatalog
+}
+
+// NewCategoryService creates a new category service
+func NewCategoryService(repo CategoryRepository, products ProductCatalog) CategoryService {
+	return &categoryService{
+		repo:     repo,
+		products: products,
+	}
+}
+
+// CreateCategory validates and creates a category
+func (s *categoryService) CreateCategory(ctx context.Context, req CategoryRequest) (*Category, error) {
+	category, err := s.build(ctx, 0, req)
+	if err != nil {
+		return nil, err
+	}
+	category.CreatedAt = time.Now().Format(time.RFC3339)
+	if err := s.repo.Create(ctx, category); err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// UpdateCategory renames or moves a category
+// A new name is copied to the category's products
+func (s *categoryService) UpdateCategory(ctx context.Context, categoryID uint64, req CategoryRequest) (*Category, error) {
+	existing, err := s.GetCategory(ctx, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	category, err := s.build(ctx, categoryID, req)
+	if err != nil {
+		return nil, err
+	}
+	category.CreatedAt = existing.CreatedAt
+	if err := s.repo.Update(ctx, category); err != nil {
+		return nil, err
+	}
+	if category.Name != existing.Name {
+		if err := s.products.RenameCategory(ctx, categoryID, category.Name); err != nil {
+			return nil, fmt.Errorf("failed to rename category of products: %w", err)
+		}
+	}
+	return category, nil
+}
+
+// DeleteCategory removes a category that has no sub-categories and no products
+func (s *categoryService) DeleteCategory(ctx context.Context, categoryID uint64) error {
+	if _, err := s.GetCategory(ctx, categoryID); err != nil {
+		return err
+	}
+	categories, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range categories {
+		if c.ParentID == categoryID {
+			return fmt.Errorf("category must not have sub-categories to be deleted")
+		}
+	}
+	count, err := s.products.CountByCategory(ctx, categoryID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("category must not have products to be deleted, but has %d", count)
+	}
+	return s.repo.Delete(ctx, categoryID)
+}
+
+// GetCategory retrieves a category by ID
+func (s *categoryService) GetCategory(ctx context.Context, categoryID uint64) (*Category, error) {
+	if categoryID == 0 {
+		return nil, fmt.Errorf("valid category ID is required")
+	}
+	return s.repo.GetByID(ctx, categoryID)
+}
+
+// GetCategoryBySlug retrieves a category by slug, or by a name that slugifies to it
+func (s *categoryService) GetCategoryBySlug(ctx context.Context, slug string) (*Category, error) {
+	slug = Slugify(slug)
+	if slug == "" {
+		return nil, fmt.Errorf("category slug is required")
+	}
+	return s.repo.GetBySlug(ctx, slug)
+}
+
+// ListCategories retrieves all categories as a flat list
+func (s *categoryService) ListCategories(ctx context.Context) ([]Category, error) {
+	return s.repo.GetAll(ctx)
+}
+
+// Tree nests all categories under their parents
+func (s *categoryService) Tree(ctx context.Context) ([]*Category, error) {
+	categories, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[uint64]*Category, len(categories))
+	for i := range categories {
+		nodes[categories[i].CategoryID] = &categories[i]
+	}
+	// GetAll is ordered by position, so children are appended in order
+	roots := []*Category{}
+	for i := range categories {
+		node := &categories[i]
+		if parent, ok := nodes[node.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots, nil
+}
+
+// Descendants walks the tree below a category
+func (s *categoryService) Descendants(ctx context.Context, categoryID uint64) ([]uint64, error) {
+	categories, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	children := map[uint64][]uint64{}
+	for _, c := range categories {
+		children[c.ParentID] = append(children[c.ParentID], c.CategoryID)
+	}
+	ids := []uint64{categoryID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+// EnsureCategory finds or creates the category for a free-text name
+func (s *categoryService) EnsureCategory(ctx context.Context, name string) (*Category, error) {
+	category, err := s.GetCategoryBySlug(ctx, name)
+	if err == nil {
+		return category, nil
+	}
+	if err.Error() != "category not found" {
+		return nil, err
+	}
+	return s.CreateCategory(ctx, CategoryRequest{Name: name})
+}
+
+// build validates a request and turns it into a category
+func (s *categoryService) build(ctx context.Context, categoryID uint64, req CategoryRequest) (*Category, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("category name is required")
+	}
+	slug := Slugify(req.Slug)
+	if slug == "" {
+		slug = Slugify(name)
+	}
+	if slug == "" {
+		return nil, fmt.Errorf("category slug must contain letters or digits")
+	}
+
+	existing, err := s.repo.GetBySlug(ctx, slug)
+	if err != nil && err.Error() != "category not found" {
+		return nil, err
+	}
+	if existing != nil && existing.CategoryID != categoryID {
+		return nil, fmt.Errorf("category with slug '%s' already exists", slug)
+	}
+
+	if req.ParentID != 0 {
+		if _, err := s.GetCategory(ctx, req.ParentID); err != nil {
+			return nil, fmt.Errorf("parent category %d must exist", req.ParentID)
+		}
+		// A category can't be moved below itself
+		if categoryID != 0 {
+			descendants, err := s.Descendants(ctx, categoryID)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range descendants {
+				if id == req.ParentID {
+					return nil, fmt.Errorf("category must not be moved under itself or its sub-categories")
+				}
+			}
+		}
+	}
+
+	return &Category{
+		CategoryID: categoryID,
+		ParentID:   req.ParentID,
+		Name:       name,
+		Slug:       slug,
+		Position:   req.Position,
+	}, nil
+}
@@ -0,0 +1,133 @@
+// Command paymentsim sends signed webhooks of the fake payment provider to a local server,
+// standing in for the customer's card and the provider so payments can be tested offline.
+//
+// Usage:
+//
+//	paymentsim [flags] <authorized|succeeded|failed|refunded> <provider_intent_id> [amount] [currency]
+//
+// For example, after POST /payments/intents returned the intent pi_fake_1a2b3c:
+//
+//	paymentsim authorized pi_fake_1a2b3c 49.99 USD   # the customer's card was authorized
+//	paymentsim succeeded pi_fake_1a2b3c 49.99 USD    # the payment was captured at the provider
+//	paymentsim -repeat 3 refunded pi_fake_1a2b3c 10.00 USD
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/payment"
+)
+
+// eventTypes maps the command line names to event types
+var eventTypes = map[string]string{
+	"authorized": payment.EventAuthorized,
+	"succeeded":  payment.EventSucceeded,
+	"failed":     payment.EventFailed,
+	"refunded":   payment.EventRefunded,
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/payments/webhook", "webhook endpoint of the server")
+	secret := flag.String("secret", defaultSecret(), "webhook secret, defaults to PAYMENT_WEBHOOK_SECRET or the fake provider's default")
+	eventID := flag.String("event-id", "", "event ID, random by default; reuse one to simulate a redelivery")
+	refundID := flag.String("refund-id", "", "refund ID of refunded events, random by default")
+	reason := flag.String("reason", "Your card was declined.", "failure reason of failed events")
+	repeat := flag.Int("repeat", 1, "number of times to deliver the event, to check that it is applied once")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: paymentsim [flags] <authorized|succeeded|failed|refunded> <provider_intent_id> [amount] [currency]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*url, *secret, *eventID, *refundID, *reason, *repeat, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "paymentsim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(url, secret, eventID, refundID, reason string, repeat int, args []string) error {
+	if len(args) < 2 || len(args) > 4 {
+		flag.Usage()
+		return fmt.Errorf("expected an event and a provider intent ID")
+	}
+	eventType, ok := eventTypes[args[0]]
+	if !ok {
+		return fmt.Errorf("invalid event '%s': must be authorized, succeeded, failed or refunded", args[0])
+	}
+
+	event := payment.Event{ID: eventID, Type: eventType, IntentID: args[1]}
+	if len(args) > 2 {
+		currency := ""
+		if len(args) > 3 {
+			currency = args[3]
+		}
+		amount, err := money.Parse(args[2], currency)
+		if err != nil {
+			return err
+		}
+		event.Amount = amount
+	}
+	if (eventType == payment.EventSucceeded || eventType == payment.EventRefunded) && event.Amount.IsZero() {
+		return fmt.Errorf("an amount is required for %s events", args[0])
+	}
+	switch eventType {
+	case payment.EventFailed:
+		event.FailureReason = reason
+	case payment.EventRefunded:
+		event.RefundID = refundID
+		if event.RefundID == "" {
+			event.RefundID = fmt.Sprintf("re_sim_%d", time.Now().UnixNano())
+		}
+	}
+
+	// The event ID is fixed before the first delivery, so repeated deliveries are the same event
+	payload, _, err := payment.NewFakeWebhook(event, secret, time.Now())
+	if err != nil {
+		return err
+	}
+	for i := 0; i < repeat; i++ {
+		if err := deliver(url, secret, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultSecret returns PAYMENT_WEBHOOK_SECRET, falling back to the fake provider's public default
+// The server never uses that default, so it only helps against a server started with the same secret
+func defaultSecret() string {
+	if secret := os.Getenv("PAYMENT_WEBHOOK_SECRET"); secret != "" {
+		return secret
+	}
+	return payment.DefaultFakeWebhookSecret
+}
+
+// deliver posts a webhook payload, signed at the time of delivery like a provider's retries
+func deliver(url, secret string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(payment.FakeSignatureHeader, payment.Sign(payload, secret, time.Now()))
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	fmt.Printf("%s %s\n", resp.Status, bytes.TrimSpace(body))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook was rejected")
+	}
+	return nil
+}
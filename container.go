@@ -2,14 +2,34 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/blobstore"
+	"github.com/rajindersingh041/go-auth-sessions/cart"
+	"github.com/rajindersingh041/go-auth-sessions/category"
+	"github.com/rajindersingh041/go-auth-sessions/fx"
+	"github.com/rajindersingh041/go-auth-sessions/idempotency"
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+	"github.com/rajindersingh041/go-auth-sessions/notify"
 	"github.com/rajindersingh041/go-auth-sessions/invoice"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/numbering"
 	"github.com/rajindersingh041/go-auth-sessions/order"
 	"github.com/rajindersingh041/go-auth-sessions/orderproduction"
+	"github.com/rajindersingh041/go-auth-sessions/payment"
+	"github.com/rajindersingh041/go-auth-sessions/subscription"
 	"github.com/rajindersingh041/go-auth-sessions/product"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/review"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
 	"github.com/rajindersingh041/go-auth-sessions/user"
+	"github.com/rajindersingh041/go-auth-sessions/wishlist"
 )
 
 // Container holds all services and dependencies
@@ -49,10 +69,25 @@ type Container struct {
 	ProductService product.ProductService
 	InvoiceService invoice.InvoiceService
 	OrderProductionService orderproduction.ProductionService
+	FXService      fx.FXService
+	PromotionService promotion.PromotionService
+	CartService    cart.CartService
+	CategoryService category.CategoryService
+	ReviewService  review.ReviewService
+	WishlistService wishlist.WishlistService
+	PaymentService payment.PaymentService
+	SubscriptionService subscription.SubscriptionService
+
+	// Idempotency store for safely retried requests
+	IdempotencyStore idempotency.Store
+
+	// Blob store for uploaded files such as product images
+	BlobStore blobstore.Store
 
 	// Auth components
 	JWTManager     auth.JWTManager
 	PasswordHasher auth.PasswordHasher
+	Admins         auth.AdminList
 
 	// Database
 	DB *sql.DB
@@ -85,6 +120,25 @@ func NewContainer(db *sql.DB, dbDriver string) *Container {
 	var productRepo product.ProductRepository
 	var invoiceRepo invoice.InvoiceRepository
 	var orderProductionRepo orderproduction.ProductionRepositary
+	var idempotencyStore idempotency.Store
+	var fxRepo fx.FXRepository
+	var promotionRepo promotion.PromotionRepository
+	var cartRepo cart.CartRepository
+	var categoryRepo category.CategoryRepository
+	var reviewRepo review.ReviewRepository
+	var wishlistRepo wishlist.WishlistRepository
+	var paymentRepo payment.PaymentRepository
+	var subscriptionRepo subscription.SubscriptionRepository
+
+	// ID generator shared by repositories of databases without auto-increment (ClickHouse)
+	// Each running instance must use a distinct NODE_ID (0-1023) to keep IDs collision-free
+	var idGenerator idgen.Generator
+	if nodeID, err := strconv.ParseUint(os.Getenv("NODE_ID"), 10, 16); err == nil {
+		idGenerator = idgen.NewSnowflakeGenerator(uint16(nodeID))
+	} else {
+		log.Println("NODE_ID not set, using a random node ID for ID generation")
+		idGenerator = idgen.NewRandomNodeGenerator()
+	}
 
 
 	// Initialize repositories based on dbDriver
@@ -92,9 +146,18 @@ func NewContainer(db *sql.DB, dbDriver string) *Container {
        switch dbDriver {
        case "clickhouse":
 	       userRepo = user.NewClickHouseRepository(db)
-	       orderRepo = order.NewClickHouseRepository(db)
-	       productRepo = product.NewClickHouseRepository(db)
-	       invoiceRepo = invoice.NewClickHouseRepository(db)
+	       orderRepo = order.NewClickHouseRepository(db, idGenerator)
+	       productRepo = product.NewClickHouseRepository(db, idGenerator)
+	       invoiceRepo = invoice.NewClickHouseRepository(db, idGenerator)
+	       idempotencyStore = idempotency.NewClickHouseRepository(db)
+	       fxRepo = fx.NewClickHouseRepository(db)
+	       promotionRepo = promotion.NewClickHouseRepository(db, idGenerator)
+	       cartRepo = cart.NewClickHouseRepository(db)
+	       categoryRepo = category.NewClickHouseRepository(db, idGenerator)
+	       reviewRepo = review.NewClickHouseRepository(db, idGenerator)
+	       wishlistRepo = wishlist.NewClickHouseRepository(db)
+	       paymentRepo = payment.NewClickHouseRepository(db, idGenerator)
+	       subscriptionRepo = subscription.NewClickHouseRepository(db, idGenerator)
 	       // TODO: Add ClickHouse implementation for orderProductionRepo if needed
        case "postgres":
 	       userRepo = user.NewPostgresRepository(db)
@@ -102,21 +165,122 @@ func NewContainer(db *sql.DB, dbDriver string) *Container {
 	       productRepo = product.NewPostgresRepository(db)
 	       invoiceRepo = invoice.NewPostgresRepository(db)
 	       orderProductionRepo = orderproduction.NewPostgresRepository(db)
+	       idempotencyStore = idempotency.NewPostgresRepository(db)
+	       fxRepo = fx.NewPostgresRepository(db)
+	       promotionRepo = promotion.NewPostgresRepository(db)
+	       cartRepo = cart.NewPostgresRepository(db)
+	       categoryRepo = category.NewPostgresRepository(db)
+	       reviewRepo = review.NewPostgresRepository(db)
+	       wishlistRepo = wishlist.NewPostgresRepository(db)
+	       paymentRepo = payment.NewPostgresRepository(db)
+	       subscriptionRepo = subscription.NewPostgresRepository(db)
        default:
 	       log.Fatalf("Unsupported DB_DRIVER: %s", dbDriver)
        }
 
+	// Load tax rules; without TAX_RULES_FILE a flat 10% tax is applied
+	taxConfig, err := tax.LoadConfig(os.Getenv("TAX_RULES_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load tax rules: %v", err)
+	}
+	taxCalculator := tax.NewRuleBasedCalculator(taxConfig)
+
+	// Carts untouched for CART_TTL (e.g. "72h") are removed; defaults to 7 days
+	cartTTL := cart.DefaultTTL
+	if value := os.Getenv("CART_TTL"); value != "" {
+		if ttl, err := time.ParseDuration(value); err == nil && ttl > 0 {
+			cartTTL = ttl
+		} else {
+			log.Printf("Invalid CART_TTL %q, using default of %s", value, cart.DefaultTTL)
+		}
+	}
+
+	// PDF invoices use the seller details and texts of INVOICE_BRANDING_FILE, or a default branding
+	branding, err := invoice.LoadBranding(os.Getenv("INVOICE_BRANDING_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load invoice branding: %v", err)
+	}
+	pdfRenderer, err := invoice.NewPDFRenderer(branding)
+	if err != nil {
+		log.Fatalf("Invalid invoice branding: %v", err)
+	}
+
+	// E-invoices state the seller, tax scheme and party identifiers of EINVOICING_FILE
+	eInvoicing, err := invoice.LoadEInvoicing(os.Getenv("EINVOICING_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load e-invoicing configuration: %v", err)
+	}
+	ublExporter := invoice.NewUBLExporter(eInvoicing)
+
+	// Invoices and credit notes are numbered from INVOICE_NUMBER_FORMAT and CREDIT_NOTE_NUMBER_FORMAT,
+	// e.g. INV-{YYYY}-{000000}; with NUMBERING_TENANT, numbers are prefixed with the tenant by default
+	invoiceNumbers, err := newNumberScheme("invoice", "INVOICE_NUMBER_FORMAT", invoice.DefaultInvoiceNumberFormat)
+	if err != nil {
+		log.Fatalf("Invalid invoice numbering: %v", err)
+	}
+	creditNoteNumbers, err := newNumberScheme("credit_note", "CREDIT_NOTE_NUMBER_FORMAT", invoice.DefaultCreditNoteNumberFormat)
+	if err != nil {
+		log.Fatalf("Invalid credit note numbering: %v", err)
+	}
+
+	// Overdue invoices get reminders on the days after their due date in DUNNING_REMINDER_DAYS (default 1,7,14),
+	// and are charged DUNNING_LATE_FEE_RATE of their balance due with the first one (no late fee by default)
+	dunningPolicy, err := newDunningPolicy()
+	if err != nil {
+		log.Fatalf("Invalid dunning policy: %v", err)
+	}
+
+	// Uploaded files go to the local filesystem, or to S3/MinIO with BLOBSTORE=s3
+	blobStore, err := newBlobStore()
+	if err != nil {
+		log.Fatalf("Failed to create blob store: %v", err)
+	}
+
+	// Notifications such as back-in-stock alerts are logged, or emailed with NOTIFIER=email
+	notifier, err := newNotifier()
+	if err != nil {
+		log.Fatalf("Failed to create notifier: %v", err)
+	}
+
+	// Emails with attachments such as invoices go through the same driver, unless MAILER selects another one
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatalf("Failed to create mailer: %v", err)
+	}
+
+	// Card payments are disabled unless PAYMENT_PROVIDER selects Stripe, or the offline fake provider for development
+	paymentProvider, err := newPaymentProvider()
+	if err != nil {
+		log.Fatalf("Failed to create payment provider: %v", err)
+	}
+
 	// Create services
 	// Services use repositories and other components to perform business logic
 	// userService depends on userRepo and passwordHasher
-	// productService depends on productRepo
+	// categoryService depends on categoryRepo and productRepo, which it updates when categories are renamed
+	// reviewService depends on reviewRepo and orderRepo, which tells whether a customer received a product
+	// stockAlerts depends on wishlistRepo, userService and notifier, and is told by productService when products are back in stock
+	// productService depends on productRepo, categoryService, reviewService for ratings and stockAlerts
 	// orderService depends on orderRepo and productService
-	// invoiceService depends on invoiceRepo, orderService, productService, and userService	
+	// wishlistService depends on wishlistRepo and productService
+	// invoiceService depends on invoiceRepo, orderService, productService, userService, pdfRenderer, ublExporter, the number schemes,
+	// dunningPolicy and notifier for reminders of overdue invoices, and mailer for emailing invoices
+	// paymentService depends on paymentRepo, paymentProvider, invoiceService for the payments ledger and orderService
+	// subscriptionService depends on subscriptionRepo, productService, and orderService and invoiceService to bill each period
 		userService := user.NewUserService(userRepo, passwordHasher)
-		productService := product.NewProductService(productRepo)
-		orderService := order.NewOrderService(orderRepo, productService)
-		invoiceService := invoice.NewInvoiceService(invoiceRepo, orderService, productService, userService)
+		categoryService := category.NewCategoryService(categoryRepo, productRepo)
+		reviewService := review.NewReviewService(reviewRepo, orderRepo)
+		stockAlerts := wishlist.NewBackInStockNotifier(wishlistRepo, userService, notifier)
+		productService := product.NewProductService(productRepo, categoryService, reviewService, stockAlerts, blobStore, getEnv("MEDIA_BASE_URL", "/media"))
+		wishlistService := wishlist.NewWishlistService(wishlistRepo, productService)
+		fxService := fx.NewFXService(fxRepo)
+		promotionService := promotion.NewPromotionService(promotionRepo)
+		orderService := order.NewOrderService(orderRepo, productService, taxCalculator, fxService, promotionService)
+		cartService := cart.NewCartService(cartRepo, productService, orderService, cartTTL)
+		invoiceService := invoice.NewInvoiceService(invoiceRepo, orderService, productService, userService, pdfRenderer, ublExporter, invoiceNumbers, creditNoteNumbers, dunningPolicy, notifier, mailer)
 		orderProductionService := orderproduction.NewProductionService(orderProductionRepo)
+		paymentService := payment.NewPaymentService(paymentRepo, paymentProvider, invoiceService, orderService)
+		subscriptionService := subscription.NewSubscriptionService(subscriptionRepo, productService, orderService, invoiceService, taxCalculator)
 	// what is the purpose of newservice?
 	// NewService functions create and return service instances
 	// They take the required dependencies as parameters
@@ -135,6 +299,131 @@ func NewContainer(db *sql.DB, dbDriver string) *Container {
 		PasswordHasher: passwordHasher,
 		DB:             db,
 		OrderProductionService: orderProductionService,
+		IdempotencyStore: idempotencyStore,
+		BlobStore:      blobStore,
+		FXService:      fxService,
+		PromotionService: promotionService,
+		CartService:    cartService,
+		CategoryService: categoryService,
+		ReviewService:  reviewService,
+		WishlistService: wishlistService,
+		PaymentService: paymentService,
+		SubscriptionService: subscriptionService,
+		Admins:         auth.ParseAdminList(os.Getenv("ADMIN_USERS")),
+	}
+}
+
+// newBlobStore creates the blob store selected by BLOBSTORE ("local" or "s3")
+func newBlobStore() (blobstore.Store, error) {
+	switch driver := getEnv("BLOBSTORE", "local"); driver {
+	case "local":
+		return blobstore.NewLocalStore(getEnv("BLOBSTORE_DIR", "data/media"))
+	case "s3":
+		return blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported BLOBSTORE: %s", driver)
+	}
+}
+
+// newNumberScheme creates the numbering of a kind of document from the template in formatVar
+// Without a template, the default is used, prefixed with "{TENANT}-" when NUMBERING_TENANT is set
+func newNumberScheme(name, formatVar, defaultFormat string) (*numbering.Scheme, error) {
+	tenant := os.Getenv("NUMBERING_TENANT")
+	format := os.Getenv(formatVar)
+	if format == "" {
+		format = defaultFormat
+		if tenant != "" {
+			format = "{TENANT}-" + defaultFormat
+		}
+	}
+	return numbering.NewScheme(name, format, tenant)
+}
+
+// newDunningPolicy creates the reminder schedule and late fee of overdue invoices
+// DUNNING_REMINDER_DAYS is a comma-separated list of days after the due date, or "none" to send no reminders
+func newDunningPolicy() (invoice.DunningPolicy, error) {
+	days := invoice.DefaultReminderDays
+	if value := os.Getenv("DUNNING_REMINDER_DAYS"); value == "none" {
+		days = nil
+	} else if value != "" {
+		days = nil
+		for _, field := range strings.Split(value, ",") {
+			day, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return invoice.DunningPolicy{}, fmt.Errorf("invalid DUNNING_REMINDER_DAYS '%s': %w", value, err)
+			}
+			days = append(days, day)
+		}
+	}
+
+	var lateFeeRate money.Rate
+	if value := os.Getenv("DUNNING_LATE_FEE_RATE"); value != "" {
+		rate, err := money.ParseRate(value)
+		if err != nil {
+			return invoice.DunningPolicy{}, fmt.Errorf("invalid DUNNING_LATE_FEE_RATE '%s': %w", value, err)
+		}
+		lateFeeRate = rate
+	}
+	return invoice.NewDunningPolicy(days, lateFeeRate)
+}
+
+// newPaymentProvider creates the payment provider selected by PAYMENT_PROVIDER ("none", "fake" or "stripe")
+// Both real providers verify webhooks with PAYMENT_WEBHOOK_SECRET, which is required
+func newPaymentProvider() (payment.PaymentProvider, error) {
+	switch provider := getEnv("PAYMENT_PROVIDER", "none"); provider {
+	case "none":
+		return payment.NewDisabledProvider(), nil
+	case "fake":
+		return payment.NewFakeProvider(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+	case "stripe":
+		return payment.NewStripeProvider(payment.StripeConfig{
+			SecretKey:     os.Getenv("STRIPE_SECRET_KEY"),
+			WebhookSecret: os.Getenv("PAYMENT_WEBHOOK_SECRET"),
+			BaseURL:       os.Getenv("STRIPE_BASE_URL"),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported PAYMENT_PROVIDER: %s", provider)
+	}
+}
+
+// newNotifier creates the notifier selected by NOTIFIER ("log" or "email")
+func newNotifier() (notify.Notifier, error) {
+	switch driver := getEnv("NOTIFIER", "log"); driver {
+	case "log":
+		return notify.NewLogNotifier(), nil
+	case "email":
+		return notify.NewEmailNotifier(smtpConfig())
+	default:
+		return nil, fmt.Errorf("unsupported NOTIFIER: %s", driver)
+	}
+}
+
+// newMailer creates the mailer selected by MAILER ("log" or "email"), which defaults to the NOTIFIER driver
+func newMailer() (notify.Mailer, error) {
+	switch driver := getEnv("MAILER", getEnv("NOTIFIER", "log")); driver {
+	case "log":
+		return notify.NewLogNotifier(), nil
+	case "email":
+		return notify.NewEmailNotifier(smtpConfig())
+	default:
+		return nil, fmt.Errorf("unsupported MAILER: %s", driver)
+	}
+}
+
+// smtpConfig reads the SMTP server shared by the email notifier and mailer
+func smtpConfig() notify.SMTPConfig {
+	return notify.SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
 	}
 }
 
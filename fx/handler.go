@@ -0,0 +1,93 @@
+package fx
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+)
+
+// maxImportSize limits the size of uploaded rate files
+const maxImportSize = 10 << 20
+
+// Handler handles HTTP requests for exchange rate operations
+type Handler struct {
+	service FXService
+	admins  auth.AdminList
+}
+
+// NewHandler creates a new exchange rate handler
+func NewHandler(service FXService, admins auth.AdminList) *Handler {
+	return &Handler{
+		service: service,
+		admins:  admins,
+	}
+}
+
+// RegisterRoutes registers all exchange rate routes
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	// Public routes (no authentication required)
+	mux.HandleFunc("GET /fx/rates", h.handleListRates())
+
+	// Admin routes
+	mux.Handle("POST /admin/fx/rates", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleImportRates())))
+}
+
+// handleListRates handles GET /fx/rates?base=EUR&quote=USD
+func (h *Handler) handleListRates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		base := r.URL.Query().Get("base")
+		quote := r.URL.Query().Get("quote")
+
+		rates, err := h.service.ListRates(r.Context(), base, quote)
+		if err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch exchange rates")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"rates": rates,
+			"count": len(rates),
+		})
+	}
+}
+
+// handleImportRates handles POST /admin/fx/rates (admin only)
+// The body is either CSV (Content-Type: text/csv) with the header base,quote,rate,effective_date
+// or JSON: {"rates":[{"base":"EUR","quote":"USD","rate":"1.085","effective_date":"2024-06-01"}]}
+func (h *Handler) handleImportRates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		body := http.MaxBytesReader(w, r.Body, maxImportSize)
+
+		var count int
+		var err error
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			count, err = h.service.ImportCSV(ctx, body)
+		} else {
+			var req struct {
+				Rates []Rate `json:"rates"`
+			}
+			if err := json.NewDecoder(body).Decode(&req); err != nil {
+				helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+				return
+			}
+			count, err = len(req.Rates), h.service.AddRates(ctx, req.Rates)
+		}
+		if err != nil {
+			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "invalid") {
+				helper.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to import exchange rates")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"message": "Exchange rates imported successfully",
+			"count":   count,
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package fx // Package fx manages historical foreign exchange rates and currency conversion.
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DateLayout is the format of effective dates
+const DateLayout = "2006-01-02"
+
+// Rate is an exchange rate effective from a given date
+// Rate is the number of Quote units for one Base unit, kept as an exact decimal string
+type Rate struct {
+	Base          string `json:"base"`
+	Quote         string `json:"quote"`
+	Rate          string `json:"rate"`
+	EffectiveDate string `json:"effective_date"` // YYYY-MM-DD
+}
+
+// Rat returns the rate as an exact rational number
+func (r Rate) Rat() (*big.Rat, error) {
+	rat, ok := new(big.Rat).SetString(r.Rate)
+	if !ok || rat.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid exchange rate %q", r.Rate)
+	}
+	return rat, nil
+}
+
+// Validate checks and normalizes a rate
+func (r *Rate) Validate() error {
+	r.Base = strings.ToUpper(strings.TrimSpace(r.Base))
+	r.Quote = strings.ToUpper(strings.TrimSpace(r.Quote))
+	r.Rate = strings.TrimSpace(r.Rate)
+	r.EffectiveDate = strings.TrimSpace(r.EffectiveDate)
+	if len(r.Base) != 3 || len(r.Quote) != 3 {
+		return fmt.Errorf("base and quote must be 3-letter currency codes")
+	}
+	if r.Base == r.Quote {
+		return fmt.Errorf("base and quote currencies must differ")
+	}
+	if _, err := r.Rat(); err != nil {
+		return err
+	}
+	if _, err := time.Parse(DateLayout, r.EffectiveDate); err != nil {
+		return fmt.Errorf("effective_date must be in YYYY-MM-DD format")
+	}
+	return nil
+}
+
+// Repository defines the interface for exchange rate data operations
+type FXRepository interface {
+	// Upsert stores rates, replacing any rate for the same pair and date
+	Upsert(ctx context.Context, rates []Rate) error
+	// FindEffective returns the latest rate for a pair effective on or before the given date
+	FindEffective(ctx context.Context, base, quote string, date string) (*Rate, error)
+	List(ctx context.Context, base, quote string) ([]Rate, error)
+}
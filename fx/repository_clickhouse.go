@@ -0,0 +1,125 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ClickHouseRepository implements FXRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db *sql.DB
+}
+
+// NewClickHouseRepository creates a new ClickHouse exchange rate repository
+func NewClickHouseRepository(db *sql.DB) FXRepository {
+	return &ClickHouseRepository{db: db}
+}
+
+// ensureRatesTable creates the fx_rates table if it doesn't exist
+// ReplacingMergeTree keeps the most recently loaded rate per pair and date
+func (r *ClickHouseRepository) ensureRatesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS fx_rates (
+			base String,
+			quote String,
+			rate Decimal(20, 10),
+			effective_date Date,
+			created_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(created_at)
+		ORDER BY (base, quote, effective_date)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) Upsert(ctx context.Context, rates []Rate) error {
+	if err := r.ensureRatesTable(ctx); err != nil {
+		return err
+	}
+
+	// Send all rates as a single batch
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO fx_rates (base, quote, rate, effective_date, created_at)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, rate := range rates {
+		effectiveDate, err := time.Parse(DateLayout, rate.EffectiveDate)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, rate.Base, rate.Quote, rate.Rate, effectiveDate, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *ClickHouseRepository) FindEffective(ctx context.Context, base, quote string, date string) (*Rate, error) {
+	if err := r.ensureRatesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT base, quote, toString(rate), effective_date FROM fx_rates FINAL
+		WHERE base = ? AND quote = ? AND effective_date <= toDate(?)
+		ORDER BY effective_date DESC LIMIT 1`
+	var rate Rate
+	var effectiveDate time.Time
+	err := r.db.QueryRowContext(ctx, query, base, quote, date).Scan(&rate.Base, &rate.Quote, &rate.Rate, &effectiveDate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rate.Rate = trimRate(rate.Rate)
+	rate.EffectiveDate = effectiveDate.Format(DateLayout)
+	return &rate, nil
+}
+
+func (r *ClickHouseRepository) List(ctx context.Context, base, quote string) ([]Rate, error) {
+	if err := r.ensureRatesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT base, quote, toString(rate), effective_date FROM fx_rates FINAL
+		WHERE (? = '' OR base = ?) AND (? = '' OR quote = ?)
+		ORDER BY base, quote, effective_date DESC`
+	rows, err := r.db.QueryContext(ctx, query, base, base, quote, quote)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []Rate
+	for rows.Next() {
+		var rate Rate
+		var effectiveDate time.Time
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Rate, &effectiveDate); err != nil {
+			return nil, err
+		}
+		rate.Rate = trimRate(rate.Rate)
+		rate.EffectiveDate = effectiveDate.Format(DateLayout)
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// trimRate removes insignificant trailing zeros from a stored decimal ("1.0850000000" -> "1.085")
+func trimRate(rate string) string {
+	if !strings.Contains(rate, ".") {
+		return rate
+	}
+	return strings.TrimSuffix(strings.TrimRight(rate, "0"), ".")
+}
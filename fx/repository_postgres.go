@@ -0,0 +1,105 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostgresRepository implements FXRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL exchange rate repository
+func NewPostgresRepository(db *sql.DB) FXRepository {
+	return &PostgresRepository{db: db}
+}
+
+// ensureRatesTable creates the fx_rates table if it doesn't exist
+func (r *PostgresRepository) ensureRatesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS fx_rates (
+			base TEXT NOT NULL,
+			quote TEXT NOT NULL,
+			rate NUMERIC(20,10) NOT NULL,
+			effective_date DATE NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (base, quote, effective_date)
+		)`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *PostgresRepository) Upsert(ctx context.Context, rates []Rate) error {
+	if err := r.ensureRatesTable(ctx); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO fx_rates (base, quote, rate, effective_date) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base, quote, effective_date) DO UPDATE SET rate = EXCLUDED.rate, created_at = NOW()`
+	for _, rate := range rates {
+		if _, err := tx.ExecContext(ctx, query, rate.Base, rate.Quote, rate.Rate, rate.EffectiveDate); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) FindEffective(ctx context.Context, base, quote string, date string) (*Rate, error) {
+	if err := r.ensureRatesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT base, quote, rate::text, effective_date FROM fx_rates
+		WHERE base = $1 AND quote = $2 AND effective_date <= $3
+		ORDER BY effective_date DESC LIMIT 1`
+	var rate Rate
+	var effectiveDate time.Time
+	err := r.db.QueryRowContext(ctx, query, base, quote, date).Scan(&rate.Base, &rate.Quote, &rate.Rate, &effectiveDate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rate.Rate = trimRate(rate.Rate)
+	rate.EffectiveDate = effectiveDate.Format(DateLayout)
+	return &rate, nil
+}
+
+func (r *PostgresRepository) List(ctx context.Context, base, quote string) ([]Rate, error) {
+	if err := r.ensureRatesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT base, quote, rate::text, effective_date FROM fx_rates
+		WHERE ($1 = '' OR base = $1) AND ($2 = '' OR quote = $2)
+		ORDER BY base, quote, effective_date DESC`
+	rows, err := r.db.QueryContext(ctx, query, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []Rate
+	for rows.Next() {
+		var rate Rate
+		var effectiveDate time.Time
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Rate, &effectiveDate); err != nil {
+			return nil, err
+		}
+		rate.Rate = trimRate(rate.Rate)
+		rate.EffectiveDate = effectiveDate.Format(DateLayout)
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
@@ -0,0 +1,157 @@
+package fx
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// FXService defines the business logic interface for exchange rates
+type FXService interface {
+	AddRates(ctx context.Context, rates []Rate) error
+	ImportCSV(ctx context.Context, r io.Reader) (int, error)
+	GetRate(ctx context.Context, base, quote string, at time.Time) (*Rate, error)
+	ListRates(ctx context.Context, base, quote string) ([]Rate, error)
+	Convert(ctx context.Context, amount money.Money, to string, at time.Time) (money.Money, *Rate, error)
+}
+
+// fxService implements the FXService interface
+type fxService struct {
+	repo FXRepository
+}
+
+// NewFXService creates a new exchange rate service
+func NewFXService(repo FXRepository) FXService {
+	return &fxService{repo: repo}
+}
+
+// AddRates validates and stores exchange rates
+func (s *fxService) AddRates(ctx context.Context, rates []Rate) error {
+	if len(rates) == 0 {
+		return fmt.Errorf("at least one rate is required")
+	}
+	for i := range rates {
+		if err := rates[i].Validate(); err != nil {
+			return fmt.Errorf("invalid rate %d: %w", i+1, err)
+		}
+	}
+	return s.repo.Upsert(ctx, rates)
+}
+
+// ImportCSV loads rates from CSV with the header: base,quote,rate,effective_date
+// It returns the number of rates imported
+func (s *fxService) ImportCSV(ctx context.Context, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"base", "quote", "rate", "effective_date"} {
+		if _, ok := columns[name]; !ok {
+			return 0, fmt.Errorf("CSV column %q is required", name)
+		}
+	}
+
+	var rates []Rate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("invalid CSV line %d: %w", line, err)
+		}
+		rate := Rate{
+			Base:          record[columns["base"]],
+			Quote:         record[columns["quote"]],
+			Rate:          record[columns["rate"]],
+			EffectiveDate: record[columns["effective_date"]],
+		}
+		if err := rate.Validate(); err != nil {
+			return 0, fmt.Errorf("invalid CSV line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return 0, fmt.Errorf("at least one rate is required")
+	}
+	if err := s.repo.Upsert(ctx, rates); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// GetRate returns the rate for converting base into quote that was effective at the given time
+// If only the opposite pair is known, its inverse is used
+func (s *fxService) GetRate(ctx context.Context, base, quote string, at time.Time) (*Rate, error) {
+	base, quote = strings.ToUpper(base), strings.ToUpper(quote)
+	date := at.UTC().Format(DateLayout)
+	if base == quote {
+		return &Rate{Base: base, Quote: quote, Rate: "1", EffectiveDate: date}, nil
+	}
+
+	rate, err := s.repo.FindEffective(ctx, base, quote, date)
+	if err != nil {
+		return nil, err
+	}
+	if rate != nil {
+		return rate, nil
+	}
+
+	inverse, err := s.repo.FindEffective(ctx, quote, base, date)
+	if err != nil {
+		return nil, err
+	}
+	if inverse == nil {
+		return nil, fmt.Errorf("exchange rate not found for %s/%s on %s", base, quote, date)
+	}
+	rat, err := inverse.Rat()
+	if err != nil {
+		return nil, err
+	}
+	return &Rate{
+		Base:          base,
+		Quote:         quote,
+		Rate:          formatRat(new(big.Rat).Inv(rat)),
+		EffectiveDate: inverse.EffectiveDate,
+	}, nil
+}
+
+// ListRates returns stored rates, optionally filtered by currency pair
+func (s *fxService) ListRates(ctx context.Context, base, quote string) ([]Rate, error) {
+	return s.repo.List(ctx, strings.ToUpper(base), strings.ToUpper(quote))
+}
+
+// Convert converts an amount into another currency at the rate effective at the given time
+// It also returns the rate that was applied, so callers can record it
+func (s *fxService) Convert(ctx context.Context, amount money.Money, to string, at time.Time) (money.Money, *Rate, error) {
+	rate, err := s.GetRate(ctx, amount.Currency, to, at)
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	rat, err := rate.Rat()
+	if err != nil {
+		return money.Money{}, nil, err
+	}
+	return amount.Convert(rate.Quote, rat), rate, nil
+}
+
+// formatRat formats a rational number as a decimal with up to 10 places
+func formatRat(r *big.Rat) string {
+	s := r.FloatString(10)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
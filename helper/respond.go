@@ -2,7 +2,9 @@ package helper
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -19,3 +21,36 @@ func RespondError(w http.ResponseWriter, status int, message string) {
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
+
+// ErrorRule answers service errors whose message contains Fragment with Status
+type ErrorRule struct {
+	Fragment string
+	Status   int
+}
+
+// serviceErrorRules are the rules every service shares, tried after a package's own rules
+var serviceErrorRules = []ErrorRule{
+	{"not found", http.StatusNotFound},
+	{"already", http.StatusConflict},
+	{"required", http.StatusBadRequest},
+	{"invalid", http.StatusBadRequest},
+	{"must", http.StatusBadRequest},
+}
+
+// RespondServiceError answers a service error with the status of the first rule its message matches,
+// trying rules before the shared ones: "not found" is 404, "already" 409, "required", "invalid" and "must" 400.
+// Matched messages are meant for clients and returned as is; other errors are logged and answered with 500
+// and the fallback message, so internal details don't leak.
+func RespondServiceError(w http.ResponseWriter, err error, fallback string, rules ...ErrorRule) {
+	message := err.Error()
+	for _, set := range [][]ErrorRule{rules, serviceErrorRules} {
+		for _, rule := range set {
+			if strings.Contains(message, rule.Fragment) {
+				RespondError(w, rule.Status, message)
+				return
+			}
+		}
+	}
+	log.Printf("%s: %v", fallback, err)
+	RespondError(w, http.StatusInternalServerError, fallback)
+}
@@ -0,0 +1,126 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+)
+
+// maxBodyBytes limits the size of request bodies buffered for hashing; larger ones are refused
+const maxBodyBytes = 1 << 20
+
+// maxKeyLength limits the size of client-supplied keys
+const maxKeyLength = 255
+
+// Middleware makes a handler idempotent for requests carrying an Idempotency-Key header.
+//
+// The first request with a key is executed and its response stored. Repeats with the
+// same key and body replay the stored response instead of running the handler again.
+// Keys are scoped to the authenticated user, so it must run after auth.WithJWTAuth.
+// Requests without the header are passed through unchanged.
+//
+// Usage:
+//
+//	mux.Handle("POST /orders", auth.WithJWTAuth(jwtManager, idempotency.Middleware(store, handler)))
+func Middleware(store Store, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			helper.RespondError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		scope, ok := r.Context().Value(auth.UsernameContextKey).(string)
+		if !ok || scope == "" {
+			helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+			return
+		}
+
+		// Buffer the body so it can be hashed and still be read by the handler
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				helper.RespondError(w, http.StatusRequestEntityTooLarge, "Request body must be at most 1 MiB")
+				return
+			}
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		requestHash := hex.EncodeToString(sum[:])
+
+		ctx := r.Context()
+		existing, err := store.Reserve(ctx, scope, key, requestHash)
+		if err != nil {
+			log.Printf("Idempotency reserve failed: %v", err)
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to process idempotency key")
+			return
+		}
+
+		if existing != nil {
+			if existing.RequestHash != requestHash {
+				helper.RespondError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+				return
+			}
+			if existing.InFlight() {
+				helper.RespondError(w, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+				return
+			}
+			// Replay the stored response
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(existing.StatusCode)
+			w.Write(existing.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// Server errors are not stored so the client can retry with the same key
+		if rec.status == 0 || rec.status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, scope, key); err != nil {
+				log.Printf("Idempotency release failed: %v", err)
+			}
+			return
+		}
+		if err := store.Complete(ctx, scope, key, rec.status, rec.body.Bytes()); err != nil {
+			log.Printf("Idempotency complete failed: %v", err)
+		}
+	})
+}
+
+// responseRecorder passes the response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,38 @@
+package idempotency // Package idempotency lets clients safely retry non-idempotent requests using an Idempotency-Key header.
+
+import (
+	"context"
+	"time"
+)
+
+// HeaderKey is the request header carrying the client-chosen idempotency key
+const HeaderKey = "Idempotency-Key"
+
+// KeyTTL is how long a stored response is replayed for a repeated key
+const KeyTTL = 24 * time.Hour
+
+// Record is the stored outcome of a request made with an idempotency key
+type Record struct {
+	Scope       string // who owns the key, e.g. the authenticated username
+	Key         string
+	RequestHash string // SHA-256 of the request body, to detect key reuse with a different payload
+	StatusCode  int    // 0 while the original request is still being processed
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// InFlight reports whether the original request has not finished yet
+func (r *Record) InFlight() bool {
+	return r.StatusCode == 0
+}
+
+// Store defines the interface for idempotency key persistence
+type Store interface {
+	// Reserve claims the key for a new request.
+	// If the key was already claimed within KeyTTL, the existing record is returned instead.
+	Reserve(ctx context.Context, scope, key, requestHash string) (*Record, error)
+	// Complete stores the response for a reserved key
+	Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error
+	// Release drops a reservation so the request can be retried
+	Release(ctx context.Context, scope, key string) error
+}
@@ -0,0 +1,108 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ClickHouseRepository implements Store for ClickHouse database
+type ClickHouseRepository struct {
+	db *sql.DB
+}
+
+// NewClickHouseRepository creates a new ClickHouse idempotency store
+func NewClickHouseRepository(db *sql.DB) Store {
+	return &ClickHouseRepository{db: db}
+}
+
+// ensureIdempotencyTable creates the idempotency_keys table if it doesn't exist
+// ReplacingMergeTree keeps the row with the highest version per key,
+// so completing a key is done by inserting a newer version of the row
+func (r *ClickHouseRepository) ensureIdempotencyTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			scope String,
+			idem_key String,
+			request_hash String,
+			status_code UInt16,
+			response_body String,
+			created_at DateTime,
+			version UInt64
+		) ENGINE = ReplacingMergeTree(version)
+		ORDER BY (scope, idem_key)
+		TTL created_at + INTERVAL 1 DAY
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) Reserve(ctx context.Context, scope, key, requestHash string) (*Record, error) {
+	if err := r.ensureIdempotencyTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rec, err := r.find(ctx, scope, key)
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		return rec, nil
+	}
+
+	// ClickHouse has no unique constraints, so two requests racing on the very same key
+	// can both get here. The window is small and only affects simultaneous duplicates.
+	query := "INSERT INTO idempotency_keys (scope, idem_key, request_hash, status_code, response_body, created_at, version) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query, scope, key, requestHash, 0, "", time.Now().UTC(), uint64(time.Now().UnixNano()))
+	return nil, err
+}
+
+func (r *ClickHouseRepository) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	if err := r.ensureIdempotencyTable(ctx); err != nil {
+		return err
+	}
+	rec, err := r.find(ctx, scope, key)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return errors.New("idempotency key not found")
+	}
+
+	// Insert a newer version of the row carrying the response
+	query := "INSERT INTO idempotency_keys (scope, idem_key, request_hash, status_code, response_body, created_at, version) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query, scope, key, rec.RequestHash, statusCode, string(body), rec.CreatedAt, uint64(time.Now().UnixNano()))
+	return err
+}
+
+func (r *ClickHouseRepository) Release(ctx context.Context, scope, key string) error {
+	if err := r.ensureIdempotencyTable(ctx); err != nil {
+		return err
+	}
+	query := "ALTER TABLE idempotency_keys DELETE WHERE scope = ? AND idem_key = ? AND status_code = 0"
+	_, err := r.db.ExecContext(ctx, query, scope, key)
+	return err
+}
+
+// find returns the latest unexpired record for a key, or nil if there is none
+func (r *ClickHouseRepository) find(ctx context.Context, scope, key string) (*Record, error) {
+	query := `
+		SELECT scope, idem_key, request_hash, status_code, response_body, created_at
+		FROM idempotency_keys FINAL
+		WHERE scope = ? AND idem_key = ? AND created_at > now() - INTERVAL 1 DAY
+		LIMIT 1`
+	var rec Record
+	var statusCode uint16
+	var body string
+	err := r.db.QueryRowContext(ctx, query, scope, key).Scan(&rec.Scope, &rec.Key, &rec.RequestHash, &statusCode, &body, &rec.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	rec.StatusCode = int(statusCode)
+	rec.Body = []byte(body)
+	return &rec, nil
+}
@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// PostgresRepository implements Store for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL idempotency store
+func NewPostgresRepository(db *sql.DB) Store {
+	return &PostgresRepository{db: db}
+}
+
+// ensureIdempotencyTable creates the idempotency_keys table if it doesn't exist
+func (r *PostgresRepository) ensureIdempotencyTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS idempotency_keys (
+			scope TEXT NOT NULL,
+			idem_key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status_code INT NOT NULL DEFAULT 0,
+			response_body BYTEA,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (scope, idem_key)
+		)`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *PostgresRepository) Reserve(ctx context.Context, scope, key, requestHash string) (*Record, error) {
+	if err := r.ensureIdempotencyTable(ctx); err != nil {
+		return nil, err
+	}
+
+	// The primary key makes the reservation atomic: only one request can claim a key.
+	// Expired keys are taken over by the new request.
+	query := `
+		INSERT INTO idempotency_keys (scope, idem_key, request_hash, status_code, response_body, created_at)
+		VALUES ($1, $2, $3, 0, NULL, NOW())
+		ON CONFLICT (scope, idem_key) DO UPDATE
+			SET request_hash = EXCLUDED.request_hash, status_code = 0, response_body = NULL, created_at = NOW()
+			WHERE idempotency_keys.created_at < NOW() - make_interval(secs => $4)
+		RETURNING idem_key`
+	var reserved string
+	err := r.db.QueryRowContext(ctx, query, scope, key, requestHash, KeyTTL.Seconds()).Scan(&reserved)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Key is already taken; return what is stored for it
+	var rec Record
+	selectQuery := "SELECT scope, idem_key, request_hash, status_code, response_body, created_at FROM idempotency_keys WHERE scope = $1 AND idem_key = $2"
+	err = r.db.QueryRowContext(ctx, selectQuery, scope, key).Scan(&rec.Scope, &rec.Key, &rec.RequestHash, &rec.StatusCode, &rec.Body, &rec.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *PostgresRepository) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	if err := r.ensureIdempotencyTable(ctx); err != nil {
+		return err
+	}
+	query := "UPDATE idempotency_keys SET status_code = $1, response_body = $2 WHERE scope = $3 AND idem_key = $4"
+	_, err := r.db.ExecContext(ctx, query, statusCode, body, scope, key)
+	return err
+}
+
+func (r *PostgresRepository) Release(ctx context.Context, scope, key string) error {
+	if err := r.ensureIdempotencyTable(ctx); err != nil {
+		return err
+	}
+	query := "DELETE FROM idempotency_keys WHERE scope = $1 AND idem_key = $2 AND status_code = 0"
+	_, err := r.db.ExecContext(ctx, query, scope, key)
+	return err
+}
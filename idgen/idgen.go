@@ -0,0 +1,80 @@
+package idgen // Package idgen provides collision-free ID generation for databases without auto-increment.
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// Generator hands out unique 64-bit identifiers
+// Repositories for databases without auto-increment columns (ClickHouse)
+// use a Generator instead of SELECT MAX(id)+1, which races under load
+type Generator interface {
+	NextID() uint64
+}
+
+// Bit layout of a snowflake ID:
+// 41 bits of milliseconds since epoch | 10 bits of node ID | 12 bits of sequence
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	maxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+// epoch is the custom epoch for snowflake IDs (2024-01-01 UTC)
+// 41 bits of milliseconds from here last until the year 2093
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeGenerator implements Generator using the snowflake scheme
+// IDs are unique as long as every running instance uses a distinct node ID,
+// and they are roughly ordered by creation time
+type SnowflakeGenerator struct {
+	mu       sync.Mutex
+	node     uint64
+	lastMs   int64
+	sequence uint64
+}
+
+// NewSnowflakeGenerator creates a generator for the given node ID (0-1023)
+func NewSnowflakeGenerator(node uint16) *SnowflakeGenerator {
+	return &SnowflakeGenerator{node: uint64(node) & maxNode}
+}
+
+// NewRandomNodeGenerator creates a generator with a random node ID
+// Use it only for single-instance deployments; multiple instances should
+// be given distinct node IDs through NewSnowflakeGenerator
+func NewRandomNodeGenerator() *SnowflakeGenerator {
+	var b [2]byte
+	_, _ = rand.Read(b[:])
+	return NewSnowflakeGenerator(binary.BigEndian.Uint16(b[:]))
+}
+
+// NextID returns the next unique ID
+func (g *SnowflakeGenerator) NextID() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Since(epoch).Milliseconds()
+	if now < g.lastMs {
+		// Clock moved backwards; keep issuing IDs from the last known millisecond
+		now = g.lastMs
+	}
+
+	if now == g.lastMs {
+		g.sequence = (g.sequence + 1) & maxSequence
+		if g.sequence == 0 {
+			// Sequence exhausted for this millisecond, wait for the next one
+			for now <= g.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Since(epoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = now
+
+	return uint64(now)<<(nodeBits+sequenceBits) | g.node<<sequenceBits | g.sequence
+}
@@ -0,0 +1,72 @@
+package invoice
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Branding configures the seller details, colors and texts of PDF invoices
+// The seller details are stored with each invoice when it is created, see Invoice.Seller
+// Title, Notes and Footer are Go text/template templates executed with TemplateData,
+// e.g. "Please pay {{.Total}} by {{.DueDate}}"
+type Branding struct {
+	CompanyName string   `json:"company_name"`
+	Address     []string `json:"address"`
+	Email       string   `json:"email"`
+	Phone       string   `json:"phone"`
+	Website     string   `json:"website"`
+	TaxID       string   `json:"tax_id"`       // e.g. the VAT number, printed in the seller block
+	AccentColor string   `json:"accent_color"` // hex color of the header bar, headings and table header, e.g. "#1f4e79"
+	PageSize    string   `json:"page_size"`    // "A4" or "Letter"
+	DateFormat  string   `json:"date_format"`  // Go time layout, e.g. "2 Jan 2006"
+	Title       string   `json:"title"`
+	Notes       string   `json:"notes"`  // printed below the totals, e.g. payment instructions
+	Footer      string   `json:"footer"` // printed at the bottom of every page
+}
+
+// TemplateData is the data available to the Title, Notes and Footer templates of Branding
+type TemplateData struct {
+	CompanyName   string
+	InvoiceNumber string
+	OrderID       uint64
+	Status        string
+	IssueDate     string // formatted with DateFormat
+	DueDate       string
+	Subtotal      string // amounts with their currency, e.g. "2499.99 USD"
+	Tax           string
+	Total         string
+	Currency      string
+	CustomerName  string
+	CustomerEmail string
+}
+
+// DefaultBranding is used when no branding file is configured
+func DefaultBranding() Branding {
+	return Branding{
+		CompanyName: "Go Auth Sessions Store",
+		AccentColor: "#1f4e79",
+		PageSize:    "A4",
+		DateFormat:  "2006-01-02",
+		Title:       "Invoice",
+		Notes:       "Please pay {{.Total}} by {{.DueDate}}, quoting invoice number {{.InvoiceNumber}}.",
+		Footer:      "{{.CompanyName}} - Thank you for your business.",
+	}
+}
+
+// LoadBranding reads invoice branding from a JSON file
+// An empty path returns DefaultBranding; fields missing from the file keep their defaults
+func LoadBranding(path string) (Branding, error) {
+	branding := DefaultBranding()
+	if path == "" {
+		return branding, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Branding{}, fmt.Errorf("failed to read invoice branding: %w", err)
+	}
+	if err := json.Unmarshal(data, &branding); err != nil {
+		return Branding{}, fmt.Errorf("failed to parse invoice branding: %w", err)
+	}
+	return branding, nil
+}
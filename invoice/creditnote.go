@@ -0,0 +1,297 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
+)
+
+// maxCreditNoteReasonLength limits the length of credit note reasons
+const maxCreditNoteReasonLength = 500
+
+// CreateCreditNote credits lines of an invoice and, if requested, links or records the refund that goes with it
+// Invoices are never changed or deleted once issued; a credit note reduces what is owed on them instead
+func (s *invoiceService) CreateCreditNote(ctx context.Context, invoiceID uint64, req CreateCreditNoteRequest) (*CreditNote, *Invoice, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invoice.Status == StatusCancelled {
+		return nil, nil, fmt.Errorf("credit note must not be issued for a cancelled invoice")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if utf8.RuneCountInString(reason) > maxCreditNoteReasonLength {
+		return nil, nil, fmt.Errorf("credit note reason must be at most %d characters", maxCreditNoteReasonLength)
+	}
+
+	previous, err := s.repo.GetCreditNotesByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get credit notes: %w", err)
+	}
+	items, err := creditNoteItems(invoice, previous, req.Lines)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	note := &CreditNote{
+		InvoiceID:     invoice.InvoiceID,
+		InvoiceNumber: invoice.InvoiceNumber,
+		OrderID:       invoice.OrderID,
+		UserID:        invoice.UserID,
+		Items:         items,
+		Currency:      invoice.Currency,
+		Subtotal:      money.Zero(invoice.Currency),
+		Tax:           money.Zero(invoice.Currency),
+		Total:         money.Zero(invoice.Currency),
+		Reason:        reason,
+		CreatedAt:     time.Now().UTC(),
+	}
+	for _, item := range items {
+		note.Subtotal = note.Subtotal.Add(item.Tax.Net)
+		note.Tax = note.Tax.Add(item.Tax.Tax)
+		note.Total = note.Total.Add(item.Tax.Gross)
+	}
+
+	// The refund is settled before the credit note is stored, so a rejected refund leaves no credit note behind
+	if req.Refund != nil {
+		paymentID, err := s.creditNoteRefund(ctx, invoice, note, *req.Refund)
+		if err != nil {
+			return nil, nil, err
+		}
+		note.RefundPaymentID = paymentID
+	}
+
+	if err := s.repo.CreateCreditNote(ctx, note, s.creditNoteNumbers.Series(note.CreatedAt)); err != nil {
+		return nil, nil, fmt.Errorf("failed to create credit note: %w", err)
+	}
+
+	// Load the invoice again, as both the credit note and a refund change its balance
+	invoice, err = s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status := paymentStatus(invoice); status != invoice.Status {
+		if err := s.repo.UpdateStatus(ctx, invoice.InvoiceID, status); err != nil {
+			return nil, nil, fmt.Errorf("failed to update invoice status: %w", err)
+		}
+		invoice.Status = status
+	}
+	return note, invoice, nil
+}
+
+// creditNoteRefund links an existing refund to a credit note, or records one of what the customer
+// has overpaid once the credit note applies, and returns the refund's payment ID
+func (s *invoiceService) creditNoteRefund(ctx context.Context, invoice *Invoice, note *CreditNote, refund CreditNoteRefund) (uint64, error) {
+	if refund.PaymentID != 0 {
+		payments, err := s.repo.GetPayments(ctx, invoice.InvoiceID)
+		if err != nil {
+			return 0, err
+		}
+		for _, payment := range payments {
+			if payment.PaymentID == refund.PaymentID && payment.Amount.IsNegative() {
+				return payment.PaymentID, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid refund: payment %d is not a refund of invoice %d", refund.PaymentID, invoice.InvoiceID)
+	}
+
+	// Only money the customer paid beyond the credited balance can go back, and at most the credit note's total
+	due := invoice.Total.Sub(invoice.Credited).Add(invoice.LateFees).Sub(note.Total)
+	amount := money.Min(note.Total, invoice.AmountPaid.Sub(due))
+	if amount.IsNegative() || amount.IsZero() {
+		return 0, fmt.Errorf("refund must not be recorded: nothing paid for the invoice is left to refund after the credit note")
+	}
+	payment, _, err := s.RecordRefund(ctx, invoice.InvoiceID, RecordPaymentRequest{
+		Amount:    amount,
+		Method:    refund.Method,
+		Reference: refund.Reference,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return payment.PaymentID, nil
+}
+
+// creditNoteItems builds the items of a credit note from the requested lines
+// Without lines, every line is credited with the quantity not yet credited
+func creditNoteItems(invoice *Invoice, previous []CreditNote, lines []CreditNoteLineRequest) ([]CreditNoteItem, error) {
+	credited := make(map[int][]CreditNoteItem)
+	for _, note := range previous {
+		for _, item := range note.Items {
+			credited[item.Line] = append(credited[item.Line], item)
+		}
+	}
+	remaining := func(line int) int {
+		quantity := invoice.Items[line-1].Quantity
+		for _, item := range credited[line] {
+			quantity -= item.Quantity
+		}
+		return quantity
+	}
+
+	if len(lines) == 0 {
+		for line := 1; line <= len(invoice.Items); line++ {
+			if remaining(line) > 0 {
+				lines = append(lines, CreditNoteLineRequest{Line: line})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, fmt.Errorf("invoice is already fully credited")
+		}
+	}
+
+	listed := make(map[int]bool)
+	items := make([]CreditNoteItem, 0, len(lines))
+	for _, req := range lines {
+		if req.Line < 1 || req.Line > len(invoice.Items) {
+			return nil, fmt.Errorf("invalid line %d: the invoice has %d lines", req.Line, len(invoice.Items))
+		}
+		if listed[req.Line] {
+			return nil, fmt.Errorf("line %d must only be listed once", req.Line)
+		}
+		listed[req.Line] = true
+
+		left := remaining(req.Line)
+		quantity := req.Quantity
+		if quantity < 0 {
+			return nil, fmt.Errorf("quantity must be positive")
+		}
+		if quantity == 0 {
+			quantity = left
+		}
+		if quantity == 0 {
+			return nil, fmt.Errorf("line %d must not be credited again: it is already fully credited", req.Line)
+		}
+		if quantity > left {
+			return nil, fmt.Errorf("quantity of line %d must not exceed the %d not yet credited", req.Line, left)
+		}
+
+		item := invoice.Items[req.Line-1]
+		breakdown, err := creditedBreakdown(invoice, item, credited[req.Line], quantity, quantity == left)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, CreditNoteItem{
+			Line:        req.Line,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			SKU:         item.SKU,
+			ProductName: item.ProductName,
+			Quantity:    quantity,
+			UnitPrice:   item.UnitPrice,
+			Tax:         breakdown,
+		})
+	}
+	return items, nil
+}
+
+// creditedBreakdown reverses the tax of a quantity of an invoice line at the rate it was charged
+// The last credit of a line takes whatever was not credited before, so rounding never leaves a cent behind
+func creditedBreakdown(invoice *Invoice, item InvoiceItem, previous []CreditNoteItem, quantity int, last bool) (tax.Breakdown, error) {
+	line := lineBreakdown(invoice, item)
+	credited := tax.Breakdown{
+		Rule:      line.Rule,
+		Rate:      line.Rate,
+		Inclusive: line.Inclusive,
+		Exempt:    line.Exempt,
+	}
+	if last {
+		credited.Net, credited.Tax, credited.Gross = line.Net, line.Tax, line.Gross
+		// Earlier credits are stored, so a currency they don't share with the line is an error rather than a panic
+		var err error
+		for _, prev := range previous {
+			if credited.Net, err = credited.Net.CheckedSub(prev.Tax.Net); err != nil {
+				break
+			}
+			if credited.Tax, err = credited.Tax.CheckedSub(prev.Tax.Tax); err != nil {
+				break
+			}
+			if credited.Gross, err = credited.Gross.CheckedSub(prev.Tax.Gross); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			return tax.Breakdown{}, fmt.Errorf("earlier credits of invoice %s don't match its currency: %w", invoice.InvoiceNumber, err)
+		}
+		return credited, nil
+	}
+	credited.Tax = prorate(line.Tax, int64(quantity), int64(item.Quantity))
+	credited.Gross = prorate(line.Gross, int64(quantity), int64(item.Quantity))
+	credited.Net = credited.Gross.Sub(credited.Tax)
+	return credited, nil
+}
+
+// lineBreakdown returns the tax breakdown of an invoice line
+// Lines invoiced before tax was kept per line get a share of the invoice's tax in proportion to their net amount
+func lineBreakdown(invoice *Invoice, item InvoiceItem) tax.Breakdown {
+	if item.Tax != nil {
+		line := *item.Tax
+		money.WithCurrency(invoice.Currency, &line.Net, &line.Tax, &line.Gross)
+		return line
+	}
+	net := item.TotalPrice.Sub(item.Discount)
+	lineTax := money.Zero(invoice.Currency)
+	if !invoice.Subtotal.IsZero() {
+		lineTax = prorate(invoice.Tax, net.Amount, invoice.Subtotal.Amount)
+	}
+	return tax.Breakdown{Net: net, Tax: lineTax, Gross: net.Add(lineTax)}
+}
+
+// prorate returns the share num/den of an amount, rounded half away from zero; den must be positive
+func prorate(m money.Money, num, den int64) money.Money {
+	if den <= 0 {
+		return money.Zero(m.Currency)
+	}
+	product := m.Amount * num
+	q, r := product/den, product%den
+	if r*2 >= den {
+		q++
+	} else if r*2 <= -den {
+		q--
+	}
+	return money.New(q, m.Currency)
+}
+
+// GetCreditNote retrieves a credit note by its ID
+func (s *invoiceService) GetCreditNote(ctx context.Context, creditNoteID uint64) (*CreditNote, error) {
+	if creditNoteID == 0 {
+		return nil, fmt.Errorf("valid credit note ID is required")
+	}
+	return s.repo.GetCreditNote(ctx, creditNoteID)
+}
+
+// GetCreditNotes returns an invoice with its balance and its credit notes, oldest first
+func (s *invoiceService) GetCreditNotes(ctx context.Context, invoiceID uint64) (*Invoice, []CreditNote, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	notes, err := s.repo.GetCreditNotesByInvoiceID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if notes == nil {
+		notes = []CreditNote{}
+	}
+	return invoice, notes, nil
+}
+
+// GetCreditNotesByUserID retrieves all credit notes for a user, newest first
+func (s *invoiceService) GetCreditNotesByUserID(ctx context.Context, userID uint64) ([]CreditNote, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	notes, err := s.repo.GetCreditNotesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if notes == nil {
+		notes = []CreditNote{}
+	}
+	return notes, nil
+}
@@ -0,0 +1,118 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/notify"
+)
+
+// SendInvoice emails an invoice to its customer with the invoice PDF attached, and records the attempt
+// An invoice whose PDF can't be rendered is sent without it. Drafts become sent once the email is
+// sent; a failed attempt is returned as a failed delivery, leaving the invoice unchanged.
+func (s *invoiceService) SendInvoice(ctx context.Context, invoiceID uint64) (*Delivery, *Invoice, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invoice.Status == StatusCancelled {
+		return nil, nil, fmt.Errorf("cancelled invoice must not be sent")
+	}
+	u, err := s.userService.GetUserByID(ctx, invoice.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user details: %w", err)
+	}
+	if u == nil {
+		return nil, nil, fmt.Errorf("user not found")
+	}
+
+	delivery := &Delivery{
+		InvoiceID:   invoice.InvoiceID,
+		Status:      DeliverySent,
+		AttemptedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	if u.EmailID == "" {
+		delivery.Status = DeliveryFailed
+		delivery.Error = fmt.Sprintf("user %s has no email address", u.Username)
+	} else if err := s.mailInvoice(ctx, invoice, u.Username, u.EmailID, delivery); err != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+	}
+	if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, nil, fmt.Errorf("failed to record delivery: %w", err)
+	}
+
+	if delivery.Status == DeliverySent && invoice.Status == StatusDraft {
+		if err := s.repo.UpdateStatus(ctx, invoice.InvoiceID, StatusSent); err != nil {
+			return nil, nil, fmt.Errorf("failed to mark invoice sent: %w", err)
+		}
+		invoice.Status = StatusSent
+	}
+	return delivery, invoice, nil
+}
+
+// mailInvoice emails an invoice to an address, setting the recipient and attachment of its delivery
+func (s *invoiceService) mailInvoice(ctx context.Context, invoice *Invoice, username, address string, delivery *Delivery) error {
+	delivery.Recipient = (&mail.Address{Name: username, Address: address}).String()
+	email := notify.Email{
+		To:      delivery.Recipient,
+		Subject: fmt.Sprintf("Invoice %s", invoice.InvoiceNumber),
+	}
+
+	// The PDF shows the status the invoice has once it is sent
+	sent := *invoice
+	if sent.Status == StatusDraft {
+		sent.Status = StatusSent
+	}
+	data, err := s.pdfRenderer.Render(&sent)
+	if err != nil {
+		log.Printf("Invoice %s is sent without PDF, as rendering it failed: %v", invoice.InvoiceNumber, err)
+	} else {
+		delivery.Attachment = invoiceFilename(invoice, ".pdf")
+		email.Attachments = []notify.Attachment{{Filename: delivery.Attachment, ContentType: "application/pdf", Data: data}}
+	}
+	email.Body = s.invoiceEmailBody(invoice, username, delivery.Attachment != "")
+	return s.mailer.Send(ctx, email)
+}
+
+// invoiceEmailBody writes the text of an invoice email, with the amounts in it when no PDF is attached
+func (s *invoiceService) invoiceEmailBody(invoice *Invoice, username string, attached bool) string {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hi %s,\n\n", username)
+	if attached {
+		fmt.Fprintf(&body, "Please find attached invoice %s for order %d.\n\n", invoice.InvoiceNumber, invoice.OrderID)
+	} else {
+		fmt.Fprintf(&body, "Here is invoice %s for order %d.\n\n", invoice.InvoiceNumber, invoice.OrderID)
+		for _, item := range invoice.Items {
+			fmt.Fprintf(&body, "%d x %s: %s\n", item.Quantity, item.ProductName, item.TotalPrice.Display())
+		}
+		fmt.Fprintf(&body, "Subtotal: %s\nTax: %s\n", invoice.Subtotal.Display(), invoice.Tax.Display())
+	}
+	fmt.Fprintf(&body, "Total: %s\nBalance due: %s\nDue date: %s\n", invoice.Total.Display(), invoice.BalanceDue.Display(), s.pdfRenderer.formatDate(invoice.DueDate))
+	if name := invoice.Seller.Name; name != "" {
+		fmt.Fprintf(&body, "\nThank you for your business,\n%s", name)
+	} else {
+		body.WriteString("\nThank you for your business.")
+	}
+	return body.String()
+}
+
+// GetDeliveries returns an invoice with the attempts to email it
+func (s *invoiceService) GetDeliveries(ctx context.Context, invoiceID uint64) (*Invoice, []Delivery, error) {
+	invoice, err := s.getInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	deliveries, err := s.repo.GetDeliveries(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deliveries == nil {
+		deliveries = []Delivery{}
+	}
+	return invoice, deliveries, nil
+}
@@ -0,0 +1,280 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/notify"
+)
+
+// DefaultReminderDays are the days after the due date on which reminders are sent by default
+var DefaultReminderDays = []int{1, 7, 14}
+
+// maxReminderDay limits how long after the due date reminders can be scheduled
+const maxReminderDay = 365
+
+// DunningPolicy configures how overdue invoices are chased
+type DunningPolicy struct {
+	// ReminderDays are the days after the due date on which a reminder is sent, in ascending order
+	ReminderDays []int
+	// LateFeeRate is the share of the balance due charged as a late fee with the first reminder; zero charges none
+	LateFeeRate money.Rate
+}
+
+// NewDunningPolicy validates the days of a reminder schedule and a late fee rate
+func NewDunningPolicy(reminderDays []int, lateFeeRate money.Rate) (DunningPolicy, error) {
+	days := append([]int(nil), reminderDays...)
+	sort.Ints(days)
+	for i, day := range days {
+		if day < 1 || day > maxReminderDay {
+			return DunningPolicy{}, fmt.Errorf("invalid reminder day %d: must be between 1 and %d", day, maxReminderDay)
+		}
+		if i > 0 && days[i-1] == day {
+			return DunningPolicy{}, fmt.Errorf("invalid reminder days: day %d is listed twice", day)
+		}
+	}
+	if lateFeeRate < 0 || lateFeeRate > money.MustParseRate("1") {
+		return DunningPolicy{}, fmt.Errorf("invalid late fee rate %s: must be between 0 and 1", lateFeeRate)
+	}
+	return DunningPolicy{ReminderDays: days, LateFeeRate: lateFeeRate}, nil
+}
+
+// reminderDay returns the latest day of the schedule reached after overdue, or 0 if none was reached yet
+func (p DunningPolicy) reminderDay(overdue time.Duration) int {
+	reached := 0
+	for _, day := range p.ReminderDays {
+		if overdue >= time.Duration(day)*24*time.Hour {
+			reached = day
+		}
+	}
+	return reached
+}
+
+// RunDunning chases the sent and partially paid invoices with a balance due past their due date
+// Each is marked overdue, and gets the reminder of the latest day of the schedule it reached, unless it
+// got that or a later one already; after downtime, reminders of days that were missed are not sent.
+// Failures of one invoice don't stop the others.
+func (s *invoiceService) RunDunning(ctx context.Context, now time.Time) (int, int, error) {
+	invoices, err := s.repo.GetPastDue(ctx, now)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get past due invoices: %w", err)
+	}
+
+	overdue, reminded := 0, 0
+	for i := range invoices {
+		marked, sent, err := s.dun(ctx, &invoices[i], now)
+		if marked {
+			overdue++
+		}
+		if sent {
+			reminded++
+		}
+		if err != nil {
+			log.Printf("Dunning: failed to chase invoice %s: %v", invoices[i].InvoiceNumber, err)
+		}
+	}
+	return overdue, reminded, nil
+}
+
+// dun marks an invoice overdue and sends its next reminder, reporting whether it did either
+func (s *invoiceService) dun(ctx context.Context, invoice *Invoice, now time.Time) (marked, sent bool, err error) {
+	due, err := parseDueDate(invoice.DueDate)
+	if err != nil {
+		return false, false, err
+	}
+	if err := s.loadPayments(ctx, invoice); err != nil {
+		return false, false, err
+	}
+	if invoice.BalanceDue.IsZero() {
+		return false, false, nil
+	}
+
+	if invoice.Status != StatusOverdue {
+		if err := s.repo.UpdateStatus(ctx, invoice.InvoiceID, StatusOverdue); err != nil {
+			return false, false, fmt.Errorf("failed to mark invoice overdue: %w", err)
+		}
+		invoice.Status = StatusOverdue
+		marked = true
+	}
+
+	day := s.dunning.reminderDay(now.Sub(due))
+	if day == 0 {
+		return marked, false, nil
+	}
+	reminders, err := s.repo.GetReminders(ctx, invoice.InvoiceID)
+	if err != nil {
+		return marked, false, err
+	}
+	for _, existing := range reminders {
+		if existing.Day >= day {
+			return marked, false, nil
+		}
+	}
+
+	// The reminder is claimed before it is sent, so it is never sent twice
+	reminder := &Reminder{
+		InvoiceID: invoice.InvoiceID,
+		Day:       day,
+		LateFee:   money.Zero(invoice.Currency),
+		SentAt:    now.UTC(),
+	}
+	if len(reminders) == 0 && s.dunning.LateFeeRate > 0 {
+		reminder.LateFee = invoice.BalanceDue.MulRate(s.dunning.LateFeeRate)
+	}
+	if err := s.repo.CreateReminder(ctx, reminder); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return marked, false, nil
+		}
+		return marked, false, fmt.Errorf("failed to record reminder: %w", err)
+	}
+	invoice.LateFees = invoice.LateFees.Add(reminder.LateFee)
+	invoice.BalanceDue = invoice.BalanceDue.Add(reminder.LateFee)
+
+	// Undeliverable reminders, e.g. to users without an email address, are recorded with the reason and not retried
+	if err := s.sendReminder(ctx, invoice, reminder, due, now); err != nil {
+		reminder.Error = err.Error()
+		if err := s.repo.UpdateReminder(ctx, reminder); err != nil {
+			return marked, false, fmt.Errorf("failed to record reminder error: %w", err)
+		}
+		return marked, false, fmt.Errorf("failed to send reminder of day %d: %w", day, err)
+	}
+	return marked, true, nil
+}
+
+// sendReminder notifies the customer of an invoice that it is overdue
+func (s *invoiceService) sendReminder(ctx context.Context, invoice *Invoice, reminder *Reminder, due, now time.Time) error {
+	u, err := s.userService.GetUserByID(ctx, invoice.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return fmt.Errorf("user not found")
+	}
+
+	lateFee := ""
+	if !reminder.LateFee.IsZero() {
+		lateFee = fmt.Sprintf(" This includes a late fee of %s.", reminder.LateFee.Display())
+	}
+	return s.notifier.Notify(ctx, notify.Notification{
+		Username: u.Username,
+		Email:    u.EmailID,
+		Subject:  fmt.Sprintf("Payment reminder: invoice %s is overdue", invoice.InvoiceNumber),
+		Body: fmt.Sprintf("Hi %s,\n\nInvoice %s was due on %s and is %d days overdue. The balance due is %s.%s\n\nPlease pay it at your earliest convenience. If you have already paid, please ignore this reminder.",
+			u.Username, invoice.InvoiceNumber, due.Format("January 2, 2006"), daysOverdue(due, now), invoice.BalanceDue.Display(), lateFee),
+	})
+}
+
+// agingBuckets are the buckets of the aging report, by days overdue
+var agingBuckets = []struct {
+	name     string
+	min, max int
+}{
+	{"1-30", 1, 30},
+	{"31-60", 31, 60},
+	{"61-90", 61, 90},
+	{"over_90", 91, 0},
+}
+
+// GetAgingReport buckets the invoices with a balance due past their due date by days overdue
+// Amounts are totalled per currency, as invoices of different currencies can't be added up
+func (s *invoiceService) GetAgingReport(ctx context.Context, now time.Time) (*AgingReport, error) {
+	invoices, err := s.repo.GetPastDue(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get past due invoices: %w", err)
+	}
+
+	report := &AgingReport{AsOf: now.UTC(), Totals: []money.Money{}}
+	for _, bucket := range agingBuckets {
+		report.Buckets = append(report.Buckets, AgingBucket{
+			Name:     bucket.name,
+			MinDays:  bucket.min,
+			MaxDays:  bucket.max,
+			Invoices: []OverdueInvoice{},
+			Totals:   []money.Money{},
+		})
+	}
+
+	for i := range invoices {
+		invoice := &invoices[i]
+		due, err := parseDueDate(invoice.DueDate)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.loadPayments(ctx, invoice); err != nil {
+			return nil, err
+		}
+		if invoice.BalanceDue.IsZero() {
+			continue
+		}
+		reminders, err := s.repo.GetReminders(ctx, invoice.InvoiceID)
+		if err != nil {
+			return nil, err
+		}
+		sent := 0
+		for _, reminder := range reminders {
+			if reminder.Error == "" {
+				sent++
+			}
+		}
+
+		days := daysOverdue(due, now)
+		bucket := &report.Buckets[len(report.Buckets)-1]
+		for b := range agingBuckets {
+			if days <= agingBuckets[b].max {
+				bucket = &report.Buckets[b]
+				break
+			}
+		}
+		bucket.Invoices = append(bucket.Invoices, OverdueInvoice{
+			InvoiceID:     invoice.InvoiceID,
+			InvoiceNumber: invoice.InvoiceNumber,
+			UserID:        invoice.UserID,
+			Username:      invoice.Username,
+			DueDate:       invoice.DueDate,
+			DaysOverdue:   days,
+			Total:         invoice.Total,
+			BalanceDue:    invoice.BalanceDue,
+			LateFees:      invoice.LateFees,
+			Reminders:     sent,
+		})
+		bucket.Totals = addTotal(bucket.Totals, invoice.BalanceDue)
+		report.Totals = addTotal(report.Totals, invoice.BalanceDue)
+		report.Count++
+	}
+	return report, nil
+}
+
+// addTotal adds an amount to the total of its currency
+func addTotal(totals []money.Money, amount money.Money) []money.Money {
+	for i := range totals {
+		if totals[i].SameCurrency(amount) {
+			totals[i] = totals[i].Add(amount)
+			return totals
+		}
+	}
+	return append(totals, amount)
+}
+
+// parseDueDate parses the due date of an invoice as stored by the repositories
+func parseDueDate(dueDate string) (time.Time, error) {
+	due, err := time.Parse(time.RFC3339, dueDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid due date '%s': %w", dueDate, err)
+	}
+	return due, nil
+}
+
+// daysOverdue counts the started days since the due date, so an invoice is 1 day overdue right after it
+func daysOverdue(due, now time.Time) int {
+	overdue := now.Sub(due)
+	days := int(overdue / (24 * time.Hour))
+	if overdue%(24*time.Hour) > 0 {
+		days++
+	}
+	return days
+}
@@ -2,27 +2,36 @@ package invoice
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rajindersingh041/go-auth-sessions/auth"
 	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/user"
 )
 
 // Handler handles HTTP requests for invoice operations
 type Handler struct {
-	service    InvoiceService
-	jwtManager auth.JWTManager
+	service     InvoiceService
+	userService user.UserService
+	jwtManager  auth.JWTManager
+	admins      auth.AdminList
 }
 
 // NewHandler creates a new invoice handler
-func NewHandler(service InvoiceService, jwtManager auth.JWTManager) *Handler {
+// Customers only see and change their own invoices; admins see all of them, and are the users allowed to
+// record payments and issue credit notes
+func NewHandler(service InvoiceService, userService user.UserService, jwtManager auth.JWTManager, admins auth.AdminList) *Handler {
 	return &Handler{
-		service:    service,
-		jwtManager: jwtManager,
+		service:     service,
+		userService: userService,
+		jwtManager:  jwtManager,
+		admins:      admins,
 	}
 }
 
@@ -33,6 +42,20 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager)
 	mux.Handle("GET /invoices/", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetInvoice())))
 	mux.Handle("GET /invoices/user/", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetUserInvoices())))
 	mux.Handle("PUT /invoices/", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleUpdateInvoiceStatus())))
+	mux.Handle("PUT /invoices/{id}/billing-address", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleUpdateBillingAddress())))
+
+	mux.Handle("GET /credit-notes/{id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetCreditNote())))
+
+	// Recording payments is limited to admins; payments are listed via GET /invoices/{id}/payments
+	mux.Handle("POST /invoices/{id}/payments", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleRecordPayment())))
+	// Issuing credit notes is limited to admins; they are listed via GET /invoices/{id}/credit-notes
+	mux.Handle("POST /invoices/{id}/credit-notes", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleCreateCreditNote())))
+	// Sending invoices is limited to admins; the attempts are listed via GET /invoices/{id}/deliveries
+	mux.Handle("POST /invoices/{id}/send", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleSendInvoice())))
+	// The aging report of overdue invoices is for finance only
+	mux.Handle("GET /invoices/overdue", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleAgingReport())))
+	// Invoices from before snapshots are frozen by an admin, as reading them never writes
+	mux.Handle("POST /invoices/freeze", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleFreezeInvoices())))
 }
 
 
@@ -50,10 +73,10 @@ func (h *Handler) handleCreateInvoice() http.HandlerFunc {
 		}
 
 		// Create the invoice
-		invoice, err := h.service.CreateInvoiceFromOrder(ctx, req.OrderID)
+		invoice, err := h.service.CreateInvoiceFromOrder(ctx, req.OrderID, req.BillingAddress)
 		if err != nil {
 			log.Printf("Invoice creation failed: %v", err)
-			if strings.Contains(err.Error(), "valid order ID is required") {
+			if strings.Contains(err.Error(), "valid order ID is required") || strings.Contains(err.Error(), "billing address") {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -68,6 +91,12 @@ func (h *Handler) handleCreateInvoice() http.HandlerFunc {
 // handleGetInvoice handles requests to get an invoice by ID or order ID
 // URL patterns: 
 // - GET /invoices/{id} - Get by invoice ID
+// - GET /invoices/{id}/pdf - Download the invoice as PDF
+// - GET /invoices/{id}/ubl - Download the invoice as UBL e-invoice
+// - GET /invoices/{id}/payments - List the invoice's payments
+// - GET /invoices/{id}/credit-notes - List the invoice's credit notes
+// - GET /invoices/{id}/deliveries - List the attempts to email the invoice
+// - GET /invoices/{id}/versions - List the versions of the invoice's content
 // - GET /invoices/order/{order_id} - Get by order ID
 func (h *Handler) handleGetInvoice() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -80,6 +109,32 @@ func (h *Handler) handleGetInvoice() http.HandlerFunc {
 			return
 		}
 
+		// PDF downloads share the prefix, as /invoices/{id}/pdf would conflict with /invoices/user/
+		if invoiceIDStr, ok := strings.CutSuffix(path, "/pdf"); ok {
+			h.serveInvoicePDF(w, r, invoiceIDStr)
+			return
+		}
+		if invoiceIDStr, ok := strings.CutSuffix(path, "/ubl"); ok {
+			h.serveInvoiceUBL(w, r, invoiceIDStr)
+			return
+		}
+		if invoiceIDStr, ok := strings.CutSuffix(path, "/payments"); ok {
+			h.servePayments(w, r, invoiceIDStr)
+			return
+		}
+		if invoiceIDStr, ok := strings.CutSuffix(path, "/credit-notes"); ok {
+			h.serveCreditNotes(w, r, invoiceIDStr)
+			return
+		}
+		if invoiceIDStr, ok := strings.CutSuffix(path, "/deliveries"); ok {
+			h.serveDeliveries(w, r, invoiceIDStr)
+			return
+		}
+		if invoiceIDStr, ok := strings.CutSuffix(path, "/versions"); ok {
+			h.serveVersions(w, r, invoiceIDStr)
+			return
+		}
+
 		var invoice *Invoice
 		var err error
 
@@ -92,6 +147,9 @@ func (h *Handler) handleGetInvoice() http.HandlerFunc {
 				return
 			}
 			invoice, err = h.service.GetInvoiceByOrderID(ctx, orderID)
+			if err == nil && !h.authorizeInvoice(w, r, invoice.InvoiceID) {
+				return
+			}
 		} else {
 			// Assume it's an invoice ID
 			invoiceID, parseErr := strconv.ParseUint(path, 10, 64)
@@ -99,14 +157,23 @@ func (h *Handler) handleGetInvoice() http.HandlerFunc {
 				helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
 				return
 			}
+			if !h.authorizeInvoice(w, r, invoiceID) {
+				return
+			}
 			invoice, err = h.service.GetInvoiceByID(ctx, invoiceID)
 		}
 
 		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
+			// Other lookups fail invoices from before snapshots, e.g. a missing product, which isn't a missing invoice
+			if err.Error() == "invoice not found" {
 				helper.RespondError(w, http.StatusNotFound, "Invoice not found")
 				return
 			}
+			if strings.Contains(err.Error(), "must be frozen") {
+				helper.RespondError(w, http.StatusConflict, err.Error())
+				return
+			}
+			log.Printf("Invoice retrieval failed: %v", err)
 			helper.RespondError(w, http.StatusInternalServerError, "Failed to retrieve invoice")
 			return
 		}
@@ -115,8 +182,420 @@ func (h *Handler) handleGetInvoice() http.HandlerFunc {
 	}
 }
 
+// serveInvoicePDF sends an invoice as a PDF download named after its invoice number
+func (h *Handler) serveInvoicePDF(w http.ResponseWriter, r *http.Request, invoiceIDStr string) {
+	invoiceID, err := strconv.ParseUint(invoiceIDStr, 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	if !h.authorizeInvoice(w, r, invoiceID) {
+		return
+	}
+
+	invoice, data, err := h.service.RenderInvoicePDF(r.Context(), invoiceID)
+	if err != nil {
+		if err.Error() == "invoice not found" {
+			helper.RespondError(w, http.StatusNotFound, "Invoice not found")
+			return
+		}
+		log.Printf("Invoice PDF rendering failed: %v", err)
+		helper.RespondError(w, http.StatusInternalServerError, "Failed to render invoice")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoiceFilename(invoice, ".pdf")))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// serveInvoiceUBL sends an invoice as a UBL 2.1 e-invoice named after its invoice number
+// Invoices that don't conform to Peppol BIS Billing 3.0 are answered with the rules they break
+func (h *Handler) serveInvoiceUBL(w http.ResponseWriter, r *http.Request, invoiceIDStr string) {
+	invoiceID, err := strconv.ParseUint(invoiceIDStr, 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	if !h.authorizeInvoice(w, r, invoiceID) {
+		return
+	}
+
+	invoice, data, err := h.service.ExportInvoiceUBL(r.Context(), invoiceID)
+	var invalid *UBLValidationError
+	if errors.As(err, &invalid) {
+		helper.RespondJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":      "Invoice is not a valid e-invoice",
+			"violations": invalid.Violations,
+		})
+		return
+	}
+	if err != nil {
+		helper.RespondServiceError(w, err, "Failed to export invoice", errorRules...)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", invoiceFilename(invoice, ".xml")))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// servePayments lists an invoice's payments with its current balance
+func (h *Handler) servePayments(w http.ResponseWriter, r *http.Request, invoiceIDStr string) {
+	invoiceID, err := strconv.ParseUint(invoiceIDStr, 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	if !h.authorizeInvoice(w, r, invoiceID) {
+		return
+	}
+
+	invoice, payments, err := h.service.GetPayments(r.Context(), invoiceID)
+	if err != nil {
+		helper.RespondServiceError(w, err, "Failed to retrieve payments", errorRules...)
+		return
+	}
+
+	helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"invoice_id":  invoice.InvoiceID,
+		"status":      invoice.Status,
+		"total":       invoice.Total,
+		"amount_paid": invoice.AmountPaid,
+		"balance_due": invoice.BalanceDue,
+		"credit":      invoice.Credit,
+		"payments":    payments,
+		"count":       len(payments),
+	})
+}
+
+// handleRecordPayment handles requests to record a payment against an invoice
+// URL pattern: POST /invoices/{id}/payments
+func (h *Handler) handleRecordPayment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+			return
+		}
+
+		var req RecordPaymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		payment, invoice, err := h.service.RecordPayment(r.Context(), invoiceID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to record payment", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"payment":     payment,
+			"status":      invoice.Status,
+			"total":       invoice.Total,
+			"amount_paid": invoice.AmountPaid,
+			"balance_due": invoice.BalanceDue,
+			"credit":      invoice.Credit,
+		})
+	}
+}
+
+// serveCreditNotes lists an invoice's credit notes with its current balance
+func (h *Handler) serveCreditNotes(w http.ResponseWriter, r *http.Request, invoiceIDStr string) {
+	invoiceID, err := strconv.ParseUint(invoiceIDStr, 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	if !h.authorizeInvoice(w, r, invoiceID) {
+		return
+	}
+
+	invoice, notes, err := h.service.GetCreditNotes(r.Context(), invoiceID)
+	if err != nil {
+		helper.RespondServiceError(w, err, "Failed to retrieve credit notes", errorRules...)
+		return
+	}
+
+	helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"invoice_id":   invoice.InvoiceID,
+		"status":       invoice.Status,
+		"total":        invoice.Total,
+		"credited":     invoice.Credited,
+		"balance_due":  invoice.BalanceDue,
+		"credit":       invoice.Credit,
+		"credit_notes": notes,
+		"count":        len(notes),
+	})
+}
+
+// handleCreateCreditNote handles requests to credit lines of an invoice
+// URL pattern: POST /invoices/{id}/credit-notes
+func (h *Handler) handleCreateCreditNote() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+			return
+		}
+
+		var req CreateCreditNoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		note, invoice, err := h.service.CreateCreditNote(r.Context(), invoiceID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create credit note", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, map[string]interface{}{
+			"credit_note": note,
+			"status":      invoice.Status,
+			"total":       invoice.Total,
+			"credited":    invoice.Credited,
+			"amount_paid": invoice.AmountPaid,
+			"balance_due": invoice.BalanceDue,
+			"credit":      invoice.Credit,
+		})
+	}
+}
+
+// handleGetCreditNote handles requests to get a credit note by ID
+// URL pattern: GET /credit-notes/{id}
+func (h *Handler) handleGetCreditNote() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		creditNoteID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid credit note ID")
+			return
+		}
+
+		u, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+		note, err := h.service.GetCreditNote(r.Context(), creditNoteID)
+		if err == nil && note.UserID != u.UserID && !h.admins.IsAdmin(u.Username) {
+			helper.RespondError(w, http.StatusNotFound, "credit note not found")
+			return
+		}
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to retrieve credit note", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, note)
+	}
+}
+
+// handleSendInvoice handles requests to email an invoice to its customer
+// URL pattern: POST /invoices/{id}/send
+// A failed attempt is recorded, and reported with the delivery and a 502 status
+func (h *Handler) handleSendInvoice() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+			return
+		}
+
+		delivery, invoice, err := h.service.SendInvoice(r.Context(), invoiceID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to send invoice", errorRules...)
+			return
+		}
+
+		status := http.StatusOK
+		if delivery.Status == DeliveryFailed {
+			log.Printf("Sending invoice %s failed: %s", invoice.InvoiceNumber, delivery.Error)
+			status = http.StatusBadGateway
+		}
+		helper.RespondJSON(w, status, map[string]interface{}{
+			"delivery": delivery,
+			"status":   invoice.Status,
+		})
+	}
+}
+
+// serveDeliveries lists the attempts to email an invoice
+func (h *Handler) serveDeliveries(w http.ResponseWriter, r *http.Request, invoiceIDStr string) {
+	invoiceID, err := strconv.ParseUint(invoiceIDStr, 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	if !h.authorizeInvoice(w, r, invoiceID) {
+		return
+	}
+
+	invoice, deliveries, err := h.service.GetDeliveries(r.Context(), invoiceID)
+	if err != nil {
+		helper.RespondServiceError(w, err, "Failed to retrieve deliveries", errorRules...)
+		return
+	}
+
+	helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"invoice_id": invoice.InvoiceID,
+		"status":     invoice.Status,
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+// serveVersions lists the versions of an invoice's content with their hashes
+func (h *Handler) serveVersions(w http.ResponseWriter, r *http.Request, invoiceIDStr string) {
+	invoiceID, err := strconv.ParseUint(invoiceIDStr, 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+	if !h.authorizeInvoice(w, r, invoiceID) {
+		return
+	}
+
+	invoice, versions, err := h.service.GetVersions(r.Context(), invoiceID)
+	if err != nil {
+		helper.RespondServiceError(w, err, "Failed to retrieve invoice versions", errorRules...)
+		return
+	}
+
+	helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+		"invoice_id":   invoice.InvoiceID,
+		"version":      invoice.Version,
+		"content_hash": invoice.ContentHash,
+		"versions":     versions,
+		"count":        len(versions),
+	})
+}
+
+// handleUpdateBillingAddress handles requests to change the billing address of a draft invoice
+// URL pattern: PUT /invoices/{id}/billing-address
+func (h *Handler) handleUpdateBillingAddress() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
+			return
+		}
+		if !h.authorizeInvoice(w, r, invoiceID) {
+			return
+		}
+
+		var address Address
+		if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		invoice, err := h.service.UpdateBillingAddress(r.Context(), invoiceID, address)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update billing address", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, invoice)
+	}
+}
+
+// handleAgingReport handles requests for the aging report of overdue invoices
+// URL pattern: GET /invoices/overdue
+func (h *Handler) handleAgingReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := h.service.GetAgingReport(r.Context(), time.Now())
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to build aging report", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, report)
+	}
+}
+
+// handleFreezeInvoices handles requests to freeze the invoices from before snapshots
+// URL pattern: POST /invoices/freeze
+func (h *Handler) handleFreezeInvoices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := h.service.FreezeLegacyInvoices(r.Context())
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to freeze invoices", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, report)
+	}
+}
+
+// currentUser resolves the authenticated user, writing an error response when that fails
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	username, ok := r.Context().Value(auth.UsernameContextKey).(string)
+	if !ok || username == "" {
+		helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+		return nil, false
+	}
+	u, err := h.userService.GetUserByUsername(r.Context(), username)
+	if err != nil || u == nil {
+		helper.RespondError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	return u, true
+}
+
+// authorizeInvoice checks that the authenticated user is the customer of an invoice or an admin,
+// writing an error response when they aren't; other customers' invoices are reported as not found
+func (h *Handler) authorizeInvoice(w http.ResponseWriter, r *http.Request, invoiceID uint64) bool {
+	u, ok := h.currentUser(w, r)
+	if !ok {
+		return false
+	}
+	if h.admins.IsAdmin(u.Username) {
+		return true
+	}
+	owner, err := h.service.GetInvoiceOwner(r.Context(), invoiceID)
+	if err != nil && err.Error() != "invoice not found" {
+		log.Printf("Invoice owner lookup failed: %v", err)
+		helper.RespondError(w, http.StatusInternalServerError, "Failed to retrieve invoice")
+		return false
+	}
+	if err != nil || owner != u.UserID {
+		helper.RespondError(w, http.StatusNotFound, "Invoice not found")
+		return false
+	}
+	return true
+}
+
+// errorRules answer the invoice service errors the shared rules don't; changes that an invoice's state rules out
+// ("must not ...") and invoices from before snapshots are conflicts
+var errorRules = []helper.ErrorRule{
+	{Fragment: "must not", Status: http.StatusConflict},
+	{Fragment: "must be frozen", Status: http.StatusConflict},
+}
+
+// invoiceFilename names a document of an invoice after its number, keeping only characters that are safe in file names
+func invoiceFilename(invoice *Invoice, extension string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, invoice.InvoiceNumber)
+	if name == "" {
+		name = "invoice-" + strconv.FormatUint(invoice.InvoiceID, 10)
+	}
+	return name + extension
+}
+
 // handleGetUserInvoices handles requests to get all invoices for a user
 // URL pattern: GET /invoices/user/{user_id}
+// The user's credit notes are listed next to the invoices they credit
 func (h *Handler) handleGetUserInvoices() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -133,6 +612,14 @@ func (h *Handler) handleGetUserInvoices() http.HandlerFunc {
 			helper.RespondError(w, http.StatusBadRequest, "Invalid user ID")
 			return
 		}
+		u, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+		if userID != u.UserID && !h.admins.IsAdmin(u.Username) {
+			helper.RespondError(w, http.StatusForbidden, "Only admins can list the invoices of other users")
+			return
+		}
 
 		// Get invoices
 		invoices, err := h.service.GetInvoicesByUserID(ctx, userID)
@@ -141,9 +628,16 @@ func (h *Handler) handleGetUserInvoices() http.HandlerFunc {
 			return
 		}
 
+		notes, err := h.service.GetCreditNotesByUserID(ctx, userID)
+		if err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to retrieve credit notes")
+			return
+		}
+
 		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
-			"invoices": invoices,
-			"count":    len(invoices),
+			"invoices":     invoices,
+			"count":        len(invoices),
+			"credit_notes": notes,
 		})
 	}
 }
@@ -166,6 +660,9 @@ func (h *Handler) handleUpdateInvoiceStatus() http.HandlerFunc {
 			helper.RespondError(w, http.StatusBadRequest, "Invalid invoice ID")
 			return
 		}
+		if !h.authorizeInvoice(w, r, invoiceID) {
+			return
+		}
 
 		// Parse request body
 		var req UpdateInvoiceStatusRequest
@@ -176,11 +673,7 @@ func (h *Handler) handleUpdateInvoiceStatus() http.HandlerFunc {
 
 		// Update status
 		if err := h.service.UpdateInvoiceStatus(ctx, invoiceID, req.Status); err != nil {
-			if strings.Contains(err.Error(), "invalid status") {
-				helper.RespondError(w, http.StatusBadRequest, err.Error())
-				return
-			}
-			helper.RespondError(w, http.StatusInternalServerError, "Failed to update invoice status")
+			helper.RespondServiceError(w, err, "Failed to update invoice status", errorRules...)
 			return
 		}
 
@@ -2,6 +2,56 @@ package invoice
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/fx"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/numbering"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
+)
+
+// Invoice statuses
+// Paid and partially paid follow from the recorded payments, credited from the credit notes and
+// overdue from the due date; they can't be set directly
+const (
+	StatusDraft         = "draft"
+	StatusSent          = "sent"
+	StatusPartiallyPaid = "partially_paid"
+	StatusOverdue       = "overdue" // sent or partially paid, with a balance due past the due date
+	StatusPaid          = "paid"
+	StatusCredited      = "credited" // fully credited by credit notes
+	StatusCancelled     = "cancelled"
+)
+
+// Default numbering templates of invoices and credit notes, see package numbering
+const (
+	DefaultInvoiceNumberFormat    = "INV-{YYYY}-{000000}"
+	DefaultCreditNoteNumberFormat = "CN-{YYYY}-{000000}"
+)
+
+// Payment methods accepted by RecordPayment
+const (
+	MethodBankTransfer = "bank_transfer"
+	MethodCard         = "card"
+	MethodCash         = "cash"
+	MethodCheque       = "cheque"
+	MethodOther        = "other"
+)
+
+// Delivery statuses
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+)
+
+// Reasons an invoice's content was versioned, see InvoiceVersion
+const (
+	VersionCreated        = "created"         // the invoice was created from its order
+	VersionFrozen         = "frozen"          // an invoice from before versioning was completed from its order and stored
+	VersionBillingAddress = "billing_address" // the billing address of a draft changed
 )
 
 // Invoice represents an invoice in the database
@@ -12,39 +62,353 @@ type Invoice struct {
 	Username      string        `json:"username"`
 	InvoiceNumber string        `json:"invoice_number"`
 	Items         []InvoiceItem `json:"items"`
-	Subtotal      float64       `json:"subtotal"`
-	Tax           float64       `json:"tax"`
-	Total         float64       `json:"total"`
-	Status        string        `json:"status"` // "draft", "sent", "paid", "cancelled"
+	Currency      string        `json:"currency"`
+	Discount      money.Money   `json:"discount"` // total discount, already deducted from Subtotal
+	Subtotal      money.Money   `json:"subtotal"`
+	Tax           money.Money   `json:"tax"`
+	Total         money.Money   `json:"total"`
+	Status        string        `json:"status"` // "draft", "sent", "partially_paid", "overdue", "paid", "credited", "cancelled"
 	CreatedAt     string        `json:"created_at"`
 	DueDate       string        `json:"due_date"`
+
+	// Seller and Buyer are stored with the invoice, so it never changes with the configuration or the customer's account
+	Seller Party `json:"seller"`
+	Buyer  Party `json:"buyer"`
+	// Version counts the stored versions of the invoice's content, and ContentHash is the SHA-256 of the current one
+	// Only drafts get new versions; once sent, an invoice keeps its content for good
+	Version     int    `json:"version"`
+	ContentHash string `json:"content_hash"`
+	// IntegrityError is set in listings on an invoice whose content failed its check, which reading it alone refuses
+	IntegrityError string `json:"integrity_error,omitempty"`
+
+	// Credited is the total of the invoice's credit notes, and LateFees the fees charged by its reminders
+	// AmountPaid, BalanceDue and Credit are derived from the recorded payments and Total minus Credited plus LateFees
+	Credited   money.Money `json:"credited"`
+	LateFees   money.Money `json:"late_fees"`
+	AmountPaid money.Money `json:"amount_paid"`
+	BalanceDue money.Money `json:"balance_due"`
+	Credit     money.Money `json:"credit"` // amount paid beyond what is owed, owed back to the customer
+
+	// ExchangeRates are the rates the order was converted at, copied so the invoice stays reproducible
+	ExchangeRates []fx.Rate `json:"exchange_rates,omitempty"`
+	// Discounts are the discount lines copied from the order
+	Discounts []promotion.Discount `json:"discounts,omitempty"`
 }
 
 // InvoiceItem represents an item in an invoice
 type InvoiceItem struct {
-	ProductID    uint64  `json:"product_id"`
-	ProductName  string  `json:"product_name"`
-	Description  string  `json:"description"`
-	Quantity     int     `json:"quantity"`
-	UnitPrice    float64 `json:"unit_price"`
-	TotalPrice   float64 `json:"total_price"`
+	ProductID   uint64            `json:"product_id"`
+	VariantID   uint64            `json:"variant_id,omitempty"`
+	SKU         string            `json:"sku,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"` // variant attributes, e.g. colour and size
+	ProductName string            `json:"product_name"`
+	Description string            `json:"description"`
+	Quantity    int               `json:"quantity"`
+	UnitPrice   money.Money       `json:"unit_price"`
+	TotalPrice  money.Money       `json:"total_price"`
+	Discount    money.Money       `json:"discount"`
+	Tax         *tax.Breakdown    `json:"tax,omitempty"`        // per-line tax breakdown copied from the order
+}
+
+// Party is the seller or the buyer of an invoice, as stored when the invoice was created
+type Party struct {
+	Name             string  `json:"name"`
+	Address          Address `json:"address"`
+	TaxID            string  `json:"tax_id,omitempty"`            // e.g. the VAT number, with its country prefix
+	LegalID          string  `json:"legal_id,omitempty"`          // company registration number
+	IdentifierScheme string  `json:"identifier_scheme,omitempty"` // ISO 6523 code of Identifier
+	Identifier       string  `json:"identifier,omitempty"`        // e.g. a GLN
+	EndpointScheme   string  `json:"endpoint_scheme,omitempty"`   // EAS code of the electronic address of e-invoices
+	EndpointID       string  `json:"endpoint_id,omitempty"`
+	ContactName      string  `json:"contact_name,omitempty"`
+	Email            string  `json:"email,omitempty"`
+	Phone            string  `json:"phone,omitempty"`
+	Website          string  `json:"website,omitempty"`
+	CustomerID       uint64  `json:"customer_id,omitempty"` // buyers only
+}
+
+// Address is a postal address
+// Lines are the street lines, or the whole address of a seller whose branding doesn't split it up
+type Address struct {
+	Lines      []string `json:"lines,omitempty"`
+	City       string   `json:"city,omitempty"`
+	PostalZone string   `json:"postal_zone,omitempty"`
+	Country    string   `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, e.g. "GB"
+}
+
+// FreezeReport is the outcome of freezing the invoices from before snapshots
+// Failed invoices stay unversioned and are tried again by the next run
+type FreezeReport struct {
+	Frozen int             `json:"frozen"`
+	Failed []FreezeFailure `json:"failed"`
+}
+
+// FreezeFailure is an invoice that couldn't be frozen, e.g. because its order or customer is gone
+type FreezeFailure struct {
+	InvoiceID     uint64 `json:"invoice_id"`
+	InvoiceNumber string `json:"invoice_number"`
+	Error         string `json:"error"`
+}
+
+// InvoiceVersion is a version of the content of an invoice
+// Content is the JSON of everything the invoice states except its status and payments, and ContentHash its SHA-256
+type InvoiceVersion struct {
+	InvoiceID   uint64          `json:"invoice_id"`
+	Version     int             `json:"version"`
+	Reason      string          `json:"reason"` // "created", "frozen" or "billing_address"
+	ContentHash string          `json:"content_hash"`
+	Content     json.RawMessage `json:"content"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Payment is a payment received for an invoice
+// Refunds are payments with a negative amount
+type Payment struct {
+	PaymentID uint64      `json:"payment_id"`
+	InvoiceID uint64      `json:"invoice_id"`
+	Amount    money.Money `json:"amount"`
+	Method    string      `json:"method"`
+	Reference string      `json:"reference,omitempty"` // e.g. a bank transfer reference or card transaction ID
+	PaidAt    time.Time   `json:"paid_at"`
+	CreatedAt time.Time   `json:"created_at"`
 }
 
 // Repository defines the interface for invoice data operations
 type InvoiceRepository interface {
-	Create(ctx context.Context, invoice *Invoice) error
+	// Create stores an invoice with the next number of series, setting its InvoiceNumber
+	Create(ctx context.Context, invoice *Invoice, series numbering.Series) error
 	GetByID(ctx context.Context, invoiceID uint64) (*Invoice, error)
 	GetByOrderID(ctx context.Context, orderID uint64) (*Invoice, error)
 	GetByUserID(ctx context.Context, userID uint64) ([]Invoice, error)
 	UpdateStatus(ctx context.Context, invoiceID uint64, status string) error
+	CreatePayment(ctx context.Context, payment *Payment) error
+	// GetPayments returns the payments of an invoice in the order they were made
+	GetPayments(ctx context.Context, invoiceID uint64) ([]Payment, error)
+	// CreateCreditNote stores a credit note with the next number of series, setting its CreditNoteNumber
+	CreateCreditNote(ctx context.Context, note *CreditNote, series numbering.Series) error
+	GetCreditNote(ctx context.Context, creditNoteID uint64) (*CreditNote, error)
+	// GetCreditNotesByInvoiceID returns the credit notes of an invoice, oldest first
+	GetCreditNotesByInvoiceID(ctx context.Context, invoiceID uint64) ([]CreditNote, error)
+	// GetCreditNotesByUserID returns the credit notes of a user, newest first
+	GetCreditNotesByUserID(ctx context.Context, userID uint64) ([]CreditNote, error)
+	// GetPastDue returns the sent, partially paid and overdue invoices with a due date before now, oldest due first
+	GetPastDue(ctx context.Context, now time.Time) ([]Invoice, error)
+	// CreateReminder claims the reminder of a day of an invoice's schedule; a second one "already exists"
+	CreateReminder(ctx context.Context, reminder *Reminder) error
+	// UpdateReminder records why a reminder couldn't be delivered
+	UpdateReminder(ctx context.Context, reminder *Reminder) error
+	// GetReminders returns the reminders of an invoice, oldest first
+	GetReminders(ctx context.Context, invoiceID uint64) ([]Reminder, error)
+	CreateDelivery(ctx context.Context, delivery *Delivery) error
+	// GetDeliveries returns the attempts to email an invoice, oldest first
+	GetDeliveries(ctx context.Context, invoiceID uint64) ([]Delivery, error)
+	// SaveVersion stores the content of an invoice as its Version with its ContentHash, and adds the version to
+	// the history; it fails with "already exists" unless the stored invoice is at the version before
+	SaveVersion(ctx context.Context, invoice *Invoice, version *InvoiceVersion) error
+	// GetVersions returns the version history of an invoice, oldest first
+	GetVersions(ctx context.Context, invoiceID uint64) ([]InvoiceVersion, error)
+	// GetUnversioned returns the invoices from before snapshots, which have no version yet
+	GetUnversioned(ctx context.Context) ([]Invoice, error)
+}
+
+// Delivery is an attempt to email an invoice to its customer
+type Delivery struct {
+	DeliveryID  uint64    `json:"delivery_id"`
+	InvoiceID   uint64    `json:"invoice_id"`
+	Recipient   string    `json:"recipient"`            // empty if the customer has no email address
+	Attachment  string    `json:"attachment,omitempty"` // file name of the attached PDF, empty if it couldn't be rendered
+	Status      string    `json:"status"`               // "sent" or "failed"
+	Error       string    `json:"error,omitempty"`      // why the email couldn't be sent
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// Reminder is a payment reminder for an overdue invoice
+type Reminder struct {
+	ReminderID uint64      `json:"reminder_id"`
+	InvoiceID  uint64      `json:"invoice_id"`
+	Day        int         `json:"day"`      // day after the due date of the schedule it was sent for
+	LateFee    money.Money `json:"late_fee"` // charged with this reminder, zero for none
+	SentAt     time.Time   `json:"sent_at"`
+	Error      string      `json:"error,omitempty"` // why the reminder couldn't be delivered
+}
+
+// AgingReport lists the overdue invoices in buckets of how long they are overdue
+type AgingReport struct {
+	AsOf    time.Time     `json:"as_of"`
+	Buckets []AgingBucket `json:"buckets"`
+	Totals  []money.Money `json:"totals"` // balance due of all buckets, one amount per currency
+	Count   int           `json:"count"`
+}
+
+// AgingBucket holds the invoices overdue between MinDays and MaxDays days
+type AgingBucket struct {
+	Name     string           `json:"name"` // "1-30", "31-60", "61-90" or "over_90"
+	MinDays  int              `json:"min_days"`
+	MaxDays  int              `json:"max_days,omitempty"` // zero for the last, open-ended bucket
+	Invoices []OverdueInvoice `json:"invoices"`
+	Totals   []money.Money    `json:"totals"` // balance due per currency
+}
+
+// OverdueInvoice is an overdue invoice in an aging report
+type OverdueInvoice struct {
+	InvoiceID     uint64      `json:"invoice_id"`
+	InvoiceNumber string      `json:"invoice_number"`
+	UserID        uint64      `json:"user_id"`
+	Username      string      `json:"username"`
+	DueDate       string      `json:"due_date"`
+	DaysOverdue   int         `json:"days_overdue"`
+	Total         money.Money `json:"total"`
+	BalanceDue    money.Money `json:"balance_due"`
+	LateFees      money.Money `json:"late_fees"`
+	Reminders     int         `json:"reminders"` // reminders sent so far
+}
+
+// CreditNote credits some or all lines of an invoice, e.g. when an order is cancelled after invoicing
+// Amounts are positive and reduce what is owed on the invoice; the invoice itself never changes
+type CreditNote struct {
+	CreditNoteID     uint64           `json:"credit_note_id"`
+	CreditNoteNumber string           `json:"credit_note_number"` // from its own sequence, e.g. CN-2025-000001
+	InvoiceID        uint64           `json:"invoice_id"`
+	InvoiceNumber    string           `json:"invoice_number"` // number of the credited invoice
+	OrderID          uint64           `json:"order_id"`
+	UserID           uint64           `json:"user_id"`
+	Items            []CreditNoteItem `json:"items"`
+	Currency         string           `json:"currency"`
+	Subtotal         money.Money      `json:"subtotal"` // excluding tax
+	Tax              money.Money      `json:"tax"`      // tax reversed
+	Total            money.Money      `json:"total"`
+	Reason           string           `json:"reason,omitempty"`
+	RefundPaymentID  uint64           `json:"refund_payment_id,omitempty"` // refund paid out for this credit note
+	CreatedAt        time.Time        `json:"created_at"`
+}
+
+// CreditNoteItem credits a quantity of one invoice line
+// Tax holds the credited share of the line's tax breakdown, so the tax is reversed at the rate it was charged
+type CreditNoteItem struct {
+	Line        int           `json:"line"` // 1-based position of the item on the invoice
+	ProductID   uint64        `json:"product_id"`
+	VariantID   uint64        `json:"variant_id,omitempty"`
+	SKU         string        `json:"sku,omitempty"`
+	ProductName string        `json:"product_name"`
+	Quantity    int           `json:"quantity"`
+	UnitPrice   money.Money   `json:"unit_price"`
+	Tax         tax.Breakdown `json:"tax"`
 }
 
 // CreateInvoiceRequest represents the request to create an invoice
 type CreateInvoiceRequest struct {
-	OrderID uint64 `json:"order_id"`
+	OrderID        uint64  `json:"order_id"`
+	BillingAddress Address `json:"billing_address"` // optional, stored with the invoice
 }
 
 // UpdateInvoiceStatusRequest represents the request to update invoice status
 type UpdateInvoiceStatusRequest struct {
 	Status string `json:"status"`
-}
\ No newline at end of file
+}
+
+// RecordPaymentRequest represents a payment received for an invoice
+type RecordPaymentRequest struct {
+	Amount    money.Money `json:"amount"` // in the invoice currency
+	Method    string      `json:"method"`
+	Reference string      `json:"reference"`
+	PaidAt    *time.Time  `json:"paid_at"` // defaults to now
+}
+
+// CreateCreditNoteRequest represents the request to credit lines of an invoice
+// Without lines, everything not yet credited is credited
+type CreateCreditNoteRequest struct {
+	Lines  []CreditNoteLineRequest `json:"lines"`
+	Reason string                  `json:"reason"`
+	Refund *CreditNoteRefund       `json:"refund,omitempty"`
+}
+
+// CreditNoteLineRequest selects the quantity of an invoice line to credit
+type CreditNoteLineRequest struct {
+	Line     int `json:"line"`     // 1-based position of the item on the invoice
+	Quantity int `json:"quantity"` // defaults to the quantity not yet credited
+}
+
+// CreditNoteRefund links a credit note to a refund
+// Either PaymentID names a refund that was already recorded, e.g. by the payment provider,
+// or Method and Reference record a refund of what the customer overpaid once the credit note applies
+type CreditNoteRefund struct {
+	PaymentID uint64 `json:"payment_id,omitempty"`
+	Method    string `json:"method,omitempty"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// applyCurrency sets the invoice currency on all scanned amounts, including the stored items
+func (i *Invoice) applyCurrency() {
+	money.WithCurrency(i.Currency, &i.Subtotal, &i.Tax, &i.Total, &i.Discount)
+	for idx := range i.Items {
+		money.WithCurrency(i.Currency, &i.Items[idx].UnitPrice, &i.Items[idx].TotalPrice, &i.Items[idx].Discount)
+	}
+	for idx := range i.Discounts {
+		money.WithCurrency(i.Currency, &i.Discounts[idx].Amount)
+	}
+}
+
+// creditNoteColumns are the columns read by scanCreditNote, in both databases
+const creditNoteColumns = "credit_note_id, credit_note_number, invoice_id, invoice_number, order_id, user_id, items, currency, subtotal, tax, total, reason, refund_payment_id, created_at"
+
+// scanCreditNote reads a row of creditNoteColumns; the items JSON is left in itemsJSON for the caller to decode
+func scanCreditNote(rows *sql.Rows, itemsJSON interface{}) (*CreditNote, error) {
+	var note CreditNote
+	err := rows.Scan(
+		&note.CreditNoteID, &note.CreditNoteNumber, &note.InvoiceID, &note.InvoiceNumber, &note.OrderID, &note.UserID,
+		itemsJSON, &note.Currency, &note.Subtotal, &note.Tax, &note.Total, &note.Reason, &note.RefundPaymentID, &note.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	money.WithCurrency(note.Currency, &note.Subtotal, &note.Tax, &note.Total)
+	return &note, nil
+}
+
+// decodeParties reads the stored seller and buyer of an invoice; invoices from before they were stored have none
+func (i *Invoice) decodeParties(seller, buyer []byte) error {
+	if len(seller) > 0 {
+		if err := json.Unmarshal(seller, &i.Seller); err != nil {
+			return err
+		}
+	}
+	if len(buyer) > 0 {
+		if err := json.Unmarshal(buyer, &i.Buyer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeParty serializes the seller or buyer of an invoice for storage
+func encodeParty(party Party) (string, error) {
+	data, err := json.Marshal(party)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encodeExchangeRates serializes the exchange rates of an invoice for storage
+func encodeExchangeRates(rates []fx.Rate) (string, error) {
+	if len(rates) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(rates)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encodeDiscounts serializes the discount lines of an invoice for storage
+func encodeDiscounts(discounts []promotion.Discount) (string, error) {
+	if len(discounts) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(discounts)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
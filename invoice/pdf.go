@@ -0,0 +1,431 @@
+package invoice
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/pdf"
+)
+
+// Page layout in points
+const (
+	pageMargin   = 50.0
+	footerHeight = 60.0 // space kept free at the bottom of every page for the footer
+	rowPadding   = 6.0
+)
+
+// Colors of secondary text and table rules
+var (
+	mutedColor = pdf.Color{R: 0.35, G: 0.35, B: 0.35}
+	ruleColor  = pdf.Color{R: 0.82, G: 0.82, B: 0.82}
+)
+
+// PDFRenderer renders invoices as PDF documents with a branding
+// Output only depends on the invoice and the branding, so the same invoice always renders to the same bytes
+// The seller and buyer blocks show the parties stored with the invoice, not the current branding
+type PDFRenderer struct {
+	branding Branding
+	size     pdf.Size
+	accent   pdf.Color
+	light    pdf.Color // the accent mixed with white, for the table header
+	title    *template.Template
+	notes    *template.Template
+	footer   *template.Template
+}
+
+// NewPDFRenderer checks a branding and creates a renderer for it
+func NewPDFRenderer(branding Branding) (*PDFRenderer, error) {
+	r := &PDFRenderer{branding: branding}
+	switch strings.ToLower(branding.PageSize) {
+	case "", "a4":
+		r.size = pdf.A4
+	case "letter":
+		r.size = pdf.Letter
+	default:
+		return nil, fmt.Errorf("invalid page size '%s': must be A4 or Letter", branding.PageSize)
+	}
+	accent, err := pdf.ParseColor(branding.AccentColor)
+	if err != nil {
+		return nil, err
+	}
+	r.accent = accent
+	r.light = pdf.Color{R: 0.85 + 0.15*accent.R, G: 0.85 + 0.15*accent.G, B: 0.85 + 0.15*accent.B}
+	if r.branding.DateFormat == "" {
+		r.branding.DateFormat = "2006-01-02"
+	}
+	for _, t := range []struct {
+		name string
+		text string
+		tmpl **template.Template
+	}{
+		{"title", branding.Title, &r.title},
+		{"notes", branding.Notes, &r.notes},
+		{"footer", branding.Footer, &r.footer},
+	} {
+		if *t.tmpl, err = template.New(t.name).Option("missingkey=error").Parse(t.text); err != nil {
+			return nil, fmt.Errorf("invalid invoice %s template: %w", t.name, err)
+		}
+	}
+	return r, nil
+}
+
+// invoiceLayout tracks the drawing position while an invoice is rendered
+type invoiceLayout struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64
+}
+
+// Render draws an invoice with its seller and buyer blocks, line items and totals
+func (r *PDFRenderer) Render(invoice *Invoice) ([]byte, error) {
+	data := r.templateData(invoice)
+	title, err := execute(r.title, data)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := execute(r.notes, data)
+	if err != nil {
+		return nil, err
+	}
+	footer, err := execute(r.footer, data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := pdf.New(r.size)
+	doc.SetTitle(strings.TrimSpace(title + " " + invoice.InvoiceNumber))
+	layout := &invoiceLayout{doc: doc, page: doc.AddPage()}
+	r.drawHeader(layout, invoice, title, data)
+	r.drawItems(layout, invoice)
+	r.drawTotals(layout, invoice)
+	r.drawNotes(layout, invoice, notes)
+	r.drawFooters(doc, footer)
+	return doc.Bytes(), nil
+}
+
+// templateData collects the values available to branding templates
+func (r *PDFRenderer) templateData(invoice *Invoice) TemplateData {
+	return TemplateData{
+		CompanyName:   invoice.Seller.Name,
+		InvoiceNumber: invoice.InvoiceNumber,
+		OrderID:       invoice.OrderID,
+		Status:        invoice.Status,
+		IssueDate:     r.formatDate(invoice.CreatedAt),
+		DueDate:       r.formatDate(invoice.DueDate),
+		Subtotal:      invoice.Subtotal.Display(),
+		Tax:           invoice.Tax.Display(),
+		Total:         invoice.Total.Display(),
+		Currency:      invoice.Currency,
+		CustomerName:  invoice.Buyer.Name,
+		CustomerEmail: invoice.Buyer.Email,
+	}
+}
+
+// drawHeader draws the accent bar, the title, the seller and buyer blocks and the invoice details
+func (r *PDFRenderer) drawHeader(l *invoiceLayout, invoice *Invoice, title string, data TemplateData) {
+	page, right := l.page, r.size.Width-pageMargin
+	page.Rect(0, 0, r.size.Width, 10, r.accent)
+	page.Text(pageMargin, 62, pdf.HelveticaBold, 18, r.accent, invoice.Seller.Name)
+	page.TextRight(right, 62, pdf.HelveticaBold, 22, r.accent, title)
+
+	// Seller block
+	seller := addressLines(invoice.Seller.Address)
+	for _, line := range []string{invoice.Seller.Email, invoice.Seller.Phone, invoice.Seller.Website} {
+		if line != "" {
+			seller = append(seller, line)
+		}
+	}
+	if invoice.Seller.TaxID != "" {
+		seller = append(seller, "Tax ID: "+invoice.Seller.TaxID)
+	}
+	sellerY := 84.0
+	for _, line := range seller {
+		page.Text(pageMargin, sellerY, pdf.Helvetica, 9, mutedColor, line)
+		sellerY += 12
+	}
+
+	// Invoice details
+	details := [][2]string{
+		{"Invoice number", invoice.InvoiceNumber},
+		{"Invoice date", data.IssueDate},
+		{"Due date", data.DueDate},
+		{"Order", "#" + strconv.FormatUint(invoice.OrderID, 10)},
+		{"Status", strings.ToUpper(invoice.Status)},
+	}
+	detailsY := 88.0
+	for _, detail := range details {
+		page.Text(right-210, detailsY, pdf.Helvetica, 9, mutedColor, detail[0])
+		page.TextRight(right, detailsY, pdf.HelveticaBold, 9, pdf.Black, detail[1])
+		detailsY += 14
+	}
+
+	// Buyer block
+	y := max(sellerY, detailsY) + 20
+	page.Text(pageMargin, y, pdf.HelveticaBold, 10, r.accent, "Bill to")
+	y += 15
+	page.Text(pageMargin, y, pdf.HelveticaBold, 10, pdf.Black, invoice.Buyer.Name)
+	for _, line := range addressLines(invoice.Buyer.Address) {
+		y += 13
+		page.Text(pageMargin, y, pdf.Helvetica, 9, mutedColor, line)
+	}
+	if invoice.Buyer.Email != "" {
+		y += 13
+		page.Text(pageMargin, y, pdf.Helvetica, 9, mutedColor, invoice.Buyer.Email)
+	}
+	if invoice.Buyer.CustomerID != 0 {
+		y += 13
+		page.Text(pageMargin, y, pdf.Helvetica, 9, mutedColor, "Customer ID: "+strconv.FormatUint(invoice.Buyer.CustomerID, 10))
+	}
+	l.y = y + 28
+}
+
+// addressLines prints an address: its street lines, the postal zone with the city, and the country
+func addressLines(address Address) []string {
+	lines := append([]string{}, address.Lines...)
+	if city := strings.TrimSpace(address.PostalZone + " " + address.City); city != "" {
+		lines = append(lines, city)
+	}
+	if address.Country != "" {
+		lines = append(lines, address.Country)
+	}
+	return lines
+}
+
+// Right edges of the numeric columns of the items table, measured from the right margin
+const (
+	quantityColumn  = 245.0
+	unitPriceColumn = 170.0
+	taxColumn       = 85.0
+)
+
+// drawTableHeader draws the header row of the items table
+func (r *PDFRenderer) drawTableHeader(l *invoiceLayout) {
+	right := r.size.Width - pageMargin
+	l.page.Rect(pageMargin, l.y, right-pageMargin, 20, r.light)
+	textY := l.y + 13.5
+	l.page.Text(pageMargin+rowPadding, textY, pdf.HelveticaBold, 9, pdf.Black, "Description")
+	l.page.TextRight(right-quantityColumn, textY, pdf.HelveticaBold, 9, pdf.Black, "Qty")
+	l.page.TextRight(right-unitPriceColumn, textY, pdf.HelveticaBold, 9, pdf.Black, "Unit price")
+	l.page.TextRight(right-taxColumn, textY, pdf.HelveticaBold, 9, pdf.Black, "Tax")
+	l.page.TextRight(right-rowPadding, textY, pdf.HelveticaBold, 9, pdf.Black, "Amount")
+	l.y += 20
+}
+
+// drawItems draws one table row per line item, continuing the table on new pages as needed
+func (r *PDFRenderer) drawItems(l *invoiceLayout, invoice *Invoice) {
+	right := r.size.Width - pageMargin
+	descriptionWidth := right - quantityColumn - 40 - pageMargin - rowPadding
+	r.drawTableHeader(l)
+	for _, item := range invoice.Items {
+		name := pdf.WrapText(pdf.Helvetica, 9.5, item.ProductName, descriptionWidth)
+		details := pdf.WrapText(pdf.Helvetica, 8, itemDetails(item), descriptionWidth)
+		if len(details) == 1 && details[0] == "" {
+			details = nil
+		}
+		height := rowPadding*2 + float64(len(name))*12 + float64(len(details))*10
+		if r.needsPage(l, height) {
+			r.newPage(l)
+			r.drawTableHeader(l)
+		}
+
+		y := l.y + rowPadding + 9
+		l.page.TextRight(right-quantityColumn, y, pdf.Helvetica, 9.5, pdf.Black, strconv.Itoa(item.Quantity))
+		l.page.TextRight(right-unitPriceColumn, y, pdf.Helvetica, 9.5, pdf.Black, item.UnitPrice.Display())
+		l.page.TextRight(right-taxColumn, y, pdf.Helvetica, 9.5, pdf.Black, itemTax(item))
+		l.page.TextRight(right-rowPadding, y, pdf.Helvetica, 9.5, pdf.Black, item.TotalPrice.Display())
+		for _, line := range name {
+			l.page.Text(pageMargin+rowPadding, y, pdf.Helvetica, 9.5, pdf.Black, line)
+			y += 12
+		}
+		for _, line := range details {
+			l.page.Text(pageMargin+rowPadding, y-1, pdf.Helvetica, 8, mutedColor, line)
+			y += 10
+		}
+		l.y += height
+		l.page.Line(pageMargin, l.y, right, l.y, 0.5, ruleColor)
+	}
+}
+
+// itemDetails describes an item's variant and discount below its name
+// Attributes are sorted by name, so the output doesn't depend on map order
+func itemDetails(item InvoiceItem) string {
+	var parts []string
+	if item.SKU != "" {
+		parts = append(parts, "SKU "+item.SKU)
+	}
+	names := make([]string, 0, len(item.Attributes))
+	for name := range item.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+": "+item.Attributes[name])
+	}
+	if !item.Discount.IsZero() {
+		parts = append(parts, "Discount "+item.Discount.Neg().Display())
+	}
+	return strings.Join(parts, " | ")
+}
+
+// itemTax formats the tax rate of an item, e.g. "20%", or "exempt"
+func itemTax(item InvoiceItem) string {
+	if item.Tax == nil {
+		return "-"
+	}
+	if item.Tax.Exempt {
+		return "exempt"
+	}
+	return formatPercent(item.Tax.Rate)
+}
+
+// formatPercent formats a rate as a percentage, e.g. 0.08875 as "8.875%"
+func formatPercent(rate money.Rate) string {
+	// Rates are exact millionths, so a percentage has at most four decimals
+	percent := strconv.FormatInt(int64(rate)/10000, 10)
+	if fraction := int64(rate) % 10000; fraction != 0 {
+		if fraction < 0 {
+			fraction = -fraction
+		}
+		percent += "." + strings.TrimRight(fmt.Sprintf("%04d", fraction), "0")
+	}
+	return percent + "%"
+}
+
+// drawTotals draws the discounts, subtotal, tax and total below the items
+func (r *PDFRenderer) drawTotals(l *invoiceLayout, invoice *Invoice) {
+	right := r.size.Width - pageMargin
+	var rows [][2]string
+	if !invoice.Discount.IsZero() && invoice.Discount.SameCurrency(invoice.Subtotal) {
+		rows = append(rows, [2]string{"Items", invoice.Subtotal.Add(invoice.Discount).Display()})
+		for _, discount := range invoice.Discounts {
+			label := discount.Name
+			if discount.Code != "" {
+				label += " (" + discount.Code + ")"
+			}
+			rows = append(rows, [2]string{label, discount.Amount.Neg().Display()})
+		}
+		if len(invoice.Discounts) == 0 {
+			rows = append(rows, [2]string{"Discount", invoice.Discount.Neg().Display()})
+		}
+	}
+	rows = append(rows, [2]string{"Subtotal", invoice.Subtotal.Display()}, [2]string{"Tax", invoice.Tax.Display()})
+
+	// Paid, credited or late invoices also show late fees, what was received and what is still owed or credited
+	var payments [][2]string
+	if !invoice.LateFees.IsZero() {
+		payments = append(payments, [2]string{"Late fees", invoice.LateFees.Display()})
+	}
+	if !invoice.Credited.IsZero() {
+		payments = append(payments, [2]string{"Credit notes", invoice.Credited.Neg().Display()})
+	}
+	if !invoice.AmountPaid.IsZero() {
+		payments = append(payments, [2]string{"Paid", invoice.AmountPaid.Neg().Display()})
+	}
+	if len(payments) > 0 {
+		if !invoice.Credit.IsZero() {
+			payments = append(payments, [2]string{"Credit", invoice.Credit.Display()})
+		} else {
+			payments = append(payments, [2]string{"Balance due", invoice.BalanceDue.Display()})
+		}
+	}
+
+	height := 14 + float64(len(rows)+len(payments))*15 + 30
+	if r.needsPage(l, height) {
+		r.newPage(l)
+	}
+	y := l.y + 20
+	labelWidth := 200.0
+	for _, row := range rows {
+		label := pdf.Truncate(pdf.Helvetica, 9.5, row[0], labelWidth-100)
+		l.page.Text(right-labelWidth, y, pdf.Helvetica, 9.5, mutedColor, label)
+		l.page.TextRight(right-rowPadding, y, pdf.Helvetica, 9.5, pdf.Black, row[1])
+		y += 15
+	}
+	l.page.Line(right-labelWidth, y-6, right, y-6, 1, r.accent)
+	y += 10
+	l.page.Text(right-labelWidth, y, pdf.HelveticaBold, 12, r.accent, "Total")
+	l.page.TextRight(right-rowPadding, y, pdf.HelveticaBold, 12, r.accent, invoice.Total.Display())
+	for _, row := range payments {
+		y += 16
+		l.page.Text(right-labelWidth, y, pdf.Helvetica, 9.5, mutedColor, row[0])
+		l.page.TextRight(right-rowPadding, y, pdf.Helvetica, 9.5, pdf.Black, row[1])
+	}
+	l.y = y + 10
+}
+
+// drawNotes draws the exchange rates the order was converted at and the branding notes
+func (r *PDFRenderer) drawNotes(l *invoiceLayout, invoice *Invoice, notes string) {
+	width := r.size.Width - 2*pageMargin
+	var lines []string
+	for _, rate := range invoice.ExchangeRates {
+		lines = append(lines, fmt.Sprintf("Converted at 1 %s = %s %s (rate of %s)", rate.Base, rate.Rate, rate.Quote, rate.EffectiveDate))
+	}
+	if strings.TrimSpace(notes) != "" {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, pdf.WrapText(pdf.Helvetica, 9, strings.TrimSpace(notes), width)...)
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	l.y += 20
+	for _, line := range lines {
+		if r.needsPage(l, 12) {
+			r.newPage(l)
+		}
+		l.y += 12
+		l.page.Text(pageMargin, l.y, pdf.Helvetica, 9, mutedColor, line)
+	}
+}
+
+// drawFooters draws the footer text and page numbers on every page once the page count is known
+func (r *PDFRenderer) drawFooters(doc *pdf.Document, footer string) {
+	pages := doc.Pages()
+	right := r.size.Width - pageMargin
+	y := r.size.Height - 30
+	pageLabelWidth := 70.0
+	footer = pdf.Truncate(pdf.Helvetica, 8, strings.Join(strings.Fields(footer), " "), right-pageMargin-pageLabelWidth)
+	for i, page := range pages {
+		page.Line(pageMargin, y-12, right, y-12, 0.5, ruleColor)
+		page.Text(pageMargin, y, pdf.Helvetica, 8, mutedColor, footer)
+		page.TextRight(right, y, pdf.Helvetica, 8, mutedColor, fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+	}
+}
+
+// needsPage reports whether content of a height no longer fits on the current page
+func (r *PDFRenderer) needsPage(l *invoiceLayout, height float64) bool {
+	return l.y+height > r.size.Height-footerHeight
+}
+
+// newPage continues the invoice on a new page below a thin accent bar
+func (r *PDFRenderer) newPage(l *invoiceLayout) {
+	l.page = l.doc.AddPage()
+	l.page.Rect(0, 0, r.size.Width, 4, r.accent)
+	l.y = pageMargin
+}
+
+// formatDate formats an RFC3339 timestamp with the branding's date format
+// Dates keep the time zone they were stored with, so rendering doesn't depend on the server's zone
+func (r *PDFRenderer) formatDate(value string) string {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return value
+	}
+	return t.Format(r.branding.DateFormat)
+}
+
+// execute runs a branding template
+func execute(tmpl *template.Template, data TemplateData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render invoice %s template: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,122 @@
+package invoice
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
+)
+
+// update rewrites the golden files from the current output: go test ./invoice -run Golden -update
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// goldenBranding is a branding using every option, so changes to any of them show in the golden file
+func goldenBranding() Branding {
+	return Branding{
+		CompanyName: "Golden Goods Ltd",
+		AccentColor: "#7a1f3d",
+		PageSize:    "Letter",
+		DateFormat:  "2 Jan 2006",
+		Title:       "Tax Invoice",
+		Notes:       "Please pay {{.Total}} by {{.DueDate}}, quoting {{.InvoiceNumber}}.",
+		Footer:      "{{.CompanyName}} - order {{.OrderID}}",
+	}
+}
+
+// goldenInvoice is a fixed invoice with a variant, a discount and taxed lines
+func goldenInvoice() *Invoice {
+	vat := money.MustParseRate("0.2")
+	return &Invoice{
+		InvoiceID:     1,
+		OrderID:       42,
+		UserID:        7,
+		Username:      "alice",
+		InvoiceNumber: "INV-2025-000042",
+		Currency:      "EUR",
+		Status:        StatusSent,
+		CreatedAt:     "2025-03-14T09:30:00Z",
+		DueDate:       "2025-04-13T09:30:00Z",
+		Seller: Party{
+			Name:    "Golden Goods Ltd",
+			Address: Address{Lines: []string{"1 Market Street"}, City: "Dublin", PostalZone: "D01 F5P2", Country: "IE"},
+			TaxID:   "IE1234567T",
+			Email:   "billing@golden.example",
+		},
+		Buyer: Party{
+			Name:    "Alice Example",
+			Address: Address{Lines: []string{"12 Rue de la Paix"}, City: "Paris", PostalZone: "75002", Country: "FR"},
+			Email:   "alice@example.com",
+		},
+		Items: []InvoiceItem{
+			{
+				ProductID:   3,
+				VariantID:   31,
+				SKU:         "TEE-RED-M",
+				Attributes:  map[string]string{"colour": "red", "size": "M"},
+				ProductName: "T-shirt",
+				Quantity:    2,
+				UnitPrice:   money.MustParse("20.00", "EUR"),
+				TotalPrice:  money.MustParse("40.00", "EUR"),
+				Discount:    money.MustParse("4.00", "EUR"),
+				Tax: &tax.Breakdown{Rule: "standard", Rate: vat,
+					Net: money.MustParse("36.00", "EUR"), Tax: money.MustParse("7.20", "EUR"), Gross: money.MustParse("43.20", "EUR")},
+			},
+			{
+				ProductID:   5,
+				ProductName: "Gift card",
+				Description: "Redeemable online",
+				Quantity:    1,
+				UnitPrice:   money.MustParse("25.00", "EUR"),
+				TotalPrice:  money.MustParse("25.00", "EUR"),
+				Discount:    money.Zero("EUR"),
+				Tax: &tax.Breakdown{Rule: "exempt", Exempt: true,
+					Net: money.MustParse("25.00", "EUR"), Tax: money.Zero("EUR"), Gross: money.MustParse("25.00", "EUR")},
+			},
+		},
+		Discounts: []promotion.Discount{
+			{PromotionID: 9, Code: "SPRING10", Name: "Spring sale", Type: "percentage", Amount: money.MustParse("4.00", "EUR")},
+		},
+		Discount: money.MustParse("4.00", "EUR"),
+		Subtotal: money.MustParse("61.00", "EUR"),
+		Tax:      money.MustParse("7.20", "EUR"),
+		Total:    money.MustParse("68.20", "EUR"),
+	}
+}
+
+// TestRenderGolden checks that rendering is deterministic and matches the checked-in PDF
+func TestRenderGolden(t *testing.T) {
+	renderer, err := NewPDFRenderer(goldenBranding())
+	if err != nil {
+		t.Fatalf("NewPDFRenderer: %v", err)
+	}
+	first, err := renderer.Render(goldenInvoice())
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	second, err := renderer.Render(goldenInvoice())
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatal("rendering the same invoice twice produced different bytes")
+	}
+
+	golden := filepath.Join("testdata", "invoice.golden.pdf")
+	if *update {
+		if err := os.WriteFile(golden, first, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(first, want) {
+		t.Errorf("rendered PDF differs from %s; if the change is intended, run go test ./invoice -run Golden -update", golden)
+	}
+}
@@ -4,60 +4,87 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/numbering"
 )
 
 // ClickHouseRepository implements Repository for ClickHouse database
 type ClickHouseRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	ids     idgen.Generator
+	numbers *numbering.ClickHouseSequences
 }
 
 // NewClickHouseRepository creates a new ClickHouse invoice repository
-func NewClickHouseRepository(db *sql.DB) InvoiceRepository {
-	return &ClickHouseRepository{db: db}
+// ClickHouse has no auto-increment, so invoice IDs come from the shared ID generator
+// Invoice and credit note numbers follow the highest stored ones, see numbering.ClickHouseSequences
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) InvoiceRepository {
+	return &ClickHouseRepository{db: db, ids: ids, numbers: numbering.NewClickHouseSequences(db)}
 }
 
-func (r *ClickHouseRepository) Create(ctx context.Context, invoice *Invoice) error {
-	// For ClickHouse, we'll generate a simple incremental ID
-	// First get the current max ID
-	var maxID uint64
-	maxQuery := "SELECT COALESCE(MAX(invoice_id), 0) FROM invoices"
-	err := r.db.QueryRowContext(ctx, maxQuery).Scan(&maxID)
-	if err != nil {
-		maxID = 0 // Start from 0 if no invoices exist
-	}
-	
-	// Set the next ID
-	invoice.InvoiceID = maxID + 1
+func (r *ClickHouseRepository) Create(ctx context.Context, invoice *Invoice, series numbering.Series) error {
+	invoice.InvoiceID = r.ids.NextID()
 
 	// Convert items to JSON string
 	itemsJSON, err := json.Marshal(invoice.Items)
 	if err != nil {
 		return err
 	}
+	ratesJSON, err := encodeExchangeRates(invoice.ExchangeRates)
+	if err != nil {
+		return err
+	}
+	discountsJSON, err := encodeDiscounts(invoice.Discounts)
+	if err != nil {
+		return err
+	}
+	sellerJSON, err := encodeParty(invoice.Seller)
+	if err != nil {
+		return err
+	}
+	buyerJSON, err := encodeParty(invoice.Buyer)
+	if err != nil {
+		return err
+	}
 
 	query := `
-		INSERT INTO invoices (invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	
-	_, err = r.db.ExecContext(ctx, query, 
-		invoice.InvoiceID,
-		invoice.OrderID, 
-		invoice.UserID, 
-		invoice.Username,
-		invoice.InvoiceNumber, 
-		string(itemsJSON),
-		invoice.Subtotal,
-		invoice.Tax,
-		invoice.Total,
-		invoice.Status, 
-		invoice.CreatedAt, 
-		invoice.DueDate)
-	return err
+		INSERT INTO invoices (invoice_id, order_id, user_id, username, invoice_number, number_key, number_sequence, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	return r.numbers.Allocate(ctx, "invoices", series, func(number string, sequence uint64) error {
+		invoice.InvoiceNumber = number
+		_, err := r.db.ExecContext(ctx, query,
+			invoice.InvoiceID,
+			invoice.OrderID,
+			invoice.UserID,
+			invoice.Username,
+			invoice.InvoiceNumber,
+			series.Key,
+			sequence,
+			string(itemsJSON),
+			invoice.Currency,
+			ratesJSON,
+			discountsJSON,
+			invoice.Discount,
+			invoice.Subtotal,
+			invoice.Tax,
+			invoice.Total,
+			invoice.Status,
+			invoice.CreatedAt,
+			invoice.DueDate,
+			sellerJSON,
+			buyerJSON)
+		return err
+	})
 }
 
 func (r *ClickHouseRepository) GetByID(ctx context.Context, invoiceID uint64) (*Invoice, error) {
 	query := `
-		SELECT invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date 
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
 		FROM invoices WHERE invoice_id = ?`
 	
 	return r.scanInvoice(ctx, query, invoiceID)
@@ -65,7 +92,7 @@ func (r *ClickHouseRepository) GetByID(ctx context.Context, invoiceID uint64) (*
 
 func (r *ClickHouseRepository) GetByOrderID(ctx context.Context, orderID uint64) (*Invoice, error) {
 	query := `
-		SELECT invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date 
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
 		FROM invoices WHERE order_id = ?`
 	
 	return r.scanInvoice(ctx, query, orderID)
@@ -73,7 +100,7 @@ func (r *ClickHouseRepository) GetByOrderID(ctx context.Context, orderID uint64)
 
 func (r *ClickHouseRepository) GetByUserID(ctx context.Context, userID uint64) ([]Invoice, error) {
 	query := `
-		SELECT invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date 
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
 		FROM invoices WHERE user_id = ? ORDER BY created_at DESC`
 	
 	rows, err := r.db.QueryContext(ctx, query, userID)
@@ -101,6 +128,442 @@ func (r *ClickHouseRepository) UpdateStatus(ctx context.Context, invoiceID uint6
 	return err
 }
 
+// ensurePaymentsTable creates the invoice_payments table if it doesn't exist
+// The invoices table itself is managed manually, see docs/MIGRATION.md
+func (r *ClickHouseRepository) ensurePaymentsTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_payments (
+			payment_id UInt64,
+			invoice_id UInt64,
+			amount Decimal(18, 2),
+			currency String,
+			method String,
+			reference String DEFAULT '',
+			paid_at DateTime64(3),
+			created_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY (invoice_id, paid_at, payment_id)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) CreatePayment(ctx context.Context, payment *Payment) error {
+	if err := r.ensurePaymentsTable(ctx); err != nil {
+		return err
+	}
+	payment.PaymentID = r.ids.NextID()
+	query := `
+		INSERT INTO invoice_payments (payment_id, invoice_id, amount, currency, method, reference, paid_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, payment.PaymentID, payment.InvoiceID, payment.Amount, payment.Amount.Currency, payment.Method, payment.Reference, payment.PaidAt, payment.CreatedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) GetPayments(ctx context.Context, invoiceID uint64) ([]Payment, error) {
+	if err := r.ensurePaymentsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT payment_id, invoice_id, amount, currency, method, reference, paid_at, created_at
+		FROM invoice_payments WHERE invoice_id = ? ORDER BY paid_at, payment_id`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []Payment
+	for rows.Next() {
+		var payment Payment
+		var currency string
+		if err := rows.Scan(&payment.PaymentID, &payment.InvoiceID, &payment.Amount, &currency, &payment.Method, &payment.Reference, &payment.PaidAt, &payment.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &payment.Amount)
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// ensureCreditNotesTable creates the credit_notes table if it doesn't exist
+func (r *ClickHouseRepository) ensureCreditNotesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS credit_notes (
+			credit_note_id UInt64,
+			credit_note_number String,
+			number_key String,
+			number_sequence UInt64,
+			invoice_id UInt64,
+			invoice_number String,
+			order_id UInt64,
+			user_id UInt64,
+			items String,
+			currency String,
+			subtotal Decimal(18, 2),
+			tax Decimal(18, 2),
+			total Decimal(18, 2),
+			reason String DEFAULT '',
+			refund_payment_id UInt64 DEFAULT 0,
+			created_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY (invoice_id, credit_note_id)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) CreateCreditNote(ctx context.Context, note *CreditNote, series numbering.Series) error {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return err
+	}
+	itemsJSON, err := json.Marshal(note.Items)
+	if err != nil {
+		return err
+	}
+	note.CreditNoteID = r.ids.NextID()
+	query := `
+		INSERT INTO credit_notes (credit_note_id, credit_note_number, number_key, number_sequence, invoice_id, invoice_number, order_id, user_id, items, currency, subtotal, tax, total, reason, refund_payment_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	return r.numbers.Allocate(ctx, "credit_notes", series, func(number string, sequence uint64) error {
+		note.CreditNoteNumber = number
+		_, err := r.db.ExecContext(ctx, query,
+			note.CreditNoteID, note.CreditNoteNumber, series.Key, sequence, note.InvoiceID, note.InvoiceNumber, note.OrderID, note.UserID, string(itemsJSON),
+			note.Currency, note.Subtotal, note.Tax, note.Total, note.Reason, note.RefundPaymentID, note.CreatedAt,
+		)
+		return err
+	})
+}
+
+func (r *ClickHouseRepository) GetCreditNote(ctx context.Context, creditNoteID uint64) (*CreditNote, error) {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return nil, err
+	}
+	notes, err := r.queryCreditNotes(ctx, "SELECT "+creditNoteColumns+" FROM credit_notes WHERE credit_note_id = ?", creditNoteID)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, fmt.Errorf("credit note not found")
+	}
+	return &notes[0], nil
+}
+
+func (r *ClickHouseRepository) GetCreditNotesByInvoiceID(ctx context.Context, invoiceID uint64) ([]CreditNote, error) {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryCreditNotes(ctx, "SELECT "+creditNoteColumns+" FROM credit_notes WHERE invoice_id = ? ORDER BY created_at, credit_note_id", invoiceID)
+}
+
+func (r *ClickHouseRepository) GetCreditNotesByUserID(ctx context.Context, userID uint64) ([]CreditNote, error) {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryCreditNotes(ctx, "SELECT "+creditNoteColumns+" FROM credit_notes WHERE user_id = ? ORDER BY created_at DESC, credit_note_id DESC", userID)
+}
+
+// queryCreditNotes runs a query selecting creditNoteColumns
+func (r *ClickHouseRepository) queryCreditNotes(ctx context.Context, query string, arg interface{}) ([]CreditNote, error) {
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []CreditNote
+	for rows.Next() {
+		var itemsJSON string
+		note, err := scanCreditNote(rows, &itemsJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(itemsJSON), &note.Items); err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+	return notes, rows.Err()
+}
+
+func (r *ClickHouseRepository) GetUnversioned(ctx context.Context) ([]Invoice, error) {
+	query := `
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
+		FROM invoices WHERE version = 0 ORDER BY invoice_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		invoice, err := r.scanInvoiceFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, rows.Err()
+}
+
+func (r *ClickHouseRepository) GetPastDue(ctx context.Context, now time.Time) ([]Invoice, error) {
+	query := `
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
+		FROM invoices WHERE due_date < ? AND status IN (?, ?, ?) ORDER BY due_date, invoice_id`
+
+	rows, err := r.db.QueryContext(ctx, query, now, StatusSent, StatusPartiallyPaid, StatusOverdue)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		invoice, err := r.scanInvoiceFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// ensureRemindersTable creates the invoice_reminders table if it doesn't exist
+func (r *ClickHouseRepository) ensureRemindersTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_reminders (
+			reminder_id UInt64,
+			invoice_id UInt64,
+			day Int64,
+			late_fee Decimal(18, 2),
+			currency String,
+			sent_at DateTime64(3),
+			error String DEFAULT ''
+		) ENGINE = MergeTree()
+		ORDER BY (invoice_id, day)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// CreateReminder checks for an existing reminder first; without unique constraints in ClickHouse,
+// only one instance may send reminders
+func (r *ClickHouseRepository) CreateReminder(ctx context.Context, reminder *Reminder) error {
+	reminders, err := r.GetReminders(ctx, reminder.InvoiceID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range reminders {
+		if existing.Day == reminder.Day {
+			return fmt.Errorf("reminder of day %d already exists", reminder.Day)
+		}
+	}
+	reminder.ReminderID = r.ids.NextID()
+	query := `
+		INSERT INTO invoice_reminders (reminder_id, invoice_id, day, late_fee, currency, sent_at, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err = r.db.ExecContext(ctx, query,
+		reminder.ReminderID, reminder.InvoiceID, int64(reminder.Day), reminder.LateFee, reminder.LateFee.Currency, reminder.SentAt, reminder.Error,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) UpdateReminder(ctx context.Context, reminder *Reminder) error {
+	if err := r.ensureRemindersTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE invoice_reminders UPDATE error = ? WHERE reminder_id = ?", reminder.Error, reminder.ReminderID)
+	return err
+}
+
+func (r *ClickHouseRepository) GetReminders(ctx context.Context, invoiceID uint64) ([]Reminder, error) {
+	if err := r.ensureRemindersTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT reminder_id, invoice_id, day, late_fee, currency, sent_at, error
+		FROM invoice_reminders WHERE invoice_id = ? ORDER BY day`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+	for rows.Next() {
+		var reminder Reminder
+		var day int64
+		var currency string
+		if err := rows.Scan(&reminder.ReminderID, &reminder.InvoiceID, &day, &reminder.LateFee, &currency, &reminder.SentAt, &reminder.Error); err != nil {
+			return nil, err
+		}
+		reminder.Day = int(day)
+		money.WithCurrency(currency, &reminder.LateFee)
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// ensureDeliveriesTable creates the invoice_deliveries table if it doesn't exist
+func (r *ClickHouseRepository) ensureDeliveriesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_deliveries (
+			delivery_id UInt64,
+			invoice_id UInt64,
+			recipient String,
+			attachment String DEFAULT '',
+			status String,
+			error String DEFAULT '',
+			attempted_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY (invoice_id, attempted_at, delivery_id)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	if err := r.ensureDeliveriesTable(ctx); err != nil {
+		return err
+	}
+	delivery.DeliveryID = r.ids.NextID()
+	query := `
+		INSERT INTO invoice_deliveries (delivery_id, invoice_id, recipient, attachment, status, error, attempted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		delivery.DeliveryID, delivery.InvoiceID, delivery.Recipient, delivery.Attachment, delivery.Status, delivery.Error, delivery.AttemptedAt,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) GetDeliveries(ctx context.Context, invoiceID uint64) ([]Delivery, error) {
+	if err := r.ensureDeliveriesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT delivery_id, invoice_id, recipient, attachment, status, error, attempted_at
+		FROM invoice_deliveries WHERE invoice_id = ? ORDER BY attempted_at, delivery_id`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var delivery Delivery
+		if err := rows.Scan(&delivery.DeliveryID, &delivery.InvoiceID, &delivery.Recipient, &delivery.Attachment, &delivery.Status, &delivery.Error, &delivery.AttemptedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ensureVersionsTable creates the invoice_versions table if it doesn't exist
+// Content is kept as a string, so it is the exact JSON that was hashed
+func (r *ClickHouseRepository) ensureVersionsTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_versions (
+			invoice_id UInt64,
+			version Int64,
+			reason String,
+			content_hash String,
+			content String,
+			created_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY (invoice_id, version)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// SaveVersion checks the stored version first; without transactions in ClickHouse, a version that
+// two instances save at once may be recorded twice
+// The invoice is updated synchronously, so it isn't read at its previous version afterwards.
+func (r *ClickHouseRepository) SaveVersion(ctx context.Context, invoice *Invoice, version *InvoiceVersion) error {
+	stored, err := r.GetByID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return err
+	}
+	if stored.Version != version.Version-1 {
+		return fmt.Errorf("invoice version %d already exists", version.Version)
+	}
+	if err := r.ensureVersionsTable(ctx); err != nil {
+		return err
+	}
+
+	itemsJSON, err := json.Marshal(invoice.Items)
+	if err != nil {
+		return err
+	}
+	ratesJSON, err := encodeExchangeRates(invoice.ExchangeRates)
+	if err != nil {
+		return err
+	}
+	discountsJSON, err := encodeDiscounts(invoice.Discounts)
+	if err != nil {
+		return err
+	}
+	sellerJSON, err := encodeParty(invoice.Seller)
+	if err != nil {
+		return err
+	}
+	buyerJSON, err := encodeParty(invoice.Buyer)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO invoice_versions (invoice_id, version, reason, content_hash, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := r.db.ExecContext(ctx, query,
+		version.InvoiceID, int64(version.Version), version.Reason, version.ContentHash, string(version.Content), version.CreatedAt,
+	); err != nil {
+		return err
+	}
+	update := `
+		ALTER TABLE invoices UPDATE user_id = ?, username = ?, items = ?, currency = ?, exchange_rates = ?, discounts = ?,
+			discount = ?, subtotal = ?, tax = ?, total = ?, seller = ?, buyer = ?, version = ?, content_hash = ?
+		WHERE invoice_id = ? SETTINGS mutations_sync = 1`
+	_, err = r.db.ExecContext(ctx, update,
+		invoice.UserID, invoice.Username, string(itemsJSON), invoice.Currency, ratesJSON, discountsJSON,
+		invoice.Discount, invoice.Subtotal, invoice.Tax, invoice.Total, sellerJSON, buyerJSON, int64(invoice.Version), invoice.ContentHash,
+		invoice.InvoiceID,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) GetVersions(ctx context.Context, invoiceID uint64) ([]InvoiceVersion, error) {
+	if err := r.ensureVersionsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT invoice_id, version, reason, content_hash, content, created_at
+		FROM invoice_versions WHERE invoice_id = ? ORDER BY version`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []InvoiceVersion
+	for rows.Next() {
+		var version InvoiceVersion
+		var number int64
+		var content string
+		if err := rows.Scan(&version.InvoiceID, &number, &version.Reason, &version.ContentHash, &content, &version.CreatedAt); err != nil {
+			return nil, err
+		}
+		version.Version = int(number)
+		version.Content = json.RawMessage(content)
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
 // Helper method to scan a single invoice
 func (r *ClickHouseRepository) scanInvoice(ctx context.Context, query string, arg interface{}) (*Invoice, error) {
 	row := r.db.QueryRowContext(ctx, query, arg)
@@ -110,7 +573,8 @@ func (r *ClickHouseRepository) scanInvoice(ctx context.Context, query string, ar
 // Helper method to scan invoice from a single row
 func (r *ClickHouseRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error) {
 	var invoice Invoice
-	var itemsJSON string
+	var itemsJSON, ratesJSON, discountsJSON, sellerJSON, buyerJSON string
+	var version int64
 
 	err := row.Scan(
 		&invoice.InvoiceID,
@@ -119,12 +583,20 @@ func (r *ClickHouseRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error
 		&invoice.Username,
 		&invoice.InvoiceNumber,
 		&itemsJSON,
+		&invoice.Currency,
+		&ratesJSON,
+		&discountsJSON,
+		&invoice.Discount,
 		&invoice.Subtotal,
 		&invoice.Tax,
 		&invoice.Total,
 		&invoice.Status,
 		&invoice.CreatedAt,
 		&invoice.DueDate,
+		&sellerJSON,
+		&buyerJSON,
+		&version,
+		&invoice.ContentHash,
 	)
 	
 	if err != nil {
@@ -137,6 +609,21 @@ func (r *ClickHouseRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error
 			return nil, err
 		}
 	}
+	if ratesJSON != "" {
+		if err := json.Unmarshal([]byte(ratesJSON), &invoice.ExchangeRates); err != nil {
+			return nil, err
+		}
+	}
+	if discountsJSON != "" {
+		if err := json.Unmarshal([]byte(discountsJSON), &invoice.Discounts); err != nil {
+			return nil, err
+		}
+	}
+	if err := invoice.decodeParties([]byte(sellerJSON), []byte(buyerJSON)); err != nil {
+		return nil, err
+	}
+	invoice.Version = int(version)
+	invoice.applyCurrency()
 
 	return &invoice, nil
 }
@@ -144,7 +631,8 @@ func (r *ClickHouseRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error
 // Helper method to scan invoice from rows
 func (r *ClickHouseRepository) scanInvoiceFromRows(rows *sql.Rows) (*Invoice, error) {
 	var invoice Invoice
-	var itemsJSON string
+	var itemsJSON, ratesJSON, discountsJSON, sellerJSON, buyerJSON string
+	var version int64
 
 	err := rows.Scan(
 		&invoice.InvoiceID,
@@ -153,12 +641,20 @@ func (r *ClickHouseRepository) scanInvoiceFromRows(rows *sql.Rows) (*Invoice, er
 		&invoice.Username,
 		&invoice.InvoiceNumber,
 		&itemsJSON,
+		&invoice.Currency,
+		&ratesJSON,
+		&discountsJSON,
+		&invoice.Discount,
 		&invoice.Subtotal,
 		&invoice.Tax,
 		&invoice.Total,
 		&invoice.Status,
 		&invoice.CreatedAt,
 		&invoice.DueDate,
+		&sellerJSON,
+		&buyerJSON,
+		&version,
+		&invoice.ContentHash,
 	)
 	
 	if err != nil {
@@ -171,6 +667,21 @@ func (r *ClickHouseRepository) scanInvoiceFromRows(rows *sql.Rows) (*Invoice, er
 			return nil, err
 		}
 	}
+	if ratesJSON != "" {
+		if err := json.Unmarshal([]byte(ratesJSON), &invoice.ExchangeRates); err != nil {
+			return nil, err
+		}
+	}
+	if discountsJSON != "" {
+		if err := json.Unmarshal([]byte(discountsJSON), &invoice.Discounts); err != nil {
+			return nil, err
+		}
+	}
+	if err := invoice.decodeParties([]byte(sellerJSON), []byte(buyerJSON)); err != nil {
+		return nil, err
+	}
+	invoice.Version = int(version)
+	invoice.applyCurrency()
 
 	return &invoice, nil
 }
\ No newline at end of file
@@ -4,16 +4,24 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/numbering"
 )
 
 // PostgresRepository implements Repository for PostgreSQL database
 type PostgresRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	numbers *numbering.PostgresSequences
 }
 
 // NewPostgresRepository creates a new PostgreSQL invoice repository
+// Invoice and credit note numbers are allocated in the transaction that stores them, so they have no gaps
 func NewPostgresRepository(db *sql.DB) InvoiceRepository {
-	return &PostgresRepository{db: db}
+	return &PostgresRepository{db: db, numbers: numbering.NewPostgresSequences(db)}
 }
 
 // ensureInvoicesTable creates the invoices table if it doesn't exist
@@ -26,6 +34,10 @@ func (r *PostgresRepository) ensureInvoicesTable(ctx context.Context) error {
 			username TEXT NOT NULL,
 			invoice_number TEXT NOT NULL UNIQUE,
 			items JSONB,
+			currency TEXT NOT NULL DEFAULT 'USD',
+			exchange_rates JSONB,
+			discounts JSONB,
+			discount DECIMAL(10,2) DEFAULT 0.00,
 			subtotal DECIMAL(10,2) DEFAULT 0.00,
 			tax DECIMAL(10,2) DEFAULT 0.00,
 			total DECIMAL(10,2) DEFAULT 0.00,
@@ -33,11 +45,49 @@ func (r *PostgresRepository) ensureInvoicesTable(ctx context.Context) error {
 			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
 			due_date TIMESTAMP NOT NULL
 		)`
-	_, err := r.db.ExecContext(ctx, query)
-	return err
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Create the invoice_payments table, the ledger of payments received per invoice
+	paymentsQuery := `
+		CREATE TABLE IF NOT EXISTS invoice_payments (
+			payment_id SERIAL PRIMARY KEY,
+			invoice_id BIGINT NOT NULL REFERENCES invoices(invoice_id),
+			amount DECIMAL(10,2) NOT NULL,
+			currency TEXT NOT NULL,
+			method TEXT NOT NULL,
+			reference TEXT NOT NULL DEFAULT '',
+			paid_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	if _, err := r.db.ExecContext(ctx, paymentsQuery); err != nil {
+		return err
+	}
+
+	// Add columns introduced after the table was first created
+	migrations := []string{
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD'",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS exchange_rates JSONB",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS discounts JSONB",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS discount DECIMAL(10,2) DEFAULT 0.00",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS seller JSONB",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS buyer JSONB",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0",
+		"ALTER TABLE invoices ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT ''",
+		"CREATE INDEX IF NOT EXISTS idx_invoice_payments_invoice ON invoice_payments (invoice_id, paid_at)",
+		// A payment reported twice at the same time, e.g. by webhook and by hand, is only stored once
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_payments_reference ON invoice_payments (invoice_id, reference) WHERE reference <> ''",
+	}
+	for _, migration := range migrations {
+		if _, err := r.db.ExecContext(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *PostgresRepository) Create(ctx context.Context, invoice *Invoice) error {
+func (r *PostgresRepository) Create(ctx context.Context, invoice *Invoice, series numbering.Series) error {
 	if err := r.ensureInvoicesTable(ctx); err != nil {
 		return err
 	}
@@ -47,25 +97,48 @@ func (r *PostgresRepository) Create(ctx context.Context, invoice *Invoice) error
 	if err != nil {
 		return err
 	}
+	ratesJSON, err := encodeExchangeRates(invoice.ExchangeRates)
+	if err != nil {
+		return err
+	}
+	discountsJSON, err := encodeDiscounts(invoice.Discounts)
+	if err != nil {
+		return err
+	}
+	sellerJSON, err := encodeParty(invoice.Seller)
+	if err != nil {
+		return err
+	}
+	buyerJSON, err := encodeParty(invoice.Buyer)
+	if err != nil {
+		return err
+	}
 
 	query := `
-		INSERT INTO invoices (order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING invoice_id`
+		INSERT INTO invoices (order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer) 
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::jsonb, NULLIF($8, '')::jsonb, $9, $10, $11, $12, $13, $14, $15, $16::jsonb, $17::jsonb) RETURNING invoice_id`
 	
-	err = r.db.QueryRowContext(ctx, query, 
-		invoice.OrderID, 
-		invoice.UserID, 
-		invoice.Username,
-		invoice.InvoiceNumber, 
-		itemsJSON,
-		invoice.Subtotal,
-		invoice.Tax,
-		invoice.Total,
-		invoice.Status, 
-		invoice.CreatedAt, 
-		invoice.DueDate).Scan(&invoice.InvoiceID)
-		
-	return err
+	return r.numbers.Allocate(ctx, series, func(tx *sql.Tx, number string, sequence uint64) error {
+		invoice.InvoiceNumber = number
+		return tx.QueryRowContext(ctx, query,
+			invoice.OrderID,
+			invoice.UserID,
+			invoice.Username,
+			invoice.InvoiceNumber,
+			itemsJSON,
+			invoice.Currency,
+			ratesJSON,
+			discountsJSON,
+			invoice.Discount,
+			invoice.Subtotal,
+			invoice.Tax,
+			invoice.Total,
+			invoice.Status,
+			invoice.CreatedAt,
+			invoice.DueDate,
+			sellerJSON,
+			buyerJSON).Scan(&invoice.InvoiceID)
+	})
 }
 
 func (r *PostgresRepository) GetByID(ctx context.Context, invoiceID uint64) (*Invoice, error) {
@@ -74,7 +147,7 @@ func (r *PostgresRepository) GetByID(ctx context.Context, invoiceID uint64) (*In
 	}
 
 	query := `
-		SELECT invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date 
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
 		FROM invoices WHERE invoice_id = $1`
 	
 	return r.scanInvoice(ctx, query, invoiceID)
@@ -86,7 +159,7 @@ func (r *PostgresRepository) GetByOrderID(ctx context.Context, orderID uint64) (
 	}
 
 	query := `
-		SELECT invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date 
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
 		FROM invoices WHERE order_id = $1`
 	
 	return r.scanInvoice(ctx, query, orderID)
@@ -98,7 +171,7 @@ func (r *PostgresRepository) GetByUserID(ctx context.Context, userID uint64) ([]
 	}
 
 	query := `
-		SELECT invoice_id, order_id, user_id, username, invoice_number, items, subtotal, tax, total, status, created_at, due_date 
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
 		FROM invoices WHERE user_id = $1 ORDER BY created_at DESC`
 	
 	rows, err := r.db.QueryContext(ctx, query, userID)
@@ -118,6 +191,32 @@ func (r *PostgresRepository) GetByUserID(ctx context.Context, userID uint64) ([]
 	return invoices, nil
 }
 
+func (r *PostgresRepository) GetUnversioned(ctx context.Context) ([]Invoice, error) {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
+		FROM invoices WHERE version = 0 ORDER BY invoice_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		invoice, err := r.scanInvoiceFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, rows.Err()
+}
+
 func (r *PostgresRepository) UpdateStatus(ctx context.Context, invoiceID uint64, status string) error {
 	if err := r.ensureInvoicesTable(ctx); err != nil {
 		return err
@@ -128,6 +227,414 @@ func (r *PostgresRepository) UpdateStatus(ctx context.Context, invoiceID uint64,
 	return err
 }
 
+func (r *PostgresRepository) CreatePayment(ctx context.Context, payment *Payment) error {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO invoice_payments (invoice_id, amount, currency, method, reference, paid_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (invoice_id, reference) WHERE reference <> '' DO NOTHING RETURNING payment_id`
+	err := r.db.QueryRowContext(ctx, query, payment.InvoiceID, payment.Amount, payment.Amount.Currency, payment.Method, payment.Reference, payment.PaidAt, payment.CreatedAt).Scan(&payment.PaymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("payment with reference '%s' already exists", payment.Reference)
+	}
+	return err
+}
+
+func (r *PostgresRepository) GetPayments(ctx context.Context, invoiceID uint64) ([]Payment, error) {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT payment_id, invoice_id, amount, currency, method, reference, paid_at, created_at
+		FROM invoice_payments WHERE invoice_id = $1 ORDER BY paid_at, payment_id`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []Payment
+	for rows.Next() {
+		var payment Payment
+		var currency string
+		if err := rows.Scan(&payment.PaymentID, &payment.InvoiceID, &payment.Amount, &currency, &payment.Method, &payment.Reference, &payment.PaidAt, &payment.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &payment.Amount)
+		payments = append(payments, payment)
+	}
+	return payments, rows.Err()
+}
+
+// ensureCreditNotesTable creates the credit_notes table if it doesn't exist
+func (r *PostgresRepository) ensureCreditNotesTable(ctx context.Context) error {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return err
+	}
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS credit_notes (
+			credit_note_id SERIAL PRIMARY KEY,
+			credit_note_number TEXT NOT NULL UNIQUE,
+			invoice_id BIGINT NOT NULL REFERENCES invoices(invoice_id),
+			invoice_number TEXT NOT NULL,
+			order_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			items JSONB NOT NULL,
+			currency TEXT NOT NULL,
+			subtotal DECIMAL(10,2) NOT NULL,
+			tax DECIMAL(10,2) NOT NULL,
+			total DECIMAL(10,2) NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			refund_payment_id BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_credit_notes_invoice ON credit_notes (invoice_id)",
+		"CREATE INDEX IF NOT EXISTS idx_credit_notes_user ON credit_notes (user_id)",
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateCreditNote(ctx context.Context, note *CreditNote, series numbering.Series) error {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return err
+	}
+	itemsJSON, err := json.Marshal(note.Items)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO credit_notes (credit_note_number, invoice_id, invoice_number, order_id, user_id, items, currency, subtotal, tax, total, reason, refund_payment_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING credit_note_id`
+	return r.numbers.Allocate(ctx, series, func(tx *sql.Tx, number string, sequence uint64) error {
+		note.CreditNoteNumber = number
+		return tx.QueryRowContext(ctx, query,
+			note.CreditNoteNumber, note.InvoiceID, note.InvoiceNumber, note.OrderID, note.UserID, itemsJSON,
+			note.Currency, note.Subtotal, note.Tax, note.Total, note.Reason, note.RefundPaymentID, note.CreatedAt,
+		).Scan(&note.CreditNoteID)
+	})
+}
+
+func (r *PostgresRepository) GetCreditNote(ctx context.Context, creditNoteID uint64) (*CreditNote, error) {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return nil, err
+	}
+	notes, err := r.queryCreditNotes(ctx, "SELECT "+creditNoteColumns+" FROM credit_notes WHERE credit_note_id = $1", creditNoteID)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, fmt.Errorf("credit note not found")
+	}
+	return &notes[0], nil
+}
+
+func (r *PostgresRepository) GetCreditNotesByInvoiceID(ctx context.Context, invoiceID uint64) ([]CreditNote, error) {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryCreditNotes(ctx, "SELECT "+creditNoteColumns+" FROM credit_notes WHERE invoice_id = $1 ORDER BY created_at, credit_note_id", invoiceID)
+}
+
+func (r *PostgresRepository) GetCreditNotesByUserID(ctx context.Context, userID uint64) ([]CreditNote, error) {
+	if err := r.ensureCreditNotesTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryCreditNotes(ctx, "SELECT "+creditNoteColumns+" FROM credit_notes WHERE user_id = $1 ORDER BY created_at DESC, credit_note_id DESC", userID)
+}
+
+// queryCreditNotes runs a query selecting creditNoteColumns
+func (r *PostgresRepository) queryCreditNotes(ctx context.Context, query string, arg interface{}) ([]CreditNote, error) {
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []CreditNote
+	for rows.Next() {
+		var itemsJSON []byte
+		note, err := scanCreditNote(rows, &itemsJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(itemsJSON, &note.Items); err != nil {
+			return nil, err
+		}
+		notes = append(notes, *note)
+	}
+	return notes, rows.Err()
+}
+
+func (r *PostgresRepository) GetPastDue(ctx context.Context, now time.Time) ([]Invoice, error) {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT invoice_id, order_id, user_id, username, invoice_number, items, currency, exchange_rates, discounts, discount, subtotal, tax, total, status, created_at, due_date, seller, buyer, version, content_hash
+		FROM invoices WHERE due_date < $1 AND status IN ($2, $3, $4) ORDER BY due_date, invoice_id`
+
+	rows, err := r.db.QueryContext(ctx, query, now, StatusSent, StatusPartiallyPaid, StatusOverdue)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invoices []Invoice
+	for rows.Next() {
+		invoice, err := r.scanInvoiceFromRows(rows)
+		if err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, *invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// ensureRemindersTable creates the invoice_reminders table if it doesn't exist
+// A reminder is unique per day of the schedule, so two instances never send the same reminder
+func (r *PostgresRepository) ensureRemindersTable(ctx context.Context) error {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_reminders (
+			reminder_id SERIAL PRIMARY KEY,
+			invoice_id BIGINT NOT NULL REFERENCES invoices(invoice_id),
+			day INT NOT NULL,
+			late_fee DECIMAL(10,2) NOT NULL DEFAULT 0.00,
+			currency TEXT NOT NULL,
+			sent_at TIMESTAMPTZ NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			UNIQUE (invoice_id, day)
+		)`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *PostgresRepository) CreateReminder(ctx context.Context, reminder *Reminder) error {
+	if err := r.ensureRemindersTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO invoice_reminders (invoice_id, day, late_fee, currency, sent_at, error)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (invoice_id, day) DO NOTHING RETURNING reminder_id`
+	err := r.db.QueryRowContext(ctx, query,
+		reminder.InvoiceID, reminder.Day, reminder.LateFee, reminder.LateFee.Currency, reminder.SentAt, reminder.Error,
+	).Scan(&reminder.ReminderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("reminder of day %d already exists", reminder.Day)
+	}
+	return err
+}
+
+func (r *PostgresRepository) UpdateReminder(ctx context.Context, reminder *Reminder) error {
+	if err := r.ensureRemindersTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "UPDATE invoice_reminders SET error = $1 WHERE reminder_id = $2", reminder.Error, reminder.ReminderID)
+	return err
+}
+
+func (r *PostgresRepository) GetReminders(ctx context.Context, invoiceID uint64) ([]Reminder, error) {
+	if err := r.ensureRemindersTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT reminder_id, invoice_id, day, late_fee, currency, sent_at, error
+		FROM invoice_reminders WHERE invoice_id = $1 ORDER BY day`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []Reminder
+	for rows.Next() {
+		var reminder Reminder
+		var currency string
+		if err := rows.Scan(&reminder.ReminderID, &reminder.InvoiceID, &reminder.Day, &reminder.LateFee, &currency, &reminder.SentAt, &reminder.Error); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &reminder.LateFee)
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+// ensureDeliveriesTable creates the invoice_deliveries table if it doesn't exist
+func (r *PostgresRepository) ensureDeliveriesTable(ctx context.Context) error {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_deliveries (
+			delivery_id SERIAL PRIMARY KEY,
+			invoice_id BIGINT NOT NULL REFERENCES invoices(invoice_id),
+			recipient TEXT NOT NULL,
+			attachment TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			attempted_at TIMESTAMPTZ NOT NULL
+		)`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_invoice_deliveries_invoice ON invoice_deliveries (invoice_id, attempted_at)")
+	return err
+}
+
+func (r *PostgresRepository) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	if err := r.ensureDeliveriesTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO invoice_deliveries (invoice_id, recipient, attachment, status, error, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING delivery_id`
+	return r.db.QueryRowContext(ctx, query,
+		delivery.InvoiceID, delivery.Recipient, delivery.Attachment, delivery.Status, delivery.Error, delivery.AttemptedAt,
+	).Scan(&delivery.DeliveryID)
+}
+
+func (r *PostgresRepository) GetDeliveries(ctx context.Context, invoiceID uint64) ([]Delivery, error) {
+	if err := r.ensureDeliveriesTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT delivery_id, invoice_id, recipient, attachment, status, error, attempted_at
+		FROM invoice_deliveries WHERE invoice_id = $1 ORDER BY attempted_at, delivery_id`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var delivery Delivery
+		if err := rows.Scan(&delivery.DeliveryID, &delivery.InvoiceID, &delivery.Recipient, &delivery.Attachment, &delivery.Status, &delivery.Error, &delivery.AttemptedAt); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// ensureVersionsTable creates the invoice_versions table if it doesn't exist
+// Content is kept as text rather than JSONB, so it is the exact JSON that was hashed
+func (r *PostgresRepository) ensureVersionsTable(ctx context.Context) error {
+	if err := r.ensureInvoicesTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		CREATE TABLE IF NOT EXISTS invoice_versions (
+			invoice_id BIGINT NOT NULL REFERENCES invoices(invoice_id),
+			version INT NOT NULL,
+			reason TEXT NOT NULL,
+			content_hash TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (invoice_id, version)
+		)`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// SaveVersion updates the invoice only if it is still at the previous version, in the transaction that adds the version
+func (r *PostgresRepository) SaveVersion(ctx context.Context, invoice *Invoice, version *InvoiceVersion) error {
+	if err := r.ensureVersionsTable(ctx); err != nil {
+		return err
+	}
+
+	itemsJSON, err := json.Marshal(invoice.Items)
+	if err != nil {
+		return err
+	}
+	ratesJSON, err := encodeExchangeRates(invoice.ExchangeRates)
+	if err != nil {
+		return err
+	}
+	discountsJSON, err := encodeDiscounts(invoice.Discounts)
+	if err != nil {
+		return err
+	}
+	sellerJSON, err := encodeParty(invoice.Seller)
+	if err != nil {
+		return err
+	}
+	buyerJSON, err := encodeParty(invoice.Buyer)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	update := `
+		UPDATE invoices SET user_id = $1, username = $2, items = $3, currency = $4, exchange_rates = NULLIF($5, '')::jsonb,
+			discounts = NULLIF($6, '')::jsonb, discount = $7, subtotal = $8, tax = $9, total = $10, seller = $11::jsonb,
+			buyer = $12::jsonb, version = $13, content_hash = $14
+		WHERE invoice_id = $15 AND version = $16`
+	result, err := tx.ExecContext(ctx, update,
+		invoice.UserID, invoice.Username, itemsJSON, invoice.Currency, ratesJSON, discountsJSON,
+		invoice.Discount, invoice.Subtotal, invoice.Tax, invoice.Total, sellerJSON, buyerJSON, invoice.Version, invoice.ContentHash,
+		invoice.InvoiceID, version.Version-1,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("invoice version %d already exists", version.Version)
+	}
+
+	query := `
+		INSERT INTO invoice_versions (invoice_id, version, reason, content_hash, content, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.ExecContext(ctx, query,
+		version.InvoiceID, version.Version, version.Reason, version.ContentHash, string(version.Content), version.CreatedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetVersions(ctx context.Context, invoiceID uint64) ([]InvoiceVersion, error) {
+	if err := r.ensureVersionsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT invoice_id, version, reason, content_hash, content, created_at
+		FROM invoice_versions WHERE invoice_id = $1 ORDER BY version`
+	rows, err := r.db.QueryContext(ctx, query, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []InvoiceVersion
+	for rows.Next() {
+		var version InvoiceVersion
+		var content string
+		if err := rows.Scan(&version.InvoiceID, &version.Version, &version.Reason, &version.ContentHash, &content, &version.CreatedAt); err != nil {
+			return nil, err
+		}
+		version.Content = json.RawMessage(content)
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
 // Helper method to scan a single invoice
 func (r *PostgresRepository) scanInvoice(ctx context.Context, query string, arg interface{}) (*Invoice, error) {
 	row := r.db.QueryRowContext(ctx, query, arg)
@@ -137,7 +644,7 @@ func (r *PostgresRepository) scanInvoice(ctx context.Context, query string, arg
 // Helper method to scan invoice from a single row
 func (r *PostgresRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error) {
 	var invoice Invoice
-	var itemsJSON []byte
+	var itemsJSON, ratesJSON, discountsJSON, sellerJSON, buyerJSON []byte
 
 	err := row.Scan(
 		&invoice.InvoiceID,
@@ -146,12 +653,20 @@ func (r *PostgresRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error)
 		&invoice.Username,
 		&invoice.InvoiceNumber,
 		&itemsJSON,
+		&invoice.Currency,
+		&ratesJSON,
+		&discountsJSON,
+		&invoice.Discount,
 		&invoice.Subtotal,
 		&invoice.Tax,
 		&invoice.Total,
 		&invoice.Status,
 		&invoice.CreatedAt,
 		&invoice.DueDate,
+		&sellerJSON,
+		&buyerJSON,
+		&invoice.Version,
+		&invoice.ContentHash,
 	)
 	
 	if err != nil {
@@ -164,6 +679,20 @@ func (r *PostgresRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error)
 			return nil, err
 		}
 	}
+	if len(ratesJSON) > 0 {
+		if err := json.Unmarshal(ratesJSON, &invoice.ExchangeRates); err != nil {
+			return nil, err
+		}
+	}
+	if len(discountsJSON) > 0 {
+		if err := json.Unmarshal(discountsJSON, &invoice.Discounts); err != nil {
+			return nil, err
+		}
+	}
+	if err := invoice.decodeParties(sellerJSON, buyerJSON); err != nil {
+		return nil, err
+	}
+	invoice.applyCurrency()
 
 	return &invoice, nil
 }
@@ -171,7 +700,7 @@ func (r *PostgresRepository) scanInvoiceFromRow(row *sql.Row) (*Invoice, error)
 // Helper method to scan invoice from rows
 func (r *PostgresRepository) scanInvoiceFromRows(rows *sql.Rows) (*Invoice, error) {
 	var invoice Invoice
-	var itemsJSON []byte
+	var itemsJSON, ratesJSON, discountsJSON, sellerJSON, buyerJSON []byte
 
 	err := rows.Scan(
 		&invoice.InvoiceID,
@@ -180,12 +709,20 @@ func (r *PostgresRepository) scanInvoiceFromRows(rows *sql.Rows) (*Invoice, erro
 		&invoice.Username,
 		&invoice.InvoiceNumber,
 		&itemsJSON,
+		&invoice.Currency,
+		&ratesJSON,
+		&discountsJSON,
+		&invoice.Discount,
 		&invoice.Subtotal,
 		&invoice.Tax,
 		&invoice.Total,
 		&invoice.Status,
 		&invoice.CreatedAt,
 		&invoice.DueDate,
+		&sellerJSON,
+		&buyerJSON,
+		&invoice.Version,
+		&invoice.ContentHash,
 	)
 	
 	if err != nil {
@@ -198,6 +735,20 @@ func (r *PostgresRepository) scanInvoiceFromRows(rows *sql.Rows) (*Invoice, erro
 			return nil, err
 		}
 	}
+	if len(ratesJSON) > 0 {
+		if err := json.Unmarshal(ratesJSON, &invoice.ExchangeRates); err != nil {
+			return nil, err
+		}
+	}
+	if len(discountsJSON) > 0 {
+		if err := json.Unmarshal(discountsJSON, &invoice.Discounts); err != nil {
+			return nil, err
+		}
+	}
+	if err := invoice.decodeParties(sellerJSON, buyerJSON); err != nil {
+		return nil, err
+	}
+	invoice.applyCurrency()
 
 	return &invoice, nil
 }
\ No newline at end of file
@@ -0,0 +1,30 @@
+package invoice
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunDunningScheduler marks overdue invoices and sends their reminders every interval until ctx is cancelled
+// It is meant to be started in its own goroutine
+func RunDunningScheduler(ctx context.Context, service InvoiceService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			overdue, reminded, err := service.RunDunning(ctx, time.Now())
+			if err != nil {
+				log.Printf("Dunning scheduler: failed to chase overdue invoices: %v", err)
+				continue
+			}
+			if overdue > 0 || reminded > 0 {
+				log.Printf("Dunning scheduler: marked %d invoices overdue and sent %d reminders", overdue, reminded)
+			}
+		}
+	}
+}
@@ -2,10 +2,17 @@ package invoice
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"strconv"
+	"log"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/notify"
+	"github.com/rajindersingh041/go-auth-sessions/numbering"
 	"github.com/rajindersingh041/go-auth-sessions/order"
 	"github.com/rajindersingh041/go-auth-sessions/product"
 	"github.com/rajindersingh041/go-auth-sessions/user"
@@ -13,42 +20,106 @@ import (
 
 // InvoiceService defines the business logic interface for invoice operations
 type InvoiceService interface {
-	CreateInvoiceFromOrder(ctx context.Context, orderID uint64) (*Invoice, error)
+	// CreateInvoiceFromOrder invoices an order to the customer at a billing address, storing everything the
+	// invoice states as its first version; an order that was invoiced already returns its invoice
+	CreateInvoiceFromOrder(ctx context.Context, orderID uint64, billingAddress Address) (*Invoice, error)
 	GetInvoiceByID(ctx context.Context, invoiceID uint64) (*Invoice, error)
 	GetInvoiceByOrderID(ctx context.Context, orderID uint64) (*Invoice, error)
 	GetInvoicesByUserID(ctx context.Context, userID uint64) ([]Invoice, error)
+	// GetInvoiceOwner returns the ID of the customer an invoice was issued to, without checking its content
+	GetInvoiceOwner(ctx context.Context, invoiceID uint64) (uint64, error)
+	// UpdateInvoiceStatus cancels an invoice, or keeps a draft a draft; issued invoices never go back, and drafts
+	// are issued by SendInvoice only
+	// to draft, and paid statuses follow from payments
 	UpdateInvoiceStatus(ctx context.Context, invoiceID uint64, status string) error
+	// UpdateBillingAddress changes the billing address of a draft invoice, storing the invoice as a new version
+	UpdateBillingAddress(ctx context.Context, invoiceID uint64, address Address) (*Invoice, error)
+	// GetVersions returns an invoice with the versions of its content, oldest first
+	GetVersions(ctx context.Context, invoiceID uint64) (*Invoice, []InvoiceVersion, error)
+	// FreezeLegacyInvoices completes the invoices from before snapshots and stores each as its first version
+	// Until then they can't be read, as reading never writes
+	FreezeLegacyInvoices(ctx context.Context) (*FreezeReport, error)
+	// RecordPayment adds a payment to an invoice's ledger and derives the invoice status from its balance
+	RecordPayment(ctx context.Context, invoiceID uint64, req RecordPaymentRequest) (*Payment, *Invoice, error)
+	// RecordRefund records a refund to the customer as a negative payment and derives the invoice status again
+	RecordRefund(ctx context.Context, invoiceID uint64, req RecordPaymentRequest) (*Payment, *Invoice, error)
+	// GetPayments returns an invoice with its balance and the payments recorded for it
+	GetPayments(ctx context.Context, invoiceID uint64) (*Invoice, []Payment, error)
+	// CreateCreditNote credits some or all lines of an invoice, reversing their tax, and can link a refund
+	CreateCreditNote(ctx context.Context, invoiceID uint64, req CreateCreditNoteRequest) (*CreditNote, *Invoice, error)
+	GetCreditNote(ctx context.Context, creditNoteID uint64) (*CreditNote, error)
+	// GetCreditNotes returns an invoice with its balance and the credit notes issued for it
+	GetCreditNotes(ctx context.Context, invoiceID uint64) (*Invoice, []CreditNote, error)
+	GetCreditNotesByUserID(ctx context.Context, userID uint64) ([]CreditNote, error)
+	// RenderInvoicePDF renders an invoice as a PDF document with its stored seller and buyer and the configured branding
+	RenderInvoicePDF(ctx context.Context, invoiceID uint64) (*Invoice, []byte, error)
+	// ExportInvoiceUBL exports an invoice as a UBL 2.1 e-invoice conforming to Peppol BIS Billing 3.0,
+	// returning a *UBLValidationError when it doesn't
+	ExportInvoiceUBL(ctx context.Context, invoiceID uint64) (*Invoice, []byte, error)
+	// RunDunning marks invoices past their due date overdue and sends the reminders that are due,
+	// returning how many invoices became overdue and how many reminders were sent
+	RunDunning(ctx context.Context, now time.Time) (overdue, reminded int, err error)
+	// GetAgingReport lists the overdue invoices by how long they are overdue
+	GetAgingReport(ctx context.Context, now time.Time) (*AgingReport, error)
+	// SendInvoice emails an invoice to its customer; a failed attempt is recorded and returned as a failed delivery
+	SendInvoice(ctx context.Context, invoiceID uint64) (*Delivery, *Invoice, error)
+	// GetDeliveries returns an invoice with the attempts to email it
+	GetDeliveries(ctx context.Context, invoiceID uint64) (*Invoice, []Delivery, error)
 }
 
 // invoiceService implements the InvoiceService interface
 type invoiceService struct {
-	repo           InvoiceRepository
-	orderService   order.OrderService
-	productService product.ProductService
-	userService    user.UserService
+	repo              InvoiceRepository
+	orderService      order.OrderService
+	productService    product.ProductService
+	userService       user.UserService
+	pdfRenderer       *PDFRenderer
+	ublExporter       *UBLExporter
+	invoiceNumbers    *numbering.Scheme
+	creditNoteNumbers *numbering.Scheme
+	dunning           DunningPolicy
+	notifier          notify.Notifier
+	mailer            notify.Mailer
 }
 
 // NewInvoiceService creates a new invoice service
-func NewInvoiceService(repo InvoiceRepository, orderService order.OrderService, productService product.ProductService, userService user.UserService) InvoiceService {
+// pdfRenderer renders the PDF documents of invoices, and ublExporter their e-invoices
+// invoiceNumbers and creditNoteNumbers number invoices and credit notes, each from their own sequences
+// Reminders of overdue invoices are sent through notifier on the days of the dunning policy, and invoices are emailed through mailer
+func NewInvoiceService(repo InvoiceRepository, orderService order.OrderService, productService product.ProductService, userService user.UserService, pdfRenderer *PDFRenderer, ublExporter *UBLExporter, invoiceNumbers, creditNoteNumbers *numbering.Scheme, dunning DunningPolicy, notifier notify.Notifier, mailer notify.Mailer) InvoiceService {
 	return &invoiceService{
-		repo:           repo,
-		orderService:   orderService,
-		productService: productService,
-		userService:    userService,
+		repo:              repo,
+		orderService:      orderService,
+		productService:    productService,
+		userService:       userService,
+		pdfRenderer:       pdfRenderer,
+		ublExporter:       ublExporter,
+		invoiceNumbers:    invoiceNumbers,
+		creditNoteNumbers: creditNoteNumbers,
+		dunning:           dunning,
+		notifier:          notifier,
+		mailer:            mailer,
 	}
 }
 
 // CreateInvoiceFromOrder creates an invoice from an existing order
-func (s *invoiceService) CreateInvoiceFromOrder(ctx context.Context, orderID uint64) (*Invoice, error) {
+// The invoice copies the order, product names, customer and seller, so it doesn't change when they do
+func (s *invoiceService) CreateInvoiceFromOrder(ctx context.Context, orderID uint64, billingAddress Address) (*Invoice, error) {
 	if orderID == 0 {
 		return nil, fmt.Errorf("valid order ID is required")
 	}
+	billingAddress, err := normalizeAddress(billingAddress)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if invoice already exists for this order
 	existingInvoice, err := s.repo.GetByOrderID(ctx, orderID)
 	if err == nil && existingInvoice != nil {
-		// If invoice exists, populate it with complete details and return
-		return s.populateInvoiceDetails(ctx, existingInvoice)
+		if err := s.checkContent(ctx, existingInvoice); err != nil {
+			return nil, err
+		}
+		return existingInvoice, s.loadPayments(ctx, existingInvoice)
 	}
 
 	// Get order details
@@ -62,6 +133,9 @@ func (s *invoiceService) CreateInvoiceFromOrder(ctx context.Context, orderID uin
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user details: %w", err)
 	}
+	if userDetails == nil {
+		return nil, fmt.Errorf("user not found")
+	}
 
 	// Create invoice items from order items
 	var invoiceItems []InvoiceItem
@@ -74,11 +148,18 @@ func (s *invoiceService) CreateInvoiceFromOrder(ctx context.Context, orderID uin
 
 		invoiceItem := InvoiceItem{
 			ProductID:   orderItem.ProductID,
+			VariantID:   orderItem.VariantID,
+			SKU:         orderItem.SKU,
 			ProductName: productDetails.Name,
 			Description: productDetails.Description,
 			Quantity:    orderItem.Quantity,
 			UnitPrice:   orderItem.UnitPrice,
 			TotalPrice:  orderItem.Total,
+			Discount:    orderItem.Discount,
+			Tax:         orderItem.Tax,
+		}
+		if err := s.addVariantAttributes(ctx, &invoiceItem); err != nil {
+			return nil, err
 		}
 		invoiceItems = append(invoiceItems, invoiceItem)
 	}
@@ -88,31 +169,46 @@ func (s *invoiceService) CreateInvoiceFromOrder(ctx context.Context, orderID uin
 	tax := orderDetails.Tax
 	total := orderDetails.Total
 
-	// Generate invoice number
-	invoiceNumber := s.generateInvoiceNumber()
-	
-	// Create invoice structure
+	// Create invoice structure; the repository numbers it when it is stored
+	now := time.Now()
 	invoice := &Invoice{
 		OrderID:       orderID,
 		UserID:        orderDetails.UserID,
 		Username:      userDetails.Username,
-		InvoiceNumber: invoiceNumber,
 		Items:         invoiceItems,
+		Currency:      orderDetails.Currency,
+		ExchangeRates: orderDetails.ExchangeRates,
+		Discounts:     orderDetails.Discounts,
+		Discount:      orderDetails.Discount,
 		Subtotal:      subtotal,
 		Tax:           tax,
 		Total:         total,
-		Status:        "draft",
-		CreatedAt:     time.Now().Format(time.RFC3339),
-		DueDate:       time.Now().AddDate(0, 0, 30).Format(time.RFC3339), // 30 days from now
+		Status:        StatusDraft,
+		CreatedAt:     now.Format(time.RFC3339),
+		DueDate:       now.AddDate(0, 0, 30).Format(time.RFC3339), // 30 days from now
+		Seller:        s.sellerParty(),
+		Buyer:         s.buyerParty(userDetails.Username, userDetails, billingAddress),
 	}
 
 	// Create the invoice
-	if err := s.repo.Create(ctx, invoice); err != nil {
+	if err := s.repo.Create(ctx, invoice, s.invoiceNumbers.Series(now)); err != nil {
 		return nil, fmt.Errorf("failed to create invoice: %w", err)
 	}
 
-	// Return the created invoice (now has ID populated by Create method)
-	return invoice, nil
+	// The first version is hashed as stored, e.g. with the dates as the database returns them
+	created, err := s.repo.GetByID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get created invoice: %w", err)
+	}
+	if err := s.saveVersion(ctx, created, VersionCreated); err != nil {
+		return nil, fmt.Errorf("failed to save invoice version: %w", err)
+	}
+	created.Credited = money.Zero(created.Currency)
+	created.LateFees = money.Zero(created.Currency)
+	if err := applyPayments(created, nil); err != nil {
+		return nil, err
+	}
+	return created, nil
 }
 
 // GetInvoiceByID retrieves an invoice by its ID
@@ -120,11 +216,11 @@ func (s *invoiceService) GetInvoiceByID(ctx context.Context, invoiceID uint64) (
 	if invoiceID == 0 {
 		return nil, fmt.Errorf("valid invoice ID is required")
 	}
-	invoice, err := s.repo.GetByID(ctx, invoiceID)
+	invoice, err := s.getInvoice(ctx, invoiceID)
 	if err != nil {
 		return nil, err
 	}
-	return s.populateInvoiceDetails(ctx, invoice)
+	return invoice, s.loadPayments(ctx, invoice)
 }
 
 // GetInvoiceByOrderID retrieves an invoice by order ID
@@ -134,12 +230,19 @@ func (s *invoiceService) GetInvoiceByOrderID(ctx context.Context, orderID uint64
 	}
 	invoice, err := s.repo.GetByOrderID(ctx, orderID)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invoice not found")
+		}
 		return nil, err
 	}
-	return s.populateInvoiceDetails(ctx, invoice)
+	if err := s.checkContent(ctx, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, s.loadPayments(ctx, invoice)
 }
 
 // GetInvoicesByUserID retrieves all invoices for a user
+// An invoice failing its content check is listed with IntegrityError set, so it doesn't hide the others
 func (s *invoiceService) GetInvoicesByUserID(ctx context.Context, userID uint64) ([]Invoice, error) {
 	if userID == 0 {
 		return nil, fmt.Errorf("valid user ID is required")
@@ -148,19 +251,16 @@ func (s *invoiceService) GetInvoicesByUserID(ctx context.Context, userID uint64)
 	if err != nil {
 		return nil, err
 	}
-	
-	// Populate details for each invoice
-	populatedInvoices := make([]Invoice, len(invoices))
-	for i, invoice := range invoices {
-		populated, err := s.populateInvoiceDetails(ctx, &invoice)
-		if err != nil {
-			// If we can't populate details, use the original invoice
-			populatedInvoices[i] = invoice
-		} else {
-			populatedInvoices[i] = *populated
+	for i := range invoices {
+		if err := s.checkContent(ctx, &invoices[i]); err != nil {
+			log.Printf("Invoice %d failed its content check: %v", invoices[i].InvoiceID, err)
+			invoices[i].IntegrityError = err.Error()
+		}
+		if err := s.loadPayments(ctx, &invoices[i]); err != nil {
+			return nil, err
 		}
 	}
-	return populatedInvoices, nil
+	return invoices, nil
 }
 
 // UpdateInvoiceStatus updates the status of an invoice
@@ -170,82 +270,330 @@ func (s *invoiceService) UpdateInvoiceStatus(ctx context.Context, invoiceID uint
 	}
 	
 	validStatuses := map[string]bool{
-		"draft":     true,
-		"sent":      true,
-		"paid":      true,
-		"cancelled": true,
+		StatusDraft:     true,
+		StatusSent:      true,
+		StatusCancelled: true,
 	}
 	
+	if status == StatusPaid || status == StatusPartiallyPaid {
+		return fmt.Errorf("invalid status: %s follows from recorded payments, record a payment instead", status)
+	}
+	if status == StatusCredited {
+		return fmt.Errorf("invalid status: %s follows from credit notes, issue a credit note instead", status)
+	}
+	if status == StatusOverdue {
+		return fmt.Errorf("invalid status: %s follows from the due date", status)
+	}
 	if !validStatuses[status] {
-		return fmt.Errorf("invalid status: %s. Valid statuses are: draft, sent, paid, cancelled", status)
+		return fmt.Errorf("invalid status: %s. Valid statuses are: draft, sent, cancelled", status)
+	}
+
+	// While money is held for an invoice, its status is derived from the payments only
+	invoice, err := s.getInvoice(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+	if err := s.loadPayments(ctx, invoice); err != nil {
+		return err
+	}
+	if !invoice.AmountPaid.IsZero() {
+		return fmt.Errorf("invoice with payments must not change status to %s", status)
+	}
+	if !invoice.Credited.IsZero() {
+		return fmt.Errorf("invoice with credit notes must not change status to %s", status)
+	}
+	// Once issued, an invoice is final and is corrected by credit notes instead
+	if status == StatusDraft && invoice.Status != StatusDraft {
+		return fmt.Errorf("%s invoice must not go back to draft", invoice.Status)
+	}
+	// Issuing a draft records its delivery to the customer, so it only happens by sending it
+	if status == StatusSent && invoice.Status == StatusDraft {
+		return fmt.Errorf("draft invoice must not be marked sent directly, send it with POST /invoices/%d/send", invoiceID)
 	}
 	
 	return s.repo.UpdateStatus(ctx, invoiceID, status)
 }
 
-// generateInvoiceNumber generates a unique invoice number
-func (s *invoiceService) generateInvoiceNumber() string {
-	timestamp := time.Now().Unix()
-	return "INV-" + strconv.FormatInt(timestamp, 10)
+// paymentMethods are the accepted payment methods
+var paymentMethods = map[string]bool{
+	MethodBankTransfer: true,
+	MethodCard:         true,
+	MethodCash:         true,
+	MethodCheque:       true,
+	MethodOther:        true,
 }
 
-// populateInvoiceDetails populates an invoice with complete order, product, and user details
-func (s *invoiceService) populateInvoiceDetails(ctx context.Context, invoice *Invoice) (*Invoice, error) {
-	// If invoice is already populated (has user info), return as is
-	if invoice.Username != "" && len(invoice.Items) > 0 {
-		return invoice, nil
+// maxReferenceLength limits the length of payment references
+const maxReferenceLength = 200
+
+// RecordPayment validates and records a payment
+// Payments beyond the balance are accepted and kept as credit for the customer
+func (s *invoiceService) RecordPayment(ctx context.Context, invoiceID uint64, req RecordPaymentRequest) (*Payment, *Invoice, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invoice.Status == StatusCancelled || invoice.Status == StatusCredited {
+		return nil, nil, fmt.Errorf("payment must not be recorded for a %s invoice", invoice.Status)
+	}
+	payment, err := newPayment(invoiceID, req, invoice.Currency)
+	if err != nil {
+		return nil, nil, err
 	}
+	return s.addPayment(ctx, invoice, payment)
+}
 
-	// Get order details
-	orderDetails, err := s.orderService.GetOrderByID(ctx, invoice.OrderID)
+// RecordRefund records money paid back to the customer as a payment with a negative amount
+// Refunds can't exceed the amount paid and are also accepted for cancelled invoices
+func (s *invoiceService) RecordRefund(ctx context.Context, invoiceID uint64, req RecordPaymentRequest) (*Payment, *Invoice, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	payment, err := newPayment(invoiceID, req, invoice.Currency)
 	if err != nil {
-		return invoice, nil // Return original invoice if we can't get order details
+		return nil, nil, err
+	}
+	if payment.Amount.Cmp(invoice.AmountPaid) > 0 {
+		return nil, nil, fmt.Errorf("refund must not exceed the amount paid of %s", invoice.AmountPaid.Display())
 	}
+	payment.Amount = payment.Amount.Neg()
+	return s.addPayment(ctx, invoice, payment)
+}
 
-	// Get user details
-	userDetails, err := s.userService.GetUserByID(ctx, orderDetails.UserID)
+// newPayment builds and validates a payment from a request; paid_at defaults to now
+func newPayment(invoiceID uint64, req RecordPaymentRequest, currency string) (*Payment, error) {
+	now := time.Now().UTC()
+	payment := &Payment{
+		InvoiceID: invoiceID,
+		Amount:    req.Amount,
+		Method:    strings.ToLower(strings.TrimSpace(req.Method)),
+		Reference: strings.TrimSpace(req.Reference),
+		PaidAt:    now,
+		CreatedAt: now,
+	}
+	if req.PaidAt != nil {
+		payment.PaidAt = req.PaidAt.UTC()
+	}
+	if err := validatePayment(payment, currency, now); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// addPayment stores a payment or refund and updates the invoice's balance and status
+// References are unique per invoice, so a payment reported twice is only recorded once
+func (s *invoiceService) addPayment(ctx context.Context, invoice *Invoice, payment *Payment) (*Payment, *Invoice, error) {
+	payments, err := s.repo.GetPayments(ctx, invoice.InvoiceID)
 	if err != nil {
-		return invoice, nil // Return original invoice if we can't get user details
+		return nil, nil, err
+	}
+	for _, existing := range payments {
+		if payment.Reference != "" && existing.Reference == payment.Reference {
+			return nil, nil, fmt.Errorf("payment with reference '%s' already exists", payment.Reference)
+		}
+	}
+	if err := s.repo.CreatePayment(ctx, payment); err != nil {
+		return nil, nil, fmt.Errorf("failed to record payment: %w", err)
 	}
 
-	// Populate missing fields
-	if invoice.UserID == 0 {
-		invoice.UserID = orderDetails.UserID
+	if err := applyPayments(invoice, append(payments, *payment)); err != nil {
+		return nil, nil, err
 	}
-	if invoice.Username == "" {
-		invoice.Username = userDetails.Username
+	if status := paymentStatus(invoice); status != invoice.Status {
+		if err := s.repo.UpdateStatus(ctx, invoice.InvoiceID, status); err != nil {
+			return nil, nil, fmt.Errorf("failed to update invoice status: %w", err)
+		}
+		invoice.Status = status
 	}
-	
-	// Populate invoice items from order items
-	if len(invoice.Items) == 0 {
-		var invoiceItems []InvoiceItem
-		
-		for _, orderItem := range orderDetails.Items {
-			// Get product details for each item
-			productDetails, err := s.productService.GetProductByID(ctx, orderItem.ProductID)
-			if err != nil {
-				continue // Skip this item if we can't get product details
-			}
-
-			invoiceItem := InvoiceItem{
-				ProductID:   orderItem.ProductID,
-				ProductName: productDetails.Name,
-				Description: productDetails.Description,
-				Quantity:    orderItem.Quantity,
-				UnitPrice:   orderItem.UnitPrice,
-				TotalPrice:  orderItem.Total,
-			}
-			invoiceItems = append(invoiceItems, invoiceItem)
+	return payment, invoice, nil
+}
+
+// GetPayments returns an invoice and its payments, oldest first
+func (s *invoiceService) GetPayments(ctx context.Context, invoiceID uint64) (*Invoice, []Payment, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	payments, err := s.repo.GetPayments(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payments == nil {
+		payments = []Payment{}
+	}
+	return invoice, payments, nil
+}
+
+// validatePayment checks a payment's amount, method and reference
+func validatePayment(payment *Payment, currency string, now time.Time) error {
+	if payment.Amount.Currency != currency {
+		return fmt.Errorf("payment currency must be %s", currency)
+	}
+	if payment.Amount.IsNegative() || payment.Amount.IsZero() {
+		return fmt.Errorf("payment amount must be positive")
+	}
+	if payment.Method == "" {
+		return fmt.Errorf("payment method is required")
+	}
+	if !paymentMethods[payment.Method] {
+		return fmt.Errorf("invalid payment method '%s': must be bank_transfer, card, cash, cheque or other", payment.Method)
+	}
+	if utf8.RuneCountInString(payment.Reference) > maxReferenceLength {
+		return fmt.Errorf("payment reference must be at most %d characters", maxReferenceLength)
+	}
+	if payment.PaidAt.After(now) {
+		return fmt.Errorf("invalid paid_at: payments cannot be dated in the future")
+	}
+	return nil
+}
+
+// loadPayments sets the amount credited, late fees, paid, balance and credit of an invoice
+// from its credit notes, reminders and payments
+func (s *invoiceService) loadPayments(ctx context.Context, invoice *Invoice) error {
+	notes, err := s.repo.GetCreditNotesByInvoiceID(ctx, invoice.InvoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice credit notes: %w", err)
+	}
+	invoice.Credited = money.Zero(invoice.Currency)
+	for _, note := range notes {
+		if invoice.Credited, err = invoice.Credited.CheckedAdd(note.Total); err != nil {
+			return fmt.Errorf("credit note %s doesn't match the currency of invoice %s: %w", note.CreditNoteNumber, invoice.InvoiceNumber, err)
 		}
-		invoice.Items = invoiceItems
 	}
-	
-	// Use order totals if invoice totals are not set
-	if invoice.Subtotal == 0 {
-		invoice.Subtotal = orderDetails.Subtotal
-		invoice.Tax = orderDetails.Tax
-		invoice.Total = orderDetails.Total
+
+	reminders, err := s.repo.GetReminders(ctx, invoice.InvoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice reminders: %w", err)
+	}
+	invoice.LateFees = money.Zero(invoice.Currency)
+	for _, reminder := range reminders {
+		if invoice.LateFees, err = invoice.LateFees.CheckedAdd(reminder.LateFee); err != nil {
+			return fmt.Errorf("late fee of reminder %d doesn't match the currency of invoice %s: %w", reminder.ReminderID, invoice.InvoiceNumber, err)
+		}
+	}
+
+	payments, err := s.repo.GetPayments(ctx, invoice.InvoiceID)
+	if err != nil {
+		return fmt.Errorf("failed to get invoice payments: %w", err)
+	}
+	return applyPayments(invoice, payments)
+}
+
+// applyPayments derives the amount paid, balance due and credit of an invoice from its payments
+// What is owed is the total minus the credited amount plus late fees, which the caller sets first
+// Stored amounts in another currency than the invoice's are an error
+func applyPayments(invoice *Invoice, payments []Payment) error {
+	paid := money.Zero(invoice.Currency)
+	for _, payment := range payments {
+		var err error
+		if paid, err = paid.CheckedAdd(payment.Amount); err != nil {
+			return fmt.Errorf("payment %d doesn't match the currency of invoice %s: %w", payment.PaymentID, invoice.InvoiceNumber, err)
+		}
+	}
+	due, err := invoice.Total.CheckedSub(invoice.Credited)
+	if err != nil {
+		return fmt.Errorf("total of invoice %s doesn't match its currency: %w", invoice.InvoiceNumber, err)
+	}
+	due = due.Add(invoice.LateFees)
+	invoice.AmountPaid = paid
+	invoice.BalanceDue = money.Zero(invoice.Currency)
+	invoice.Credit = money.Zero(invoice.Currency)
+	if paid.Cmp(due) < 0 {
+		invoice.BalanceDue = due.Sub(paid)
+	} else {
+		invoice.Credit = paid.Sub(due)
+	}
+	return nil
+}
+
+// paymentStatus derives an invoice's status from the amounts credited and paid
+// Invoices that were fully refunded go back to sent; cancelled invoices stay cancelled
+// Overdue invoices stay overdue until nothing is due, and are marked overdue again by dunning after a refund
+func paymentStatus(invoice *Invoice) string {
+	switch {
+	case invoice.Status == StatusCancelled:
+		return invoice.Status
+	case !invoice.Credited.IsZero() && invoice.Credited.Cmp(invoice.Total) >= 0:
+		return StatusCredited
+	case invoice.Status == StatusOverdue && !invoice.BalanceDue.IsZero():
+		return StatusOverdue
+	case invoice.AmountPaid.IsZero() && (invoice.Status == StatusPaid || invoice.Status == StatusPartiallyPaid):
+		return StatusSent
+	case invoice.AmountPaid.IsZero():
+		return invoice.Status
+	case invoice.BalanceDue.IsZero():
+		return StatusPaid
+	default:
+		return StatusPartiallyPaid
+	}
+}
+
+func (s *invoiceService) GetInvoiceOwner(ctx context.Context, invoiceID uint64) (uint64, error) {
+	invoice, err := s.repo.GetByID(ctx, invoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("invoice not found")
+		}
+		return 0, err
 	}
+	return invoice.UserID, nil
+}
 
+// getInvoice loads an invoice, reporting a missing invoice as not found, and checks its content, see checkContent
+func (s *invoiceService) getInvoice(ctx context.Context, invoiceID uint64) (*Invoice, error) {
+	invoice, err := s.repo.GetByID(ctx, invoiceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		return nil, err
+	}
+	if err := s.checkContent(ctx, invoice); err != nil {
+		return nil, err
+	}
 	return invoice, nil
-}
\ No newline at end of file
+}
+
+// RenderInvoicePDF renders an invoice with its balance
+func (s *invoiceService) RenderInvoicePDF(ctx context.Context, invoiceID uint64) (*Invoice, []byte, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := s.pdfRenderer.Render(invoice)
+	if err != nil {
+		return nil, nil, err
+	}
+	return invoice, data, nil
+}
+
+// ExportInvoiceUBL exports an invoice with its payments as a UBL e-invoice
+func (s *invoiceService) ExportInvoiceUBL(ctx context.Context, invoiceID uint64) (*Invoice, []byte, error) {
+	invoice, err := s.GetInvoiceByID(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := s.ublExporter.Export(invoice)
+	if err != nil {
+		return nil, nil, err
+	}
+	return invoice, data, nil
+}
+
+// addVariantAttributes describes the variant of an invoice item
+// Archived variants are still found, so a variant that can't be loaded fails the invoice rather than leaving it without attributes
+func (s *invoiceService) addVariantAttributes(ctx context.Context, item *InvoiceItem) error {
+	if item.VariantID == 0 {
+		return nil
+	}
+	variant, err := s.productService.GetVariant(ctx, item.VariantID)
+	if err != nil {
+		return fmt.Errorf("failed to get variant %d of product %d: %w", item.VariantID, item.ProductID, err)
+	}
+	item.Attributes = variant.Attributes
+	if item.SKU == "" {
+		item.SKU = variant.SKU
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+package invoice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/fx"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// invoiceContent is what an invoice states, as hashed into its ContentHash
+// The status and the amounts that follow from payments, credit notes and reminders change over the life of
+// an invoice and aren't part of it.
+type invoiceContent struct {
+	InvoiceNumber string               `json:"invoice_number"`
+	OrderID       uint64               `json:"order_id"`
+	UserID        uint64               `json:"user_id"`
+	Username      string               `json:"username"`
+	Seller        Party                `json:"seller"`
+	Buyer         Party                `json:"buyer"`
+	Items         []InvoiceItem        `json:"items"`
+	Currency      string               `json:"currency"`
+	ExchangeRates []fx.Rate            `json:"exchange_rates,omitempty"`
+	Discounts     []promotion.Discount `json:"discounts,omitempty"`
+	Discount      money.Money          `json:"discount"`
+	Subtotal      money.Money          `json:"subtotal"`
+	Tax           money.Money          `json:"tax"`
+	Total         money.Money          `json:"total"`
+	CreatedAt     string               `json:"created_at"`
+	DueDate       string               `json:"due_date"`
+}
+
+// content returns the JSON of the content of an invoice and its SHA-256 as a hex string
+func (i *Invoice) content() ([]byte, string, error) {
+	data, err := json.Marshal(invoiceContent{
+		InvoiceNumber: i.InvoiceNumber,
+		OrderID:       i.OrderID,
+		UserID:        i.UserID,
+		Username:      i.Username,
+		Seller:        i.Seller,
+		Buyer:         i.Buyer,
+		Items:         i.Items,
+		Currency:      i.Currency,
+		ExchangeRates: i.ExchangeRates,
+		Discounts:     i.Discounts,
+		Discount:      i.Discount,
+		Subtotal:      i.Subtotal,
+		Tax:           i.Tax,
+		Total:         i.Total,
+		CreatedAt:     i.CreatedAt,
+		DueDate:       i.DueDate,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentHash(data), nil
+}
+
+// contentHash returns the SHA-256 of the content of an invoice version as a hex string
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// sellerParty is the seller stored with new invoices, from the branding of their PDFs and the seller of their
+// e-invoices; where both configure a detail, the e-invoicing one is taken
+func (s *invoiceService) sellerParty() Party {
+	branding, config := s.pdfRenderer.branding, s.ublExporter.config.Seller
+	seller := Party{
+		Name:             branding.CompanyName,
+		Address:          Address{Lines: branding.Address, Country: config.Country},
+		TaxID:            branding.TaxID,
+		LegalID:          config.LegalID,
+		IdentifierScheme: config.IdentifierScheme,
+		Identifier:       config.Identifier,
+		EndpointScheme:   config.EndpointScheme,
+		EndpointID:       config.EndpointID,
+		ContactName:      config.ContactName,
+		Email:            branding.Email,
+		Phone:            branding.Phone,
+		Website:          branding.Website,
+	}
+	if config.Name != "" {
+		seller.Name = config.Name
+	}
+	if config.Street != "" {
+		seller.Address = Address{
+			Lines:      nonEmpty(config.Street, config.AdditionalStreet),
+			City:       config.City,
+			PostalZone: config.PostalZone,
+			Country:    config.Country,
+		}
+	}
+	if config.VATID != "" {
+		seller.TaxID = config.VATID
+	}
+	if config.Email != "" {
+		seller.Email = config.Email
+	}
+	if config.Phone != "" {
+		seller.Phone = config.Phone
+	}
+	return seller
+}
+
+// buyerParty is the buyer stored with an invoice for a customer and their billing address
+// A billing address without country is in the customers' country of the e-invoicing configuration, and
+// customers are addressed by email on the Peppol network unless another endpoint scheme is configured
+func (s *invoiceService) buyerParty(username string, u *user.User, address Address) Party {
+	config := s.ublExporter.config
+	buyer := Party{
+		Name:           username,
+		Address:        address,
+		EndpointScheme: config.BuyerEndpointScheme,
+		Email:          u.EmailID,
+		CustomerID:     u.UserID,
+	}
+	if buyer.Address.Country == "" {
+		buyer.Address.Country = config.BuyerCountry
+	}
+	if config.BuyerEndpointScheme == "EM" {
+		buyer.EndpointID = u.EmailID
+	}
+	return buyer
+}
+
+// maxAddressLines is the number of street lines of a billing address, as e-invoices state two
+const maxAddressLines = 2
+
+// normalizeAddress trims a billing address and checks its street lines and country code
+func normalizeAddress(address Address) (Address, error) {
+	normalized := Address{
+		Lines:      nonEmpty(address.Lines...),
+		City:       strings.TrimSpace(address.City),
+		PostalZone: strings.TrimSpace(address.PostalZone),
+		Country:    strings.ToUpper(strings.TrimSpace(address.Country)),
+	}
+	if len(normalized.Lines) > maxAddressLines {
+		return Address{}, fmt.Errorf("billing address must have at most %d street lines", maxAddressLines)
+	}
+	if normalized.Country != "" && !countryCode.MatchString(normalized.Country) {
+		return Address{}, fmt.Errorf("invalid billing address country '%s': must be an ISO 3166-1 alpha-2 code", address.Country)
+	}
+	return normalized, nil
+}
+
+// nonEmpty trims lines and drops the empty ones
+func nonEmpty(lines ...string) []string {
+	var kept []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// saveVersion stores the content of an invoice as its next version, setting its Version and ContentHash
+func (s *invoiceService) saveVersion(ctx context.Context, invoice *Invoice, reason string) error {
+	content, hash, err := invoice.content()
+	if err != nil {
+		return err
+	}
+	version := &InvoiceVersion{
+		InvoiceID:   invoice.InvoiceID,
+		Version:     invoice.Version + 1,
+		Reason:      reason,
+		ContentHash: hash,
+		Content:     content,
+		CreatedAt:   time.Now().UTC().Truncate(time.Millisecond),
+	}
+	versioned := *invoice
+	versioned.Version, versioned.ContentHash = version.Version, hash
+	if err := s.repo.SaveVersion(ctx, &versioned, version); err != nil {
+		return err
+	}
+	invoice.Version, invoice.ContentHash = version.Version, hash
+	return nil
+}
+
+// checkContent checks that the content of an invoice is still the content of its current version
+// Invoices from before versioning have none until FreezeLegacyInvoices stores it; reads never do
+func (s *invoiceService) checkContent(ctx context.Context, invoice *Invoice) error {
+	if invoice.Version == 0 {
+		return fmt.Errorf("invoice %s is from before invoice snapshots and must be frozen by an admin with POST /invoices/freeze first", invoice.InvoiceNumber)
+	}
+	_, hash, err := invoice.content()
+	if err != nil {
+		return err
+	}
+	if hash != invoice.ContentHash {
+		return fmt.Errorf("invoice %s was changed outside its version history: its content doesn't match the hash of version %d", invoice.InvoiceNumber, invoice.Version)
+	}
+	return nil
+}
+
+// FreezeLegacyInvoices freezes every invoice without a version, see freeze
+// An invoice that fails is reported and left unversioned, and doesn't stop the others
+func (s *invoiceService) FreezeLegacyInvoices(ctx context.Context) (*FreezeReport, error) {
+	invoices, err := s.repo.GetUnversioned(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoices without a version: %w", err)
+	}
+	report := &FreezeReport{Failed: []FreezeFailure{}}
+	for i := range invoices {
+		if err := s.freeze(ctx, &invoices[i]); err != nil {
+			log.Printf("Failed to freeze invoice %d: %v", invoices[i].InvoiceID, err)
+			report.Failed = append(report.Failed, FreezeFailure{
+				InvoiceID:     invoices[i].InvoiceID,
+				InvoiceNumber: invoices[i].InvoiceNumber,
+				Error:         err.Error(),
+			})
+			continue
+		}
+		report.Frozen++
+	}
+	return report, nil
+}
+
+// freeze completes an invoice from before versioning with its order, customer and the configured seller,
+// and stores the result as its first version
+// The missing parts are taken from the data as it is when the freeze runs, which is why it is an explicit job.
+// Every lookup has to succeed, so the invoice is never stored incomplete.
+func (s *invoiceService) freeze(ctx context.Context, invoice *Invoice) error {
+	if invoice.Username == "" || len(invoice.Items) == 0 || invoice.Subtotal.IsZero() {
+		if err := s.completeFromOrder(ctx, invoice); err != nil {
+			return fmt.Errorf("failed to complete invoice %s from its order: %w", invoice.InvoiceNumber, err)
+		}
+	}
+	if invoice.Seller.Name == "" {
+		invoice.Seller = s.sellerParty()
+	}
+	if invoice.Buyer.Name == "" {
+		u, err := s.userService.GetUserByID(ctx, invoice.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get user details of invoice %s: %w", invoice.InvoiceNumber, err)
+		}
+		if u == nil {
+			return fmt.Errorf("user of invoice %s not found", invoice.InvoiceNumber)
+		}
+		invoice.Buyer = s.buyerParty(invoice.Username, u, Address{})
+	}
+
+	err := s.saveVersion(ctx, invoice, VersionFrozen)
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		// Frozen by another run in the meantime
+		stored, err := s.repo.GetByID(ctx, invoice.InvoiceID)
+		if err != nil {
+			return err
+		}
+		if stored.Version == 0 {
+			return fmt.Errorf("invoice %s has no version after it was frozen", invoice.InvoiceNumber)
+		}
+		*invoice = *stored
+		return s.checkContent(ctx, invoice)
+	}
+	return err
+}
+
+// completeFromOrder fills in the customer, items and totals of an invoice from before they were stored
+func (s *invoiceService) completeFromOrder(ctx context.Context, invoice *Invoice) error {
+	orderDetails, err := s.orderService.GetOrderByID(ctx, invoice.OrderID)
+	if err != nil {
+		return fmt.Errorf("failed to get order details: %w", err)
+	}
+	if invoice.UserID == 0 {
+		invoice.UserID = orderDetails.UserID
+	}
+	if invoice.Username == "" {
+		u, err := s.userService.GetUserByID(ctx, invoice.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to get user details: %w", err)
+		}
+		if u == nil {
+			return fmt.Errorf("user not found")
+		}
+		invoice.Username = u.Username
+	}
+
+	if len(invoice.Items) == 0 {
+		for _, orderItem := range orderDetails.Items {
+			productDetails, err := s.productService.GetProductByID(ctx, orderItem.ProductID)
+			if err != nil {
+				return fmt.Errorf("failed to get product details for product %d: %w", orderItem.ProductID, err)
+			}
+			item := InvoiceItem{
+				ProductID:   orderItem.ProductID,
+				VariantID:   orderItem.VariantID,
+				SKU:         orderItem.SKU,
+				ProductName: productDetails.Name,
+				Description: productDetails.Description,
+				Quantity:    orderItem.Quantity,
+				UnitPrice:   orderItem.UnitPrice,
+				TotalPrice:  orderItem.Total,
+				Discount:    orderItem.Discount,
+				Tax:         orderItem.Tax,
+			}
+			if err := s.addVariantAttributes(ctx, &item); err != nil {
+				return err
+			}
+			invoice.Items = append(invoice.Items, item)
+		}
+	}
+
+	// Invoices created before exchange rates were recorded take them from the order
+	if len(invoice.ExchangeRates) == 0 && invoice.Currency == orderDetails.Currency {
+		invoice.ExchangeRates = orderDetails.ExchangeRates
+	}
+	if invoice.Subtotal.IsZero() {
+		invoice.Currency = orderDetails.Currency
+		invoice.ExchangeRates = orderDetails.ExchangeRates
+		invoice.Discounts = orderDetails.Discounts
+		invoice.Discount = orderDetails.Discount
+		invoice.Subtotal = orderDetails.Subtotal
+		invoice.Tax = orderDetails.Tax
+		invoice.Total = orderDetails.Total
+	}
+	return nil
+}
+
+// UpdateBillingAddress changes the billing address of a draft invoice as a new version
+func (s *invoiceService) UpdateBillingAddress(ctx context.Context, invoiceID uint64, address Address) (*Invoice, error) {
+	address, err := normalizeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err := s.getInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.Status != StatusDraft {
+		return nil, fmt.Errorf("%s invoice must not change, only the billing address of drafts can", invoice.Status)
+	}
+	if address.Country == "" {
+		address.Country = s.ublExporter.config.BuyerCountry
+	}
+	invoice.Buyer.Address = address
+	if err := s.saveVersion(ctx, invoice, VersionBillingAddress); err != nil {
+		return nil, fmt.Errorf("failed to save invoice version: %w", err)
+	}
+	return invoice, s.loadPayments(ctx, invoice)
+}
+
+// GetVersions returns an invoice with its version history, checking every version against its hash
+func (s *invoiceService) GetVersions(ctx context.Context, invoiceID uint64) (*Invoice, []InvoiceVersion, error) {
+	invoice, err := s.getInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	versions, err := s.repo.GetVersions(ctx, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, version := range versions {
+		if contentHash(version.Content) != version.ContentHash {
+			return nil, nil, fmt.Errorf("version %d of invoice %s doesn't match its content hash", version.Version, invoice.InvoiceNumber)
+		}
+	}
+	if len(versions) == 0 || versions[len(versions)-1].ContentHash != invoice.ContentHash {
+		return nil, nil, fmt.Errorf("invoice %s doesn't match the last version of its history", invoice.InvoiceNumber)
+	}
+	return invoice, versions, nil
+}
@@ -0,0 +1,506 @@
+package invoice
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// Identifiers of UBL 2.1 invoices conforming to Peppol BIS Billing 3.0
+const (
+	peppolCustomizationID = "urn:cen.eu:en16931:2017#compliant#urn:fdc:peppol.eu:2017:poacc:billing:3.0"
+	peppolProfileID       = "urn:fdc:peppol.eu:2017:poacc:billing:01:1.0"
+	ublInvoiceNamespace   = "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2"
+	ublCACNamespace       = "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2"
+	ublCBCNamespace       = "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2"
+	ublCommercialInvoice  = "380" // UNCL1001 code of a commercial invoice
+	ublUnitCode           = "C62" // UN/ECE Recommendation 20 code of "one", as items are sold per piece
+)
+
+// Tax category codes (UNCL5305) of invoice lines
+const (
+	taxCategoryStandard = "S"
+	taxCategoryZero     = "Z"
+	taxCategoryExempt   = "E"
+)
+
+// EInvoicing configures the seller and the party identifiers of UBL e-invoices
+// The seller and the buyer's identifiers are stored with each invoice when it is created, see Invoice.Seller
+type EInvoicing struct {
+	Seller Seller `json:"seller"`
+	// TaxScheme identifies the tax of the tax categories, "VAT" by default
+	TaxScheme string `json:"tax_scheme"`
+	// BuyerEndpointScheme is the EAS code of the customers' electronic addresses; with "EM", the default,
+	// their email address is used
+	BuyerEndpointScheme string `json:"buyer_endpoint_scheme"`
+	// BuyerCountry is the ISO 3166-1 alpha-2 code of the country of billing addresses that don't state one
+	BuyerCountry string `json:"buyer_country"`
+	// ExemptionReason is stated for the lines of tax exempt products, e.g. "Exempt under Article 132 of Directive 2006/112/EC"
+	ExemptionReason string `json:"exemption_reason"`
+	// PaymentMeansCode is the UNCL4461 code of how invoices are paid, "30" (credit transfer) by default
+	PaymentMeansCode string `json:"payment_means_code"`
+	// IBAN is the account invoices are paid to; payment means are only stated with it
+	IBAN string `json:"iban"`
+}
+
+// Seller is the legal identity of the seller of e-invoices
+type Seller struct {
+	Name             string `json:"name"`              // registered legal name
+	EndpointScheme   string `json:"endpoint_scheme"`   // EAS code of the Peppol address, e.g. "0088" for a GLN or "9930" for a German VAT number
+	EndpointID       string `json:"endpoint_id"`       // Peppol address within the scheme
+	IdentifierScheme string `json:"identifier_scheme"` // optional ISO 6523 code of Identifier
+	Identifier       string `json:"identifier"`        // optional, e.g. a GLN
+	VATID            string `json:"vat_id"`            // with its country prefix, e.g. "GB123456789"
+	LegalID          string `json:"legal_id"`          // optional company registration number
+	Street           string `json:"street"`
+	AdditionalStreet string `json:"additional_street"`
+	City             string `json:"city"`
+	PostalZone       string `json:"postal_zone"`
+	Country          string `json:"country"` // ISO 3166-1 alpha-2 code, e.g. "GB"
+	ContactName      string `json:"contact_name"`
+	Phone            string `json:"phone"`
+	Email            string `json:"email"`
+}
+
+// LoadEInvoicing reads the e-invoicing configuration from a JSON file
+// An empty path returns a configuration without seller, whose e-invoices fail validation until one is configured
+func LoadEInvoicing(path string) (EInvoicing, error) {
+	var config EInvoicing
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EInvoicing{}, fmt.Errorf("failed to read e-invoicing configuration: %w", err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return EInvoicing{}, fmt.Errorf("failed to parse e-invoicing configuration: %w", err)
+	}
+	return config, nil
+}
+
+// UBLExporter exports invoices as UBL 2.1 documents conforming to Peppol BIS Billing 3.0
+type UBLExporter struct {
+	config EInvoicing
+}
+
+// NewUBLExporter creates an exporter for the seller and identifiers of an e-invoicing configuration
+func NewUBLExporter(config EInvoicing) *UBLExporter {
+	if config.TaxScheme == "" {
+		config.TaxScheme = "VAT"
+	}
+	if config.BuyerEndpointScheme == "" {
+		config.BuyerEndpointScheme = "EM"
+	}
+	if config.PaymentMeansCode == "" {
+		config.PaymentMeansCode = "30"
+	}
+	if config.ExemptionReason == "" {
+		config.ExemptionReason = "Exempt"
+	}
+	config.Seller.Country = strings.ToUpper(config.Seller.Country)
+	config.BuyerCountry = strings.ToUpper(config.BuyerCountry)
+	config.IBAN = strings.ReplaceAll(config.IBAN, " ", "")
+	return &UBLExporter{config: config}
+}
+
+// Export builds the UBL document of an invoice and validates it
+// Payments are stated as prepaid; credit notes are documents of their own and don't change the invoice.
+// An invoice breaking the rules of Peppol BIS Billing 3.0 returns a *UBLValidationError.
+func (e *UBLExporter) Export(invoice *Invoice) ([]byte, error) {
+	doc, err := e.document(invoice)
+	if err != nil {
+		return nil, err
+	}
+	if violations := validateUBL(doc); len(violations) > 0 {
+		return nil, &UBLValidationError{Violations: violations}
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// document maps an invoice with its stored seller and buyer to a UBL invoice
+func (e *UBLExporter) document(invoice *Invoice) (*ublInvoice, error) {
+	issueDate, err := ublDate(invoice.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issue date '%s': %w", invoice.CreatedAt, err)
+	}
+	dueDate, err := ublDate(invoice.DueDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due date '%s': %w", invoice.DueDate, err)
+	}
+
+	doc := &ublInvoice{
+		Namespace:            ublInvoiceNamespace,
+		CACNamespace:         ublCACNamespace,
+		CBCNamespace:         ublCBCNamespace,
+		CustomizationID:      peppolCustomizationID,
+		ProfileID:            peppolProfileID,
+		ID:                   invoice.InvoiceNumber,
+		IssueDate:            issueDate,
+		DueDate:              dueDate,
+		InvoiceTypeCode:      ublCommercialInvoice,
+		DocumentCurrencyCode: invoice.Currency,
+		Supplier:             ublPartyRole{Party: e.sellerParty(invoice.Seller)},
+		Customer:             ublPartyRole{Party: e.buyerParty(invoice.Buyer)},
+	}
+	if invoice.OrderID != 0 {
+		doc.OrderReference = &ublReference{ID: strconv.FormatUint(invoice.OrderID, 10)}
+	}
+	if e.config.IBAN != "" {
+		doc.PaymentMeans = &ublPaymentMeans{
+			PaymentMeansCode: e.config.PaymentMeansCode,
+			PaymentID:        invoice.InvoiceNumber,
+			Account:          &ublAccount{ID: e.config.IBAN, Name: invoice.Seller.Name},
+		}
+	}
+
+	// Lines are stated with their net amounts, which already have their share of the order's discounts deducted
+	lineTotal := money.Zero(invoice.Currency)
+	var subtotals []ublTaxSubtotal
+	for i, item := range invoice.Items {
+		line, category, lineTax := e.line(i+1, item, invoice.Currency)
+		doc.Lines = append(doc.Lines, line)
+		lineTotal = lineTotal.Add(line.LineExtensionAmount.money)
+		subtotals = addTaxSubtotal(subtotals, category, line.LineExtensionAmount.money, lineTax)
+	}
+	for i := range subtotals {
+		subtotals[i].TaxableAmount = ublMoney(subtotals[i].TaxableAmount.money)
+		subtotals[i].TaxAmount = ublMoney(subtotals[i].TaxAmount.money)
+	}
+	doc.TaxTotal = ublTaxTotal{TaxAmount: ublMoney(invoice.Tax), Subtotals: subtotals}
+
+	total := ublMonetaryTotal{
+		LineExtensionAmount: ublMoney(lineTotal),
+		TaxExclusiveAmount:  ublMoney(invoice.Subtotal),
+		TaxInclusiveAmount:  ublMoney(invoice.Total),
+		PayableAmount:       ublMoney(invoice.Total),
+	}
+	if invoice.AmountPaid.Currency != "" && !invoice.AmountPaid.IsZero() {
+		prepaid := ublMoney(invoice.AmountPaid)
+		total.PrepaidAmount = &prepaid
+		total.PayableAmount = ublMoney(invoice.Total.Sub(invoice.AmountPaid))
+	}
+	doc.LegalMonetaryTotal = total
+	return doc, nil
+}
+
+// sellerParty states the seller of an invoice
+func (e *UBLExporter) sellerParty(seller Party) ublParty {
+	party := ublParty{
+		EndpointID:    ublIdentifier{SchemeID: seller.EndpointScheme, Value: seller.EndpointID},
+		PartyName:     &ublPartyName{Name: seller.Name},
+		PostalAddress: ublPostalAddress(seller.Address),
+		LegalEntity:   ublLegalEntity{RegistrationName: seller.Name, CompanyID: seller.LegalID},
+	}
+	if seller.Identifier != "" {
+		party.Identification = &ublPartyIdentification{ID: ublIdentifier{SchemeID: seller.IdentifierScheme, Value: seller.Identifier}}
+	}
+	if seller.TaxID != "" {
+		party.TaxScheme = &ublPartyTaxScheme{CompanyID: seller.TaxID, TaxScheme: ublTaxScheme{ID: e.config.TaxScheme}}
+	}
+	if seller.ContactName != "" || seller.Phone != "" || seller.Email != "" {
+		party.Contact = &ublContact{Name: seller.ContactName, Telephone: seller.Phone, ElectronicMail: seller.Email}
+	}
+	return party
+}
+
+// buyerParty states the customer of an invoice at the electronic address stored with it
+func (e *UBLExporter) buyerParty(buyer Party) ublParty {
+	party := ublParty{
+		EndpointID:    ublIdentifier{SchemeID: buyer.EndpointScheme, Value: buyer.EndpointID},
+		PartyName:     &ublPartyName{Name: buyer.Name},
+		PostalAddress: ublPostalAddress(buyer.Address),
+		LegalEntity:   ublLegalEntity{RegistrationName: buyer.Name},
+	}
+	if buyer.CustomerID != 0 {
+		party.Identification = &ublPartyIdentification{ID: ublIdentifier{Value: strconv.FormatUint(buyer.CustomerID, 10)}}
+	}
+	if buyer.Email != "" {
+		party.Contact = &ublContact{ElectronicMail: buyer.Email}
+	}
+	return party
+}
+
+// ublPostalAddress states an address with its first two lines as street names
+// Further lines, which only the address of a seller configured by branding alone has, are left out
+func ublPostalAddress(address Address) ublAddress {
+	postal := ublAddress{
+		CityName:   address.City,
+		PostalZone: address.PostalZone,
+		Country:    ublCountry{IdentificationCode: address.Country},
+	}
+	if len(address.Lines) > 0 {
+		postal.StreetName = address.Lines[0]
+	}
+	if len(address.Lines) > 1 {
+		postal.AdditionalStreetName = address.Lines[1]
+	}
+	return postal
+}
+
+// line maps an invoice item to an invoice line, returning its tax category and tax amount
+// Items of invoices from before tax was broken down per line are zero rated; if such an invoice was
+// taxed, its tax total doesn't match its lines and it fails validation
+// The price is per piece when the net amount divides evenly by the quantity, otherwise it is stated
+// for the whole quantity, so quantity times price is always exactly the net amount
+func (e *UBLExporter) line(number int, item InvoiceItem, currency string) (ublLine, ublTaxCategory, money.Money) {
+	net := item.TotalPrice.Sub(item.Discount)
+	lineTax := money.Zero(currency)
+	category := ublTaxCategory{ID: taxCategoryZero, Percent: "0", TaxScheme: ublTaxScheme{ID: e.config.TaxScheme}}
+	if item.Tax != nil {
+		net, lineTax = item.Tax.Net, item.Tax.Tax
+		switch {
+		case item.Tax.Exempt:
+			category.ID = taxCategoryExempt
+			category.ExemptionReason = e.config.ExemptionReason
+		case item.Tax.Rate != 0:
+			category.ID = taxCategoryStandard
+			category.Percent = strings.TrimSuffix(formatPercent(item.Tax.Rate), "%")
+		}
+	}
+
+	price := ublPrice{PriceAmount: ublMoney(net)}
+	if item.Quantity > 1 {
+		if net.Amount%int64(item.Quantity) == 0 {
+			price.PriceAmount = ublMoney(money.New(net.Amount/int64(item.Quantity), net.Currency))
+		} else {
+			price.BaseQuantity = &ublQuantity{UnitCode: ublUnitCode, Value: strconv.Itoa(item.Quantity)}
+		}
+	}
+
+	// Peppol states exemption reasons in the tax breakdown only
+	lineCategory := category
+	lineCategory.ExemptionReason = ""
+	lineItem := ublItem{
+		Description: item.Description,
+		Name:        item.ProductName,
+		TaxCategory: lineCategory,
+	}
+	if item.SKU != "" {
+		lineItem.SellersItemIdentification = &ublItemIdentification{ID: item.SKU}
+	}
+	names := make([]string, 0, len(item.Attributes))
+	for name := range item.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lineItem.Properties = append(lineItem.Properties, ublItemProperty{Name: name, Value: item.Attributes[name]})
+	}
+
+	return ublLine{
+		ID:                  strconv.Itoa(number),
+		InvoicedQuantity:    ublQuantity{UnitCode: ublUnitCode, Value: strconv.Itoa(item.Quantity)},
+		LineExtensionAmount: ublMoney(net),
+		Item:                lineItem,
+		Price:               price,
+	}, category, lineTax
+}
+
+// addTaxSubtotal adds the net and tax amounts of a line to the subtotal of its tax category and rate
+func addTaxSubtotal(subtotals []ublTaxSubtotal, category ublTaxCategory, net, lineTax money.Money) []ublTaxSubtotal {
+	for i := range subtotals {
+		if subtotals[i].TaxCategory.ID == category.ID && subtotals[i].TaxCategory.Percent == category.Percent {
+			subtotals[i].TaxableAmount.money = subtotals[i].TaxableAmount.money.Add(net)
+			subtotals[i].TaxAmount.money = subtotals[i].TaxAmount.money.Add(lineTax)
+			return subtotals
+		}
+	}
+	return append(subtotals, ublTaxSubtotal{
+		TaxableAmount: ublAmount{money: net},
+		TaxAmount:     ublAmount{money: lineTax},
+		TaxCategory:   category,
+	})
+}
+
+// ublDate formats a stored RFC 3339 timestamp as a UBL date, in the time zone it was stored with
+func ublDate(value string) (string, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format("2006-01-02"), nil
+}
+
+// ublMoney states an amount with its currency
+func ublMoney(m money.Money) ublAmount {
+	return ublAmount{CurrencyID: m.Currency, Value: m.String(), money: m}
+}
+
+// UBL 2.1 elements used by Peppol BIS Billing 3.0, in the order the schema requires
+// Elements are named with the cac and cbc prefixes the root element declares
+
+type ublInvoice struct {
+	XMLName              xml.Name         `xml:"Invoice"`
+	Namespace            string           `xml:"xmlns,attr"`
+	CACNamespace         string           `xml:"xmlns:cac,attr"`
+	CBCNamespace         string           `xml:"xmlns:cbc,attr"`
+	CustomizationID      string           `xml:"cbc:CustomizationID"`
+	ProfileID            string           `xml:"cbc:ProfileID"`
+	ID                   string           `xml:"cbc:ID"`
+	IssueDate            string           `xml:"cbc:IssueDate"`
+	DueDate              string           `xml:"cbc:DueDate,omitempty"`
+	InvoiceTypeCode      string           `xml:"cbc:InvoiceTypeCode"`
+	DocumentCurrencyCode string           `xml:"cbc:DocumentCurrencyCode"`
+	BuyerReference       string           `xml:"cbc:BuyerReference,omitempty"`
+	OrderReference       *ublReference    `xml:"cac:OrderReference,omitempty"`
+	Supplier             ublPartyRole     `xml:"cac:AccountingSupplierParty"`
+	Customer             ublPartyRole     `xml:"cac:AccountingCustomerParty"`
+	PaymentMeans         *ublPaymentMeans `xml:"cac:PaymentMeans,omitempty"`
+	TaxTotal             ublTaxTotal      `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal   ublMonetaryTotal `xml:"cac:LegalMonetaryTotal"`
+	Lines                []ublLine        `xml:"cac:InvoiceLine"`
+}
+
+type ublReference struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type ublPartyRole struct {
+	Party ublParty `xml:"cac:Party"`
+}
+
+type ublParty struct {
+	EndpointID     ublIdentifier           `xml:"cbc:EndpointID"`
+	Identification *ublPartyIdentification `xml:"cac:PartyIdentification,omitempty"`
+	PartyName      *ublPartyName           `xml:"cac:PartyName,omitempty"`
+	PostalAddress  ublAddress              `xml:"cac:PostalAddress"`
+	TaxScheme      *ublPartyTaxScheme      `xml:"cac:PartyTaxScheme,omitempty"`
+	LegalEntity    ublLegalEntity          `xml:"cac:PartyLegalEntity"`
+	Contact        *ublContact             `xml:"cac:Contact,omitempty"`
+}
+
+type ublIdentifier struct {
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type ublPartyIdentification struct {
+	ID ublIdentifier `xml:"cbc:ID"`
+}
+
+type ublPartyName struct {
+	Name string `xml:"cbc:Name"`
+}
+
+type ublAddress struct {
+	StreetName           string     `xml:"cbc:StreetName,omitempty"`
+	AdditionalStreetName string     `xml:"cbc:AdditionalStreetName,omitempty"`
+	CityName             string     `xml:"cbc:CityName,omitempty"`
+	PostalZone           string     `xml:"cbc:PostalZone,omitempty"`
+	Country              ublCountry `xml:"cac:Country"`
+}
+
+type ublCountry struct {
+	IdentificationCode string `xml:"cbc:IdentificationCode"`
+}
+
+type ublPartyTaxScheme struct {
+	CompanyID string       `xml:"cbc:CompanyID"`
+	TaxScheme ublTaxScheme `xml:"cac:TaxScheme"`
+}
+
+type ublTaxScheme struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type ublLegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+	CompanyID        string `xml:"cbc:CompanyID,omitempty"`
+}
+
+type ublContact struct {
+	Name           string `xml:"cbc:Name,omitempty"`
+	Telephone      string `xml:"cbc:Telephone,omitempty"`
+	ElectronicMail string `xml:"cbc:ElectronicMail,omitempty"`
+}
+
+type ublPaymentMeans struct {
+	PaymentMeansCode string      `xml:"cbc:PaymentMeansCode"`
+	PaymentID        string      `xml:"cbc:PaymentID,omitempty"`
+	Account          *ublAccount `xml:"cac:PayeeFinancialAccount,omitempty"`
+}
+
+type ublAccount struct {
+	ID   string `xml:"cbc:ID"`
+	Name string `xml:"cbc:Name,omitempty"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount ublAmount        `xml:"cbc:TaxAmount"`
+	Subtotals []ublTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount ublAmount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     ublAmount      `xml:"cbc:TaxAmount"`
+	TaxCategory   ublTaxCategory `xml:"cac:TaxCategory"`
+}
+
+type ublTaxCategory struct {
+	ID              string       `xml:"cbc:ID"`
+	Percent         string       `xml:"cbc:Percent,omitempty"`
+	ExemptionReason string       `xml:"cbc:TaxExemptionReason,omitempty"`
+	TaxScheme       ublTaxScheme `xml:"cac:TaxScheme"`
+}
+
+type ublMonetaryTotal struct {
+	LineExtensionAmount ublAmount  `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount  ublAmount  `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount  ublAmount  `xml:"cbc:TaxInclusiveAmount"`
+	PrepaidAmount       *ublAmount `xml:"cbc:PrepaidAmount,omitempty"`
+	PayableAmount       ublAmount  `xml:"cbc:PayableAmount"`
+}
+
+type ublLine struct {
+	ID                  string      `xml:"cbc:ID"`
+	InvoicedQuantity    ublQuantity `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount ublAmount   `xml:"cbc:LineExtensionAmount"`
+	Item                ublItem     `xml:"cac:Item"`
+	Price               ublPrice    `xml:"cac:Price"`
+}
+
+type ublItem struct {
+	Description               string                 `xml:"cbc:Description,omitempty"`
+	Name                      string                 `xml:"cbc:Name"`
+	SellersItemIdentification *ublItemIdentification `xml:"cac:SellersItemIdentification,omitempty"`
+	TaxCategory               ublTaxCategory         `xml:"cac:ClassifiedTaxCategory"`
+	Properties                []ublItemProperty      `xml:"cac:AdditionalItemProperty"`
+}
+
+type ublItemIdentification struct {
+	ID string `xml:"cbc:ID"`
+}
+
+type ublItemProperty struct {
+	Name  string `xml:"cbc:Name"`
+	Value string `xml:"cbc:Value"`
+}
+
+type ublPrice struct {
+	PriceAmount  ublAmount    `xml:"cbc:PriceAmount"`
+	BaseQuantity *ublQuantity `xml:"cbc:BaseQuantity,omitempty"`
+}
+
+type ublQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// ublAmount keeps the amount it states, so the validator can check totals without parsing them again
+type ublAmount struct {
+	CurrencyID string `xml:"currencyID,attr"`
+	Value      string `xml:",chardata"`
+	money      money.Money
+}
@@ -0,0 +1,107 @@
+package invoice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// UBLValidationError lists the rules of Peppol BIS Billing 3.0 an e-invoice breaks
+type UBLValidationError struct {
+	Violations []string
+}
+
+func (e *UBLValidationError) Error() string {
+	return "invalid e-invoice: " + strings.Join(e.Violations, "; ")
+}
+
+var (
+	countryCode  = regexp.MustCompile(`^[A-Z]{2}$`)
+	currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+	// endpointScheme matches the EAS codes of the Peppol network: four digits, or "EM" for email
+	endpointScheme = regexp.MustCompile(`^([0-9]{4}|EM)$`)
+	// vatID has the ISO 3166-1 prefix of its country, or "EL" for Greece
+	vatID = regexp.MustCompile(`^[A-Z]{2}[0-9A-Za-z+*.]{2,13}$`)
+)
+
+// validateUBL checks the fields required by EN 16931 and Peppol BIS Billing 3.0 and the sums of the totals,
+// returning the broken rules with their identifiers, e.g. "BR-06: seller name is required"
+// It covers what this exporter can get wrong, mostly configuration and data of old invoices;
+// the schematron of the Peppol network remains the authority.
+func validateUBL(doc *ublInvoice) []string {
+	var violations []string
+	check := func(ok bool, rule, format string, args ...interface{}) {
+		if !ok {
+			violations = append(violations, rule+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(doc.CustomizationID != "", "BR-01", "specification identifier is required")
+	check(doc.ProfileID != "", "PEPPOL-EN16931-R001", "business process is required")
+	check(doc.ID != "", "BR-02", "invoice number is required")
+	check(doc.IssueDate != "", "BR-03", "issue date is required")
+	check(doc.InvoiceTypeCode != "", "BR-04", "invoice type code is required")
+	check(currencyCode.MatchString(doc.DocumentCurrencyCode), "BR-05", "invoice currency code '%s' must be an ISO 4217 code", doc.DocumentCurrencyCode)
+	check(doc.BuyerReference != "" || doc.OrderReference != nil, "PEPPOL-EN16931-R003", "buyer reference or order reference is required")
+
+	seller, buyer := doc.Supplier.Party, doc.Customer.Party
+	check(seller.LegalEntity.RegistrationName != "", "BR-06", "seller name is required")
+	check(buyer.LegalEntity.RegistrationName != "", "BR-07", "buyer name is required")
+	check(countryCode.MatchString(seller.PostalAddress.Country.IdentificationCode), "BR-09", "seller country code '%s' must be an ISO 3166-1 alpha-2 code", seller.PostalAddress.Country.IdentificationCode)
+	check(countryCode.MatchString(buyer.PostalAddress.Country.IdentificationCode), "BR-11", "buyer country code '%s' must be an ISO 3166-1 alpha-2 code", buyer.PostalAddress.Country.IdentificationCode)
+	check(seller.EndpointID.Value != "", "PEPPOL-EN16931-R020", "seller electronic address is required")
+	check(endpointScheme.MatchString(seller.EndpointID.SchemeID), "BR-62", "seller electronic address scheme '%s' must be an EAS code", seller.EndpointID.SchemeID)
+	check(buyer.EndpointID.Value != "", "PEPPOL-EN16931-R010", "buyer electronic address is required")
+	check(endpointScheme.MatchString(buyer.EndpointID.SchemeID), "BR-63", "buyer electronic address scheme '%s' must be an EAS code", buyer.EndpointID.SchemeID)
+	if seller.TaxScheme != nil {
+		check(vatID.MatchString(seller.TaxScheme.CompanyID), "BR-CO-09", "seller VAT identifier '%s' must start with its country code", seller.TaxScheme.CompanyID)
+	}
+
+	check(len(doc.Lines) > 0, "BR-16", "at least one invoice line is required")
+	lineTotal := money.Zero(doc.DocumentCurrencyCode)
+	for _, line := range doc.Lines {
+		check(line.InvoicedQuantity.Value != "" && line.InvoicedQuantity.Value != "0", "BR-22", "line %s must have a quantity", line.ID)
+		check(line.Item.Name != "", "BR-25", "line %s item name is required", line.ID)
+		check(!line.Price.PriceAmount.money.IsNegative(), "BR-27", "line %s price must not be negative", line.ID)
+		check(line.Item.TaxCategory.ID != "", "BR-CO-04", "line %s tax category is required", line.ID)
+		if line.LineExtensionAmount.money.SameCurrency(lineTotal) {
+			lineTotal = lineTotal.Add(line.LineExtensionAmount.money)
+		} else {
+			check(false, "BR-CO-10", "line %s amount must be in the invoice currency", line.ID)
+		}
+	}
+
+	taxTotal := money.Zero(doc.DocumentCurrencyCode)
+	standardRated := false
+	check(len(doc.TaxTotal.Subtotals) > 0, "BR-CO-18", "at least one tax breakdown is required")
+	for _, subtotal := range doc.TaxTotal.Subtotals {
+		category := subtotal.TaxCategory
+		taxTotal = taxTotal.Add(subtotal.TaxAmount.money)
+		switch category.ID {
+		case taxCategoryStandard:
+			standardRated = true
+			check(category.Percent != "" && category.Percent != "0", "BR-S-05", "standard rated tax breakdown must have a rate above zero")
+		case taxCategoryZero, taxCategoryExempt:
+			check(subtotal.TaxAmount.money.IsZero(), "BR-"+category.ID+"-09", "tax of category %s must be zero", category.ID)
+		}
+		if category.ID == taxCategoryExempt {
+			check(category.ExemptionReason != "", "BR-E-10", "exempt tax breakdown must state an exemption reason")
+		}
+	}
+	check(!standardRated || seller.TaxScheme != nil, "BR-S-02", "seller VAT identifier is required for standard rated lines")
+
+	totals := doc.LegalMonetaryTotal
+	payable := totals.TaxInclusiveAmount.money
+	if totals.PrepaidAmount != nil {
+		payable = payable.Sub(totals.PrepaidAmount.money)
+	}
+	check(totals.LineExtensionAmount.money == lineTotal, "BR-CO-10", "sum of line amounts %s must equal the lines total %s", lineTotal, totals.LineExtensionAmount.Value)
+	check(totals.TaxExclusiveAmount.money == totals.LineExtensionAmount.money, "BR-CO-13", "total without tax %s must equal the lines total %s", totals.TaxExclusiveAmount.Value, totals.LineExtensionAmount.Value)
+	check(doc.TaxTotal.TaxAmount.money == taxTotal, "BR-CO-14", "tax total %s must equal the sum of the tax breakdown %s", doc.TaxTotal.TaxAmount.Value, taxTotal)
+	check(totals.TaxInclusiveAmount.money == totals.TaxExclusiveAmount.money.Add(doc.TaxTotal.TaxAmount.money), "BR-CO-15", "total with tax %s must equal the total without tax plus the tax total", totals.TaxInclusiveAmount.Value)
+	check(totals.PayableAmount.money == payable, "BR-CO-16", "amount due %s must equal the total with tax minus the paid amount", totals.PayableAmount.Value)
+	check(totals.PayableAmount.money.IsNegative() || totals.PayableAmount.money.IsZero() || doc.DueDate != "", "BR-CO-25", "due date is required while an amount is due")
+	return violations
+}
@@ -11,11 +11,20 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/blobstore"
+	"github.com/rajindersingh041/go-auth-sessions/cart"
+	"github.com/rajindersingh041/go-auth-sessions/category"
+	"github.com/rajindersingh041/go-auth-sessions/fx"
 	"github.com/rajindersingh041/go-auth-sessions/invoice"
 	"github.com/rajindersingh041/go-auth-sessions/order"
 	"github.com/rajindersingh041/go-auth-sessions/orderproduction"
+	"github.com/rajindersingh041/go-auth-sessions/payment"
+	"github.com/rajindersingh041/go-auth-sessions/subscription"
 	"github.com/rajindersingh041/go-auth-sessions/product"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/review"
 	"github.com/rajindersingh041/go-auth-sessions/user"
+	"github.com/rajindersingh041/go-auth-sessions/wishlist"
 )
 
 func main() {
@@ -44,15 +53,46 @@ func main() {
 		log.Printf("Warning: Failed to initialize sample products: %v", err)
 	}
 
+	// Move products that only have a free-text category into the category tree
+	if count, err := container.ProductService.MigrateCategories(context.Background()); err != nil {
+		log.Printf("Warning: Failed to migrate product categories: %v", err)
+	} else if count > 0 {
+		log.Printf("Migrated %d product categories into the category tree", count)
+	}
+
+	// Load exchange rates from FX_RATES_FILE (CSV: base,quote,rate,effective_date)
+	if path := os.Getenv("FX_RATES_FILE"); path != "" {
+		if err := loadExchangeRates(container.FXService, path); err != nil {
+			log.Printf("Warning: Failed to load exchange rates: %v", err)
+		}
+	}
+
+	// Remove abandoned carts, apply scheduled prices, bill subscriptions and chase overdue invoices in the background until shutdown
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	go cart.RunJanitor(janitorCtx, container.CartService, time.Hour)
+	go product.RunPriceScheduler(janitorCtx, container.ProductService, time.Minute)
+	go subscription.RunScheduler(janitorCtx, container.SubscriptionService, time.Minute)
+	go invoice.RunDunningScheduler(janitorCtx, container.InvoiceService, time.Hour)
+
 	// Create HTTP handlers
 	userHandler := user.NewHandler(container.UserService, container.JWTManager)
-	orderHandler := order.NewHandler(container.OrderService, container.UserService)
-	productHandler := product.NewHandler(container.ProductService, container.JWTManager)
-	invoiceHandler := invoice.NewHandler(container.InvoiceService, container.JWTManager)
+	orderHandler := order.NewHandler(container.OrderService, container.UserService, container.IdempotencyStore, container.Admins)
+	productHandler := product.NewHandler(container.ProductService, container.JWTManager, container.Admins)
+	invoiceHandler := invoice.NewHandler(container.InvoiceService, container.UserService, container.JWTManager, container.Admins)
 	orderproductionHandler := orderproduction.NewProductionHandler(container.OrderProductionService, container.OrderService)
+	fxHandler := fx.NewHandler(container.FXService, container.Admins)
+	promotionHandler := promotion.NewHandler(container.PromotionService, container.Admins)
+	cartHandler := cart.NewHandler(container.CartService, container.UserService, container.IdempotencyStore)
+	categoryHandler := category.NewHandler(container.CategoryService, container.Admins)
+	mediaHandler := blobstore.NewHandler(container.BlobStore)
+	reviewHandler := review.NewHandler(container.ReviewService, container.UserService, container.Admins)
+	wishlistHandler := wishlist.NewHandler(container.WishlistService, container.UserService)
+	paymentHandler := payment.NewHandler(container.PaymentService, container.UserService, container.Admins)
+	subscriptionHandler := subscription.NewHandler(container.SubscriptionService, container.UserService, container.Admins)
 
 	// Setup HTTP server with routes
-	server := setupServer(userHandler, orderHandler, productHandler, invoiceHandler, container.JWTManager,orderproductionHandler)
+	server := setupServer(userHandler, orderHandler, productHandler, invoiceHandler, container.JWTManager,orderproductionHandler, fxHandler, promotionHandler, cartHandler, categoryHandler, mediaHandler, reviewHandler, wishlistHandler, paymentHandler, subscriptionHandler)
 
 	// Get port from environment
 	port := getEnv("PORT", "8080")
@@ -95,7 +135,7 @@ func main() {
 }
 
 // setupServer configures HTTP routes and middleware
-func setupServer(userHandler *user.Handler, orderHandler *order.Handler, productHandler *product.Handler, invoiceHandler *invoice.Handler, jwtManager auth.JWTManager, orderProductionHandler * orderproduction.ProductionHandler) http.Handler {
+func setupServer(userHandler *user.Handler, orderHandler *order.Handler, productHandler *product.Handler, invoiceHandler *invoice.Handler, jwtManager auth.JWTManager, orderProductionHandler * orderproduction.ProductionHandler, fxHandler *fx.Handler, promotionHandler *promotion.Handler, cartHandler *cart.Handler, categoryHandler *category.Handler, mediaHandler *blobstore.Handler, reviewHandler *review.Handler, wishlistHandler *wishlist.Handler, paymentHandler *payment.Handler, subscriptionHandler *subscription.Handler) http.Handler {
 	mux := http.NewServeMux()
 
 	// Health check endpoint
@@ -107,6 +147,15 @@ func setupServer(userHandler *user.Handler, orderHandler *order.Handler, product
 	productHandler.RegisterRoutes(mux, jwtManager)
 	invoiceHandler.RegisterRoutes(mux, jwtManager)
 	orderProductionHandler.RegisterRoutes(mux, jwtManager)
+	fxHandler.RegisterRoutes(mux, jwtManager)
+	promotionHandler.RegisterRoutes(mux, jwtManager)
+	cartHandler.RegisterRoutes(mux, jwtManager)
+	categoryHandler.RegisterRoutes(mux, jwtManager)
+	mediaHandler.RegisterRoutes(mux)
+	reviewHandler.RegisterRoutes(mux, jwtManager)
+	wishlistHandler.RegisterRoutes(mux, jwtManager)
+	paymentHandler.RegisterRoutes(mux, jwtManager)
+	subscriptionHandler.RegisterRoutes(mux, jwtManager)
 
 	// Apply global middleware: logging, recovery, CORS, etc.
 	handler := globalLoggingMiddleware(globalRecoveryMiddleware(mux))
@@ -122,6 +171,22 @@ func handleHealth() http.HandlerFunc {
 	}
 }
 
+// loadExchangeRates imports exchange rates from a CSV file
+func loadExchangeRates(fxService fx.FXService, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := fxService.ImportCSV(context.Background(), file)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %d exchange rates from %s", count, path)
+	return nil
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
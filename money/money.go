@@ -0,0 +1,365 @@
+package money // Package money provides an exact decimal money type with a currency code.
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used for amounts given without a currency
+const DefaultCurrency = "USD"
+
+// Money is an exact amount in the minor unit of its currency (e.g. cents)
+// Arithmetic never goes through float64, so 0.10 * 3 is always exactly 0.30
+type Money struct {
+	Amount   int64  // amount in minor units
+	Currency string // ISO 4217 code, e.g. "USD"
+}
+
+// zeroDecimalCurrencies have no minor unit
+var zeroDecimalCurrencies = map[string]bool{
+	"JPY": true, "KRW": true, "VND": true, "CLP": true, "ISK": true, "UGX": true, "XAF": true, "XOF": true,
+}
+
+// Scale returns the number of decimal places used by a currency
+// Amounts are stored in DECIMAL(x,2) columns, so currencies with three decimals are not supported
+func Scale(currency string) int {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return 0
+	}
+	return 2
+}
+
+// New creates a Money from an amount in minor units
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: strings.ToUpper(currency)}
+}
+
+// Zero returns a zero amount in the given currency
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// Parse parses a decimal string such as "2499.99" into Money
+func Parse(amount, currency string) (Money, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	minor, err := parseMinor(strings.TrimSpace(amount), Scale(currency))
+	if err != nil {
+		return Money{}, err
+	}
+	return New(minor, currency), nil
+}
+
+// MustParse is like Parse but panics on error; use it for constants such as seed data
+func MustParse(amount, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// parseMinor converts a decimal string to an integer number of minor units
+func parseMinor(s string, scale int) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount: empty")
+	}
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	// Trailing zeros beyond the currency scale are harmless ("10.500" for USD)
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > scale {
+		return 0, fmt.Errorf("invalid amount %q: more than %d decimal places", s, scale)
+	}
+	fracPart += strings.Repeat("0", scale-len(fracPart))
+	if intPart == "" {
+		intPart = "0"
+	}
+	digits := intPart + fracPart
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid amount: %q", s)
+		}
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// String formats the amount as a plain decimal, e.g. "2499.99"
+func (m Money) String() string {
+	scale := Scale(m.Currency)
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	if scale == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	pow := int64(math.Pow10(scale))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/pow, scale, amount%pow)
+}
+
+// Display formats the amount with its currency, e.g. "2499.99 USD"
+func (m Money) Display() string {
+	return m.String() + " " + m.Currency
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// SameCurrency reports whether two amounts can be combined
+// An empty currency is treated as compatible with any currency
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == "" || o.Currency == "" || m.Currency == o.Currency
+}
+
+// match returns the currency of combining two amounts, or an error if they are in different currencies
+func (m Money) match(o Money) (string, error) {
+	if !m.SameCurrency(o) {
+		return "", fmt.Errorf("currency mismatch %s vs %s", m.Currency, o.Currency)
+	}
+	if m.Currency != "" {
+		return m.Currency, nil
+	}
+	return o.Currency, nil
+}
+
+// mustMatch panics when combining different currencies, which is a programming error for amounts
+// the code itself derived in one currency; amounts from storage or requests use the Checked methods
+func (m Money) mustMatch(o Money) string {
+	currency, err := m.match(o)
+	if err != nil {
+		panic("money: " + err.Error())
+	}
+	return currency
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) Money {
+	return New(m.Amount+o.Amount, m.mustMatch(o))
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) Money {
+	return New(m.Amount-o.Amount, m.mustMatch(o))
+}
+
+// CheckedAdd returns m + o, or an error if they are in different currencies
+func (m Money) CheckedAdd(o Money) (Money, error) {
+	currency, err := m.match(o)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount+o.Amount, currency), nil
+}
+
+// CheckedSub returns m - o, or an error if they are in different currencies
+func (m Money) CheckedSub(o Money) (Money, error) {
+	currency, err := m.match(o)
+	if err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount-o.Amount, currency), nil
+}
+
+// Mul returns m multiplied by a whole quantity
+func (m Money) Mul(quantity int64) Money {
+	return New(m.Amount*quantity, m.Currency)
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return New(-m.Amount, m.Currency)
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or 1
+func (m Money) Cmp(o Money) int {
+	m.mustMatch(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	}
+	return 0
+}
+
+// CheckedCmp compares two amounts like Cmp, or returns an error if they are in different currencies
+func (m Money) CheckedCmp(o Money) (int, error) {
+	if _, err := m.match(o); err != nil {
+		return 0, err
+	}
+	return m.Cmp(o), nil
+}
+
+// Min returns the smaller of two amounts
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+// WithCurrency sets the currency of amounts that were scanned from amount-only columns
+// Amounts scanned without a currency use two decimals and are rescaled to the currency's scale
+func WithCurrency(currency string, amounts ...*Money) {
+	currency = strings.ToUpper(currency)
+	for _, m := range amounts {
+		if m.Currency == "" {
+			if scale := Scale(currency); scale != 2 {
+				m.Amount = divRound(m.Amount, int64(math.Pow10(2-scale)))
+			}
+		}
+		m.Currency = currency
+	}
+}
+
+// divRound divides and rounds half away from zero
+func divRound(num, den int64) int64 {
+	if den < 0 {
+		num, den = -num, -den
+	}
+	q, r := num/den, num%den
+	if r*2 >= den {
+		q++
+	} else if r*2 <= -den {
+		q--
+	}
+	return q
+}
+
+// Value implements driver.Valuer; amounts are stored as decimal strings in NUMERIC/Decimal columns
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC/Decimal columns
+// Only the amount is scanned; the currency comes from its own column (see WithCurrency)
+func (m *Money) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+		m.Amount = 0
+		return nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case int64:
+		m.Amount = v * int64(math.Pow10(Scale(m.Currency)))
+		return nil
+	case float64:
+		// Legacy Float64 columns
+		m.Amount = int64(math.Round(v * math.Pow10(Scale(m.Currency))))
+		return nil
+	case fmt.Stringer:
+		// e.g. decimal.Decimal from the ClickHouse driver
+		s = v.String()
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	// Database decimals may carry more places than the currency (e.g. "10.5000")
+	minor, err := parseMinor(s, 4)
+	if err != nil {
+		return err
+	}
+	m.Amount = divRound(minor, int64(math.Pow10(4-Scale(m.Currency))))
+	return nil
+}
+
+// jsonMoney is the JSON representation of Money
+// The amount is a string so that no JSON decoder turns it into a float
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes Money as {"amount":"2499.99","currency":"USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.String(), Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount":"2499.99","currency":"USD"} as well as a bare
+// number or string ("price": 2499.99) for clients using the older format.
+// Bare amounts get DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var amount, currency string
+	switch data[0] {
+	case '{':
+		var v struct {
+			Amount   json.RawMessage `json:"amount"`
+			Currency string          `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		currency = v.Currency
+		raw := bytes.TrimSpace(v.Amount)
+		if len(raw) > 0 && raw[0] == '"' {
+			if err := json.Unmarshal(raw, &amount); err != nil {
+				return err
+			}
+		} else {
+			amount = string(raw)
+		}
+	case '"':
+		if err := json.Unmarshal(data, &amount); err != nil {
+			return err
+		}
+	default:
+		amount = string(data)
+	}
+
+	parsed, err := Parse(normalizeNumber(amount), currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// normalizeNumber expands JSON numbers in exponent form (e.g. 1e2) to plain decimals
+func normalizeNumber(s string) string {
+	if !strings.ContainsAny(s, "eE") {
+		return s
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
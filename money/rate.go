@@ -0,0 +1,103 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// rateScale is the number of decimal places kept by Rate
+const rateScale = 1_000_000
+
+// Rate is an exact decimal fraction with six decimal places, e.g. 0.2 for 20% VAT
+// It is stored as millionths, so 0.08875 is exactly 88750
+type Rate int64
+
+// ParseRate parses a decimal string such as "0.2" into a Rate
+func ParseRate(s string) (Rate, error) {
+	v, err := parseMinor(strings.TrimSpace(normalizeNumber(s)), 6)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate: %w", err)
+	}
+	return Rate(v), nil
+}
+
+// MustParseRate is like ParseRate but panics on error
+func MustParseRate(s string) Rate {
+	r, err := ParseRate(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String formats the rate as a decimal, e.g. "0.2"
+func (r Rate) String() string {
+	s := strconv.FormatFloat(float64(r)/rateScale, 'f', -1, 64)
+	return s
+}
+
+// MarshalJSON encodes a Rate as a JSON number
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or string without going through float64
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+	parsed, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// MulRate returns m multiplied by a rate, rounded half away from zero to the minor unit
+func (m Money) MulRate(r Rate) Money {
+	return New(divRound(m.Amount*int64(r), rateScale), m.Currency)
+}
+
+// ExcludeRate removes a rate that is included in m, i.e. returns m / (1 + r)
+// Used to split tax out of tax-inclusive prices
+func (m Money) ExcludeRate(r Rate) Money {
+	return New(divRound(m.Amount*rateScale, rateScale+int64(r)), m.Currency)
+}
+
+// Convert converts m into another currency using an exchange rate
+// (units of the target currency per one unit of m's currency), rounded half away from zero
+func (m Money) Convert(to string, rate *big.Rat) Money {
+	// amount in major units = minor / 10^scale
+	value := new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(Scale(m.Currency)))
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetInt(pow10(Scale(to))))
+
+	num, den := value.Num(), value.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return New(quo.Int64(), to)
+}
+
+// pow10 returns 10^n as a big.Int
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendTimeout limits the time spent sending one email
+const sendTimeout = time.Minute
+
+// SMTPConfig configures an SMTP server for sending emails
+type SMTPConfig struct {
+	Host     string
+	Port     string // defaults to 587
+	Username string // optional; PLAIN authentication is used when set
+	Password string
+	From     string // sender address, e.g. "Shop <shop@example.com>"
+}
+
+// EmailNotifier sends notifications as plain text emails over SMTP, and is a Mailer for emails with attachments
+// STARTTLS is used whenever the server offers it
+type EmailNotifier struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+// NewEmailNotifier creates a notifier that emails users
+func NewEmailNotifier(config SMTPConfig) (*EmailNotifier, error) {
+	if config.Host == "" {
+		return nil, fmt.Errorf("SMTP host is required")
+	}
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP from address '%s': %w", config.From, err)
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return &EmailNotifier{config: config, from: from}, nil
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	if notification.Email == "" {
+		return fmt.Errorf("user %s has no email address", notification.Username)
+	}
+	to, err := mail.ParseAddress(notification.Email)
+	if err != nil {
+		return fmt.Errorf("invalid email address '%s': %w", notification.Email, err)
+	}
+	return n.deliver(ctx, to, n.message(to, notification))
+}
+
+func (n *EmailNotifier) Send(ctx context.Context, email Email) error {
+	if email.To == "" {
+		return fmt.Errorf("email address is required")
+	}
+	to, err := mail.ParseAddress(email.To)
+	if err != nil {
+		return fmt.Errorf("invalid email address '%s': %w", email.To, err)
+	}
+	message, err := n.multipartMessage(to, email)
+	if err != nil {
+		return err
+	}
+	return n.deliver(ctx, to, message)
+}
+
+// deliver sends a complete message to one recipient over a new SMTP session
+func (n *EmailNotifier) deliver(ctx context.Context, to *mail.Address, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.config.Host, n.config.Port))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, n.config.Host)
+	if err != nil {
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: n.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if n.config.Username != "" {
+		auth := smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(n.from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message builds the email with its headers; lines end in CRLF as SMTP requires
+func (n *EmailNotifier) message(to *mail.Address, notification Notification) []byte {
+	headers := append(n.headers(to, notification.Subject),
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: 8bit",
+	)
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + crlf(notification.Body) + "\r\n")
+}
+
+// multipartMessage builds an email with a plain text body followed by its attachments, encoded in base64
+func (n *EmailNotifier) multipartMessage(to *mail.Address, email Email) ([]byte, error) {
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+
+	body, err := mw.CreatePart(map[string][]string{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := body.Write([]byte(crlf(email.Body) + "\r\n")); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range email.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := mw.CreatePart(map[string][]string{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": attachment.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := part.Write(base64Lines(attachment.Data)); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	headers := append(n.headers(to, email.Subject), "Content-Type: multipart/mixed; boundary="+mw.Boundary())
+	return append([]byte(strings.Join(headers, "\r\n")+"\r\n\r\n"), parts.Bytes()...), nil
+}
+
+// headers returns the headers shared by all emails to a recipient
+func (n *EmailNotifier) headers(to *mail.Address, subject string) []string {
+	return []string{
+		"From: " + n.from.String(),
+		"To: " + to.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+	}
+}
+
+// crlf ends the lines of a text in CRLF
+func crlf(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")
+}
+
+// base64Lines encodes data in base64 with lines of 76 characters, the maximum for MIME
+func base64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var lines bytes.Buffer
+	for len(encoded) > 76 {
+		lines.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	lines.WriteString(encoded + "\r\n")
+	return lines.Bytes()
+}
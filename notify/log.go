@@ -0,0 +1,33 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// LogNotifier writes notifications and emails to the application log, for development and testing
+type LogNotifier struct{}
+
+// NewLogNotifier creates a notifier that logs notifications
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	log.Printf("Notification to %s <%s>: %s: %q", notification.Username, notification.Email, notification.Subject, notification.Body)
+	return nil
+}
+
+// Send logs an email with the names and sizes of its attachments instead of their contents
+func (n *LogNotifier) Send(ctx context.Context, email Email) error {
+	if email.To == "" {
+		return fmt.Errorf("email address is required")
+	}
+	attachments := make([]string, len(email.Attachments))
+	for i, attachment := range email.Attachments {
+		attachments[i] = fmt.Sprintf("%s (%d bytes)", attachment.Filename, len(attachment.Data))
+	}
+	log.Printf("Email to %s: %s: %q, attachments: %v", email.To, email.Subject, email.Body, attachments)
+	return nil
+}
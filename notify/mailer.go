@@ -0,0 +1,25 @@
+package notify
+
+import (
+	"context"
+)
+
+// Email is a message with attachments to one address
+type Email struct {
+	To          string // e.g. "Jane Doe <jane@example.com>" or "jane@example.com"
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email
+type Attachment struct {
+	Filename    string
+	ContentType string // e.g. "application/pdf"
+	Data        []byte
+}
+
+// Mailer sends emails, such as invoices, with attachments
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
@@ -0,0 +1,18 @@
+package notify // Package notify sends notifications such as back-in-stock alerts, and emails such as invoices, to users.
+
+import (
+	"context"
+)
+
+// Notification is a message to one user
+type Notification struct {
+	Username string
+	Email    string // may be empty for users who registered without an email address
+	Subject  string
+	Body     string
+}
+
+// Notifier delivers notifications
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
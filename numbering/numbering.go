@@ -0,0 +1,129 @@
+// Package numbering formats legally sequential document numbers, such as invoice and credit note numbers.
+//
+// A Scheme is a template like "INV-{YYYY}-{000000}". Every distinct text around the counter is its own
+// Series with its own sequence, so numbers restart at 1 each year when the template contains the year,
+// and each tenant counts on its own when it contains {TENANT}. Numbers are allocated by the database,
+// see PostgresSequences and ClickHouseSequences.
+package numbering
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Template tokens
+//
+//	{YYYY}    four-digit year
+//	{YY}      two-digit year
+//	{MM}      two-digit month
+//	{TENANT}  the configured tenant prefix
+//	{000000}  the counter, zero-padded to the number of zeros (required, exactly once)
+const (
+	tokenYear      = "{YYYY}"
+	tokenShortYear = "{YY}"
+	tokenMonth     = "{MM}"
+	tokenTenant    = "{TENANT}"
+)
+
+// maxTenantLength limits the length of tenant prefixes
+const maxTenantLength = 32
+
+// Scheme formats the numbers of one kind of document
+type Scheme struct {
+	name   string
+	tenant string
+	width  int
+	before string // template before the counter
+	after  string // template after the counter
+}
+
+// NewScheme parses a numbering template for the documents called name, e.g. "invoice"
+// tenant replaces {TENANT}; templates must contain {TENANT} when a tenant is set, so tenants never share numbers
+func NewScheme(name, template, tenant string) (*Scheme, error) {
+	tenant = strings.TrimSpace(tenant)
+	if len(tenant) > maxTenantLength {
+		return nil, fmt.Errorf("invalid numbering tenant: must be at most %d characters", maxTenantLength)
+	}
+	if strings.ContainsAny(tenant, "{}") {
+		return nil, fmt.Errorf("invalid numbering tenant %q: must not contain braces", tenant)
+	}
+
+	scheme := &Scheme{name: name, tenant: tenant}
+	counters := 0
+	rest := template
+	for rest != "" {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid numbering template %q: unclosed {", template)
+		}
+		token := rest[start : start+end+1]
+		switch {
+		case token == tokenYear, token == tokenShortYear, token == tokenMonth, token == tokenTenant:
+		case isCounter(token):
+			counters++
+			offset := len(template) - len(rest) + start
+			scheme.width = len(token) - 2
+			scheme.before = template[:offset]
+			scheme.after = template[offset+len(token):]
+		default:
+			return nil, fmt.Errorf("invalid numbering template %q: unknown token %s", template, token)
+		}
+		rest = rest[start+end+1:]
+	}
+	if counters != 1 {
+		return nil, fmt.Errorf("invalid numbering template %q: must contain exactly one counter such as {000000}", template)
+	}
+	if strings.Contains(template, tokenTenant) != (tenant != "") {
+		if tenant == "" {
+			return nil, fmt.Errorf("invalid numbering template %q: {TENANT} is used but no tenant is configured", template)
+		}
+		return nil, fmt.Errorf("invalid numbering template %q: must contain {TENANT} when a tenant is configured", template)
+	}
+	return scheme, nil
+}
+
+// isCounter reports whether a token is a counter, i.e. only zeros between the braces
+func isCounter(token string) bool {
+	zeros := token[1 : len(token)-1]
+	return zeros != "" && strings.Trim(zeros, "0") == ""
+}
+
+// Series returns the series that documents issued at t are numbered in
+// Dates are taken in UTC, so every instance agrees on the year a number belongs to
+func (s *Scheme) Series(t time.Time) Series {
+	t = t.UTC()
+	replacer := strings.NewReplacer(
+		tokenYear, strconv.Itoa(t.Year()),
+		tokenShortYear, fmt.Sprintf("%02d", t.Year()%100),
+		tokenMonth, fmt.Sprintf("%02d", int(t.Month())),
+		tokenTenant, s.tenant,
+	)
+	series := Series{
+		prefix: replacer.Replace(s.before),
+		suffix: replacer.Replace(s.after),
+		width:  s.width,
+	}
+	series.Key = s.name + ":" + series.prefix + "#" + series.suffix
+	return series
+}
+
+// Series is a run of numbers sharing one sequence, e.g. the invoices of 2025
+type Series struct {
+	// Key identifies the sequence, e.g. "invoice:INV-2025-#"
+	Key string
+
+	prefix string
+	suffix string
+	width  int
+}
+
+// Format returns the document number for the n-th number of the series
+func (s Series) Format(n uint64) string {
+	return s.prefix + fmt.Sprintf("%0*d", s.width, n) + s.suffix
+}
@@ -0,0 +1,165 @@
+package numbering
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand/v2"
+	"sync"
+	"time"
+)
+
+// maxReserveAttempts bounds how often an allocation retries after losing a number to another instance
+const maxReserveAttempts = 10
+
+// ClickHouseSequences allocates numbers in ClickHouse, which has no transactions or row locks
+// The numbered documents are their own counter: their table keeps each document's series key and sequence
+// in number_key and number_sequence columns, and the next number follows the highest stored one.
+// Instances sharing the database claim a number by inserting a reservation into number_reservations and
+// reading the reservations of that number back: the earliest one wins, and the others withdraw theirs and
+// try the next number. Allocations are also serialized per process, so only instances contend.
+type ClickHouseSequences struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+// NewClickHouseSequences creates a ClickHouse number allocator
+func NewClickHouseSequences(db *sql.DB) *ClickHouseSequences {
+	return &ClickHouseSequences{db: db}
+}
+
+// ensureReservationsTable creates the number_reservations table if it doesn't exist
+// reserved_at is set by the server, so reservations of all instances are ordered by the same clock
+func (c *ClickHouseSequences) ensureReservationsTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS number_reservations (
+			table_name String,
+			number_key String,
+			number_sequence UInt64,
+			token String,
+			reserved_at DateTime64(9) DEFAULT now64(9)
+		) ENGINE = MergeTree()
+		ORDER BY (table_name, number_key, number_sequence)
+	`
+	_, err := c.db.ExecContext(ctx, query)
+	return err
+}
+
+// Allocate stores a document with the next number of a series in table
+// create must insert the document with number_key set to series.Key and number_sequence to sequence;
+// when it fails the reservation is withdrawn, so the number is only lost if another instance allocated
+// the next one meanwhile
+func (c *ClickHouseSequences) Allocate(ctx context.Context, table string, series Series, create func(number string, sequence uint64) error) error {
+	if err := c.ensureReservationsTable(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for attempt := 0; attempt < maxReserveAttempts; attempt++ {
+		sequence, err := c.next(ctx, table, series.Key)
+		if err != nil {
+			return err
+		}
+		token := newToken()
+		won, err := c.reserve(ctx, table, series.Key, sequence, token)
+		if err != nil {
+			return err
+		}
+		if !won {
+			if err := c.withdraw(ctx, token); err != nil {
+				return err
+			}
+			// Back off for a random while, so instances that tied don't keep tying
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(mathrand.N(20 * time.Millisecond)):
+			}
+			continue
+		}
+		if err := create(series.Format(sequence), sequence); err != nil {
+			if withdrawErr := c.withdraw(ctx, token); withdrawErr != nil {
+				return fmt.Errorf("%v (and failed to withdraw number reservation: %v)", err, withdrawErr)
+			}
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to allocate a number of series %s: too many concurrent allocations", series.Key)
+}
+
+// next returns the number following the highest stored document and reservation of a series
+func (c *ClickHouseSequences) next(ctx context.Context, table, key string) (uint64, error) {
+	var last uint64
+	query := fmt.Sprintf(`
+		SELECT greatest(
+			(SELECT max(number_sequence) FROM %s WHERE number_key = ?),
+			(SELECT max(number_sequence) FROM number_reservations WHERE table_name = ? AND number_key = ?)
+		)`, table)
+	if err := c.db.QueryRowContext(ctx, query, key, table, key).Scan(&last); err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
+
+// reserve inserts a reservation of a number and tells whether it is the earliest one
+// Ties lose on both sides, so two instances never both win a number
+func (c *ClickHouseSequences) reserve(ctx context.Context, table, key string, sequence uint64, token string) (bool, error) {
+	query := `INSERT INTO number_reservations (table_name, number_key, number_sequence, token) VALUES (?, ?, ?, ?)`
+	if _, err := c.db.ExecContext(ctx, query, table, key, sequence, token); err != nil {
+		return false, err
+	}
+
+	query = `
+		SELECT token, reserved_at FROM number_reservations
+		WHERE table_name = ? AND number_key = ? AND number_sequence = ?`
+	rows, err := c.db.QueryContext(ctx, query, table, key, sequence)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var mine time.Time
+	var others []time.Time
+	for rows.Next() {
+		var rowToken string
+		var reservedAt time.Time
+		if err := rows.Scan(&rowToken, &reservedAt); err != nil {
+			return false, err
+		}
+		if rowToken == token {
+			mine = reservedAt
+		} else {
+			others = append(others, reservedAt)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if mine.IsZero() {
+		return false, fmt.Errorf("number reservation %s was not stored", token)
+	}
+	for _, other := range others {
+		if !other.After(mine) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// withdraw deletes a reservation that lost its number or whose document wasn't stored
+func (c *ClickHouseSequences) withdraw(ctx context.Context, token string) error {
+	query := `ALTER TABLE number_reservations DELETE WHERE token = ? SETTINGS mutations_sync = 1`
+	_, err := c.db.ExecContext(ctx, query, token)
+	return err
+}
+
+// newToken returns 16 random hex characters identifying a reservation
+func newToken() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,56 @@
+package numbering
+
+import (
+	"context"
+	"database/sql"
+)
+
+// PostgresSequences allocates numbers from counters in the number_sequences table
+// A number is allocated in the transaction that stores the numbered document, so the counter row stays locked
+// until it commits: concurrent documents wait for each other, and a rolled back document gives its number back
+type PostgresSequences struct {
+	db *sql.DB
+}
+
+// NewPostgresSequences creates a Postgres number allocator
+func NewPostgresSequences(db *sql.DB) *PostgresSequences {
+	return &PostgresSequences{db: db}
+}
+
+// ensureSequencesTable creates the number_sequences table if it doesn't exist
+func (p *PostgresSequences) ensureSequencesTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS number_sequences (
+			series_key TEXT PRIMARY KEY,
+			last_value BIGINT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	_, err := p.db.ExecContext(ctx, query)
+	return err
+}
+
+// Allocate stores a document with the next number of a series
+// create runs in the transaction that allocated the number; the number is only used up if create succeeds
+func (p *PostgresSequences) Allocate(ctx context.Context, series Series, create func(tx *sql.Tx, number string, sequence uint64) error) error {
+	if err := p.ensureSequencesTable(ctx); err != nil {
+		return err
+	}
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO number_sequences (series_key, last_value) VALUES ($1, 1)
+		ON CONFLICT (series_key) DO UPDATE SET last_value = number_sequences.last_value + 1, updated_at = NOW()
+		RETURNING last_value`
+	var sequence uint64
+	if err := tx.QueryRowContext(ctx, query, series.Key).Scan(&sequence); err != nil {
+		return err
+	}
+	if err := create(tx, series.Format(sequence), sequence); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -5,24 +5,30 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/rajindersingh041/go-auth-sessions/auth"
 	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/idempotency"
 	"github.com/rajindersingh041/go-auth-sessions/user"
 )
 
 // Handler handles HTTP requests for order operations
 type Handler struct {
-	service     OrderService
-	userService user.UserService
+	service          OrderService
+	userService      user.UserService
+	idempotencyStore idempotency.Store
+	admins           auth.AdminList
 }
 
 // NewHandler creates a new order handler
-func NewHandler(service OrderService, userService user.UserService) *Handler {
+func NewHandler(service OrderService, userService user.UserService, idempotencyStore idempotency.Store, admins auth.AdminList) *Handler {
 	return &Handler{
-		service:     service,
-		userService: userService,
+		service:          service,
+		userService:      userService,
+		idempotencyStore: idempotencyStore,
+		admins:           admins,
 	}
 }
 
@@ -30,10 +36,14 @@ func NewHandler(service OrderService, userService user.UserService) *Handler {
 func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
 	// Register routes with or without authentication as needed
 	mux.Handle("GET /orders", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetOrders())))
-	mux.Handle("POST /orders", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleCreateOrder())))
+	// Order creation honours the Idempotency-Key header so client retries don't create duplicate orders
+	mux.Handle("POST /orders", auth.WithJWTAuth(jwtManager, idempotency.Middleware(h.idempotencyStore, http.HandlerFunc(h.handleCreateOrder()))))
 	mux.Handle("POST /orders/single", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleCreateSingleOrder())))
 	mux.Handle("GET /orders/", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetOrdersByUsername())))
 	mux.Handle("POST /orders/", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleCreateOrderLegacy())))
+
+	// Admin routes
+	mux.Handle("PUT /admin/orders/{id}/status", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleUpdateOrderStatus())))
 }
 
 
@@ -113,7 +123,10 @@ func (h *Handler) handleCreateOrderLegacyPath() http.HandlerFunc {
 			
 			if strings.Contains(err.Error(), "valid product ID and positive quantity are required") ||
 			strings.Contains(err.Error(), "product not found") ||
-			strings.Contains(err.Error(), "out of stock") {
+			strings.Contains(err.Error(), "out of stock") ||
+			strings.Contains(err.Error(), "cannot be priced") ||
+			strings.Contains(err.Error(), "coupon") ||
+			strings.Contains(err.Error(), "is not valid") {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -198,7 +211,7 @@ func (h *Handler) handleCreateOrder() http.HandlerFunc {
 			log.Printf("Order creation failed: %v", err)
 			
 			// Check if it's a validation error (contains specific messages)
-			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "out of stock") {
+			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "out of stock") || strings.Contains(err.Error(), "cannot be priced") || strings.Contains(err.Error(), "coupon") || strings.Contains(err.Error(), "is not valid") {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -250,7 +263,7 @@ func (h *Handler) handleCreateSingleOrder() http.HandlerFunc {
 			log.Printf("Single order creation failed: %v", err)
 			
 			// Check if it's a validation error
-			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "out of stock") {
+			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "out of stock") || strings.Contains(err.Error(), "cannot be priced") || strings.Contains(err.Error(), "coupon") || strings.Contains(err.Error(), "is not valid") {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
 			}
@@ -268,4 +281,38 @@ func (h *Handler) handleCreateSingleOrder() http.HandlerFunc {
 // handleCreateOrderLegacy handles the legacy order creation (same as handleCreateOrderLegacyPath)
 func (h *Handler) handleCreateOrderLegacy() http.HandlerFunc {
 	return h.handleCreateOrderLegacyPath()
-}
\ No newline at end of file
+}
+
+// handleUpdateOrderStatus handles PUT /admin/orders/{id}/status (admin only)
+// Body: {"status":"shipped"}; orders move from pending to shipped to delivered, or from pending to cancelled
+func (h *Handler) handleUpdateOrderStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid order ID")
+			return
+		}
+		var req UpdateStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		order, err := h.service.UpdateOrderStatus(r.Context(), orderID, req.Status)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "not found"):
+				helper.RespondError(w, http.StatusNotFound, err.Error())
+			case strings.Contains(err.Error(), "must not change"):
+				helper.RespondError(w, http.StatusConflict, err.Error())
+			case strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "invalid"):
+				helper.RespondError(w, http.StatusBadRequest, err.Error())
+			default:
+				helper.RespondError(w, http.StatusInternalServerError, "Failed to update order status")
+			}
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, order)
+	}
+}
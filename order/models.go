@@ -2,14 +2,24 @@ package order
 
 import (
 	"context"
+	"encoding/json"
+
+	"github.com/rajindersingh041/go-auth-sessions/fx"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
 )
 
 // OrderItem represents a single product within an order
 type OrderItem struct {
-	ProductID uint64  `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	UnitPrice float64 `json:"unit_price,omitempty"`
-	Total     float64 `json:"total,omitempty"`
+	ProductID uint64         `json:"product_id"`
+	VariantID uint64         `json:"variant_id,omitempty"`
+	SKU       string         `json:"sku,omitempty"` // variant SKU at the time of the order
+	Quantity  int            `json:"quantity"`
+	UnitPrice money.Money    `json:"unit_price"`
+	Total     money.Money    `json:"total"`         // UnitPrice * Quantity, before discounts
+	Discount  money.Money    `json:"discount"`      // share of the order's discounts allocated to this line
+	Tax       *tax.Breakdown `json:"tax,omitempty"` // per-line tax breakdown, on Total minus Discount
 }
 
 // Order represents an order placed by a user (can contain multiple products)
@@ -17,11 +27,37 @@ type Order struct {
 	OrderID   uint64      `json:"order_id"`
 	UserID    uint64      `json:"user_id"`
 	Items     []OrderItem `json:"items"`
-	Subtotal  float64     `json:"subtotal"`
-	Tax       float64     `json:"tax"`
-	Total     float64     `json:"total"`
+	Region    string      `json:"region"` // tax region the order was taxed for
+	Currency  string      `json:"currency"`
+	Discount  money.Money `json:"discount"` // sum of Discounts, already deducted from Subtotal
+	Subtotal  money.Money `json:"subtotal"`
+	Tax       money.Money `json:"tax"`
+	Total     money.Money `json:"total"`
 	Status    string      `json:"status"`
 	CreatedAt string      `json:"created_at"`
+
+	// ExchangeRates are the rates used to convert product prices into Currency at checkout
+	ExchangeRates []fx.Rate `json:"exchange_rates,omitempty"`
+	// Discounts are the promotions and coupons applied to the order
+	Discounts []promotion.Discount `json:"discounts,omitempty"`
+}
+
+// Order statuses
+// Orders are placed as pending, paid once their invoice is paid online, then shipped and delivered
+// Orders that haven't shipped can be cancelled
+const (
+	StatusPending   = "pending"
+	StatusPaid      = "paid"
+	StatusShipped   = "shipped"
+	StatusDelivered = "delivered"
+	StatusCancelled = "cancelled"
+)
+
+// statusTransitions lists the statuses an order can move to from each status
+var statusTransitions = map[string][]string{
+	StatusPending: {StatusPaid, StatusShipped, StatusCancelled},
+	StatusPaid:    {StatusShipped, StatusCancelled},
+	StatusShipped: {StatusDelivered},
 }
 
 // Repository defines the interface for order data operations
@@ -30,23 +66,40 @@ type OrderRepository interface {
 	CreateOrderItems(ctx context.Context, orderID uint64, items []OrderItem) error
 	GetOrdersByUserID(ctx context.Context, userID uint64) ([]Order, error)
 	GetOrderByID(ctx context.Context, orderID uint64) (*Order, error)
+	UpdateStatus(ctx context.Context, orderID uint64, status string) error
+	// HasDeliveredProduct reports whether a user has a delivered order containing a product
+	HasDeliveredProduct(ctx context.Context, userID, productID uint64) (bool, error)
+}
+
+// UpdateStatusRequest represents the request to move an order to another status
+type UpdateStatusRequest struct {
+	Status string `json:"status"`
 }
 
 // CreateOrderRequest represents the request to create an order with multiple products
 type CreateOrderRequest struct {
-	Items []OrderItemRequest `json:"items"`
+	Items       []OrderItemRequest `json:"items"`
+	Region      string             `json:"region,omitempty"`   // tax region, defaults to the configured region
+	Currency    string             `json:"currency,omitempty"` // checkout currency, defaults to the first product's currency
+	CouponCodes []string           `json:"coupon_codes,omitempty"`
 }
 
 // OrderItemRequest represents a product to add to an order
+// VariantID is required for products that have variants
 type OrderItemRequest struct {
 	ProductID uint64 `json:"product_id"`
+	VariantID uint64 `json:"variant_id,omitempty"`
 	Quantity  int    `json:"quantity"`
 }
 
 // Legacy single product order request (for backward compatibility)
 type CreateSingleOrderRequest struct {
-	ProductID uint64 `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+	ProductID   uint64   `json:"product_id"`
+	VariantID   uint64   `json:"variant_id,omitempty"`
+	Quantity    int      `json:"quantity"`
+	Region      string   `json:"region,omitempty"`
+	Currency    string   `json:"currency,omitempty"`
+	CouponCodes []string `json:"coupon_codes,omitempty"`
 }
 
 // Order now includes ProductID instead of Item
@@ -57,6 +110,54 @@ type OrderWithProduct struct {
 	Quantity  int     `json:"quantity"`
 	CreatedAt string  `json:"created_at"`
 	// Additional product info for responses
-	ProductName  string  `json:"product_name,omitempty"`
-	ProductPrice float64 `json:"product_price,omitempty"`
-}
\ No newline at end of file
+	ProductName  string       `json:"product_name,omitempty"`
+	ProductPrice *money.Money `json:"product_price,omitempty"`
+}
+
+// encodeTaxBreakdown serializes a line's tax breakdown for storage
+func encodeTaxBreakdown(b *tax.Breakdown) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeTaxBreakdown parses a stored tax breakdown; orders created before per-line tax have none
+func decodeTaxBreakdown(data string) (*tax.Breakdown, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var b tax.Breakdown
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// encodeExchangeRates serializes the exchange rates used by an order for storage
+func encodeExchangeRates(rates []fx.Rate) (string, error) {
+	if len(rates) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(rates)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeExchangeRates parses stored exchange rates; single-currency orders have none
+func decodeExchangeRates(data string) ([]fx.Rate, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var rates []fx.Rate
+	if err := json.Unmarshal([]byte(data), &rates); err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
@@ -3,52 +3,162 @@ package order
 import (
 	"context"
 	"database/sql"
+	"log"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
 )
 
 // ClickHouseRepository implements Repository for ClickHouse database
 type ClickHouseRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	ids idgen.Generator
 }
 
 // NewClickHouseRepository creates a new ClickHouse order repository
-func NewClickHouseRepository(db *sql.DB) OrderRepository {
-	return &ClickHouseRepository{db: db}
+// ClickHouse has no auto-increment, so order IDs come from the shared ID generator
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) OrderRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
 }
 
 func (r *ClickHouseRepository) Create(ctx context.Context, order *Order) error {
-	// For ClickHouse, we need to generate an ID since it doesn't have auto-increment
-	var maxID uint64
-	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(order_id), 0) FROM orders").Scan(&maxID)
+	order.OrderID = r.ids.NextID()
+
+	// Insert the items first as a single batch, which ClickHouse writes atomically.
+	// The order row is written last, so an order only becomes visible once all of its items exist.
+	if err := r.CreateOrderItems(ctx, order.OrderID, order.Items); err != nil {
+		return err
+	}
+
+	exchangeRates, err := encodeExchangeRates(order.ExchangeRates)
 	if err != nil {
-		maxID = 0 // Start with ID 1 if no orders exist
+		return err
 	}
-	
-	order.OrderID = maxID + 1
-	
-	// Insert order
-	orderQuery := "INSERT INTO orders (order_id, user_id, subtotal, tax, total, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
-	_, err = r.db.ExecContext(ctx, orderQuery, order.OrderID, order.UserID, order.Subtotal, order.Tax, order.Total, order.Status, order.CreatedAt)
+
+	if err := r.createOrderDiscounts(ctx, order.OrderID, order.Discounts); err != nil {
+		r.cleanupOrder(ctx, order.OrderID)
+		return err
+	}
+
+	orderQuery := "INSERT INTO orders (order_id, user_id, subtotal, tax, total, currency, exchange_rates, discount, status, region, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, orderQuery, order.OrderID, order.UserID, order.Subtotal, order.Tax, order.Total, order.Currency, exchangeRates, order.Discount, order.Status, order.Region, order.CreatedAt)
 	if err != nil {
+		r.cleanupOrder(ctx, order.OrderID)
 		return err
 	}
+	return nil
+}
+
+// cleanupOrder removes the items and discounts of an order whose header could not be written
+// This is best effort; the rows are unreachable without the order row anyway
+func (r *ClickHouseRepository) cleanupOrder(ctx context.Context, orderID uint64) {
+	for _, table := range []string{"order_items", "order_discounts"} {
+		if _, err := r.db.ExecContext(ctx, "ALTER TABLE "+table+" DELETE WHERE order_id = ?", orderID); err != nil {
+			log.Printf("Failed to clean up %s of order %d: %v", table, orderID, err)
+		}
+	}
+}
+
+// ensureOrderDiscountsTable creates the order_discounts table if it doesn't exist
+func (r *ClickHouseRepository) ensureOrderDiscountsTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS order_discounts (
+			order_id UInt64,
+			promotion_id UInt64,
+			code String,
+			name String,
+			type String,
+			amount Decimal(18, 2)
+		) ENGINE = MergeTree()
+		ORDER BY (order_id, promotion_id)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+// createOrderDiscounts inserts the discount lines of an order in one batch
+func (r *ClickHouseRepository) createOrderDiscounts(ctx context.Context, orderID uint64, discounts []promotion.Discount) error {
+	if len(discounts) == 0 {
+		return nil
+	}
+	if err := r.ensureOrderDiscountsTable(ctx); err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO order_discounts (order_id, promotion_id, code, name, type, amount)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, discount := range discounts {
+		if _, err := stmt.ExecContext(ctx, orderID, discount.PromotionID, discount.Code, discount.Name, discount.Type, discount.Amount); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// getOrderDiscounts retrieves the discount lines of an order
+func (r *ClickHouseRepository) getOrderDiscounts(ctx context.Context, orderID uint64, currency string) ([]promotion.Discount, error) {
+	if err := r.ensureOrderDiscountsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT promotion_id, code, name, type, amount FROM order_discounts WHERE order_id = ? ORDER BY promotion_id"
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	// Insert order items
-	return r.CreateOrderItems(ctx, order.OrderID, order.Items)
+	var discounts []promotion.Discount
+	for rows.Next() {
+		var d promotion.Discount
+		if err := rows.Scan(&d.PromotionID, &d.Code, &d.Name, &d.Type, &d.Amount); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &d.Amount)
+		discounts = append(discounts, d)
+	}
+	return discounts, nil
 }
 
+// CreateOrderItems inserts all items in one batch
+// The clickhouse driver sends the rows of a prepared INSERT inside a transaction as a single block on commit
 func (r *ClickHouseRepository) CreateOrderItems(ctx context.Context, orderID uint64, items []OrderItem) error {
-	itemQuery := "INSERT INTO order_items (order_id, product_id, quantity, unit_price, total) VALUES (?, ?, ?, ?, ?)"
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, unit_price, total, discount, tax_breakdown)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
 	for _, item := range items {
-		_, err := r.db.ExecContext(ctx, itemQuery, orderID, item.ProductID, item.Quantity, item.UnitPrice, item.Total)
+		taxBreakdown, err := encodeTaxBreakdown(item.Tax)
 		if err != nil {
 			return err
 		}
+		if _, err := stmt.ExecContext(ctx, orderID, item.ProductID, item.VariantID, item.SKU, item.Quantity, item.UnitPrice, item.Total, item.Discount, taxBreakdown); err != nil {
+			return err
+		}
 	}
-	return nil
+	return tx.Commit()
 }
 
 func (r *ClickHouseRepository) GetOrdersByUserID(ctx context.Context, userID uint64) ([]Order, error) {
-	query := "SELECT order_id, user_id, subtotal, tax, total, status, created_at FROM orders WHERE user_id = ? ORDER BY created_at DESC"
+	query := "SELECT order_id, user_id, subtotal, tax, total, discount, currency, exchange_rates, status, region, created_at FROM orders WHERE user_id = ? ORDER BY created_at DESC"
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
@@ -58,16 +168,26 @@ func (r *ClickHouseRepository) GetOrdersByUserID(ctx context.Context, userID uin
 	var orders []Order
 	for rows.Next() {
 		var o Order
-		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Status, &o.CreatedAt); err != nil {
+		var exchangeRates string
+		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Discount, &o.Currency, &exchangeRates, &o.Status, &o.Region, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(o.Currency, &o.Subtotal, &o.Tax, &o.Total, &o.Discount)
+		if o.ExchangeRates, err = decodeExchangeRates(exchangeRates); err != nil {
 			return nil, err
 		}
 		
 		// Load order items
-		items, err := r.getOrderItems(ctx, o.OrderID)
+		items, err := r.getOrderItems(ctx, o.OrderID, o.Currency)
 		if err != nil {
 			return nil, err
 		}
 		o.Items = items
+
+		// Load discount lines
+		if o.Discounts, err = r.getOrderDiscounts(ctx, o.OrderID, o.Currency); err != nil {
+			return nil, err
+		}
 		
 		orders = append(orders, o)
 	}
@@ -75,27 +195,55 @@ func (r *ClickHouseRepository) GetOrdersByUserID(ctx context.Context, userID uin
 }
 
 func (r *ClickHouseRepository) GetOrderByID(ctx context.Context, orderID uint64) (*Order, error) {
-	query := "SELECT order_id, user_id, subtotal, tax, total, status, created_at FROM orders WHERE order_id = ?"
+	query := "SELECT order_id, user_id, subtotal, tax, total, discount, currency, exchange_rates, status, region, created_at FROM orders WHERE order_id = ?"
 	row := r.db.QueryRowContext(ctx, query, orderID)
 	
 	var o Order
-	if err := row.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Status, &o.CreatedAt); err != nil {
+	var exchangeRates string
+	if err := row.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Discount, &o.Currency, &exchangeRates, &o.Status, &o.Region, &o.CreatedAt); err != nil {
 		return nil, err
 	}
+	money.WithCurrency(o.Currency, &o.Subtotal, &o.Tax, &o.Total, &o.Discount)
+	rates, err := decodeExchangeRates(exchangeRates)
+	if err != nil {
+		return nil, err
+	}
+	o.ExchangeRates = rates
 	
 	// Load order items
-	items, err := r.getOrderItems(ctx, o.OrderID)
+	items, err := r.getOrderItems(ctx, o.OrderID, o.Currency)
 	if err != nil {
 		return nil, err
 	}
 	o.Items = items
+
+	// Load discount lines
+	if o.Discounts, err = r.getOrderDiscounts(ctx, o.OrderID, o.Currency); err != nil {
+		return nil, err
+	}
 	
 	return &o, nil
 }
 
 // getOrderItems retrieves all items for a specific order
-func (r *ClickHouseRepository) getOrderItems(ctx context.Context, orderID uint64) ([]OrderItem, error) {
-	query := "SELECT product_id, quantity, unit_price, total FROM order_items WHERE order_id = ? ORDER BY product_id"
+func (r *ClickHouseRepository) UpdateStatus(ctx context.Context, orderID uint64, status string) error {
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE orders UPDATE status = ? WHERE order_id = ?", status, orderID)
+	return err
+}
+
+func (r *ClickHouseRepository) HasDeliveredProduct(ctx context.Context, userID, productID uint64) (bool, error) {
+	query := `
+		SELECT count() FROM orders
+		WHERE user_id = ? AND status = ? AND order_id IN (SELECT order_id FROM order_items WHERE product_id = ?)`
+	var count uint64
+	if err := r.db.QueryRowContext(ctx, query, userID, StatusDelivered, productID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *ClickHouseRepository) getOrderItems(ctx context.Context, orderID uint64, currency string) ([]OrderItem, error) {
+	query := "SELECT product_id, variant_id, sku, quantity, unit_price, total, discount, tax_breakdown FROM order_items WHERE order_id = ? ORDER BY product_id, variant_id"
 	rows, err := r.db.QueryContext(ctx, query, orderID)
 	if err != nil {
 		return nil, err
@@ -105,9 +253,14 @@ func (r *ClickHouseRepository) getOrderItems(ctx context.Context, orderID uint64
 	var items []OrderItem
 	for rows.Next() {
 		var item OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice, &item.Total); err != nil {
+		var taxBreakdown string
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.SKU, &item.Quantity, &item.UnitPrice, &item.Total, &item.Discount, &taxBreakdown); err != nil {
+			return nil, err
+		}
+		if item.Tax, err = decodeTaxBreakdown(taxBreakdown); err != nil {
 			return nil, err
 		}
+		money.WithCurrency(currency, &item.UnitPrice, &item.Total, &item.Discount)
 		items = append(items, item)
 	}
 	return items, nil
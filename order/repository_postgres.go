@@ -3,6 +3,10 @@ package order
 import (
 	"context"
 	"database/sql"
+	"fmt"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
 )
 
 // PostgresRepository implements Repository for PostgreSQL database
@@ -25,7 +29,11 @@ func (r *PostgresRepository) ensureOrdersTable(ctx context.Context) error {
 			subtotal DECIMAL(10,2) NOT NULL DEFAULT 0.00,
 			tax DECIMAL(10,2) NOT NULL DEFAULT 0.00,
 			total DECIMAL(10,2) NOT NULL DEFAULT 0.00,
+			currency TEXT NOT NULL DEFAULT 'USD',
+			exchange_rates JSONB,
+			discount DECIMAL(10,2) NOT NULL DEFAULT 0.00,
 			status TEXT NOT NULL DEFAULT 'pending',
+			region TEXT NOT NULL DEFAULT '',
 			created_at TIMESTAMP NOT NULL DEFAULT NOW()
 		)`
 	if _, err := r.db.ExecContext(ctx, createOrdersQuery); err != nil {
@@ -38,12 +46,50 @@ func (r *PostgresRepository) ensureOrdersTable(ctx context.Context) error {
 			item_id SERIAL PRIMARY KEY,
 			order_id BIGINT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
 			product_id BIGINT NOT NULL,
+			variant_id BIGINT NOT NULL DEFAULT 0,
+			sku TEXT NOT NULL DEFAULT '',
 			quantity INT NOT NULL,
 			unit_price DECIMAL(10,2) NOT NULL,
-			total DECIMAL(10,2) NOT NULL
+			total DECIMAL(10,2) NOT NULL,
+			discount DECIMAL(10,2) NOT NULL DEFAULT 0.00,
+			tax_breakdown JSONB
 		)`
-	_, err := r.db.ExecContext(ctx, createOrderItemsQuery)
-	return err
+	if _, err := r.db.ExecContext(ctx, createOrderItemsQuery); err != nil {
+		return err
+	}
+
+	// Create the order_discounts table holding the promotions applied to each order
+	createOrderDiscountsQuery := `
+		CREATE TABLE IF NOT EXISTS order_discounts (
+			discount_id SERIAL PRIMARY KEY,
+			order_id BIGINT NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+			promotion_id BIGINT NOT NULL,
+			code TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			amount DECIMAL(10,2) NOT NULL
+		)`
+	if _, err := r.db.ExecContext(ctx, createOrderDiscountsQuery); err != nil {
+		return err
+	}
+
+	// Add columns introduced after the tables were first created
+	migrations := []string{
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS region TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD'",
+		"ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax_breakdown JSONB",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rates JSONB",
+		"ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount DECIMAL(10,2) NOT NULL DEFAULT 0.00",
+		"ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount DECIMAL(10,2) NOT NULL DEFAULT 0.00",
+		"ALTER TABLE order_items ADD COLUMN IF NOT EXISTS variant_id BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE order_items ADD COLUMN IF NOT EXISTS sku TEXT NOT NULL DEFAULT ''",
+	}
+	for _, migration := range migrations {
+		if _, err := r.db.ExecContext(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *PostgresRepository) Create(ctx context.Context, order *Order) error {
@@ -58,9 +104,14 @@ func (r *PostgresRepository) Create(ctx context.Context, order *Order) error {
 	}
 	defer tx.Rollback()
 
+	exchangeRates, err := encodeExchangeRates(order.ExchangeRates)
+	if err != nil {
+		return err
+	}
+
 	// Insert order
-	orderQuery := "INSERT INTO orders (user_id, subtotal, tax, total, status, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING order_id"
-	err = tx.QueryRowContext(ctx, orderQuery, order.UserID, order.Subtotal, order.Tax, order.Total, order.Status, order.CreatedAt).Scan(&order.OrderID)
+	orderQuery := "INSERT INTO orders (user_id, subtotal, tax, total, currency, exchange_rates, discount, status, region, created_at) VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::jsonb, $7, $8, $9, $10) RETURNING order_id"
+	err = tx.QueryRowContext(ctx, orderQuery, order.UserID, order.Subtotal, order.Tax, order.Total, order.Currency, exchangeRates, order.Discount, order.Status, order.Region, order.CreatedAt).Scan(&order.OrderID)
 	if err != nil {
 		return err
 	}
@@ -70,6 +121,14 @@ func (r *PostgresRepository) Create(ctx context.Context, order *Order) error {
 		return err
 	}
 
+	// Insert discount lines
+	discountQuery := "INSERT INTO order_discounts (order_id, promotion_id, code, name, type, amount) VALUES ($1, $2, $3, $4, $5, $6)"
+	for _, discount := range order.Discounts {
+		if _, err := tx.ExecContext(ctx, discountQuery, order.OrderID, discount.PromotionID, discount.Code, discount.Name, discount.Type, discount.Amount); err != nil {
+			return err
+		}
+	}
+
 	// Commit transaction
 	return tx.Commit()
 }
@@ -89,9 +148,13 @@ func (r *PostgresRepository) CreateOrderItems(ctx context.Context, orderID uint6
 }
 
 func (r *PostgresRepository) createOrderItemsInTx(ctx context.Context, tx *sql.Tx, orderID uint64, items []OrderItem) error {
-	itemQuery := "INSERT INTO order_items (order_id, product_id, quantity, unit_price, total) VALUES ($1, $2, $3, $4, $5)"
+	itemQuery := "INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, unit_price, total, discount, tax_breakdown) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, '')::jsonb)"
 	for _, item := range items {
-		_, err := tx.ExecContext(ctx, itemQuery, orderID, item.ProductID, item.Quantity, item.UnitPrice, item.Total)
+		taxBreakdown, err := encodeTaxBreakdown(item.Tax)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, itemQuery, orderID, item.ProductID, item.VariantID, item.SKU, item.Quantity, item.UnitPrice, item.Total, item.Discount, taxBreakdown)
 		if err != nil {
 			return err
 		}
@@ -103,7 +166,7 @@ func (r *PostgresRepository) GetOrdersByUserID(ctx context.Context, userID uint6
 	if err := r.ensureOrdersTable(ctx); err != nil {
 		return nil, err
 	}
-	query := "SELECT order_id, user_id, subtotal, tax, total, status, created_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC"
+	query := "SELECT order_id, user_id, subtotal, tax, total, discount, currency, COALESCE(exchange_rates::text, ''), status, region, created_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC"
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
@@ -113,16 +176,26 @@ func (r *PostgresRepository) GetOrdersByUserID(ctx context.Context, userID uint6
 	var orders []Order
 	for rows.Next() {
 		var o Order
-		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Status, &o.CreatedAt); err != nil {
+		var exchangeRates string
+		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Discount, &o.Currency, &exchangeRates, &o.Status, &o.Region, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(o.Currency, &o.Subtotal, &o.Tax, &o.Total, &o.Discount)
+		if o.ExchangeRates, err = decodeExchangeRates(exchangeRates); err != nil {
 			return nil, err
 		}
 		
 		// Load order items
-		items, err := r.getOrderItems(ctx, o.OrderID)
+		items, err := r.getOrderItems(ctx, o.OrderID, o.Currency)
 		if err != nil {
 			return nil, err
 		}
 		o.Items = items
+
+		// Load discount lines
+		if o.Discounts, err = r.getOrderDiscounts(ctx, o.OrderID, o.Currency); err != nil {
+			return nil, err
+		}
 		
 		orders = append(orders, o)
 	}
@@ -133,27 +206,67 @@ func (r *PostgresRepository) GetOrderByID(ctx context.Context, orderID uint64) (
 	if err := r.ensureOrdersTable(ctx); err != nil {
 		return nil, err
 	}
-	query := "SELECT order_id, user_id, subtotal, tax, total, status, created_at FROM orders WHERE order_id = $1"
+	query := "SELECT order_id, user_id, subtotal, tax, total, discount, currency, COALESCE(exchange_rates::text, ''), status, region, created_at FROM orders WHERE order_id = $1"
 	row := r.db.QueryRowContext(ctx, query, orderID)
 	
 	var o Order
-	if err := row.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Status, &o.CreatedAt); err != nil {
+	var exchangeRates string
+	if err := row.Scan(&o.OrderID, &o.UserID, &o.Subtotal, &o.Tax, &o.Total, &o.Discount, &o.Currency, &exchangeRates, &o.Status, &o.Region, &o.CreatedAt); err != nil {
+		return nil, err
+	}
+	money.WithCurrency(o.Currency, &o.Subtotal, &o.Tax, &o.Total, &o.Discount)
+	rates, err := decodeExchangeRates(exchangeRates)
+	if err != nil {
 		return nil, err
 	}
+	o.ExchangeRates = rates
 	
 	// Load order items
-	items, err := r.getOrderItems(ctx, o.OrderID)
+	items, err := r.getOrderItems(ctx, o.OrderID, o.Currency)
 	if err != nil {
 		return nil, err
 	}
 	o.Items = items
+
+	// Load discount lines
+	if o.Discounts, err = r.getOrderDiscounts(ctx, o.OrderID, o.Currency); err != nil {
+		return nil, err
+	}
 	
 	return &o, nil
 }
 
 // getOrderItems retrieves all items for a specific order
-func (r *PostgresRepository) getOrderItems(ctx context.Context, orderID uint64) ([]OrderItem, error) {
-	query := "SELECT product_id, quantity, unit_price, total FROM order_items WHERE order_id = $1 ORDER BY item_id"
+func (r *PostgresRepository) UpdateStatus(ctx context.Context, orderID uint64, status string) error {
+	if err := r.ensureOrdersTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "UPDATE orders SET status = $1 WHERE order_id = $2", status, orderID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("order not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) HasDeliveredProduct(ctx context.Context, userID, productID uint64) (bool, error) {
+	if err := r.ensureOrdersTable(ctx); err != nil {
+		return false, err
+	}
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM orders o JOIN order_items i ON i.order_id = o.order_id
+			WHERE o.user_id = $1 AND o.status = $2 AND i.product_id = $3
+		)`
+	var delivered bool
+	err := r.db.QueryRowContext(ctx, query, userID, StatusDelivered, productID).Scan(&delivered)
+	return delivered, err
+}
+
+func (r *PostgresRepository) getOrderItems(ctx context.Context, orderID uint64, currency string) ([]OrderItem, error) {
+	query := "SELECT product_id, variant_id, sku, quantity, unit_price, total, discount, COALESCE(tax_breakdown::text, '') FROM order_items WHERE order_id = $1 ORDER BY item_id"
 	rows, err := r.db.QueryContext(ctx, query, orderID)
 	if err != nil {
 		return nil, err
@@ -163,10 +276,36 @@ func (r *PostgresRepository) getOrderItems(ctx context.Context, orderID uint64)
 	var items []OrderItem
 	for rows.Next() {
 		var item OrderItem
-		if err := rows.Scan(&item.ProductID, &item.Quantity, &item.UnitPrice, &item.Total); err != nil {
+		var taxBreakdown string
+		if err := rows.Scan(&item.ProductID, &item.VariantID, &item.SKU, &item.Quantity, &item.UnitPrice, &item.Total, &item.Discount, &taxBreakdown); err != nil {
+			return nil, err
+		}
+		if item.Tax, err = decodeTaxBreakdown(taxBreakdown); err != nil {
 			return nil, err
 		}
+		money.WithCurrency(currency, &item.UnitPrice, &item.Total, &item.Discount)
 		items = append(items, item)
 	}
 	return items, nil
+}
+
+// getOrderDiscounts retrieves the discount lines of an order
+func (r *PostgresRepository) getOrderDiscounts(ctx context.Context, orderID uint64, currency string) ([]promotion.Discount, error) {
+	query := "SELECT promotion_id, code, name, type, amount FROM order_discounts WHERE order_id = $1 ORDER BY discount_id"
+	rows, err := r.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []promotion.Discount
+	for rows.Next() {
+		var d promotion.Discount
+		if err := rows.Scan(&d.PromotionID, &d.Code, &d.Name, &d.Type, &d.Amount); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &d.Amount)
+		discounts = append(discounts, d)
+	}
+	return discounts, nil
 }
\ No newline at end of file
@@ -2,10 +2,16 @@ package order
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/rajindersingh041/go-auth-sessions/fx"
 	"github.com/rajindersingh041/go-auth-sessions/product"
+	"github.com/rajindersingh041/go-auth-sessions/promotion"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
 )
 
 // OrderService defines the business logic interface for order operations
@@ -14,19 +20,27 @@ type OrderService interface {
 	CreateSingleOrder(ctx context.Context, userID uint64, req CreateSingleOrderRequest) (*Order, error)
 	GetOrdersByUserID(ctx context.Context, userID uint64) ([]Order, error)
 	GetOrderByID(ctx context.Context, orderID uint64) (*Order, error)
+	// UpdateOrderStatus moves an order along its lifecycle: pending, shipped, delivered or cancelled
+	UpdateOrderStatus(ctx context.Context, orderID uint64, status string) (*Order, error)
 }
 
 // orderService implements the OrderService interface
 type orderService struct {
-	repo           OrderRepository
-	productService product.ProductService
+	repo             OrderRepository
+	productService   product.ProductService
+	taxCalculator    tax.TaxCalculator
+	fxService        fx.FXService
+	promotionService promotion.PromotionService
 }
 
 // NewOrderService creates a new order service
-func NewOrderService(repo OrderRepository, productService product.ProductService) OrderService {
+func NewOrderService(repo OrderRepository, productService product.ProductService, taxCalculator tax.TaxCalculator, fxService fx.FXService, promotionService promotion.PromotionService) OrderService {
 	return &orderService{
-		repo:           repo,
-		productService: productService,
+		repo:             repo,
+		productService:   productService,
+		taxCalculator:    taxCalculator,
+		fxService:        fxService,
+		promotionService: promotionService,
 	}
 }
 
@@ -38,9 +52,16 @@ func (s *orderService) CreateOrder(ctx context.Context, userID uint64, req Creat
 	if len(req.Items) == 0 {
 		return nil, fmt.Errorf("at least one product is required")
 	}
+	if err := s.taxCalculator.ValidateRegion(req.Region); err != nil {
+		return nil, err
+	}
 
 	var orderItems []OrderItem
-	var subtotal float64
+	var promotionLines []promotion.Line
+	var taxLines []tax.Line
+	var exchangeRates []fx.Rate
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	now := time.Now()
 
 	// Validate each product and calculate totals
 	for i, item := range req.Items {
@@ -48,45 +69,121 @@ func (s *orderService) CreateOrder(ctx context.Context, userID uint64, req Creat
 			return nil, fmt.Errorf("item %d: valid product ID and positive quantity are required", i+1)
 		}
 
-		// Validate product exists and is in stock
-		prod, err := s.productService.GetProductByID(ctx, item.ProductID)
-		if err != nil {
+		// Validate product exists and is in stock, at the prices scheduled for now
+		prod, err := s.productService.GetProductAt(ctx, item.ProductID, now)
+		if err != nil || prod.Archived {
 			return nil, fmt.Errorf("item %d: product not found", i+1)
 		}
+
+		// Products with variants are sold by variant, at the variant's price and stock
+		sku := ""
+		if item.VariantID != 0 {
+			variant, ok := prod.Variant(item.VariantID)
+			if !ok {
+				return nil, fmt.Errorf("item %d: variant %d of product '%s' not found", i+1, item.VariantID, prod.Name)
+			}
+			sold := prod.ForVariant(*variant)
+			prod, sku = &sold, variant.SKU
+		} else if len(prod.Variants) > 0 {
+			return nil, fmt.Errorf("item %d: a variant of product '%s' is required", i+1, prod.Name)
+		}
+
 		if !prod.InStock {
 			return nil, fmt.Errorf("item %d: product '%s' is currently out of stock", i+1, prod.Name)
 		}
 
+		// All items of an order are priced in one currency
+		if currency == "" {
+			currency = prod.Price.Currency
+		}
+		// Use the product's price in that currency, or convert its base price at today's rate
+		unitPrice, ok := prod.PriceIn(currency)
+		if !ok {
+			converted, rate, err := s.fxService.Convert(ctx, prod.Price, currency, now)
+			if err != nil {
+				return nil, fmt.Errorf("item %d: product '%s' cannot be priced in %s: %w", i+1, prod.Name, currency, err)
+			}
+			unitPrice = converted
+			exchangeRates = appendRate(exchangeRates, *rate)
+		}
+
 		// Calculate item total
-		itemTotal := prod.Price * float64(item.Quantity)
-		subtotal += itemTotal
+		itemTotal := unitPrice.Mul(int64(item.Quantity))
 
 		// Create order item
 		orderItem := OrderItem{
 			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			SKU:       sku,
 			Quantity:  item.Quantity,
-			UnitPrice: prod.Price,
+			UnitPrice: unitPrice,
 			Total:     itemTotal,
 		}
 		orderItems = append(orderItems, orderItem)
+		promotionLines = append(promotionLines, promotion.Line{
+			ProductID: item.ProductID,
+			Category:  prod.Category,
+			Quantity:  item.Quantity,
+			UnitPrice: unitPrice,
+			Amount:    itemTotal,
+		})
 	}
 
-	// Calculate tax and total
-	tax := subtotal * 0.1 // 10% tax
-	total := subtotal + tax
+	// Apply promotions and coupons before tax, so tax is charged on the discounted amounts
+	discounts, err := s.promotionService.Apply(ctx, promotion.ApplyRequest{
+		UserID:   userID,
+		Currency: currency,
+		Codes:    req.CouponCodes,
+		Lines:    promotionLines,
+		At:       now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range orderItems {
+		orderItems[i].Discount = discounts.LineDiscounts[i]
+		taxLines = append(taxLines, tax.Line{
+			ProductID: promotionLines[i].ProductID,
+			Category:  promotionLines[i].Category,
+			Quantity:  promotionLines[i].Quantity,
+			Amount:    orderItems[i].Total.Sub(orderItems[i].Discount),
+		})
+	}
+
+	// Calculate tax per line for the order's region
+	taxResult, err := s.taxCalculator.Calculate(ctx, tax.Request{Region: req.Region, Currency: currency, Lines: taxLines})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate tax: %w", err)
+	}
+	for i := range orderItems {
+		orderItems[i].Tax = &taxResult.Lines[i]
+	}
 
 	// Create order
+	// Subtotal excludes tax, so Subtotal + Tax = Total for both tax-inclusive and exclusive prices
 	order := &Order{
-		UserID:    userID,
-		Items:     orderItems,
-		Subtotal:  subtotal,
-		Tax:       tax,
-		Total:     total,
-		Status:    "pending",
-		CreatedAt: time.Now().Format(time.RFC3339),
+		UserID:        userID,
+		Items:         orderItems,
+		Region:        taxResult.Region,
+		Currency:      currency,
+		ExchangeRates: exchangeRates,
+		Discounts:     discounts.Discounts,
+		Discount:      discounts.Total,
+		Subtotal:      taxResult.Net,
+		Tax:           taxResult.Tax,
+		Total:         taxResult.Gross,
+		Status:        StatusPending,
+		CreatedAt:     now.Format(time.RFC3339),
+	}
+
+	// Count the use of each promotion; this fails if a usage limit was reached in the meantime
+	redemptions, err := s.promotionService.Redeem(ctx, userID, order.Discounts)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := s.repo.Create(ctx, order); err != nil {
+		s.promotionService.Release(ctx, redemptions)
 		return nil, err
 	}
 
@@ -100,13 +197,27 @@ func (s *orderService) CreateSingleOrder(ctx context.Context, userID uint64, req
 		Items: []OrderItemRequest{
 			{
 				ProductID: req.ProductID,
+				VariantID: req.VariantID,
 				Quantity:  req.Quantity,
 			},
 		},
+		Region:      req.Region,
+		Currency:    req.Currency,
+		CouponCodes: req.CouponCodes,
 	}
 	return s.CreateOrder(ctx, userID, multiReq)
 }
 
+// appendRate records an exchange rate once per currency pair
+func appendRate(rates []fx.Rate, rate fx.Rate) []fx.Rate {
+	for _, r := range rates {
+		if r.Base == rate.Base && r.Quote == rate.Quote {
+			return rates
+		}
+	}
+	return append(rates, rate)
+}
+
 // GetOrdersByUserID retrieves all orders for a specific user
 func (s *orderService) GetOrdersByUserID(ctx context.Context, userID uint64) ([]Order, error) {
 	if userID == 0 {
@@ -123,3 +234,35 @@ func (s *orderService) GetOrderByID(ctx context.Context, orderID uint64) (*Order
 	return s.repo.GetOrderByID(ctx, orderID)
 }
 
+// UpdateOrderStatus changes an order's status if its current status allows it
+func (s *orderService) UpdateOrderStatus(ctx context.Context, orderID uint64, status string) (*Order, error) {
+	order, err := s.GetOrderByID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, err
+	}
+
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case "":
+		return nil, fmt.Errorf("order status is required")
+	case StatusPending, StatusPaid, StatusShipped, StatusDelivered, StatusCancelled:
+	default:
+		return nil, fmt.Errorf("invalid order status '%s'", status)
+	}
+	allowed := false
+	for _, next := range statusTransitions[order.Status] {
+		allowed = allowed || next == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("order status must not change from '%s' to '%s'", order.Status, status)
+	}
+
+	if err := s.repo.UpdateStatus(ctx, orderID, status); err != nil {
+		return nil, err
+	}
+	order.Status = status
+	return order, nil
+}
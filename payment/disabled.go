@@ -0,0 +1,42 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// errPaymentsDisabled is returned by every operation of the disabled provider
+var errPaymentsDisabled = fmt.Errorf("card payments are not configured")
+
+// DisabledProvider is used when no PAYMENT_PROVIDER is configured
+// It refuses every operation, so no intents are created and no webhooks are accepted.
+type DisabledProvider struct{}
+
+// NewDisabledProvider creates a provider refusing all card payments
+func NewDisabledProvider() *DisabledProvider {
+	return &DisabledProvider{}
+}
+
+func (p *DisabledProvider) Name() string {
+	return "none"
+}
+
+func (p *DisabledProvider) CreateIntent(ctx context.Context, req IntentRequest) (*ProviderIntent, error) {
+	return nil, errPaymentsDisabled
+}
+
+func (p *DisabledProvider) Capture(ctx context.Context, providerIntentID string, amount money.Money) (*ProviderIntent, error) {
+	return nil, errPaymentsDisabled
+}
+
+func (p *DisabledProvider) Refund(ctx context.Context, providerIntentID string, amount money.Money, idempotencyKey string) (*ProviderRefund, error) {
+	return nil, errPaymentsDisabled
+}
+
+func (p *DisabledProvider) ParseWebhook(payload []byte, header http.Header, now time.Time) (*Event, error) {
+	return nil, errPaymentsDisabled
+}
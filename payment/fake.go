@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// FakeSignatureHeader carries the signature of the fake provider's webhooks
+const FakeSignatureHeader = "Fake-Signature"
+
+// DefaultFakeWebhookSecret is the secret cmd/paymentsim signs with when no secret is configured
+// It is public, so the server never falls back to it: the fake provider requires PAYMENT_WEBHOOK_SECRET
+const DefaultFakeWebhookSecret = "whsec_fake_local"
+
+// FakeProvider is an offline PaymentProvider for development and tests
+// It keeps no state: captures and refunds always succeed, and the service's stored intents decide
+// which operations are allowed. What the customer does with their card is simulated by sending
+// webhooks with cmd/paymentsim, which are signed with the same secret.
+type FakeProvider struct {
+	webhookSecret string
+}
+
+// NewFakeProvider creates a fake provider verifying webhooks with the given secret
+func NewFakeProvider(webhookSecret string) (*FakeProvider, error) {
+	if webhookSecret == "" {
+		return nil, fmt.Errorf("fake provider webhook secret is required")
+	}
+	return &FakeProvider{webhookSecret: webhookSecret}, nil
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateIntent(ctx context.Context, req IntentRequest) (*ProviderIntent, error) {
+	id := "pi_fake_" + randomID()
+	return &ProviderIntent{
+		ID:           id,
+		Status:       StatusPending,
+		ClientSecret: id + "_secret_" + randomID(),
+	}, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, providerIntentID string, amount money.Money) (*ProviderIntent, error) {
+	return &ProviderIntent{ID: providerIntentID, Status: StatusSucceeded}, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, providerIntentID string, amount money.Money, idempotencyKey string) (*ProviderRefund, error) {
+	return &ProviderRefund{ID: "re_fake_" + randomID(), Amount: amount, Succeeded: true}, nil
+}
+
+// ParseWebhook verifies the Fake-Signature header; the payload is an Event as JSON
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header, now time.Time) (*Event, error) {
+	if err := VerifySignature(payload, header.Get(FakeSignatureHeader), p.webhookSecret, now); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+	if event.ID == "" || event.IntentID == "" {
+		return nil, fmt.Errorf("invalid webhook payload: id and intent_id are required")
+	}
+	return &event, nil
+}
+
+// NewFakeWebhook builds a signed webhook of the fake provider, returning the payload and its Fake-Signature header
+// Events without an ID get a random one; reuse an ID to simulate a repeated delivery
+func NewFakeWebhook(event Event, webhookSecret string, now time.Time) ([]byte, string, error) {
+	if event.ID == "" {
+		event.ID = "evt_fake_" + randomID()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, Sign(payload, webhookSecret, now), nil
+}
+
+// randomID returns 12 random hex characters
+func randomID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,204 @@
+package payment
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// maxWebhookSize limits the size of webhook payloads
+const maxWebhookSize = 1 << 20
+
+// Handler handles HTTP requests for card payments and provider webhooks
+type Handler struct {
+	service     PaymentService
+	userService user.UserService
+	admins      auth.AdminList
+}
+
+// NewHandler creates a new payment handler
+func NewHandler(service PaymentService, userService user.UserService, admins auth.AdminList) *Handler {
+	return &Handler{
+		service:     service,
+		userService: userService,
+		admins:      admins,
+	}
+}
+
+// RegisterRoutes registers the payment routes
+// The webhook is authenticated by its signature instead of a JWT
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	mux.Handle("POST /payments/intents", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleCreateIntent())))
+	mux.Handle("GET /payments/intents/{id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetIntent())))
+	mux.HandleFunc("POST /payments/webhook", h.handleWebhook())
+
+	// Admin routes
+	mux.Handle("POST /admin/payments/intents/{id}/capture", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleCapture())))
+	mux.Handle("POST /admin/payments/intents/{id}/refund", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleRefund())))
+}
+
+// handleCreateIntent handles POST /payments/intents, starting a card payment of one of the user's invoices
+// Body: {"invoice_id":456}
+func (h *Handler) handleCreateIntent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+		var req CreateIntentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		intent, err := h.service.CreateIntent(r.Context(), user.UserID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create payment intent", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, intent)
+	}
+}
+
+// handleGetIntent handles GET /payments/intents/{id}; customers only see their own intents
+func (h *Handler) handleGetIntent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		intentID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid payment intent ID")
+			return
+		}
+		user, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+
+		intent, err := h.service.GetIntent(r.Context(), intentID)
+		if err == nil && intent.UserID != user.UserID && !h.admins.IsAdmin(user.Username) {
+			helper.RespondError(w, http.StatusNotFound, "payment intent not found")
+			return
+		}
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to retrieve payment intent", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, intent)
+	}
+}
+
+// handleCapture handles POST /admin/payments/intents/{id}/capture
+// Body (optional): {"amount":{"amount":"40.00","currency":"USD"}}
+func (h *Handler) handleCapture() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		intentID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid payment intent ID")
+			return
+		}
+		var req CaptureRequest
+		if err := decodeOptional(r, &req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		intent, err := h.service.Capture(r.Context(), intentID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to capture payment", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, intent)
+	}
+}
+
+// handleRefund handles POST /admin/payments/intents/{id}/refund
+// Body (optional): {"amount":{"amount":"10.00","currency":"USD"}}
+func (h *Handler) handleRefund() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		intentID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid payment intent ID")
+			return
+		}
+		var req RefundRequest
+		if err := decodeOptional(r, &req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		intent, err := h.service.Refund(r.Context(), intentID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to refund payment", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, intent)
+	}
+}
+
+// handleWebhook handles POST /payments/webhook from the payment provider
+// Invalid signatures get 400; failures to apply an event get 500, so the provider retries the delivery
+func (h *Handler) handleWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		event, duplicate, err := h.service.HandleWebhook(r.Context(), payload, r.Header)
+		if err != nil {
+			if event == nil {
+				helper.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("Failed to apply payment webhook %s: %v", event.ID, err)
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to apply webhook event")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"received":  true,
+			"event_id":  event.ID,
+			"duplicate": duplicate,
+		})
+	}
+}
+
+// decodeOptional decodes a JSON body that may be empty
+func decodeOptional(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// currentUser resolves the authenticated user, writing an error response when that fails
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	username, ok := r.Context().Value(auth.UsernameContextKey).(string)
+	if !ok || username == "" {
+		helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+		return nil, false
+	}
+	u, err := h.userService.GetUserByUsername(r.Context(), username)
+	if err != nil || u == nil {
+		helper.RespondError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	return u, true
+}
+
+// errorRules answer the payment service errors the shared rules don't; operations that don't fit the state
+// of the intent or invoice ("payment ... must ...") are conflicts
+var errorRules = []helper.ErrorRule{
+	{Fragment: "payment must", Status: http.StatusConflict},
+	{Fragment: "payment intent must", Status: http.StatusConflict},
+}
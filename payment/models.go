@@ -0,0 +1,87 @@
+package payment
+
+import (
+	"context"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// Intent statuses
+// Intents start as pending until the customer entered their card, are authorized until captured,
+// and succeed once captured; fully refunded intents end as refunded
+const (
+	StatusPending    = "pending"
+	StatusAuthorized = "authorized"
+	StatusSucceeded  = "succeeded"
+	StatusFailed     = "failed"
+	StatusRefunded   = "refunded"
+)
+
+// Event types reported by providers through webhooks
+const (
+	EventAuthorized = "payment.authorized" // the card was authorized and the payment can be captured
+	EventSucceeded  = "payment.succeeded"  // the payment was captured
+	EventFailed     = "payment.failed"
+	EventRefunded   = "payment.refunded" // a refund was paid out
+)
+
+// Intent is a card payment of an invoice through a payment provider
+type Intent struct {
+	IntentID         uint64      `json:"intent_id"`
+	InvoiceID        uint64      `json:"invoice_id"`
+	OrderID          uint64      `json:"order_id"`
+	UserID           uint64      `json:"user_id"`
+	Provider         string      `json:"provider"`
+	ProviderIntentID string      `json:"provider_intent_id"`
+	Amount           money.Money `json:"amount"`   // amount the customer is asked to pay
+	Captured         money.Money `json:"captured"` // amount collected, kept in sync with the invoice's payments
+	Refunded         money.Money `json:"refunded"` // amount paid back, kept in sync with the invoice's payments
+	Status           string      `json:"status"`
+	FailureReason    string      `json:"failure_reason,omitempty"`
+	ClientSecret     string      `json:"client_secret,omitempty"` // only returned when the intent is created, never stored
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// Event is a webhook notification from a payment provider, translated to the event types above
+// Events of types not listed above are acknowledged and ignored
+type Event struct {
+	ID            string      `json:"id"`
+	Type          string      `json:"type"`
+	IntentID      string      `json:"intent_id"` // the provider's payment intent ID
+	Amount        money.Money `json:"amount"`    // amount authorized, captured or refunded
+	RefundID      string      `json:"refund_id,omitempty"`
+	FailureReason string      `json:"failure_reason,omitempty"`
+}
+
+// Repository defines the interface for payment intent and webhook event data operations
+type PaymentRepository interface {
+	CreateIntent(ctx context.Context, intent *Intent) error
+	GetIntent(ctx context.Context, intentID uint64) (*Intent, error)
+	GetIntentByProviderID(ctx context.Context, provider, providerIntentID string) (*Intent, error)
+	// UpdateIntent saves an intent's status, amounts and failure reason
+	UpdateIntent(ctx context.Context, intent *Intent) error
+	// ClaimEvent records a webhook event before it is applied, so other deliveries of it are ignored
+	// It reports false if the event was already claimed
+	ClaimEvent(ctx context.Context, provider string, event *Event) (bool, error)
+	// ReleaseEvent removes the claim of an event that failed to apply, so the provider's retry applies it
+	ReleaseEvent(ctx context.Context, provider, eventID string) error
+}
+
+// CreateIntentRequest represents the request to pay an invoice by card
+type CreateIntentRequest struct {
+	InvoiceID uint64 `json:"invoice_id"`
+}
+
+// CaptureRequest represents the request to capture an authorized payment
+// Amount defaults to the full amount of the intent
+type CaptureRequest struct {
+	Amount *money.Money `json:"amount,omitempty"`
+}
+
+// RefundRequest represents the request to refund a captured payment
+// Amount defaults to everything captured and not yet refunded
+type RefundRequest struct {
+	Amount *money.Money `json:"amount,omitempty"`
+}
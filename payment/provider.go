@@ -0,0 +1,108 @@
+package payment // Package payment collects card payments for invoices through payment providers such as Stripe.
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// PaymentProvider is a card payment provider
+// Payments are authorized first and captured separately, so orders can be checked before money is taken
+type PaymentProvider interface {
+	// Name identifies the provider on stored intents and webhook events
+	Name() string
+	// CreateIntent starts a payment the customer completes with the returned client secret
+	CreateIntent(ctx context.Context, req IntentRequest) (*ProviderIntent, error)
+	// Capture collects an authorized payment; amount may be less than authorized
+	Capture(ctx context.Context, providerIntentID string, amount money.Money) (*ProviderIntent, error)
+	// Refund pays a captured amount back to the customer
+	// Refunds with the same idempotency key are only paid out once
+	Refund(ctx context.Context, providerIntentID string, amount money.Money, idempotencyKey string) (*ProviderRefund, error)
+	// ParseWebhook verifies a webhook's signature and translates its event
+	ParseWebhook(payload []byte, header http.Header, now time.Time) (*Event, error)
+}
+
+// IntentRequest describes a payment to start at a provider
+// Requests with the same idempotency key return the same provider intent
+type IntentRequest struct {
+	Amount         money.Money
+	Description    string
+	Metadata       map[string]string
+	IdempotencyKey string
+}
+
+// ProviderIntent is a payment intent as reported by a provider
+type ProviderIntent struct {
+	ID           string
+	Status       string // one of the intent statuses
+	ClientSecret string
+}
+
+// ProviderRefund is a refund as reported by a provider
+// Refunds that are still processing are applied once the provider reports them by webhook
+type ProviderRefund struct {
+	ID        string
+	Amount    money.Money
+	Succeeded bool
+}
+
+// signatureTolerance is how old a webhook signature may be, which limits replays of intercepted webhooks
+const signatureTolerance = 5 * time.Minute
+
+// Sign signs a webhook payload in the format of Stripe's Stripe-Signature header:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<payload>">"
+func Sign(payload []byte, secret string, t time.Time) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(payload, secret, timestamp)
+}
+
+// VerifySignature checks a signature header created by Sign
+// Headers may carry several v1 signatures, e.g. while a webhook secret is rotated
+func VerifySignature(payload []byte, header, secret string, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("invalid webhook signature header")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook signature timestamp")
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return fmt.Errorf("invalid webhook signature: timestamp is outside the tolerance of %s", signatureTolerance)
+	}
+
+	expected := []byte(signature(payload, secret, timestamp))
+	for _, candidate := range signatures {
+		if hmac.Equal(expected, []byte(candidate)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid webhook signature")
+}
+
+// signature computes the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func signature(payload []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,174 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+)
+
+// ClickHouseRepository implements PaymentRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db  *sql.DB
+	ids idgen.Generator
+}
+
+// NewClickHouseRepository creates a new ClickHouse payment repository
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) PaymentRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
+}
+
+// ensurePaymentTables creates the payment_intents and payment_events tables if they don't exist
+// ClickHouse has no unique constraints; deliveries of the same webhook event are told apart by their claim, see ClaimEvent
+func (r *ClickHouseRepository) ensurePaymentTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS payment_intents (
+			intent_id UInt64,
+			invoice_id UInt64,
+			order_id UInt64,
+			user_id UInt64,
+			provider String,
+			provider_intent_id String,
+			amount Decimal(18, 2),
+			captured Decimal(18, 2),
+			refunded Decimal(18, 2),
+			currency String,
+			status String,
+			failure_reason String DEFAULT '',
+			created_at DateTime64(3),
+			updated_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY intent_id`,
+		`CREATE TABLE IF NOT EXISTS payment_events (
+			provider String,
+			event_id String,
+			type String,
+			provider_intent_id String,
+			received_at DateTime64(3) DEFAULT now64(3),
+			claim String DEFAULT ''
+		) ENGINE = MergeTree()
+		ORDER BY (provider, event_id)`,
+		`ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS claim String DEFAULT ''`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ClickHouseRepository) CreateIntent(ctx context.Context, intent *Intent) error {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return err
+	}
+	intent.IntentID = r.ids.NextID()
+	query := `
+		INSERT INTO payment_intents (intent_id, invoice_id, order_id, user_id, provider, provider_intent_id, amount, captured, refunded, currency, status, failure_reason, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query,
+		intent.IntentID, intent.InvoiceID, intent.OrderID, intent.UserID, intent.Provider, intent.ProviderIntentID,
+		intent.Amount, intent.Captured, intent.Refunded, intent.Amount.Currency,
+		intent.Status, intent.FailureReason, intent.CreatedAt, intent.UpdatedAt,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) GetIntent(ctx context.Context, intentID uint64) (*Intent, error) {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + intentColumns + " FROM payment_intents WHERE intent_id = ? LIMIT 1"
+	return scanIntent(r.db.QueryRowContext(ctx, query, intentID))
+}
+
+func (r *ClickHouseRepository) GetIntentByProviderID(ctx context.Context, provider, providerIntentID string) (*Intent, error) {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + intentColumns + " FROM payment_intents WHERE provider = ? AND provider_intent_id = ? LIMIT 1"
+	return scanIntent(r.db.QueryRowContext(ctx, query, provider, providerIntentID))
+}
+
+func (r *ClickHouseRepository) UpdateIntent(ctx context.Context, intent *Intent) error {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		ALTER TABLE payment_intents UPDATE captured = ?, refunded = ?, status = ?, failure_reason = ?, updated_at = ?
+		WHERE intent_id = ?`
+	_, err := r.db.ExecContext(ctx, query, intent.Captured, intent.Refunded, intent.Status, intent.FailureReason, intent.UpdatedAt, intent.IntentID)
+	return err
+}
+
+// ClaimEvent inserts the event with a random claim and reads the event's rows back: the earliest delivery wins
+// Later deliveries withdraw their row and report the event as claimed; deliveries received in the same
+// millisecond withdraw theirs and fail, so the provider retries them
+func (r *ClickHouseRepository) ClaimEvent(ctx context.Context, provider string, event *Event) (bool, error) {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return false, err
+	}
+	claim := randomID()
+	query := "INSERT INTO payment_events (provider, event_id, type, provider_intent_id, claim) VALUES (?, ?, ?, ?, ?)"
+	if _, err := r.db.ExecContext(ctx, query, provider, event.ID, event.Type, event.IntentID, claim); err != nil {
+		return false, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT claim, received_at FROM payment_events WHERE provider = ? AND event_id = ?", provider, event.ID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	var mine time.Time
+	var others []time.Time
+	for rows.Next() {
+		var rowClaim string
+		var receivedAt time.Time
+		if err := rows.Scan(&rowClaim, &receivedAt); err != nil {
+			return false, err
+		}
+		if rowClaim == claim {
+			mine = receivedAt
+		} else {
+			others = append(others, receivedAt)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if mine.IsZero() {
+		return false, fmt.Errorf("claim of webhook event %s was not stored", event.ID)
+	}
+
+	tied := false
+	for _, other := range others {
+		if other.Before(mine) {
+			return false, r.withdrawClaim(ctx, claim)
+		}
+		tied = tied || other.Equal(mine)
+	}
+	if tied {
+		if err := r.withdrawClaim(ctx, claim); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("webhook event %s was delivered twice at the same time", event.ID)
+	}
+	return true, nil
+}
+
+// withdrawClaim deletes the row of a delivery that lost its claim
+func (r *ClickHouseRepository) withdrawClaim(ctx context.Context, claim string) error {
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE payment_events DELETE WHERE claim = ? SETTINGS mutations_sync = 1", claim)
+	return err
+}
+
+func (r *ClickHouseRepository) ReleaseEvent(ctx context.Context, provider, eventID string) error {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return err
+	}
+	query := "ALTER TABLE payment_events DELETE WHERE provider = ? AND event_id = ? SETTINGS mutations_sync = 1"
+	_, err := r.db.ExecContext(ctx, query, provider, eventID)
+	return err
+}
@@ -0,0 +1,151 @@
+package payment
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// PostgresRepository implements PaymentRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL payment repository
+func NewPostgresRepository(db *sql.DB) PaymentRepository {
+	return &PostgresRepository{db: db}
+}
+
+// ensurePaymentTables creates the payment_intents and payment_events tables if they don't exist
+func (r *PostgresRepository) ensurePaymentTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS payment_intents (
+			intent_id SERIAL PRIMARY KEY,
+			invoice_id BIGINT NOT NULL,
+			order_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			provider VARCHAR(50) NOT NULL,
+			provider_intent_id VARCHAR(255) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			captured DECIMAL(10,2) NOT NULL DEFAULT 0,
+			refunded DECIMAL(10,2) NOT NULL DEFAULT 0,
+			currency VARCHAR(3) NOT NULL,
+			status VARCHAR(20) NOT NULL,
+			failure_reason TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (provider, provider_intent_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_payment_intents_invoice ON payment_intents (invoice_id)",
+		`CREATE TABLE IF NOT EXISTS payment_events (
+			provider VARCHAR(50) NOT NULL,
+			event_id VARCHAR(255) NOT NULL,
+			type VARCHAR(100) NOT NULL,
+			provider_intent_id VARCHAR(255) NOT NULL,
+			received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (provider, event_id)
+		)`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// intentColumns are the columns read by scanIntent
+const intentColumns = "intent_id, invoice_id, order_id, user_id, provider, provider_intent_id, amount, captured, refunded, currency, status, failure_reason, created_at, updated_at"
+
+func (r *PostgresRepository) CreateIntent(ctx context.Context, intent *Intent) error {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO payment_intents (invoice_id, order_id, user_id, provider, provider_intent_id, amount, captured, refunded, currency, status, failure_reason, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING intent_id`
+	return r.db.QueryRowContext(ctx, query,
+		intent.InvoiceID, intent.OrderID, intent.UserID, intent.Provider, intent.ProviderIntentID,
+		intent.Amount, intent.Captured, intent.Refunded, intent.Amount.Currency,
+		intent.Status, intent.FailureReason, intent.CreatedAt, intent.UpdatedAt,
+	).Scan(&intent.IntentID)
+}
+
+func (r *PostgresRepository) GetIntent(ctx context.Context, intentID uint64) (*Intent, error) {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + intentColumns + " FROM payment_intents WHERE intent_id = $1"
+	return scanIntent(r.db.QueryRowContext(ctx, query, intentID))
+}
+
+func (r *PostgresRepository) GetIntentByProviderID(ctx context.Context, provider, providerIntentID string) (*Intent, error) {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + intentColumns + " FROM payment_intents WHERE provider = $1 AND provider_intent_id = $2"
+	return scanIntent(r.db.QueryRowContext(ctx, query, provider, providerIntentID))
+}
+
+func (r *PostgresRepository) UpdateIntent(ctx context.Context, intent *Intent) error {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		UPDATE payment_intents SET captured = $1, refunded = $2, status = $3, failure_reason = $4, updated_at = $5
+		WHERE intent_id = $6`
+	result, err := r.db.ExecContext(ctx, query, intent.Captured, intent.Refunded, intent.Status, intent.FailureReason, intent.UpdatedAt, intent.IntentID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("payment intent not found")
+	}
+	return nil
+}
+
+// ClaimEvent inserts the event; the primary key lets only one delivery of it in
+func (r *PostgresRepository) ClaimEvent(ctx context.Context, provider string, event *Event) (bool, error) {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return false, err
+	}
+	query := `
+		INSERT INTO payment_events (provider, event_id, type, provider_intent_id)
+		VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, provider, event.ID, event.Type, event.IntentID)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	return inserted == 1, err
+}
+
+func (r *PostgresRepository) ReleaseEvent(ctx context.Context, provider, eventID string) error {
+	if err := r.ensurePaymentTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "DELETE FROM payment_events WHERE provider = $1 AND event_id = $2", provider, eventID)
+	return err
+}
+
+// scanIntent reads a row of intentColumns
+func scanIntent(row *sql.Row) (*Intent, error) {
+	var intent Intent
+	var currency string
+	err := row.Scan(
+		&intent.IntentID, &intent.InvoiceID, &intent.OrderID, &intent.UserID, &intent.Provider, &intent.ProviderIntentID,
+		&intent.Amount, &intent.Captured, &intent.Refunded, &currency,
+		&intent.Status, &intent.FailureReason, &intent.CreatedAt, &intent.UpdatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment intent not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	money.WithCurrency(currency, &intent.Amount, &intent.Captured, &intent.Refunded)
+	return &intent, nil
+}
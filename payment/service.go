@@ -0,0 +1,348 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/invoice"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+	"github.com/rajindersingh041/go-auth-sessions/order"
+)
+
+// PaymentService defines the interface for card payments of invoices
+type PaymentService interface {
+	// CreateIntent starts a card payment of the balance of one of the user's invoices
+	CreateIntent(ctx context.Context, userID uint64, req CreateIntentRequest) (*Intent, error)
+	GetIntent(ctx context.Context, intentID uint64) (*Intent, error)
+	// Capture collects an authorized payment and records it on the invoice
+	Capture(ctx context.Context, intentID uint64, req CaptureRequest) (*Intent, error)
+	// Refund pays a captured payment back and records the refund on the invoice
+	Refund(ctx context.Context, intentID uint64, req RefundRequest) (*Intent, error)
+	// HandleWebhook verifies and applies a provider webhook
+	// Events delivered more than once are only applied once; duplicate reports whether the event was seen before
+	HandleWebhook(ctx context.Context, payload []byte, header http.Header) (event *Event, duplicate bool, err error)
+}
+
+// paymentService implements PaymentService
+type paymentService struct {
+	repo         PaymentRepository
+	provider     PaymentProvider
+	invoices     invoice.InvoiceService
+	orderService order.OrderService
+}
+
+// NewPaymentService creates a new payment service
+func NewPaymentService(repo PaymentRepository, provider PaymentProvider, invoices invoice.InvoiceService, orderService order.OrderService) PaymentService {
+	return &paymentService{
+		repo:         repo,
+		provider:     provider,
+		invoices:     invoices,
+		orderService: orderService,
+	}
+}
+
+// CreateIntent asks the provider for a payment of the invoice's balance due
+// While the balance is unchanged, the provider returns the intent it already created for it
+func (s *paymentService) CreateIntent(ctx context.Context, userID uint64, req CreateIntentRequest) (*Intent, error) {
+	if req.InvoiceID == 0 {
+		return nil, fmt.Errorf("invoice_id is required")
+	}
+	inv, err := s.invoices.GetInvoiceByID(ctx, req.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	// Other users' invoices are reported as missing rather than forbidden, so their IDs aren't revealed
+	if inv.UserID != userID {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	if inv.Status == invoice.StatusCancelled {
+		return nil, fmt.Errorf("payment must not be started for a cancelled invoice")
+	}
+	if inv.BalanceDue.IsZero() {
+		return nil, fmt.Errorf("invoice is already paid")
+	}
+
+	created, err := s.provider.CreateIntent(ctx, IntentRequest{
+		Amount:      inv.BalanceDue,
+		Description: "Invoice " + inv.InvoiceNumber,
+		Metadata: map[string]string{
+			"invoice_id": strconv.FormatUint(inv.InvoiceID, 10),
+			"order_id":   strconv.FormatUint(inv.OrderID, 10),
+		},
+		IdempotencyKey: fmt.Sprintf("invoice-%d-%s", inv.InvoiceID, inv.AmountPaid.String()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment intent: %w", err)
+	}
+	if existing, err := s.repo.GetIntentByProviderID(ctx, s.provider.Name(), created.ID); err == nil {
+		existing.ClientSecret = created.ClientSecret
+		return existing, nil
+	}
+
+	now := time.Now().UTC()
+	intent := &Intent{
+		InvoiceID:        inv.InvoiceID,
+		OrderID:          inv.OrderID,
+		UserID:           inv.UserID,
+		Provider:         s.provider.Name(),
+		ProviderIntentID: created.ID,
+		Amount:           inv.BalanceDue,
+		Captured:         money.Zero(inv.Currency),
+		Refunded:         money.Zero(inv.Currency),
+		Status:           created.Status,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := s.repo.CreateIntent(ctx, intent); err != nil {
+		return nil, fmt.Errorf("failed to save payment intent: %w", err)
+	}
+	intent.ClientSecret = created.ClientSecret
+	return intent, nil
+}
+
+func (s *paymentService) GetIntent(ctx context.Context, intentID uint64) (*Intent, error) {
+	return s.repo.GetIntent(ctx, intentID)
+}
+
+// Capture collects an authorized intent, by default for its full amount
+func (s *paymentService) Capture(ctx context.Context, intentID uint64, req CaptureRequest) (*Intent, error) {
+	intent, err := s.repo.GetIntent(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != StatusAuthorized {
+		return nil, fmt.Errorf("payment intent must be authorized to be captured, it is %s", intent.Status)
+	}
+	amount := intent.Amount
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	if err := validateAmount(amount, intent.Amount); err != nil {
+		return nil, err
+	}
+	inv, err := s.invoices.GetInvoiceByID(ctx, intent.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if inv.Status == invoice.StatusCancelled {
+		return nil, fmt.Errorf("payment must not be captured for a cancelled invoice")
+	}
+
+	captured, err := s.provider.Capture(ctx, intent.ProviderIntentID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture payment: %w", err)
+	}
+	// Providers that capture asynchronously report the capture by webhook instead
+	if captured.Status != StatusSucceeded {
+		return intent, nil
+	}
+	return intent, s.applyCapture(ctx, intent, amount)
+}
+
+// Refund pays back a captured intent, by default everything not yet refunded
+func (s *paymentService) Refund(ctx context.Context, intentID uint64, req RefundRequest) (*Intent, error) {
+	intent, err := s.repo.GetIntent(ctx, intentID)
+	if err != nil {
+		return nil, err
+	}
+	if intent.Status != StatusSucceeded {
+		return nil, fmt.Errorf("payment intent must be captured to be refunded, it is %s", intent.Status)
+	}
+	refundable := intent.Captured.Sub(intent.Refunded)
+	amount := refundable
+	if req.Amount != nil {
+		amount = *req.Amount
+	}
+	if err := validateAmount(amount, refundable); err != nil {
+		return nil, err
+	}
+
+	// The key repeats while the refunded amount is unchanged, so a retried request doesn't pay out twice
+	key := fmt.Sprintf("refund-%s-%s-%s", intent.ProviderIntentID, intent.Refunded.String(), amount.String())
+	refund, err := s.provider.Refund(ctx, intent.ProviderIntentID, amount, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+	if !refund.Succeeded {
+		return intent, nil
+	}
+	return intent, s.applyRefund(ctx, intent, refund.ID, refund.Amount)
+}
+
+// HandleWebhook claims a webhook event and applies it, so deliveries of the same event are only applied once,
+// even when they arrive at the same time
+// Events that fail to apply are released, so the provider's retry applies them again
+func (s *paymentService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, bool, error) {
+	event, err := s.provider.ParseWebhook(payload, header, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	claimed, err := s.repo.ClaimEvent(ctx, s.provider.Name(), event)
+	if err != nil {
+		return event, false, err
+	}
+	if !claimed {
+		return event, true, nil
+	}
+	if err := s.applyEvent(ctx, event); err != nil {
+		if releaseErr := s.repo.ReleaseEvent(ctx, s.provider.Name(), event.ID); releaseErr != nil {
+			return event, false, fmt.Errorf("%v (and failed to release webhook event: %v)", err, releaseErr)
+		}
+		return event, false, err
+	}
+	return event, false, nil
+}
+
+// applyEvent updates the intent an event is about and the invoice and order it pays for
+func (s *paymentService) applyEvent(ctx context.Context, event *Event) error {
+	switch event.Type {
+	case EventAuthorized, EventSucceeded, EventFailed, EventRefunded:
+	default:
+		return nil
+	}
+	intent, err := s.repo.GetIntentByProviderID(ctx, s.provider.Name(), event.IntentID)
+	if err != nil {
+		// Provider accounts may be shared with other systems, whose payments are none of our business
+		if strings.Contains(err.Error(), "not found") {
+			log.Printf("Ignoring %s webhook for unknown payment intent %s", event.Type, event.IntentID)
+			return nil
+		}
+		return err
+	}
+
+	switch event.Type {
+	case EventAuthorized:
+		if intent.Status != StatusPending {
+			return nil
+		}
+		intent.Status = StatusAuthorized
+	case EventFailed:
+		if intent.Status != StatusPending && intent.Status != StatusAuthorized {
+			return nil
+		}
+		intent.Status = StatusFailed
+		intent.FailureReason = event.FailureReason
+	case EventSucceeded:
+		// Captures already recorded, e.g. through the API, are only synced again
+		// A new one must be in the intent's currency and not exceed it, as nothing of it was captured yet
+		if intent.Captured.IsZero() {
+			if err := validateAmount(event.Amount, intent.Amount); err != nil {
+				return fmt.Errorf("invalid capture of payment intent %s: %v", intent.ProviderIntentID, err)
+			}
+		}
+		return s.applyCapture(ctx, intent, event.Amount)
+	case EventRefunded:
+		return s.applyRefund(ctx, intent, event.RefundID, event.Amount)
+	}
+	intent.UpdatedAt = time.Now().UTC()
+	return s.repo.UpdateIntent(ctx, intent)
+}
+
+// applyCapture records a captured payment on the invoice, referenced by the provider's intent ID,
+// and marks the order as paid once the invoice is paid in full
+func (s *paymentService) applyCapture(ctx context.Context, intent *Intent, amount money.Money) error {
+	_, _, err := s.invoices.RecordPayment(ctx, intent.InvoiceID, invoice.RecordPaymentRequest{
+		Amount:    amount,
+		Method:    invoice.MethodCard,
+		Reference: intent.ProviderIntentID,
+	})
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return fmt.Errorf("failed to record payment on invoice: %w", err)
+	}
+	inv, err := s.syncIntent(ctx, intent)
+	if err != nil {
+		return err
+	}
+	if inv.Status == invoice.StatusPaid {
+		return s.moveOrder(ctx, intent.OrderID, order.StatusPending, order.StatusPaid)
+	}
+	return nil
+}
+
+// applyRefund records a refund on the invoice, referenced by "<provider intent ID>/<refund ID>",
+// and cancels the order when a paid order that hasn't shipped was refunded in full
+func (s *paymentService) applyRefund(ctx context.Context, intent *Intent, refundID string, amount money.Money) error {
+	_, _, err := s.invoices.RecordRefund(ctx, intent.InvoiceID, invoice.RecordPaymentRequest{
+		Amount:    amount,
+		Method:    invoice.MethodCard,
+		Reference: intent.ProviderIntentID + "/" + refundID,
+	})
+	if err != nil && !strings.Contains(err.Error(), "already exists") {
+		return fmt.Errorf("failed to record refund on invoice: %w", err)
+	}
+	inv, err := s.syncIntent(ctx, intent)
+	if err != nil {
+		return err
+	}
+	if inv.AmountPaid.IsZero() {
+		return s.moveOrder(ctx, intent.OrderID, order.StatusPaid, order.StatusCancelled)
+	}
+	return nil
+}
+
+// syncIntent derives an intent's captured and refunded amounts and its status from the invoice's payments
+// Deriving them rather than adding to them keeps intents right when webhooks and API responses report the same payment
+// It returns the invoice with its current balance
+func (s *paymentService) syncIntent(ctx context.Context, intent *Intent) (*invoice.Invoice, error) {
+	inv, payments, err := s.invoices.GetPayments(ctx, intent.InvoiceID)
+	if err != nil {
+		return nil, err
+	}
+	captured := money.Zero(intent.Amount.Currency)
+	refunded := money.Zero(intent.Amount.Currency)
+	for _, payment := range payments {
+		switch {
+		case payment.Reference == intent.ProviderIntentID:
+			captured, err = captured.CheckedAdd(payment.Amount)
+		case strings.HasPrefix(payment.Reference, intent.ProviderIntentID+"/"):
+			refunded, err = refunded.CheckedSub(payment.Amount)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("payment %d of invoice %d doesn't match the currency of payment intent %s: %w", payment.PaymentID, intent.InvoiceID, intent.ProviderIntentID, err)
+		}
+	}
+	intent.Captured = captured
+	intent.Refunded = refunded
+	switch {
+	case !refunded.IsZero() && refunded.Cmp(captured) >= 0:
+		intent.Status = StatusRefunded
+	case !captured.IsZero():
+		intent.Status = StatusSucceeded
+	}
+	intent.UpdatedAt = time.Now().UTC()
+	return inv, s.repo.UpdateIntent(ctx, intent)
+}
+
+// moveOrder changes an order's status if it still has the expected status
+func (s *paymentService) moveOrder(ctx context.Context, orderID uint64, from, to string) error {
+	o, err := s.orderService.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get order %d: %w", orderID, err)
+	}
+	if o.Status != from {
+		return nil
+	}
+	if _, err := s.orderService.UpdateOrderStatus(ctx, orderID, to); err != nil {
+		return fmt.Errorf("failed to mark order %d as %s: %w", orderID, to, err)
+	}
+	return nil
+}
+
+// validateAmount checks that an amount is positive, in the right currency and at most limit
+func validateAmount(amount, limit money.Money) error {
+	if !amount.SameCurrency(limit) {
+		return fmt.Errorf("amount must be in %s", limit.Currency)
+	}
+	if amount.IsNegative() || amount.IsZero() {
+		return fmt.Errorf("amount must be positive")
+	}
+	if amount.Cmp(limit) > 0 {
+		return fmt.Errorf("amount must not exceed %s", limit.Display())
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// StripeConfig configures the Stripe provider
+type StripeConfig struct {
+	SecretKey     string // e.g. "sk_test_..."
+	WebhookSecret string // signing secret of the webhook endpoint, "whsec_..."
+	BaseURL       string // defaults to https://api.stripe.com
+}
+
+// StripeProvider implements PaymentProvider against the Stripe REST API without an SDK
+// Intents use manual capture, and amounts are sent in minor units like money.Money stores them
+type StripeProvider struct {
+	config StripeConfig
+	client *http.Client
+}
+
+// NewStripeProvider creates a Stripe provider
+func NewStripeProvider(config StripeConfig) (*StripeProvider, error) {
+	if config.SecretKey == "" || config.WebhookSecret == "" {
+		return nil, fmt.Errorf("Stripe secret key and webhook secret are required")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.stripe.com"
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	return &StripeProvider{config: config, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// stripeIntent is the part of a Stripe PaymentIntent object used here
+type stripeIntent struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	ClientSecret     string `json:"client_secret"`
+	Currency         string `json:"currency"`
+	AmountCapturable int64  `json:"amount_capturable"`
+	AmountReceived   int64  `json:"amount_received"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+// stripeRefund is the part of a Stripe Refund object used here
+type stripeRefund struct {
+	ID            string `json:"id"`
+	Amount        int64  `json:"amount"`
+	Currency      string `json:"currency"`
+	Status        string `json:"status"`
+	PaymentIntent string `json:"payment_intent"`
+}
+
+func (p *StripeProvider) CreateIntent(ctx context.Context, req IntentRequest) (*ProviderIntent, error) {
+	form := url.Values{
+		"amount":                 {strconv.FormatInt(req.Amount.Amount, 10)},
+		"currency":               {strings.ToLower(req.Amount.Currency)},
+		"capture_method":         {"manual"},
+		"description":            {req.Description},
+		"payment_method_types[]": {"card"},
+	}
+	for key, value := range req.Metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+	var intent stripeIntent
+	if err := p.post(ctx, "/v1/payment_intents", form, req.IdempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return &ProviderIntent{ID: intent.ID, Status: stripeStatus(intent.Status), ClientSecret: intent.ClientSecret}, nil
+}
+
+func (p *StripeProvider) Capture(ctx context.Context, providerIntentID string, amount money.Money) (*ProviderIntent, error) {
+	form := url.Values{"amount_to_capture": {strconv.FormatInt(amount.Amount, 10)}}
+	var intent stripeIntent
+	if err := p.post(ctx, "/v1/payment_intents/"+url.PathEscape(providerIntentID)+"/capture", form, "", &intent); err != nil {
+		return nil, err
+	}
+	return &ProviderIntent{ID: intent.ID, Status: stripeStatus(intent.Status)}, nil
+}
+
+func (p *StripeProvider) Refund(ctx context.Context, providerIntentID string, amount money.Money, idempotencyKey string) (*ProviderRefund, error) {
+	form := url.Values{
+		"payment_intent": {providerIntentID},
+		"amount":         {strconv.FormatInt(amount.Amount, 10)},
+	}
+	var refund stripeRefund
+	if err := p.post(ctx, "/v1/refunds", form, idempotencyKey, &refund); err != nil {
+		return nil, err
+	}
+	return &ProviderRefund{
+		ID:        refund.ID,
+		Amount:    money.New(refund.Amount, refund.Currency),
+		Succeeded: refund.Status == "succeeded",
+	}, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and translates the Stripe events used here
+// Other event types are returned unchanged, so they are acknowledged without effect
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header, now time.Time) (*Event, error) {
+	if err := VerifySignature(payload, header.Get("Stripe-Signature"), p.config.WebhookSecret, now); err != nil {
+		return nil, err
+	}
+	var envelope struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+
+	event := &Event{ID: envelope.ID, Type: envelope.Type}
+	switch envelope.Type {
+	case "payment_intent.amount_capturable_updated", "payment_intent.succeeded", "payment_intent.payment_failed":
+		var intent stripeIntent
+		if err := json.Unmarshal(envelope.Data.Object, &intent); err != nil {
+			return nil, fmt.Errorf("invalid webhook payload: %v", err)
+		}
+		event.IntentID = intent.ID
+		switch envelope.Type {
+		case "payment_intent.amount_capturable_updated":
+			event.Type = EventAuthorized
+			event.Amount = money.New(intent.AmountCapturable, intent.Currency)
+		case "payment_intent.succeeded":
+			event.Type = EventSucceeded
+			event.Amount = money.New(intent.AmountReceived, intent.Currency)
+		default:
+			event.Type = EventFailed
+			if intent.LastPaymentError != nil {
+				event.FailureReason = intent.LastPaymentError.Message
+			}
+		}
+	case "refund.created", "refund.updated":
+		var refund stripeRefund
+		if err := json.Unmarshal(envelope.Data.Object, &refund); err != nil {
+			return nil, fmt.Errorf("invalid webhook payload: %v", err)
+		}
+		// Refunds are only applied once the money was paid out
+		if refund.Status == "succeeded" {
+			event.Type = EventRefunded
+			event.IntentID = refund.PaymentIntent
+			event.RefundID = refund.ID
+			event.Amount = money.New(refund.Amount, refund.Currency)
+		}
+	}
+	return event, nil
+}
+
+// stripeStatus maps a Stripe PaymentIntent status to an intent status
+func stripeStatus(status string) string {
+	switch status {
+	case "requires_capture":
+		return StatusAuthorized
+	case "succeeded":
+		return StatusSucceeded
+	case "canceled":
+		return StatusFailed
+	default: // requires_payment_method, requires_confirmation, requires_action, processing
+		return StatusPending
+	}
+}
+
+// post sends a form-encoded request to the Stripe API and decodes the JSON response into out
+func (p *StripeProvider) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.SecretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error.Message != "" {
+			return fmt.Errorf("stripe request failed: %s", failure.Error.Message)
+		}
+		return fmt.Errorf("stripe request failed: %s", resp.Status)
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,136 @@
+package pdf
+
+import (
+	"strings"
+)
+
+// Font is one of the standard PDF fonts, which viewers provide without embedding
+type Font int
+
+// Fonts available to documents
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+// resource returns the name of the font in the page resources
+func (f Font) resource() string {
+	if f == HelveticaBold {
+		return "F2"
+	}
+	return "F1"
+}
+
+// Character widths in 1/1000 of the font size for the printable ASCII characters (32-126),
+// taken from the Adobe font metrics of the standard fonts
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// otherWidth is used for characters outside printable ASCII, such as accented letters and the euro sign
+const otherWidth = 556
+
+// TextWidth returns the width of text in points
+func TextWidth(font Font, size float64, text string) float64 {
+	widths := &helveticaWidths
+	if font == HelveticaBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, c := range encode(text) {
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += otherWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// WrapText breaks text into lines no wider than width, breaking at spaces where possible
+func WrapText(font Font, size float64, text string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(font, size, candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Words longer than a line are split wherever they reach the edge
+			line = ""
+			for _, r := range word {
+				if line != "" && TextWidth(font, size, line+string(r)) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Truncate shortens text to fit width, ending it with "..." when it was cut
+func Truncate(font Font, size float64, text string, width float64) string {
+	if TextWidth(font, size, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && TextWidth(font, size, string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimRight(string(runes), " ") + "..."
+}
+
+// winAnsiExtras maps the characters of WinAnsiEncoding between 0x80 and 0x9f, which differ from Latin-1
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// encode converts text to WinAnsiEncoding, the encoding of the standard fonts
+// Characters the fonts can't show are replaced by "?"
+func encode(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r >= 0x20 && r <= 0x7e, r >= 0xa0 && r <= 0xff:
+			out = append(out, byte(r))
+		default:
+			if c, ok := winAnsiExtras[r]; ok {
+				out = append(out, c)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+package pdf // Package pdf writes simple PDF documents with text, lines and filled rectangles.
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Page sizes in points (1/72 inch)
+var (
+	A4     = Size{Width: 595.28, Height: 841.89}
+	Letter = Size{Width: 612, Height: 792}
+)
+
+// Size is the width and height of a page in points
+type Size struct {
+	Width  float64
+	Height float64
+}
+
+// Color is an RGB color with components from 0 to 1
+type Color struct {
+	R, G, B float64
+}
+
+// Black is the default color of text and lines
+var Black = Color{}
+
+// Document is a PDF document built page by page
+// Output only depends on what was drawn: no timestamps or random IDs are written,
+// so rendering the same content twice gives identical bytes
+type Document struct {
+	size  Size
+	title string
+	pages []*Page
+}
+
+// New creates an empty document with pages of the given size
+func New(size Size) *Document {
+	return &Document{size: size}
+}
+
+// SetTitle sets the title shown by PDF viewers
+func (d *Document) SetTitle(title string) {
+	d.title = title
+}
+
+// Size returns the page size of the document
+func (d *Document) Size() Size {
+	return d.size
+}
+
+// AddPage appends a new blank page and returns it for drawing
+func (d *Document) AddPage() *Page {
+	page := &Page{size: d.size}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Pages returns the pages added so far
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// Page is one page of a document
+// Coordinates are in points from the top left corner, with y growing downwards
+type Page struct {
+	size    Size
+	content bytes.Buffer
+}
+
+// Text draws text with its baseline starting at x, y
+func (p *Page) Text(x, y float64, font Font, size float64, color Color, text string) {
+	fmt.Fprintf(&p.content, "BT /%s %s Tf %s rg %s %s Td %s Tj ET\n",
+		font.resource(), number(size), color.operands(), number(x), number(p.size.Height-y), literal(encode(text)))
+}
+
+// TextRight draws text that ends at x, for right-aligned columns
+func (p *Page) TextRight(x, y float64, font Font, size float64, color Color, text string) {
+	p.Text(x-TextWidth(font, size, text), y, font, size, color, text)
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(&p.content, "%s RG %s w %s %s m %s %s l S\n",
+		color.operands(), number(width), number(x1), number(p.size.Height-y1), number(x2), number(p.size.Height-y2))
+}
+
+// Rect fills a rectangle whose top left corner is at x, y
+func (p *Page) Rect(x, y, width, height float64, color Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n",
+		color.operands(), number(x), number(p.size.Height-y-height), number(width), number(height))
+}
+
+// WriteTo writes the document as PDF 1.4
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are the catalog, the page tree and the two fonts; each page adds a page and a content object
+	const firstPage = 5
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			number(d.size.Width), number(d.size.Height), firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+	info := "<< /Producer (go-auth-sessions) >>"
+	if d.title != "" {
+		info = fmt.Sprintf("<< /Title %s /Producer (go-auth-sessions) >>", literal(encode(d.title)))
+	}
+	object(info)
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, len(offsets), xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// Bytes returns the document as PDF
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// operands formats a color for the rg and RG operators
+func (c Color) operands() string {
+	return number(c.R) + " " + number(c.G) + " " + number(c.B)
+}
+
+// ParseColor parses a hex color such as "#1f4e79"
+func ParseColor(hex string) (Color, error) {
+	value := strings.TrimPrefix(hex, "#")
+	if len(value) != 6 {
+		return Color{}, fmt.Errorf("invalid color '%s': must be like #1f4e79", hex)
+	}
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid color '%s': must be like #1f4e79", hex)
+	}
+	return Color{
+		R: float64(rgb>>16&0xff) / 255,
+		G: float64(rgb>>8&0xff) / 255,
+		B: float64(rgb&0xff) / 255,
+	}, nil
+}
+
+// number formats a coordinate or size with at most three decimals, so output doesn't depend on float noise
+func number(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 3, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// literal writes WinAnsi encoded text as a PDF string, escaping delimiters and non-ASCII bytes
+func literal(text []byte) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -2,6 +2,10 @@ package product
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,13 +18,18 @@ import (
 type Handler struct {
 	service    ProductService
 	jwtManager auth.JWTManager
+	admins     auth.AdminList
 }
 
+// maxImportSize limits the size of uploaded product files
+const maxImportSize = 32 << 20
+
 // NewHandler creates a new product handler
-func NewHandler(service ProductService, jwtManager auth.JWTManager) *Handler {
+func NewHandler(service ProductService, jwtManager auth.JWTManager, admins auth.AdminList) *Handler {
 	return &Handler{
 		service:    service,
 		jwtManager: jwtManager,
+		admins:     admins,
 	}
 }
 
@@ -29,10 +38,26 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager)
 	// Public routes (no authentication required)
 	mux.HandleFunc("GET /products", h.handleGetAllProducts())
 	mux.HandleFunc("GET /products/", h.handleGetProductByIDOrCategory())
+	mux.HandleFunc("GET /products/search", h.handleSearchProducts())
 	
-	// Protected routes (authentication required)
-	mux.Handle("POST /products", auth.WithJWTAuth(h.jwtManager, http.HandlerFunc(h.handleCreateProduct())))
-	mux.Handle("PUT /products/", auth.WithJWTAuth(h.jwtManager, http.HandlerFunc(h.handleUpdateProductStock())))
+	// Catalog changes (admin only, like the /admin routes below)
+	mux.Handle("POST /products", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleCreateProduct())))
+	mux.Handle("PUT /products/", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleUpdateProductStock())))
+	mux.Handle("PUT /products/{id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleUpdateProduct())))
+	mux.Handle("PATCH /products/{id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handlePatchProduct())))
+	mux.Handle("DELETE /products/{id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleDeleteProduct())))
+	mux.Handle("POST /products/{id}/variants", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleAddVariant())))
+	mux.Handle("PUT /products/{id}/variants/{variant_id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleUpdateVariant())))
+	mux.Handle("DELETE /products/{id}/variants/{variant_id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleDeleteVariant())))
+	mux.Handle("POST /products/{id}/images", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleUploadImage())))
+	mux.Handle("DELETE /products/{id}/images/{image_id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleDeleteImage())))
+
+	// Admin routes
+	mux.Handle("POST /admin/products/import", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleImportProducts())))
+	mux.Handle("GET /admin/products/export", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleExportProducts())))
+	mux.Handle("GET /admin/products/{id}/prices", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleGetPriceHistory())))
+	mux.Handle("POST /admin/products/{id}/prices", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleSchedulePrice())))
+	mux.Handle("DELETE /admin/products/{id}/prices/{price_change_id}", auth.WithAdminAuth(h.jwtManager, h.admins, http.HandlerFunc(h.handleCancelScheduledPrice())))
 }
 
 
@@ -54,23 +79,82 @@ func (h *Handler) handleGetAllProducts() http.HandlerFunc {
 	}
 }
 
-// handleGetProductByIDOrCategory handles GET /products/{id} or GET /products/category/{category}
+// handleSearchProducts handles GET /products/search (public)
+// Query parameters: q, category, min_price, max_price, currency, in_stock, sort, cursor, limit
+func (h *Handler) handleSearchProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		req := SearchRequest{
+			Query:    params.Get("q"),
+			Category: params.Get("category"),
+			MinPrice: params.Get("min_price"),
+			MaxPrice: params.Get("max_price"),
+			Currency: params.Get("currency"),
+			Sort:     params.Get("sort"),
+			Cursor:   params.Get("cursor"),
+		}
+		if value := params.Get("in_stock"); value != "" {
+			inStock, err := strconv.ParseBool(value)
+			if err != nil {
+				helper.RespondError(w, http.StatusBadRequest, "Invalid in_stock value")
+				return
+			}
+			req.InStock = &inStock
+		}
+		if value := params.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				helper.RespondError(w, http.StatusBadRequest, "Invalid limit")
+				return
+			}
+			req.Limit = limit
+		}
+
+		result, err := h.service.SearchProducts(r.Context(), req)
+		if err != nil {
+			if strings.Contains(err.Error(), "invalid") {
+				helper.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to search products")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, result)
+	}
+}
+
+// handleGetProductByIDOrCategory handles GET /products/{id} or GET /products/category/{slug}
+// Category requests include sub-categories with ?include_descendants=true
 func (h *Handler) handleGetProductByIDOrCategory() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/products/")
 		
 		ctx := r.Context()
 		
-		// Check if it's a category request: /products/category/{category}
+		// Check if it's a category request: /products/category/{slug}
 		if strings.HasPrefix(path, "category/") {
-			category := strings.TrimPrefix(path, "category/")
-			if category == "" {
-				helper.RespondError(w, http.StatusBadRequest, "Category name is required")
+			slug := strings.TrimPrefix(path, "category/")
+			if slug == "" {
+				helper.RespondError(w, http.StatusBadRequest, "Category slug is required")
 				return
 			}
+
+			includeDescendants := false
+			if value := r.URL.Query().Get("include_descendants"); value != "" {
+				var err error
+				if includeDescendants, err = strconv.ParseBool(value); err != nil {
+					helper.RespondError(w, http.StatusBadRequest, "Invalid include_descendants value")
+					return
+				}
+			}
 			
-			products, err := h.service.GetProductsByCategory(ctx, category)
+			category, products, err := h.service.GetProductsByCategory(ctx, slug, includeDescendants)
 			if err != nil {
+				if err.Error() == "category not found" {
+					helper.RespondError(w, http.StatusNotFound, "Category not found")
+					return
+				}
 				helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch products by category")
 				return
 			}
@@ -96,7 +180,7 @@ func (h *Handler) handleGetProductByIDOrCategory() http.HandlerFunc {
 			return
 		}
 
-		product, err := h.service.GetProductByID(ctx, productID)
+		product, err := h.service.GetProductDetails(ctx, productID)
 		if err != nil {
 			if err.Error() == "product not found" {
 				helper.RespondError(w, http.StatusNotFound, "Product not found")
@@ -110,7 +194,7 @@ func (h *Handler) handleGetProductByIDOrCategory() http.HandlerFunc {
 	}
 }
 
-// handleCreateProduct handles requests to create a new product (protected)
+// handleCreateProduct handles requests to create a new product (admin)
 func (h *Handler) handleCreateProduct() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req CreateProductRequest
@@ -121,10 +205,14 @@ func (h *Handler) handleCreateProduct() http.HandlerFunc {
 
 		ctx := r.Context()
 		if err := h.service.CreateProduct(ctx, req); err != nil {
-			if strings.Contains(err.Error(), "required") {
+			if strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "price") || strings.Contains(err.Error(), "must") {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
 			}
+			if strings.Contains(err.Error(), "already exists") {
+				helper.RespondError(w, http.StatusConflict, err.Error())
+				return
+			}
 			helper.RespondError(w, http.StatusInternalServerError, "Failed to create product")
 			return
 		}
@@ -135,7 +223,7 @@ func (h *Handler) handleCreateProduct() http.HandlerFunc {
 	}
 }
 
-// handleUpdateProductStock handles requests to update product stock (protected)
+// handleUpdateProductStock handles requests to update product stock (admin)
 func (h *Handler) handleUpdateProductStock() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract product ID from URL: /products/{id}/stock
@@ -163,6 +251,10 @@ func (h *Handler) handleUpdateProductStock() http.HandlerFunc {
 
 		ctx := r.Context()
 		if err := h.service.UpdateProductStock(ctx, productID, req.InStock); err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				helper.RespondError(w, http.StatusNotFound, err.Error())
+				return
+			}
 			if strings.Contains(err.Error(), "required") {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
@@ -177,3 +269,391 @@ func (h *Handler) handleUpdateProductStock() http.HandlerFunc {
 	}
 }
 
+// handleUpdateProduct handles PUT /products/{id}, replacing all editable fields (admin)
+func (h *Handler) handleUpdateProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		var req CreateProductRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		product, err := h.service.UpdateProduct(r.Context(), productID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update product", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, product)
+	}
+}
+
+// handlePatchProduct handles PATCH /products/{id}, updating only the given fields (admin)
+func (h *Handler) handlePatchProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		var req PatchProductRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		product, err := h.service.PatchProduct(r.Context(), productID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update product", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, product)
+	}
+}
+
+// handleDeleteProduct handles DELETE /products/{id} (admin)
+// Products are archived rather than removed so existing orders and invoices keep their references
+func (h *Handler) handleDeleteProduct() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		if err := h.service.DeleteProduct(r.Context(), productID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to delete product", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Product deleted successfully",
+		})
+	}
+}
+
+// handleAddVariant handles POST /products/{id}/variants (admin)
+func (h *Handler) handleAddVariant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		var req VariantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		variant, err := h.service.AddVariant(r.Context(), productID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to add variant", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, variant)
+	}
+}
+
+// handleUpdateVariant handles PUT /products/{id}/variants/{variant_id} (admin)
+func (h *Handler) handleUpdateVariant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		variantID, err := strconv.ParseUint(r.PathValue("variant_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid variant ID")
+			return
+		}
+
+		var req VariantRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		variant, err := h.service.UpdateVariant(r.Context(), productID, variantID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update variant", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, variant)
+	}
+}
+
+// handleDeleteVariant handles DELETE /products/{id}/variants/{variant_id} (admin)
+func (h *Handler) handleDeleteVariant() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		variantID, err := strconv.ParseUint(r.PathValue("variant_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid variant ID")
+			return
+		}
+
+		if err := h.service.DeleteVariant(r.Context(), productID, variantID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to delete variant", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Variant deleted successfully",
+		})
+	}
+}
+
+// handleGetPriceHistory handles GET /admin/products/{id}/prices (admin only)
+func (h *Handler) handleGetPriceHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		history, err := h.service.GetPriceHistory(r.Context(), productID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to fetch price history", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"prices": history,
+			"count":  len(history),
+		})
+	}
+}
+
+// handleSchedulePrice handles POST /admin/products/{id}/prices (admin only)
+// Body: {"price":{"amount":"899.00","currency":"USD"},"prices":[...],"effective_from":"2024-11-29T00:00:00Z"}
+func (h *Handler) handleSchedulePrice() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		var req SchedulePriceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		change, err := h.service.SchedulePrice(r.Context(), productID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to schedule price", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, change)
+	}
+}
+
+// handleCancelScheduledPrice handles DELETE /admin/products/{id}/prices/{price_change_id} (admin only)
+func (h *Handler) handleCancelScheduledPrice() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		priceChangeID, err := strconv.ParseUint(r.PathValue("price_change_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid price change ID")
+			return
+		}
+
+		if err := h.service.CancelScheduledPrice(r.Context(), productID, priceChangeID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to cancel scheduled price", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Scheduled price cancelled successfully",
+		})
+	}
+}
+
+// handleUploadImage handles POST /products/{id}/images (admin)
+// The image is sent as multipart/form-data in the "image" field
+func (h *Handler) handleUploadImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		// Leave room for the multipart headers around the file
+		r.Body = http.MaxBytesReader(w, r.Body, MaxImageSize+1<<20)
+		file, _, err := r.FormFile("image")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				helper.RespondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Image must not be larger than %d MB", MaxImageSize>>20))
+				return
+			}
+			helper.RespondError(w, http.StatusBadRequest, "Multipart form with an image file is required")
+			return
+		}
+		defer file.Close()
+		if r.MultipartForm != nil {
+			defer r.MultipartForm.RemoveAll()
+		}
+
+		data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Failed to read image")
+			return
+		}
+
+		image, err := h.service.AddImage(r.Context(), productID, data)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to upload image", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, image)
+	}
+}
+
+// handleDeleteImage handles DELETE /products/{id}/images/{image_id} (admin)
+func (h *Handler) handleDeleteImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		imageID, err := strconv.ParseUint(r.PathValue("image_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid image ID")
+			return
+		}
+
+		if err := h.service.DeleteImage(r.Context(), productID, imageID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to delete image", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Image deleted successfully",
+		})
+	}
+}
+
+// errorRules answer the product service errors the shared rules don't; cancelling a price change that took effect
+// is a conflict
+var errorRules = []helper.ErrorRule{
+	{Fragment: "to be cancelled", Status: http.StatusConflict},
+	{Fragment: "more than one price", Status: http.StatusBadRequest},
+}
+
+// handleImportProducts handles POST /admin/products/import?dry_run=true (admin only)
+// The body is CSV (Content-Type: text/csv) or NDJSON (Content-Type: application/x-ndjson);
+// ?format=csv|ndjson overrides the content type
+func (h *Handler) handleImportProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			contentType := r.Header.Get("Content-Type")
+			switch {
+			case strings.HasPrefix(contentType, "text/csv"):
+				format = FormatCSV
+			case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"):
+				format = FormatNDJSON
+			default:
+				helper.RespondError(w, http.StatusBadRequest, "Content-Type must be text/csv or application/x-ndjson")
+				return
+			}
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
+		body := http.MaxBytesReader(w, r.Body, maxImportSize)
+		result, err := h.service.ImportProducts(r.Context(), format, body, dryRun)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge):
+				helper.RespondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File must not be larger than %d MB", maxImportSize>>20))
+			case strings.Contains(err.Error(), "required") || strings.Contains(err.Error(), "invalid"):
+				helper.RespondError(w, http.StatusBadRequest, err.Error())
+			default:
+				helper.RespondError(w, http.StatusInternalServerError, "Failed to import products")
+			}
+			return
+		}
+
+		switch {
+		case len(result.Errors) > 0:
+			helper.RespondJSON(w, http.StatusUnprocessableEntity, result)
+		case dryRun:
+			helper.RespondJSON(w, http.StatusOK, result)
+		default:
+			helper.RespondJSON(w, http.StatusCreated, result)
+		}
+	}
+}
+
+// handleExportProducts handles GET /admin/products/export?format=csv|ndjson (admin only)
+// The file is streamed as it is read, so the status is sent before all products are loaded
+func (h *Handler) handleExportProducts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatCSV
+		}
+		contentType := map[string]string{FormatCSV: "text/csv; charset=utf-8", FormatNDJSON: "application/x-ndjson"}[format]
+		if contentType == "" {
+			helper.RespondError(w, http.StatusBadRequest, fmt.Sprintf("invalid format '%s': must be csv or ndjson", format))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+		out := &exportWriter{ResponseWriter: w}
+		if err := h.service.ExportProducts(r.Context(), format, out); err != nil {
+			if !out.started {
+				w.Header().Del("Content-Disposition")
+				helper.RespondError(w, http.StatusInternalServerError, "Failed to export products")
+				return
+			}
+			log.Printf("Failed to export products: %v", err)
+		}
+	}
+}
+
+// exportWriter records whether an export has started sending, after which errors can only be logged
+type exportWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *exportWriter) Write(data []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *exportWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
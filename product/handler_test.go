@@ -0,0 +1,71 @@
+package product
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+)
+
+// deleteRecorder is a ProductService that only records deletions; other methods must not be reached
+type deleteRecorder struct {
+	ProductService
+	deleted []uint64
+}
+
+func (s *deleteRecorder) DeleteProduct(ctx context.Context, productID uint64) error {
+	s.deleted = append(s.deleted, productID)
+	return nil
+}
+
+// TestCatalogChangesRequireAdmin checks that every catalog mutation refuses users who aren't admins
+func TestCatalogChangesRequireAdmin(t *testing.T) {
+	jwtManager := auth.SimpleJWTManager{}
+	service := &deleteRecorder{}
+	mux := http.NewServeMux()
+	NewHandler(service, jwtManager, auth.ParseAdminList("alice")).RegisterRoutes(mux, jwtManager)
+
+	token := func(username string) string {
+		token, err := jwtManager.GenerateToken(username)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		return token
+	}
+
+	routes := []struct{ method, path string }{
+		{"POST", "/products"},
+		{"PUT", "/products/1/stock"},
+		{"PUT", "/products/1"},
+		{"PATCH", "/products/1"},
+		{"DELETE", "/products/1"},
+		{"POST", "/products/1/variants"},
+		{"PUT", "/products/1/variants/2"},
+		{"DELETE", "/products/1/variants/2"},
+		{"POST", "/products/1/images"},
+		{"DELETE", "/products/1/images/2"},
+	}
+	for _, route := range routes {
+		req := httptest.NewRequest(route.method, route.path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+token("bob"))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s by a non-admin: got status %d, want %d", route.method, route.path, rec.Code, http.StatusForbidden)
+		}
+	}
+	if len(service.deleted) != 0 {
+		t.Fatalf("a non-admin deleted products %v", service.deleted)
+	}
+
+	req := httptest.NewRequest("DELETE", "/products/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token("alice"))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || len(service.deleted) != 1 {
+		t.Fatalf("DELETE /products/1 by an admin: got status %d and deletions %v", rec.Code, service.deleted)
+	}
+}
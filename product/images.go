@@ -0,0 +1,127 @@
+package product
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the GIF decoder for image.Decode
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// Image upload limits
+const (
+	MaxImageSize         = 10 << 20   // bytes per uploaded file
+	maxImagePixels       = 40_000_000 // width * height, rejected before decoding to avoid decompression bombs
+	thumbnailMaxSide     = 320        // thumbnails fit in a square of this size
+	thumbnailJPEGQuality = 85
+)
+
+// imageExtensions lists the accepted image types, detected from the file content
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// processedImage is a validated upload with its thumbnail
+type processedImage struct {
+	ContentType          string
+	Extension            string
+	Width                int
+	Height               int
+	Thumbnail            []byte
+	ThumbnailContentType string
+	ThumbnailExtension   string
+}
+
+// processImage checks an upload's size, type and dimensions and renders its thumbnail
+// The type is detected from the content; the file name and the client's content type are not trusted
+func processImage(data []byte) (*processedImage, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("image file is required")
+	}
+	if len(data) > MaxImageSize {
+		return nil, fmt.Errorf("image must not be larger than %d MB", MaxImageSize>>20)
+	}
+	contentType := http.DetectContentType(data)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("image must be a JPEG, PNG or GIF file")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image must be a valid JPEG, PNG or GIF file")
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image must not have more than %d megapixels", maxImagePixels/1_000_000)
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image must be a valid JPEG, PNG or GIF file")
+	}
+
+	// JPEG thumbnails stay JPEG; PNG and GIF thumbnails become PNG to keep transparency
+	var thumbnail bytes.Buffer
+	processed := &processedImage{ContentType: contentType, Extension: extension, Width: config.Width, Height: config.Height}
+	scaled := scaleDown(source, thumbnailMaxSide)
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&thumbnail, scaled, &jpeg.Options{Quality: thumbnailJPEGQuality})
+		processed.ThumbnailContentType, processed.ThumbnailExtension = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&thumbnail, scaled)
+		processed.ThumbnailContentType, processed.ThumbnailExtension = "image/png", ".png"
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	processed.Thumbnail = thumbnail.Bytes()
+	return processed, nil
+}
+
+// scaleDown shrinks an image to fit in a maxSide square, averaging the source pixels behind each
+// thumbnail pixel; smaller images are only copied
+func scaleDown(source image.Image, maxSide int) *image.RGBA {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	targetWidth, targetHeight := width, height
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			targetWidth, targetHeight = maxSide, max(1, height*maxSide/width)
+		} else {
+			targetWidth, targetHeight = max(1, width*maxSide/height), maxSide
+		}
+	}
+
+	target := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < targetHeight; y++ {
+		y0, y1 := bounds.Min.Y+y*height/targetHeight, bounds.Min.Y+(y+1)*height/targetHeight
+		for x := 0; x < targetWidth; x++ {
+			x0, x1 := bounds.Min.X+x*width/targetWidth, bounds.Min.X+(x+1)*width/targetWidth
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := source.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa), n+1
+				}
+			}
+			// RGBA values are premultiplied 16-bit, as image.RGBA stores them in 8 bits
+			target.SetRGBA(x, y, color.RGBA{R: uint8((r / n) >> 8), G: uint8((g / n) >> 8), B: uint8((b / n) >> 8), A: uint8((a / n) >> 8)})
+		}
+	}
+	return target
+}
+
+// newImageToken returns a random name for stored files, so image URLs are never reused
+func newImageToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,252 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// Import and export formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson" // one JSON object per line
+)
+
+// csvColumns are the columns written by CSV exports; imports need name and price and ignore unknown columns
+// prices holds explicit prices as "EUR 459.00; GBP 399.00"
+var csvColumns = []string{"product_id", "name", "description", "price", "currency", "prices", "category", "category_id", "in_stock"}
+
+// ImportRowError is a problem with one row of an import
+// Row is the line number in the file, counting the CSV header as line 1
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult reports the outcome of a product import
+// Products are only imported when no row has errors
+type ImportResult struct {
+	Format   string           `json:"format"`
+	DryRun   bool             `json:"dry_run"`
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// importRow is a parsed row of an import; Err is set when the row could not be parsed
+type importRow struct {
+	Row     int
+	Request CreateProductRequest
+	Err     error
+}
+
+// readImportRows parses all rows of an import file
+// Rows that can't be parsed are returned with Err set, so every problem is reported at once
+func readImportRows(format string, r io.Reader) ([]importRow, error) {
+	switch format {
+	case FormatCSV:
+		return readCSVRows(r)
+	case FormatNDJSON:
+		return readNDJSONRows(r)
+	default:
+		return nil, fmt.Errorf("invalid format '%s': must be csv or ndjson", format)
+	}
+}
+
+// readCSVRows parses products from CSV with a header row
+func readCSVRows(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV column %q is required", name)
+		}
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, importRow{Row: parseErr.StartLine, Err: fmt.Errorf("invalid CSV: %v", parseErr.Err)})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		req, err := parseCSVProduct(field)
+		rows = append(rows, importRow{Row: line, Request: req, Err: err})
+	}
+	return rows, nil
+}
+
+// parseCSVProduct builds a create request from the fields of a CSV row
+func parseCSVProduct(field func(name string) string) (CreateProductRequest, error) {
+	req := CreateProductRequest{
+		Name:        field("name"),
+		Description: field("description"),
+		Category:    field("category"),
+		InStock:     true,
+	}
+	price, err := money.Parse(field("price"), field("currency"))
+	if err != nil {
+		return req, fmt.Errorf("invalid price: %w", err)
+	}
+	req.Price = price
+	if req.Prices, err = parseCSVPrices(field("prices")); err != nil {
+		return req, err
+	}
+	if value := field("category_id"); value != "" {
+		if req.CategoryID, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return req, fmt.Errorf("invalid category_id '%s'", value)
+		}
+	}
+	// An empty in_stock means in stock, so spreadsheets only need to mark sold-out products
+	if value := field("in_stock"); value != "" {
+		if req.InStock, err = strconv.ParseBool(value); err != nil {
+			return req, fmt.Errorf("invalid in_stock '%s'", value)
+		}
+	}
+	return req, nil
+}
+
+// parseCSVPrices parses explicit prices written as "EUR 459.00; GBP 399.00"
+func parseCSVPrices(value string) ([]money.Money, error) {
+	var prices []money.Money
+	for _, entry := range strings.Split(value, ";") {
+		parts := strings.Fields(entry)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid prices entry '%s': must be like 'EUR 459.00'", strings.TrimSpace(entry))
+		}
+		price, err := money.Parse(parts[1], parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid prices entry '%s': %w", strings.TrimSpace(entry), err)
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+// formatCSVPrices writes explicit prices in the format read by parseCSVPrices
+func formatCSVPrices(prices []money.Money) string {
+	entries := make([]string, len(prices))
+	for i, price := range prices {
+		entries[i] = price.Currency + " " + price.String()
+	}
+	return strings.Join(entries, "; ")
+}
+
+// readNDJSONRows parses one create request per non-empty line
+// Exported products can be imported again; fields such as product_id are ignored
+func readNDJSONRows(r io.Reader) ([]importRow, error) {
+	reader := bufio.NewReader(r)
+	var rows []importRow
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			var req CreateProductRequest
+			var rowErr error
+			if jsonErr := json.Unmarshal(trimmed, &req); jsonErr != nil {
+				rowErr = fmt.Errorf("invalid JSON: %v", jsonErr)
+			}
+			rows = append(rows, importRow{Row: line, Request: req, Err: rowErr})
+		}
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+	}
+}
+
+// productWriter writes exported products in one format
+type productWriter interface {
+	Write(product *Product) error
+	// Flush sends buffered output, so large exports stream to the client
+	Flush() error
+}
+
+// newProductWriter creates the writer for an export format
+func newProductWriter(format string, w io.Writer) (productWriter, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvColumns); err != nil {
+			return nil, err
+		}
+		return &csvProductWriter{writer: writer}, nil
+	case FormatNDJSON:
+		return &ndjsonProductWriter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("invalid format '%s': must be csv or ndjson", format)
+	}
+}
+
+// csvProductWriter writes one CSV row per product; variants and images are only exported as NDJSON
+type csvProductWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvProductWriter) Write(p *Product) error {
+	return w.writer.Write([]string{
+		strconv.FormatUint(p.ProductID, 10),
+		p.Name,
+		p.Description,
+		p.Price.String(),
+		p.Price.Currency,
+		formatCSVPrices(p.Prices),
+		p.Category,
+		strconv.FormatUint(p.CategoryID, 10),
+		strconv.FormatBool(p.InStock),
+	})
+}
+
+func (w *csvProductWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// ndjsonProductWriter writes one product JSON object per line, including variants and images
+type ndjsonProductWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonProductWriter) Write(p *Product) error {
+	return w.encoder.Encode(p)
+}
+
+func (w *ndjsonProductWriter) Flush() error {
+	return nil
+}
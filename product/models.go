@@ -2,34 +2,386 @@ package product
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
 )
 
 // Product represents a product in the database
+// Price is the base price; Prices holds explicit prices in other currencies
+// Archived products are hidden from listings and can't be ordered, but stay readable for existing orders and invoices
+// Category is the name of the category with CategoryID, kept for display, promotions and tax rules
 type Product struct {
-	ProductID   uint64  `json:"product_id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Category    string  `json:"category"`
-	InStock     bool    `json:"in_stock"`
-	CreatedAt   string  `json:"created_at"`
+	ProductID   uint64        `json:"product_id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Price       money.Money   `json:"price"`
+	Prices      []money.Money `json:"prices,omitempty"`
+	Category    string        `json:"category"`
+	CategoryID  uint64        `json:"category_id"`
+	InStock     bool          `json:"in_stock"`
+	Archived    bool          `json:"archived,omitempty"`
+	CreatedAt   string        `json:"created_at"`
+	Variants    []Variant     `json:"variants,omitempty"` // active variants; products with variants are ordered by variant
+	Images      []Image       `json:"images,omitempty"`   // ordered by position
+	Rating      *Rating       `json:"rating,omitempty"`   // only set on single product responses
+}
+
+// Rating is the aggregate of a product's approved reviews
+type Rating struct {
+	Average float64 `json:"average"` // rounded to one decimal, 0 without reviews
+	Count   int     `json:"count"`
+}
+
+// RatingSource provides the ratings shown on products; the review service implements it
+type RatingSource interface {
+	GetRating(ctx context.Context, productID uint64) (*Rating, error)
+}
+
+// StockWatcher is told when a product comes back in stock; the wishlist's back-in-stock alerts implement it
+// A product with variants comes back in stock when the first of its variants does
+// BackInStock must not block, as it is called while the stock update request is served
+type StockWatcher interface {
+	BackInStock(ctx context.Context, product *Product)
+}
+
+// Image is an uploaded product photo with a generated thumbnail
+// Files are kept in the blob store; URL and ThumbnailURL are filled in from their keys by the service
+type Image struct {
+	ImageID      uint64 `json:"image_id"`
+	ProductID    uint64 `json:"product_id"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Position     int    `json:"position"`
+	CreatedAt    string `json:"created_at"`
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+// PriceChange is an entry in a product's price history, recorded for every price change
+// Changes with an EffectiveFrom in the future are scheduled prices; Applied is set once the product holds the price
+type PriceChange struct {
+	PriceChangeID uint64        `json:"price_change_id"`
+	ProductID     uint64        `json:"product_id"`
+	Price         money.Money   `json:"price"`
+	Prices        []money.Money `json:"prices,omitempty"`
+	EffectiveFrom time.Time     `json:"effective_from"`
+	Applied       bool          `json:"applied"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// Variant is a purchasable version of a product, such as a colour or size, identified by its SKU
+// A variant without a price is sold at the product's prices
+type Variant struct {
+	VariantID  uint64            `json:"variant_id"`
+	ProductID  uint64            `json:"product_id"`
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes,omitempty"` // e.g. {"color": "Black", "size": "L"}
+	Price      *money.Money      `json:"price,omitempty"`
+	InStock    bool              `json:"in_stock"`
+	Archived   bool              `json:"archived,omitempty"`
+	CreatedAt  string            `json:"created_at"`
+}
+
+// Variant returns the active variant with the given ID
+func (p *Product) Variant(variantID uint64) (*Variant, bool) {
+	for i := range p.Variants {
+		if p.Variants[i].VariantID == variantID {
+			return &p.Variants[i], true
+		}
+	}
+	return nil, false
+}
+
+// Available reports whether the product can be ordered: it is in stock and, when it has variants,
+// at least one of them is in stock too
+func (p *Product) Available() bool {
+	if !p.InStock || len(p.Variants) == 0 {
+		return p.InStock
+	}
+	for _, v := range p.Variants {
+		if v.InStock {
+			return true
+		}
+	}
+	return false
+}
+
+// ForVariant returns the product as sold in a variant
+// A variant price replaces the base and explicit prices, and both the product and the variant must be in stock
+func (p Product) ForVariant(v Variant) Product {
+	if v.Price != nil {
+		p.Price = *v.Price
+		p.Prices = nil
+	}
+	p.InStock = p.InStock && v.InStock
+	return p
+}
+
+// PriceIn returns the price set for a currency, either the base price or an explicit price
+// ok is false when the product has no price in that currency and it must be converted
+func (p *Product) PriceIn(currency string) (price money.Money, ok bool) {
+	currency = strings.ToUpper(currency)
+	if p.Price.Currency == currency {
+		return p.Price, true
+	}
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	return money.Money{}, false
+}
+
+// encodeAttributes serializes variant attributes for storage in a text column
+func encodeAttributes(attributes map[string]string) (string, error) {
+	if len(attributes) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeAttributes parses variant attributes stored by encodeAttributes
+func decodeAttributes(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(data), &attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// variantPriceColumns returns the price and currency columns of a variant; a variant without a price stores NULL
+func variantPriceColumns(v *Variant) (interface{}, string) {
+	if v.Price == nil {
+		return nil, ""
+	}
+	return *v.Price, v.Price.Currency
+}
+
+// moneyPtr returns a pointer to an amount, for optional prices in literals
+func moneyPtr(m money.Money) *money.Money {
+	return &m
+}
+
+// decodeVariantPrice restores an optional variant price scanned as text
+func decodeVariantPrice(amount, currency string) (*money.Money, error) {
+	if amount == "" {
+		return nil, nil
+	}
+	price, err := money.Parse(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// encodePrices serializes explicit prices for storage in a text column
+func encodePrices(prices []money.Money) (string, error) {
+	if len(prices) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(prices)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodePrices parses explicit prices stored by encodePrices
+func decodePrices(data string) ([]money.Money, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var prices []money.Money
+	if err := json.Unmarshal([]byte(data), &prices); err != nil {
+		return nil, err
+	}
+	return prices, nil
 }
 
 // Repository defines the interface for product data operations
 type ProductRepository interface {
 	Create(ctx context.Context, product *Product) error
+	// CreateBatch creates products and their variants in one batch, setting their IDs
+	CreateBatch(ctx context.Context, products []*Product) error
 	GetAll(ctx context.Context) ([]Product, error)
 	GetByID(ctx context.Context, productID uint64) (*Product, error)
-	GetByCategory(ctx context.Context, category string) ([]Product, error)
+	// GetPage returns up to limit active products with IDs above afterID, ordered by ID
+	GetPage(ctx context.Context, afterID uint64, limit int) ([]Product, error)
+	// GetByCategoryIDs returns the active products in any of the given categories
+	GetByCategoryIDs(ctx context.Context, categoryIDs []uint64) ([]Product, error)
 	UpdateStock(ctx context.Context, productID uint64, inStock bool) error
+	// Search returns one page of matching products and the facet counts of all matches
+	Search(ctx context.Context, query SearchQuery) ([]Product, []FacetRow, error)
+	// Update replaces the editable fields of a product
+	Update(ctx context.Context, product *Product) error
+	// Archive soft deletes a product
+	Archive(ctx context.Context, productID uint64) error
+	CreateVariant(ctx context.Context, variant *Variant) error
+	UpdateVariant(ctx context.Context, variant *Variant) error
+	ArchiveVariant(ctx context.Context, variantID uint64) error
+	// GetVariant returns a variant by ID, including archived variants that old orders still reference
+	GetVariant(ctx context.Context, variantID uint64) (*Variant, error)
+	GetVariantBySKU(ctx context.Context, sku string) (*Variant, error)
+	CreateImage(ctx context.Context, image *Image) error
+	DeleteImage(ctx context.Context, imageID uint64) error
+	CreatePriceChanges(ctx context.Context, changes []*PriceChange) error
+	// GetPriceChanges returns the price history of a product, ordered by effective time
+	GetPriceChanges(ctx context.Context, productID uint64) ([]PriceChange, error)
+	// GetDuePriceChanges returns the changes that took effect by at but are not applied yet
+	GetDuePriceChanges(ctx context.Context, at time.Time) ([]PriceChange, error)
+	// ApplyPriceChange sets the product's prices to the change and marks the product's changes effective by at as applied
+	ApplyPriceChange(ctx context.Context, change *PriceChange, at time.Time) error
+	DeletePriceChange(ctx context.Context, priceChangeID uint64) error
+	// CountByCategory and RenameCategory let the category tree check and update its products
+	CountByCategory(ctx context.Context, categoryID uint64) (int, error)
+	RenameCategory(ctx context.Context, categoryID uint64, name string) error
+	// GetUncategorized returns the distinct free-text categories of products without a category ID
+	GetUncategorized(ctx context.Context) ([]string, error)
+	// AssignCategory moves the products of a free-text category into a category of the tree
+	AssignCategory(ctx context.Context, category string, categoryID uint64, name string) error
 	SeedSampleProducts(ctx context.Context) error
 }
 
+// SchedulePriceRequest represents the request to change a product's prices at a future time
+type SchedulePriceRequest struct {
+	Price         money.Money   `json:"price"`
+	Prices        []money.Money `json:"prices"`
+	EffectiveFrom time.Time     `json:"effective_from"`
+}
+
 // CreateProductRequest represents the request to create a product
+// Price accepts {"amount":"1299.99","currency":"USD"} or a plain number in the default currency
+// Prices optionally sets explicit prices in other currencies; other currencies are converted at the FX rate
+// The category is given by CategoryID, or by the slug or name of an existing category in Category
 type CreateProductRequest struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Category    string  `json:"category"`
-	InStock     bool    `json:"in_stock"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Price       money.Money      `json:"price"`
+	Prices      []money.Money    `json:"prices"`
+	Category    string           `json:"category"`
+	CategoryID  uint64           `json:"category_id"`
+	InStock     bool             `json:"in_stock"`
+	Variants    []VariantRequest `json:"variants"` // created with the product; ignored by updates
+}
+
+// VariantRequest represents the request to create or update a product variant
+type VariantRequest struct {
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *money.Money      `json:"price"`    // optional, defaults to the product's prices
+	InStock    *bool             `json:"in_stock"` // defaults to true
+}
+
+// PatchProductRequest represents a partial product update; omitted fields are left unchanged
+type PatchProductRequest struct {
+	Name        *string        `json:"name"`
+	Description *string        `json:"description"`
+	Price       *money.Money   `json:"price"`
+	Prices      *[]money.Money `json:"prices"`
+	Category    *string        `json:"category"`
+	CategoryID  *uint64        `json:"category_id"`
+	InStock     *bool          `json:"in_stock"`
+}
+
+// Sort orders for product search
+const (
+	SortRelevance = "relevance" // best text match first, the default when searching for text
+	SortName      = "name"      // the default otherwise
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+)
+
+// SearchQuery holds the filters of a product search
+// MinPrice and MaxPrice compare base prices and only match products priced in their currency
+// CategoryIDs holds the selected category and its descendants
+type SearchQuery struct {
+	Text        string
+	CategoryIDs []uint64
+	MinPrice    *money.Money
+	MaxPrice    *money.Money
+	InStock     *bool
+	Sort        string
+	Offset      int
+	Limit       int
+}
+
+// priceCurrency returns the currency of the price filters, empty when there are none
+func (q SearchQuery) priceCurrency() string {
+	if q.MinPrice != nil {
+		return q.MinPrice.Currency
+	}
+	if q.MaxPrice != nil {
+		return q.MaxPrice.Currency
+	}
+	return ""
+}
+
+// FacetRow counts the matching products of one category and stock status
+// Repositories count without the category and in-stock filters so facets show the alternatives
+type FacetRow struct {
+	CategoryID uint64
+	Category   string
+	InStock    bool
+	Count      int
+}
+
+// FacetCount is the number of matching products with a facet value
+type FacetCount struct {
+	Value      string `json:"value"`
+	CategoryID uint64 `json:"category_id,omitempty"`
+	Count      int    `json:"count"`
+}
+
+// Facets summarizes the matching products
+// Each facet ignores its own filter, so the category counts show what selecting another category would return
+type Facets struct {
+	Categories []FacetCount `json:"categories"`
+	InStock    int          `json:"in_stock"`
+	OutOfStock int          `json:"out_of_stock"`
+}
+
+// SearchResult is one page of product search results
+type SearchResult struct {
+	Products   []Product `json:"products"`
+	Total      int       `json:"total"`
+	Facets     Facets    `json:"facets"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// SearchRequest represents the query parameters of GET /products/search
+// MinPrice and MaxPrice are decimal amounts in Currency, which defaults to the default currency
+// Category is a category slug; products in its sub-categories match too
+type SearchRequest struct {
+	Query    string
+	Category string
+	MinPrice string
+	MaxPrice string
+	Currency string
+	InStock  *bool
+	Sort     string
+	Cursor   string
+	Limit    int
+}
+
+// searchTokens splits search text into lower-case words, dropping punctuation
+func searchTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
 }
\ No newline at end of file
@@ -4,17 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+	"github.com/rajindersingh041/go-auth-sessions/money"
 )
 
 // ClickHouseRepository implements Repository for ClickHouse database
 type ClickHouseRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	ids idgen.Generator
 }
 
 // NewClickHouseRepository creates a new ClickHouse product repository
-func NewClickHouseRepository(db *sql.DB) ProductRepository {
-	return &ClickHouseRepository{db: db}
+// ClickHouse has no auto-increment, so product and variant IDs come from the shared ID generator
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) ProductRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
 }
 
 // ensureProductsTable creates the products table if it doesn't exist
@@ -24,14 +30,75 @@ func (r *ClickHouseRepository) ensureProductsTable(ctx context.Context) error {
 			product_id UInt64 DEFAULT toUInt64(rand()),
 			name String,
 			description String,
-			price Float64,
+			price Decimal(18, 2),
+			currency String DEFAULT 'USD',
+			prices String DEFAULT '',
 			category String,
+			category_id UInt64 DEFAULT 0,
 			in_stock Bool,
+			archived Bool DEFAULT false,
 			created_at String
 		) ENGINE = MergeTree() 
 		ORDER BY product_id
 	`
-	_, err := r.db.ExecContext(ctx, query)
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Variants are archived rather than deleted so order items keep them
+	variantsQuery := `
+		CREATE TABLE IF NOT EXISTS product_variants (
+			variant_id UInt64,
+			product_id UInt64,
+			sku String,
+			attributes String,
+			price Nullable(Decimal(18, 2)),
+			currency String,
+			in_stock Bool,
+			archived Bool DEFAULT false,
+			created_at String
+		) ENGINE = MergeTree()
+		ORDER BY (product_id, variant_id)
+	`
+	if _, err := r.db.ExecContext(ctx, variantsQuery); err != nil {
+		return err
+	}
+
+	// Image files are in the blob store; this table holds their keys and metadata
+	imagesQuery := `
+		CREATE TABLE IF NOT EXISTS product_images (
+			image_id UInt64,
+			product_id UInt64,
+			key String,
+			thumbnail_key String,
+			content_type String,
+			size Int64,
+			width Int32,
+			height Int32,
+			position Int32,
+			created_at String
+		) ENGINE = MergeTree()
+		ORDER BY (product_id, image_id)
+	`
+	if _, err := r.db.ExecContext(ctx, imagesQuery); err != nil {
+		return err
+	}
+
+	// The price history, including scheduled prices
+	priceChangesQuery := `
+		CREATE TABLE IF NOT EXISTS product_price_changes (
+			price_change_id UInt64,
+			product_id UInt64,
+			price Decimal(18, 2),
+			currency String,
+			prices String DEFAULT '',
+			effective_from DateTime64(3),
+			applied Bool DEFAULT false,
+			created_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY (product_id, effective_from, price_change_id)
+	`
+	_, err := r.db.ExecContext(ctx, priceChangesQuery)
 	return err
 }
 
@@ -39,16 +106,90 @@ func (r *ClickHouseRepository) Create(ctx context.Context, product *Product) err
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return err
 	}
-	query := "INSERT INTO products (name, description, price, category, in_stock, created_at) VALUES (?, ?, ?, ?, ?, ?)"
-	_, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Category, product.InStock, product.CreatedAt)
+	prices, err := encodePrices(product.Prices)
+	if err != nil {
+		return err
+	}
+	product.ProductID = r.ids.NextID()
+	query := "INSERT INTO products (product_id, name, description, price, currency, prices, category, category_id, in_stock, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query, product.ProductID, product.Name, product.Description, product.Price, product.Price.Currency, prices, product.Category, product.CategoryID, product.InStock, product.CreatedAt)
 	return err
 }
 
+// CreateBatch sends all products, and then all their variants, as one native ClickHouse batch each
+func (r *ClickHouseRepository) CreateBatch(ctx context.Context, products []*Product) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO products (product_id, name, description, price, currency, prices, category, category_id, in_stock, created_at)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var variants []*Variant
+	for _, product := range products {
+		prices, err := encodePrices(product.Prices)
+		if err != nil {
+			return err
+		}
+		product.ProductID = r.ids.NextID()
+		if _, err := stmt.ExecContext(ctx, product.ProductID, product.Name, product.Description, product.Price, product.Price.Currency, prices, product.Category, product.CategoryID, product.InStock, product.CreatedAt); err != nil {
+			return err
+		}
+		for i := range product.Variants {
+			product.Variants[i].ProductID = product.ProductID
+			variants = append(variants, &product.Variants[i])
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+	return r.createVariantBatch(ctx, variants)
+}
+
+// createVariantBatch inserts variants as one native batch
+func (r *ClickHouseRepository) createVariantBatch(ctx context.Context, variants []*Variant) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO product_variants (variant_id, product_id, sku, attributes, price, currency, in_stock, created_at)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, variant := range variants {
+		attributes, err := encodeAttributes(variant.Attributes)
+		if err != nil {
+			return err
+		}
+		variant.VariantID = r.ids.NextID()
+		price, currency := variantPriceColumns(variant)
+		if _, err := stmt.ExecContext(ctx, variant.VariantID, variant.ProductID, variant.SKU, attributes, price, currency, variant.InStock, variant.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (r *ClickHouseRepository) GetAll(ctx context.Context) ([]Product, error) {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return nil, err
 	}
-	query := "SELECT product_id, name, description, price, category, in_stock, created_at FROM products ORDER BY name"
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE NOT archived ORDER BY name"
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -58,11 +199,19 @@ func (r *ClickHouseRepository) GetAll(ctx context.Context) ([]Product, error) {
 	var products []Product
 	for rows.Next() {
 		var p Product
-		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &p.Category, &p.InStock, &p.CreatedAt); err != nil {
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
 	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
 	return products, nil
 }
 
@@ -71,9 +220,10 @@ func (r *ClickHouseRepository) GetByID(ctx context.Context, productID uint64) (*
 		return nil, err
 	}
 	var product Product
-	query := "SELECT product_id, name, description, price, category, in_stock, created_at FROM products WHERE product_id = ? LIMIT 1"
+	var currency, prices string
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE product_id = ? LIMIT 1"
 	err := r.db.QueryRowContext(ctx, query, productID).Scan(
-		&product.ProductID, &product.Name, &product.Description, &product.Price, &product.Category, &product.InStock, &product.CreatedAt,
+		&product.ProductID, &product.Name, &product.Description, &product.Price, &currency, &prices, &product.Category, &product.CategoryID, &product.InStock, &product.Archived, &product.CreatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -81,15 +231,23 @@ func (r *ClickHouseRepository) GetByID(ctx context.Context, productID uint64) (*
 		}
 		return nil, err
 	}
-	return &product, nil
+	money.WithCurrency(currency, &product.Price)
+	if product.Prices, err = decodePrices(prices); err != nil {
+		return nil, err
+	}
+	products := []Product{product}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return &products[0], nil
 }
 
-func (r *ClickHouseRepository) GetByCategory(ctx context.Context, category string) ([]Product, error) {
+func (r *ClickHouseRepository) GetPage(ctx context.Context, afterID uint64, limit int) ([]Product, error) {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return nil, err
 	}
-	query := "SELECT product_id, name, description, price, category, in_stock, created_at FROM products WHERE category = ? ORDER BY name"
-	rows, err := r.db.QueryContext(ctx, query, category)
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE NOT archived AND product_id > ? ORDER BY product_id LIMIT ?"
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -98,14 +256,170 @@ func (r *ClickHouseRepository) GetByCategory(ctx context.Context, category strin
 	var products []Product
 	for rows.Next() {
 		var p Product
-		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &p.Category, &p.InStock, &p.CreatedAt); err != nil {
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
 	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
 	return products, nil
 }
 
+func (r *ClickHouseRepository) GetByCategoryIDs(ctx context.Context, categoryIDs []uint64) ([]Product, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE has(?, category_id) AND NOT archived ORDER BY name"
+	rows, err := r.db.QueryContext(ctx, query, categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// clickHouseSearchDocument is the text matched by product search
+const clickHouseSearchDocument = "concat(name, ' ', category, ' ', description)"
+
+// clickHouseMinSimilarity is the ngram similarity above which a product matches despite typos or partial words
+const clickHouseMinSimilarity = 0.8
+
+func (r *ClickHouseRepository) Search(ctx context.Context, query SearchQuery) ([]Product, []FacetRow, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	// Conditions shared by the results and the facets
+	// A product matches when it contains every word, or is close enough by ngram similarity
+	conditions := []string{"NOT archived"}
+	var args []interface{}
+	if query.Text != "" {
+		conditions = append(conditions, fmt.Sprintf(
+			"(hasAll(splitByNonAlpha(lower(%[1]s)), ?) OR ngramSearchCaseInsensitiveUTF8(%[1]s, ?) >= %[2]g)",
+			clickHouseSearchDocument, clickHouseMinSimilarity,
+		))
+		args = append(args, searchTokens(query.Text), query.Text)
+	}
+	if currency := query.priceCurrency(); currency != "" {
+		conditions = append(conditions, "currency = ?")
+		args = append(args, currency)
+	}
+	if query.MinPrice != nil {
+		conditions = append(conditions, "price >= toDecimal64(?, 2)")
+		args = append(args, query.MinPrice.String())
+	}
+	if query.MaxPrice != nil {
+		conditions = append(conditions, "price <= toDecimal64(?, 2)")
+		args = append(args, query.MaxPrice.String())
+	}
+
+	// Facets ignore the category and in-stock filters
+	facetQuery := "SELECT category_id, category, in_stock, count() FROM products WHERE " + strings.Join(conditions, " AND ") + " GROUP BY category_id, category, in_stock"
+	facets, err := r.queryFacets(ctx, facetQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(query.CategoryIDs) > 0 {
+		conditions = append(conditions, "has(?, category_id)")
+		args = append(args, query.CategoryIDs)
+	}
+	if query.InStock != nil {
+		conditions = append(conditions, "in_stock = ?")
+		args = append(args, *query.InStock)
+	}
+
+	orderBy := "name, product_id"
+	switch query.Sort {
+	case SortRelevance:
+		if query.Text != "" {
+			// Matches in the name count double
+			orderBy = fmt.Sprintf("ngramSearchCaseInsensitiveUTF8(name, ?) * 2 + ngramSearchCaseInsensitiveUTF8(%s, ?) DESC, product_id", clickHouseSearchDocument)
+			args = append(args, query.Text, query.Text)
+		}
+	case SortPriceAsc:
+		orderBy = "price, product_id"
+	case SortPriceDesc:
+		orderBy = "price DESC, product_id"
+	case SortNewest:
+		orderBy = "created_at DESC, product_id DESC"
+	}
+
+	sqlQuery := fmt.Sprintf(
+		"SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE %s ORDER BY %s LIMIT ? OFFSET ?",
+		strings.Join(conditions, " AND "), orderBy,
+	)
+	args = append(args, query.Limit, query.Offset)
+	rows, err := r.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &p.CreatedAt); err != nil {
+			return nil, nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
+			return nil, nil, err
+		}
+		products = append(products, p)
+	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, nil, err
+	}
+	return products, facets, nil
+}
+
+// queryFacets runs a query returning category_id, category, in_stock and count rows
+func (r *ClickHouseRepository) queryFacets(ctx context.Context, query string, args ...interface{}) ([]FacetRow, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facets []FacetRow
+	for rows.Next() {
+		var facet FacetRow
+		var count uint64
+		if err := rows.Scan(&facet.CategoryID, &facet.Category, &facet.InStock, &count); err != nil {
+			return nil, err
+		}
+		facet.Count = int(count)
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
 func (r *ClickHouseRepository) UpdateStock(ctx context.Context, productID uint64, inStock bool) error {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return err
@@ -115,6 +429,351 @@ func (r *ClickHouseRepository) UpdateStock(ctx context.Context, productID uint64
 	return err
 }
 
+func (r *ClickHouseRepository) Update(ctx context.Context, product *Product) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	prices, err := encodePrices(product.Prices)
+	if err != nil {
+		return err
+	}
+	query := `
+		ALTER TABLE products UPDATE name = ?, description = ?, price = toDecimal64(?, 2), currency = ?, prices = ?, category = ?, category_id = ?, in_stock = ?
+		WHERE product_id = ?`
+	_, err = r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price.String(), product.Price.Currency, prices, product.Category, product.CategoryID, product.InStock, product.ProductID)
+	return err
+}
+
+func (r *ClickHouseRepository) Archive(ctx context.Context, productID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE products UPDATE archived = true WHERE product_id = ?", productID)
+	return err
+}
+
+func (r *ClickHouseRepository) CountByCategory(ctx context.Context, categoryID uint64) (int, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return 0, err
+	}
+	var count uint64
+	err := r.db.QueryRowContext(ctx, "SELECT count() FROM products WHERE category_id = ? AND NOT archived", categoryID).Scan(&count)
+	return int(count), err
+}
+
+func (r *ClickHouseRepository) RenameCategory(ctx context.Context, categoryID uint64, name string) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE products UPDATE category = ? WHERE category_id = ?", name, categoryID)
+	return err
+}
+
+func (r *ClickHouseRepository) GetUncategorized(ctx context.Context) ([]string, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT DISTINCT category FROM products WHERE category_id = 0 AND category != '' ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (r *ClickHouseRepository) AssignCategory(ctx context.Context, category string, categoryID uint64, name string) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	query := "ALTER TABLE products UPDATE category_id = ?, category = ? WHERE category = ? AND category_id = 0"
+	_, err := r.db.ExecContext(ctx, query, categoryID, name, category)
+	return err
+}
+
+// clickHouseVariantColumns lists the variant columns in scan order
+const clickHouseVariantColumns = "variant_id, product_id, sku, attributes, ifNull(toString(price), ''), currency, in_stock, archived, created_at"
+
+func (r *ClickHouseRepository) CreateVariant(ctx context.Context, variant *Variant) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	attributes, err := encodeAttributes(variant.Attributes)
+	if err != nil {
+		return err
+	}
+	variant.VariantID = r.ids.NextID()
+	price, currency := variantPriceColumns(variant)
+	query := "INSERT INTO product_variants (variant_id, product_id, sku, attributes, price, currency, in_stock, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query, variant.VariantID, variant.ProductID, variant.SKU, attributes, price, currency, variant.InStock, variant.CreatedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) UpdateVariant(ctx context.Context, variant *Variant) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	attributes, err := encodeAttributes(variant.Attributes)
+	if err != nil {
+		return err
+	}
+	// An empty price string clears the price
+	price := ""
+	if variant.Price != nil {
+		price = variant.Price.String()
+	}
+	_, currency := variantPriceColumns(variant)
+	query := `
+		ALTER TABLE product_variants UPDATE sku = ?, attributes = ?, price = toDecimal64OrNull(?, 2), currency = ?, in_stock = ?
+		WHERE variant_id = ?`
+	_, err = r.db.ExecContext(ctx, query, variant.SKU, attributes, price, currency, variant.InStock, variant.VariantID)
+	return err
+}
+
+func (r *ClickHouseRepository) ArchiveVariant(ctx context.Context, variantID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE product_variants UPDATE archived = true WHERE variant_id = ?", variantID)
+	return err
+}
+
+func (r *ClickHouseRepository) GetVariant(ctx context.Context, variantID uint64) (*Variant, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHouseVariantColumns + " FROM product_variants WHERE variant_id = ? LIMIT 1"
+	return r.scanVariant(r.db.QueryRowContext(ctx, query, variantID))
+}
+
+func (r *ClickHouseRepository) GetVariantBySKU(ctx context.Context, sku string) (*Variant, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHouseVariantColumns + " FROM product_variants WHERE sku = ? LIMIT 1"
+	return r.scanVariant(r.db.QueryRowContext(ctx, query, sku))
+}
+
+func (r *ClickHouseRepository) CreateImage(ctx context.Context, image *Image) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	image.ImageID = r.ids.NextID()
+	query := `
+		INSERT INTO product_images (image_id, product_id, key, thumbnail_key, content_type, size, width, height, position, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, image.ImageID, image.ProductID, image.Key, image.ThumbnailKey, image.ContentType, image.Size,
+		int32(image.Width), int32(image.Height), int32(image.Position), image.CreatedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) DeleteImage(ctx context.Context, imageID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE product_images DELETE WHERE image_id = ?", imageID)
+	return err
+}
+
+// clickHousePriceChangeColumns lists the price change columns in scan order
+const clickHousePriceChangeColumns = "price_change_id, product_id, price, currency, prices, effective_from, applied, created_at"
+
+func (r *ClickHouseRepository) CreatePriceChanges(ctx context.Context, changes []*PriceChange) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO product_price_changes ("+clickHousePriceChangeColumns+")")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, change := range changes {
+		prices, err := encodePrices(change.Prices)
+		if err != nil {
+			return err
+		}
+		change.PriceChangeID = r.ids.NextID()
+		if _, err := stmt.ExecContext(ctx, change.PriceChangeID, change.ProductID, change.Price, change.Price.Currency, prices, change.EffectiveFrom, change.Applied, change.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *ClickHouseRepository) GetPriceChanges(ctx context.Context, productID uint64) ([]PriceChange, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHousePriceChangeColumns + " FROM product_price_changes WHERE product_id = ? ORDER BY effective_from, price_change_id"
+	return r.queryPriceChanges(ctx, query, productID)
+}
+
+func (r *ClickHouseRepository) GetDuePriceChanges(ctx context.Context, at time.Time) ([]PriceChange, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHousePriceChangeColumns + " FROM product_price_changes WHERE NOT applied AND effective_from <= ? ORDER BY effective_from, price_change_id"
+	return r.queryPriceChanges(ctx, query, at)
+}
+
+// queryPriceChanges runs a query selecting clickHousePriceChangeColumns
+func (r *ClickHouseRepository) queryPriceChanges(ctx context.Context, query string, args ...interface{}) ([]PriceChange, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PriceChange
+	for rows.Next() {
+		var change PriceChange
+		var currency, prices string
+		if err := rows.Scan(&change.PriceChangeID, &change.ProductID, &change.Price, &currency, &prices, &change.EffectiveFrom, &change.Applied, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &change.Price)
+		if change.Prices, err = decodePrices(prices); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *ClickHouseRepository) ApplyPriceChange(ctx context.Context, change *PriceChange, at time.Time) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	prices, err := encodePrices(change.Prices)
+	if err != nil {
+		return err
+	}
+	query := "ALTER TABLE products UPDATE price = toDecimal64(?, 2), currency = ?, prices = ? WHERE product_id = ?"
+	if _, err := r.db.ExecContext(ctx, query, change.Price.String(), change.Price.Currency, prices, change.ProductID); err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, "ALTER TABLE product_price_changes UPDATE applied = true WHERE product_id = ? AND effective_from <= ?", change.ProductID, at)
+	return err
+}
+
+func (r *ClickHouseRepository) DeletePriceChange(ctx context.Context, priceChangeID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE product_price_changes DELETE WHERE price_change_id = ?", priceChangeID)
+	return err
+}
+
+// attachDetails loads the variants and images of the given products
+func (r *ClickHouseRepository) attachDetails(ctx context.Context, products []Product) error {
+	if err := r.attachVariants(ctx, products); err != nil {
+		return err
+	}
+	return r.attachImages(ctx, products)
+}
+
+// attachImages loads the images of the given products
+func (r *ClickHouseRepository) attachImages(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]uint64, len(products))
+	index := make(map[uint64]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ProductID
+		index[p.ProductID] = i
+	}
+
+	query := `
+		SELECT image_id, product_id, key, thumbnail_key, content_type, size, width, height, position, created_at
+		FROM product_images WHERE has(?, product_id) ORDER BY position, image_id`
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var image Image
+		var width, height, position int32
+		if err := rows.Scan(&image.ImageID, &image.ProductID, &image.Key, &image.ThumbnailKey, &image.ContentType, &image.Size,
+			&width, &height, &position, &image.CreatedAt); err != nil {
+			return err
+		}
+		image.Width, image.Height, image.Position = int(width), int(height), int(position)
+		if i, ok := index[image.ProductID]; ok {
+			products[i].Images = append(products[i].Images, image)
+		}
+	}
+	return rows.Err()
+}
+
+// attachVariants loads the active variants of the given products
+func (r *ClickHouseRepository) attachVariants(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]uint64, len(products))
+	index := make(map[uint64]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ProductID
+		index[p.ProductID] = i
+	}
+
+	query := "SELECT " + clickHouseVariantColumns + " FROM product_variants WHERE has(?, product_id) AND NOT archived ORDER BY variant_id"
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := r.scanVariant(rows)
+		if err != nil {
+			return err
+		}
+		if i, ok := index[variant.ProductID]; ok {
+			products[i].Variants = append(products[i].Variants, *variant)
+		}
+	}
+	return nil
+}
+
+// scanVariant scans a variant from a *sql.Row or *sql.Rows
+func (r *ClickHouseRepository) scanVariant(row interface{ Scan(...interface{}) error }) (*Variant, error) {
+	var v Variant
+	var attributes, price, currency string
+	err := row.Scan(&v.VariantID, &v.ProductID, &v.SKU, &attributes, &price, &currency, &v.InStock, &v.Archived, &v.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("variant not found")
+		}
+		return nil, err
+	}
+	if v.Attributes, err = decodeAttributes(attributes); err != nil {
+		return nil, err
+	}
+	if v.Price, err = decodeVariantPrice(price, currency); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
 func (r *ClickHouseRepository) SeedSampleProducts(ctx context.Context) error {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return err
@@ -132,20 +791,35 @@ func (r *ClickHouseRepository) SeedSampleProducts(ctx context.Context) error {
 
 	// Sample products data
 	sampleProducts := []Product{
-		{Name: "MacBook Pro 16\"", Description: "High-performance laptop for professionals", Price: 2499.99, Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "iPhone 15 Pro", Description: "Latest smartphone with advanced features", Price: 999.99, Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Wireless Headphones", Description: "Premium noise-cancelling headphones", Price: 299.99, Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Coffee Maker", Description: "Automatic drip coffee maker", Price: 89.99, Category: "Appliances", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Office Chair", Description: "Ergonomic office chair with lumbar support", Price: 199.99, Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Bluetooth Speaker", Description: "Portable wireless speaker", Price: 49.99, Category: "Electronics", InStock: false, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Desk Lamp", Description: "LED desk lamp with adjustable brightness", Price: 39.99, Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Water Bottle", Description: "Insulated stainless steel water bottle", Price: 24.99, Category: "Accessories", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "MacBook Pro 16\"", Description: "High-performance laptop for professionals", Price: money.MustParse("2499.99", "USD"), Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "iPhone 15 Pro", Description: "Latest smartphone with advanced features", Price: money.MustParse("999.99", "USD"), Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Wireless Headphones", Description: "Premium noise-cancelling headphones", Price: money.MustParse("299.99", "USD"), Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339), Variants: []Variant{
+			{SKU: "WH-BLK", Attributes: map[string]string{"color": "Black"}, InStock: true},
+			{SKU: "WH-WHT", Attributes: map[string]string{"color": "White"}, InStock: true},
+			{SKU: "WH-SLV", Attributes: map[string]string{"color": "Silver"}, Price: moneyPtr(money.MustParse("319.99", "USD")), InStock: false},
+		}},
+		{Name: "Coffee Maker", Description: "Automatic drip coffee maker", Price: money.MustParse("89.99", "USD"), Category: "Appliances", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Office Chair", Description: "Ergonomic office chair with lumbar support", Price: money.MustParse("199.99", "USD"), Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Bluetooth Speaker", Description: "Portable wireless speaker", Price: money.MustParse("49.99", "USD"), Category: "Electronics", InStock: false, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Desk Lamp", Description: "LED desk lamp with adjustable brightness", Price: money.MustParse("39.99", "USD"), Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Water Bottle", Description: "Insulated stainless steel water bottle", Price: money.MustParse("24.99", "USD"), Category: "Accessories", InStock: true, CreatedAt: time.Now().Format(time.RFC3339), Variants: []Variant{
+			{SKU: "WB-500", Attributes: map[string]string{"size": "500 ml", "color": "Steel"}, InStock: true},
+			{SKU: "WB-750", Attributes: map[string]string{"size": "750 ml", "color": "Steel"}, Price: moneyPtr(money.MustParse("29.99", "USD")), InStock: true},
+			{SKU: "WB-1000", Attributes: map[string]string{"size": "1 l", "color": "Black"}, Price: moneyPtr(money.MustParse("34.99", "USD")), InStock: true},
+		}},
 	}
 
 	for _, product := range sampleProducts {
 		if err := r.Create(ctx, &product); err != nil {
 			return fmt.Errorf("failed to seed product %s: %w", product.Name, err)
 		}
+		for _, variant := range product.Variants {
+			variant.ProductID = product.ProductID
+			variant.CreatedAt = product.CreatedAt
+			if err := r.CreateVariant(ctx, &variant); err != nil {
+				return fmt.Errorf("failed to seed variant %s: %w", variant.SKU, err)
+			}
+		}
 	}
 
 	return nil
@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+	"github.com/rajindersingh041/go-auth-sessions/money"
 )
 
 // PostgresRepository implements Repository for PostgreSQL database
@@ -25,28 +29,161 @@ func (r *PostgresRepository) ensureProductsTable(ctx context.Context) error {
 			name TEXT NOT NULL,
 			description TEXT,
 			price DECIMAL(10,2) NOT NULL,
+			currency TEXT NOT NULL DEFAULT 'USD',
+			prices TEXT NOT NULL DEFAULT '',
 			category TEXT NOT NULL,
+			category_id BIGINT NOT NULL DEFAULT 0,
 			in_stock BOOLEAN DEFAULT true,
+			archived BOOLEAN NOT NULL DEFAULT false,
 			created_at TIMESTAMP DEFAULT NOW()
 		)`
-	_, err := r.db.ExecContext(ctx, query)
-	return err
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+
+	// Create the product_variants table; variants are archived rather than deleted so order items keep them
+	variantsQuery := `
+		CREATE TABLE IF NOT EXISTS product_variants (
+			variant_id SERIAL PRIMARY KEY,
+			product_id BIGINT NOT NULL REFERENCES products(product_id),
+			sku TEXT NOT NULL UNIQUE,
+			attributes JSONB,
+			price DECIMAL(10,2),
+			currency TEXT NOT NULL DEFAULT '',
+			in_stock BOOLEAN NOT NULL DEFAULT true,
+			archived BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`
+	if _, err := r.db.ExecContext(ctx, variantsQuery); err != nil {
+		return err
+	}
+
+	// Create the product_images table; the files themselves are in the blob store
+	imagesQuery := `
+		CREATE TABLE IF NOT EXISTS product_images (
+			image_id SERIAL PRIMARY KEY,
+			product_id BIGINT NOT NULL REFERENCES products(product_id),
+			key TEXT NOT NULL,
+			thumbnail_key TEXT NOT NULL,
+			content_type TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			width INT NOT NULL,
+			height INT NOT NULL,
+			position INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`
+	if _, err := r.db.ExecContext(ctx, imagesQuery); err != nil {
+		return err
+	}
+
+	// Create the product_price_changes table, the price history including scheduled prices
+	priceChangesQuery := `
+		CREATE TABLE IF NOT EXISTS product_price_changes (
+			price_change_id SERIAL PRIMARY KEY,
+			product_id BIGINT NOT NULL REFERENCES products(product_id),
+			price DECIMAL(10,2) NOT NULL,
+			currency TEXT NOT NULL,
+			prices TEXT NOT NULL DEFAULT '',
+			effective_from TIMESTAMPTZ NOT NULL,
+			applied BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	if _, err := r.db.ExecContext(ctx, priceChangesQuery); err != nil {
+		return err
+	}
+
+	// Add columns introduced after the table was first created
+	migrations := []string{
+		"ALTER TABLE products ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD'",
+		"ALTER TABLE products ADD COLUMN IF NOT EXISTS prices TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE products ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false",
+		// Products reference the category tree; category keeps a copy of the category's name
+		"ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id BIGINT NOT NULL DEFAULT 0",
+		"CREATE INDEX IF NOT EXISTS idx_products_category_id ON products (category_id)",
+		// Full-text search document, weighted by where a word appears
+		`ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(category, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(description, '')), 'C')
+		) STORED`,
+		"CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)",
+		"CREATE INDEX IF NOT EXISTS idx_product_price_changes_product ON product_price_changes (product_id, effective_from)",
+	}
+	for _, migration := range migrations {
+		if _, err := r.db.ExecContext(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *PostgresRepository) Create(ctx context.Context, product *Product) error {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return err
 	}
-	query := "INSERT INTO products (name, description, price, category, in_stock, created_at) VALUES ($1, $2, $3, $4, $5, $6)"
-	_, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Category, product.InStock, product.CreatedAt)
-	return err
+	prices, err := encodePrices(product.Prices)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO products (name, description, price, currency, prices, category, category_id, in_stock, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING product_id"
+	return r.db.QueryRowContext(ctx, query, product.Name, product.Description, product.Price, product.Price.Currency, prices, product.Category, product.CategoryID, product.InStock, product.CreatedAt).Scan(&product.ProductID)
+}
+
+// CreateBatch inserts the products and their variants with prepared statements in one transaction
+func (r *PostgresRepository) CreateBatch(ctx context.Context, products []*Product) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	productStmt, err := tx.PrepareContext(ctx, "INSERT INTO products (name, description, price, currency, prices, category, category_id, in_stock, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING product_id")
+	if err != nil {
+		return err
+	}
+	defer productStmt.Close()
+	variantStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO product_variants (product_id, sku, attributes, price, currency, in_stock, created_at)
+		VALUES ($1, $2, NULLIF($3, '')::jsonb, $4, $5, $6, $7) RETURNING variant_id`)
+	if err != nil {
+		return err
+	}
+	defer variantStmt.Close()
+
+	for _, product := range products {
+		prices, err := encodePrices(product.Prices)
+		if err != nil {
+			return err
+		}
+		err = productStmt.QueryRowContext(ctx, product.Name, product.Description, product.Price, product.Price.Currency, prices, product.Category, product.CategoryID, product.InStock, product.CreatedAt).Scan(&product.ProductID)
+		if err != nil {
+			return err
+		}
+		for i := range product.Variants {
+			variant := &product.Variants[i]
+			variant.ProductID = product.ProductID
+			attributes, err := encodeAttributes(variant.Attributes)
+			if err != nil {
+				return err
+			}
+			price, currency := variantPriceColumns(variant)
+			err = variantStmt.QueryRowContext(ctx, variant.ProductID, variant.SKU, attributes, price, currency, variant.InStock, variant.CreatedAt).Scan(&variant.VariantID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
 }
 
 func (r *PostgresRepository) GetAll(ctx context.Context) ([]Product, error) {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return nil, err
 	}
-	query := "SELECT product_id, name, description, price, category, in_stock, created_at FROM products ORDER BY name"
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE NOT archived ORDER BY name"
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -57,12 +194,20 @@ func (r *PostgresRepository) GetAll(ctx context.Context) ([]Product, error) {
 	for rows.Next() {
 		var p Product
 		var createdAt time.Time
-		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &p.Category, &p.InStock, &createdAt); err != nil {
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &createdAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
 			return nil, err
 		}
 		p.CreatedAt = createdAt.Format(time.RFC3339)
 		products = append(products, p)
 	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
 	return products, nil
 }
 
@@ -72,9 +217,10 @@ func (r *PostgresRepository) GetByID(ctx context.Context, productID uint64) (*Pr
 	}
 	var product Product
 	var createdAt time.Time
-	query := "SELECT product_id, name, description, price, category, in_stock, created_at FROM products WHERE product_id = $1 LIMIT 1"
+	var currency, prices string
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE product_id = $1 LIMIT 1"
 	err := r.db.QueryRowContext(ctx, query, productID).Scan(
-		&product.ProductID, &product.Name, &product.Description, &product.Price, &product.Category, &product.InStock, &createdAt,
+		&product.ProductID, &product.Name, &product.Description, &product.Price, &currency, &prices, &product.Category, &product.CategoryID, &product.InStock, &product.Archived, &createdAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -82,16 +228,24 @@ func (r *PostgresRepository) GetByID(ctx context.Context, productID uint64) (*Pr
 		}
 		return nil, err
 	}
+	money.WithCurrency(currency, &product.Price)
+	if product.Prices, err = decodePrices(prices); err != nil {
+		return nil, err
+	}
 	product.CreatedAt = createdAt.Format(time.RFC3339)
-	return &product, nil
+	products := []Product{product}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return &products[0], nil
 }
 
-func (r *PostgresRepository) GetByCategory(ctx context.Context, category string) ([]Product, error) {
+func (r *PostgresRepository) GetPage(ctx context.Context, afterID uint64, limit int) ([]Product, error) {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return nil, err
 	}
-	query := "SELECT product_id, name, description, price, category, in_stock, created_at FROM products WHERE category = $1 ORDER BY name"
-	rows, err := r.db.QueryContext(ctx, query, category)
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE NOT archived AND product_id > $1 ORDER BY product_id LIMIT $2"
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -101,15 +255,166 @@ func (r *PostgresRepository) GetByCategory(ctx context.Context, category string)
 	for rows.Next() {
 		var p Product
 		var createdAt time.Time
-		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &p.Category, &p.InStock, &createdAt); err != nil {
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &createdAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
 			return nil, err
 		}
 		p.CreatedAt = createdAt.Format(time.RFC3339)
 		products = append(products, p)
 	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
 	return products, nil
 }
 
+func (r *PostgresRepository) GetByCategoryIDs(ctx context.Context, categoryIDs []uint64) ([]Product, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE category_id = ANY($1) AND NOT archived ORDER BY name"
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(int64IDs(categoryIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		var createdAt time.Time
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &createdAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
+			return nil, err
+		}
+		p.CreatedAt = createdAt.Format(time.RFC3339)
+		products = append(products, p)
+	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *PostgresRepository) Search(ctx context.Context, query SearchQuery) ([]Product, []FacetRow, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	// Every word must match, as a prefix so partially typed words already find products
+	tsQuery := ""
+	if query.Text != "" {
+		tsQuery = strings.Join(searchTokens(query.Text), ":* & ") + ":*"
+	}
+
+	// Conditions shared by the results and the facets
+	conditions := []string{"NOT archived"}
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if tsQuery != "" {
+		addCondition("search_vector @@ to_tsquery('english', $%d)", tsQuery)
+	}
+	if currency := query.priceCurrency(); currency != "" {
+		addCondition("currency = $%d", currency)
+	}
+	if query.MinPrice != nil {
+		addCondition("price >= $%d", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		addCondition("price <= $%d", *query.MaxPrice)
+	}
+
+	// Facets ignore the category and in-stock filters
+	facetQuery := "SELECT category_id, category, COALESCE(in_stock, false), COUNT(*) FROM products WHERE " + strings.Join(conditions, " AND ") + " GROUP BY 1, 2, 3"
+	facets, err := r.queryFacets(ctx, facetQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(query.CategoryIDs) > 0 {
+		addCondition("category_id = ANY($%d)", pq.Array(int64IDs(query.CategoryIDs)))
+	}
+	if query.InStock != nil {
+		addCondition("COALESCE(in_stock, false) = $%d", *query.InStock)
+	}
+
+	orderBy := "name, product_id"
+	switch query.Sort {
+	case SortRelevance:
+		if tsQuery != "" {
+			args = append(args, tsQuery)
+			orderBy = fmt.Sprintf("ts_rank(search_vector, to_tsquery('english', $%d)) DESC, product_id", len(args))
+		}
+	case SortPriceAsc:
+		orderBy = "price, product_id"
+	case SortPriceDesc:
+		orderBy = "price DESC, product_id"
+	case SortNewest:
+		orderBy = "created_at DESC, product_id DESC"
+	}
+
+	args = append(args, query.Limit, query.Offset)
+	sqlQuery := fmt.Sprintf(
+		"SELECT product_id, name, description, price, currency, prices, category, category_id, in_stock, archived, created_at FROM products WHERE %s ORDER BY %s LIMIT $%d OFFSET $%d",
+		strings.Join(conditions, " AND "), orderBy, len(args)-1, len(args),
+	)
+	rows, err := r.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		var createdAt time.Time
+		var currency, prices string
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Description, &p.Price, &currency, &prices, &p.Category, &p.CategoryID, &p.InStock, &p.Archived, &createdAt); err != nil {
+			return nil, nil, err
+		}
+		money.WithCurrency(currency, &p.Price)
+		if p.Prices, err = decodePrices(prices); err != nil {
+			return nil, nil, err
+		}
+		p.CreatedAt = createdAt.Format(time.RFC3339)
+		products = append(products, p)
+	}
+	if err := r.attachDetails(ctx, products); err != nil {
+		return nil, nil, err
+	}
+	return products, facets, nil
+}
+
+// queryFacets runs a query returning category_id, category, in_stock and count rows
+func (r *PostgresRepository) queryFacets(ctx context.Context, query string, args ...interface{}) ([]FacetRow, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var facets []FacetRow
+	for rows.Next() {
+		var facet FacetRow
+		if err := rows.Scan(&facet.CategoryID, &facet.Category, &facet.InStock, &facet.Count); err != nil {
+			return nil, err
+		}
+		facets = append(facets, facet)
+	}
+	return facets, nil
+}
+
 func (r *PostgresRepository) UpdateStock(ctx context.Context, productID uint64, inStock bool) error {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return err
@@ -119,6 +424,400 @@ func (r *PostgresRepository) UpdateStock(ctx context.Context, productID uint64,
 	return err
 }
 
+func (r *PostgresRepository) Update(ctx context.Context, product *Product) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	prices, err := encodePrices(product.Prices)
+	if err != nil {
+		return err
+	}
+	query := "UPDATE products SET name = $1, description = $2, price = $3, currency = $4, prices = $5, category = $6, category_id = $7, in_stock = $8 WHERE product_id = $9"
+	result, err := r.db.ExecContext(ctx, query, product.Name, product.Description, product.Price, product.Price.Currency, prices, product.Category, product.CategoryID, product.InStock, product.ProductID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("product not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Archive(ctx context.Context, productID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "UPDATE products SET archived = true WHERE product_id = $1", productID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("product not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CountByCategory(ctx context.Context, categoryID uint64) (int, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return 0, err
+	}
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE category_id = $1 AND NOT archived", categoryID).Scan(&count)
+	return count, err
+}
+
+func (r *PostgresRepository) RenameCategory(ctx context.Context, categoryID uint64, name string) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "UPDATE products SET category = $1 WHERE category_id = $2", name, categoryID)
+	return err
+}
+
+func (r *PostgresRepository) GetUncategorized(ctx context.Context) ([]string, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT DISTINCT category FROM products WHERE category_id = 0 AND category <> '' ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (r *PostgresRepository) AssignCategory(ctx context.Context, category string, categoryID uint64, name string) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	query := "UPDATE products SET category_id = $1, category = $2 WHERE category = $3 AND category_id = 0"
+	_, err := r.db.ExecContext(ctx, query, categoryID, name, category)
+	return err
+}
+
+// int64IDs converts IDs for pq.Array, which has no uint64 support
+func int64IDs(ids []uint64) []int64 {
+	converted := make([]int64, len(ids))
+	for i, id := range ids {
+		converted[i] = int64(id)
+	}
+	return converted
+}
+
+// postgresVariantColumns lists the variant columns in scan order
+const postgresVariantColumns = "variant_id, product_id, sku, COALESCE(attributes::text, ''), COALESCE(price::text, ''), currency, in_stock, archived, created_at"
+
+func (r *PostgresRepository) CreateVariant(ctx context.Context, variant *Variant) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	attributes, err := encodeAttributes(variant.Attributes)
+	if err != nil {
+		return err
+	}
+	price, currency := variantPriceColumns(variant)
+	query := `
+		INSERT INTO product_variants (product_id, sku, attributes, price, currency, in_stock, created_at)
+		VALUES ($1, $2, NULLIF($3, '')::jsonb, $4, $5, $6, $7) RETURNING variant_id`
+	return r.db.QueryRowContext(ctx, query, variant.ProductID, variant.SKU, attributes, price, currency, variant.InStock, variant.CreatedAt).Scan(&variant.VariantID)
+}
+
+func (r *PostgresRepository) UpdateVariant(ctx context.Context, variant *Variant) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	attributes, err := encodeAttributes(variant.Attributes)
+	if err != nil {
+		return err
+	}
+	price, currency := variantPriceColumns(variant)
+	query := "UPDATE product_variants SET sku = $1, attributes = NULLIF($2, '')::jsonb, price = $3, currency = $4, in_stock = $5 WHERE variant_id = $6"
+	result, err := r.db.ExecContext(ctx, query, variant.SKU, attributes, price, currency, variant.InStock, variant.VariantID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("variant not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) ArchiveVariant(ctx context.Context, variantID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "UPDATE product_variants SET archived = true WHERE variant_id = $1", variantID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("variant not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetVariant(ctx context.Context, variantID uint64) (*Variant, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + postgresVariantColumns + " FROM product_variants WHERE variant_id = $1"
+	return r.scanVariant(r.db.QueryRowContext(ctx, query, variantID))
+}
+
+func (r *PostgresRepository) GetVariantBySKU(ctx context.Context, sku string) (*Variant, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + postgresVariantColumns + " FROM product_variants WHERE sku = $1"
+	return r.scanVariant(r.db.QueryRowContext(ctx, query, sku))
+}
+
+func (r *PostgresRepository) CreateImage(ctx context.Context, image *Image) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO product_images (product_id, key, thumbnail_key, content_type, size, width, height, position, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING image_id`
+	return r.db.QueryRowContext(ctx, query, image.ProductID, image.Key, image.ThumbnailKey, image.ContentType, image.Size,
+		image.Width, image.Height, image.Position, image.CreatedAt).Scan(&image.ImageID)
+}
+
+func (r *PostgresRepository) DeleteImage(ctx context.Context, imageID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM product_images WHERE image_id = $1", imageID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("image not found")
+	}
+	return nil
+}
+
+// postgresPriceChangeColumns lists the price change columns in scan order
+const postgresPriceChangeColumns = "price_change_id, product_id, price, currency, prices, effective_from, applied, created_at"
+
+func (r *PostgresRepository) CreatePriceChanges(ctx context.Context, changes []*PriceChange) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO product_price_changes (product_id, price, currency, prices, effective_from, applied, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING price_change_id`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, change := range changes {
+		prices, err := encodePrices(change.Prices)
+		if err != nil {
+			return err
+		}
+		err = stmt.QueryRowContext(ctx, change.ProductID, change.Price, change.Price.Currency, prices, change.EffectiveFrom, change.Applied, change.CreatedAt).Scan(&change.PriceChangeID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) GetPriceChanges(ctx context.Context, productID uint64) ([]PriceChange, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + postgresPriceChangeColumns + " FROM product_price_changes WHERE product_id = $1 ORDER BY effective_from, price_change_id"
+	return r.queryPriceChanges(ctx, query, productID)
+}
+
+func (r *PostgresRepository) GetDuePriceChanges(ctx context.Context, at time.Time) ([]PriceChange, error) {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + postgresPriceChangeColumns + " FROM product_price_changes WHERE NOT applied AND effective_from <= $1 ORDER BY effective_from, price_change_id"
+	return r.queryPriceChanges(ctx, query, at)
+}
+
+// queryPriceChanges runs a query selecting postgresPriceChangeColumns
+func (r *PostgresRepository) queryPriceChanges(ctx context.Context, query string, args ...interface{}) ([]PriceChange, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PriceChange
+	for rows.Next() {
+		var change PriceChange
+		var currency, prices string
+		if err := rows.Scan(&change.PriceChangeID, &change.ProductID, &change.Price, &currency, &prices, &change.EffectiveFrom, &change.Applied, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		money.WithCurrency(currency, &change.Price)
+		if change.Prices, err = decodePrices(prices); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *PostgresRepository) ApplyPriceChange(ctx context.Context, change *PriceChange, at time.Time) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	prices, err := encodePrices(change.Prices)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE products SET price = $1, currency = $2, prices = $3 WHERE product_id = $4", change.Price, change.Price.Currency, prices, change.ProductID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("product not found")
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE product_price_changes SET applied = true WHERE product_id = $1 AND effective_from <= $2", change.ProductID, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PostgresRepository) DeletePriceChange(ctx context.Context, priceChangeID uint64) error {
+	if err := r.ensureProductsTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM product_price_changes WHERE price_change_id = $1", priceChangeID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("price change not found")
+	}
+	return nil
+}
+
+// attachDetails loads the variants and images of the given products
+func (r *PostgresRepository) attachDetails(ctx context.Context, products []Product) error {
+	if err := r.attachVariants(ctx, products); err != nil {
+		return err
+	}
+	return r.attachImages(ctx, products)
+}
+
+// attachImages loads the images of the given products
+func (r *PostgresRepository) attachImages(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(products))
+	index := make(map[uint64]int, len(products))
+	for i, p := range products {
+		ids[i] = int64(p.ProductID)
+		index[p.ProductID] = i
+	}
+
+	query := `
+		SELECT image_id, product_id, key, thumbnail_key, content_type, size, width, height, position, created_at
+		FROM product_images WHERE product_id = ANY($1) ORDER BY position, image_id`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var image Image
+		var createdAt time.Time
+		if err := rows.Scan(&image.ImageID, &image.ProductID, &image.Key, &image.ThumbnailKey, &image.ContentType, &image.Size,
+			&image.Width, &image.Height, &image.Position, &createdAt); err != nil {
+			return err
+		}
+		image.CreatedAt = createdAt.Format(time.RFC3339)
+		if i, ok := index[image.ProductID]; ok {
+			products[i].Images = append(products[i].Images, image)
+		}
+	}
+	return rows.Err()
+}
+
+// attachVariants loads the active variants of the given products
+func (r *PostgresRepository) attachVariants(ctx context.Context, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(products))
+	index := make(map[uint64]int, len(products))
+	for i, p := range products {
+		ids[i] = int64(p.ProductID)
+		index[p.ProductID] = i
+	}
+
+	query := "SELECT " + postgresVariantColumns + " FROM product_variants WHERE product_id = ANY($1) AND NOT archived ORDER BY variant_id"
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		variant, err := r.scanVariant(rows)
+		if err != nil {
+			return err
+		}
+		if i, ok := index[variant.ProductID]; ok {
+			products[i].Variants = append(products[i].Variants, *variant)
+		}
+	}
+	return nil
+}
+
+// scanVariant scans a variant from a *sql.Row or *sql.Rows
+func (r *PostgresRepository) scanVariant(row interface{ Scan(...interface{}) error }) (*Variant, error) {
+	var v Variant
+	var attributes, price, currency string
+	var createdAt time.Time
+	err := row.Scan(&v.VariantID, &v.ProductID, &v.SKU, &attributes, &price, &currency, &v.InStock, &v.Archived, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("variant not found")
+		}
+		return nil, err
+	}
+	if v.Attributes, err = decodeAttributes(attributes); err != nil {
+		return nil, err
+	}
+	if v.Price, err = decodeVariantPrice(price, currency); err != nil {
+		return nil, err
+	}
+	v.CreatedAt = createdAt.Format(time.RFC3339)
+	return &v, nil
+}
+
 func (r *PostgresRepository) SeedSampleProducts(ctx context.Context) error {
 	if err := r.ensureProductsTable(ctx); err != nil {
 		return err
@@ -136,20 +835,35 @@ func (r *PostgresRepository) SeedSampleProducts(ctx context.Context) error {
 
 	// Sample products data
 	sampleProducts := []Product{
-		{Name: "MacBook Pro 16\"", Description: "High-performance laptop for professionals", Price: 2499.99, Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "iPhone 15 Pro", Description: "Latest smartphone with advanced features", Price: 999.99, Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Wireless Headphones", Description: "Premium noise-cancelling headphones", Price: 299.99, Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Coffee Maker", Description: "Automatic drip coffee maker", Price: 89.99, Category: "Appliances", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Office Chair", Description: "Ergonomic office chair with lumbar support", Price: 199.99, Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Bluetooth Speaker", Description: "Portable wireless speaker", Price: 49.99, Category: "Electronics", InStock: false, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Desk Lamp", Description: "LED desk lamp with adjustable brightness", Price: 39.99, Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
-		{Name: "Water Bottle", Description: "Insulated stainless steel water bottle", Price: 24.99, Category: "Accessories", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "MacBook Pro 16\"", Description: "High-performance laptop for professionals", Price: money.MustParse("2499.99", "USD"), Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "iPhone 15 Pro", Description: "Latest smartphone with advanced features", Price: money.MustParse("999.99", "USD"), Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Wireless Headphones", Description: "Premium noise-cancelling headphones", Price: money.MustParse("299.99", "USD"), Category: "Electronics", InStock: true, CreatedAt: time.Now().Format(time.RFC3339), Variants: []Variant{
+			{SKU: "WH-BLK", Attributes: map[string]string{"color": "Black"}, InStock: true},
+			{SKU: "WH-WHT", Attributes: map[string]string{"color": "White"}, InStock: true},
+			{SKU: "WH-SLV", Attributes: map[string]string{"color": "Silver"}, Price: moneyPtr(money.MustParse("319.99", "USD")), InStock: false},
+		}},
+		{Name: "Coffee Maker", Description: "Automatic drip coffee maker", Price: money.MustParse("89.99", "USD"), Category: "Appliances", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Office Chair", Description: "Ergonomic office chair with lumbar support", Price: money.MustParse("199.99", "USD"), Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Bluetooth Speaker", Description: "Portable wireless speaker", Price: money.MustParse("49.99", "USD"), Category: "Electronics", InStock: false, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Desk Lamp", Description: "LED desk lamp with adjustable brightness", Price: money.MustParse("39.99", "USD"), Category: "Furniture", InStock: true, CreatedAt: time.Now().Format(time.RFC3339)},
+		{Name: "Water Bottle", Description: "Insulated stainless steel water bottle", Price: money.MustParse("24.99", "USD"), Category: "Accessories", InStock: true, CreatedAt: time.Now().Format(time.RFC3339), Variants: []Variant{
+			{SKU: "WB-500", Attributes: map[string]string{"size": "500 ml", "color": "Steel"}, InStock: true},
+			{SKU: "WB-750", Attributes: map[string]string{"size": "750 ml", "color": "Steel"}, Price: moneyPtr(money.MustParse("29.99", "USD")), InStock: true},
+			{SKU: "WB-1000", Attributes: map[string]string{"size": "1 l", "color": "Black"}, Price: moneyPtr(money.MustParse("34.99", "USD")), InStock: true},
+		}},
 	}
 
 	for _, product := range sampleProducts {
 		if err := r.Create(ctx, &product); err != nil {
 			return fmt.Errorf("failed to seed product %s: %w", product.Name, err)
 		}
+		for _, variant := range product.Variants {
+			variant.ProductID = product.ProductID
+			variant.CreatedAt = product.CreatedAt
+			if err := r.CreateVariant(ctx, &variant); err != nil {
+				return fmt.Errorf("failed to seed variant %s: %w", variant.SKU, err)
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,31 @@
+package product
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunPriceScheduler applies scheduled prices that took effect every interval until ctx is cancelled
+// Orders resolve prices against the schedule themselves; this keeps listings and search up to date
+// It is meant to be started in its own goroutine
+func RunPriceScheduler(ctx context.Context, service ProductService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			applied, err := service.ApplyScheduledPrices(ctx)
+			if err != nil {
+				log.Printf("Price scheduler: failed to apply scheduled prices: %v", err)
+				continue
+			}
+			if applied > 0 {
+				log.Printf("Price scheduler: applied scheduled prices to %d products", applied)
+			}
+		}
+	}
+}
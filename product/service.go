@@ -1,9 +1,20 @@
 package product
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/blobstore"
+	"github.com/rajindersingh041/go-auth-sessions/category"
+	"github.com/rajindersingh041/go-auth-sessions/money"
 )
 
 // ProductService defines the business logic interface for product operations
@@ -11,52 +22,254 @@ type ProductService interface {
 	CreateProduct(ctx context.Context, req CreateProductRequest) error
 	GetAllProducts(ctx context.Context) ([]Product, error)
 	GetProductByID(ctx context.Context, productID uint64) (*Product, error)
-	GetProductsByCategory(ctx context.Context, category string) ([]Product, error)
+	// GetProductDetails retrieves a product for its product page, including its rating
+	GetProductDetails(ctx context.Context, productID uint64) (*Product, error)
+	// GetProductAt retrieves a product with the prices in effect at a time, including scheduled prices
+	GetProductAt(ctx context.Context, productID uint64, at time.Time) (*Product, error)
+	// GetProductsByCategory lists the products of a category by slug, optionally with those of its sub-categories
+	GetProductsByCategory(ctx context.Context, slug string, includeDescendants bool) (*category.Category, []Product, error)
+	SearchProducts(ctx context.Context, req SearchRequest) (*SearchResult, error)
+	// ImportProducts creates products from a CSV or NDJSON file, or only validates it in a dry run
+	ImportProducts(ctx context.Context, format string, r io.Reader, dryRun bool) (*ImportResult, error)
+	// ExportProducts streams all active products to w as CSV or NDJSON
+	ExportProducts(ctx context.Context, format string, w io.Writer) error
 	UpdateProductStock(ctx context.Context, productID uint64, inStock bool) error
+	UpdateProduct(ctx context.Context, productID uint64, req CreateProductRequest) (*Product, error)
+	PatchProduct(ctx context.Context, productID uint64, req PatchProductRequest) (*Product, error)
+	DeleteProduct(ctx context.Context, productID uint64) error
+	GetPriceHistory(ctx context.Context, productID uint64) ([]PriceChange, error)
+	// SchedulePrice sets new prices for a product from a future time
+	SchedulePrice(ctx context.Context, productID uint64, req SchedulePriceRequest) (*PriceChange, error)
+	CancelScheduledPrice(ctx context.Context, productID, priceChangeID uint64) error
+	// ApplyScheduledPrices stores scheduled prices that took effect on their products, so listings and search show them
+	ApplyScheduledPrices(ctx context.Context) (int, error)
+	GetVariant(ctx context.Context, variantID uint64) (*Variant, error)
+	AddVariant(ctx context.Context, productID uint64, req VariantRequest) (*Variant, error)
+	UpdateVariant(ctx context.Context, productID, variantID uint64, req VariantRequest) (*Variant, error)
+	DeleteVariant(ctx context.Context, productID, variantID uint64) error
+	// AddImage validates an uploaded image file, stores it with a thumbnail and adds it to the product
+	AddImage(ctx context.Context, productID uint64, data []byte) (*Image, error)
+	DeleteImage(ctx context.Context, productID, imageID uint64) error
 	InitializeSampleProducts(ctx context.Context) error
+	// MigrateCategories moves products with only a free-text category into the category tree
+	MigrateCategories(ctx context.Context) (int, error)
 }
 
 // productService implements the ProductService interface
 type productService struct {
-	repo ProductRepository
+	repo         ProductRepository
+	categories   category.CategoryService
+	ratings      RatingSource
+	stock        StockWatcher
+	images       blobstore.Store
+	mediaBaseURL string
 }
 
 // NewProductService creates a new product service
-func NewProductService(repo ProductRepository) ProductService {
+// stock is told when products come back in stock
+// Image files are kept in images and served below mediaBaseURL, e.g. "/media" or a CDN URL
+func NewProductService(repo ProductRepository, categories category.CategoryService, ratings RatingSource, stock StockWatcher, images blobstore.Store, mediaBaseURL string) ProductService {
 	return &productService{
-		repo: repo,
+		repo:         repo,
+		categories:   categories,
+		ratings:      ratings,
+		stock:        stock,
+		images:       images,
+		mediaBaseURL: strings.TrimRight(mediaBaseURL, "/"),
 	}
 }
 
 // CreateProduct creates a new product with validation
 func (s *productService) CreateProduct(ctx context.Context, req CreateProductRequest) error {
-	// Validate input
-	if req.Name == "" {
-		return fmt.Errorf("product name is required")
+	product, err := s.newProduct(ctx, req, map[string]bool{})
+	if err != nil {
+		return err
 	}
-	if req.Price < 0 {
-		return fmt.Errorf("product price must be non-negative")
+
+	variants := product.Variants
+	product.Variants = nil
+	if err := s.repo.Create(ctx, product); err != nil {
+		return err
 	}
-	if req.Category == "" {
-		return fmt.Errorf("product category is required")
+	for _, variant := range variants {
+		variant.ProductID = product.ProductID
+		if err := s.repo.CreateVariant(ctx, &variant); err != nil {
+			return fmt.Errorf("failed to create variant %s: %w", variant.SKU, err)
+		}
+		product.Variants = append(product.Variants, variant)
 	}
+	return s.recordPrices(ctx, product)
+}
 
-	// Create product
+// newProduct builds and validates a product with its variants from a create request
+// seenSKUs holds the SKUs of variants that are about to be created, so none is used twice
+func (s *productService) newProduct(ctx context.Context, req CreateProductRequest, seenSKUs map[string]bool) (*Product, error) {
 	product := &Product{
 		Name:        req.Name,
 		Description: req.Description,
 		Price:       req.Price,
+		Prices:      req.Prices,
 		Category:    req.Category,
+		CategoryID:  req.CategoryID,
 		InStock:     req.InStock,
 		CreatedAt:   time.Now().Format(time.RFC3339),
 	}
 
-	return s.repo.Create(ctx, product)
+	// Validate input
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+	if err := s.resolveCategory(ctx, product); err != nil {
+		return nil, err
+	}
+	for _, variantReq := range req.Variants {
+		variant := newVariant(variantReq, product.CreatedAt)
+		if err := s.validateVariant(ctx, variant); err != nil {
+			return nil, err
+		}
+		if seenSKUs[variant.SKU] {
+			return nil, fmt.Errorf("variant with SKU '%s' already exists", variant.SKU)
+		}
+		seenSKUs[variant.SKU] = true
+		product.Variants = append(product.Variants, *variant)
+	}
+	return product, nil
+}
+
+// importBatchSize is the number of products inserted per batch by ImportProducts
+const importBatchSize = 500
+
+// ImportProducts validates every row of a CSV or NDJSON file with the same rules as CreateProduct
+// Products are only created when all rows are valid, so a corrected file can simply be imported again
+func (s *productService) ImportProducts(ctx context.Context, format string, r io.Reader, dryRun bool) (*ImportResult, error) {
+	rows, err := readImportRows(format, r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Format: format, DryRun: dryRun, Rows: len(rows), Errors: []ImportRowError{}}
+	products := make([]*Product, 0, len(rows))
+	seenSKUs := map[string]bool{}
+	for _, row := range rows {
+		if row.Err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.Row, Error: row.Err.Error()})
+			continue
+		}
+		product, err := s.newProduct(ctx, row.Request, seenSKUs)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: row.Row, Error: err.Error()})
+			continue
+		}
+		products = append(products, product)
+	}
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	for start := 0; start < len(products); start += importBatchSize {
+		batch := products[start:min(start+importBatchSize, len(products))]
+		if err := s.repo.CreateBatch(ctx, batch); err != nil {
+			return result, fmt.Errorf("failed to import products after %d of %d: %w", result.Imported, len(products), err)
+		}
+		result.Imported += len(batch)
+		if err := s.recordPrices(ctx, batch...); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// exportPageSize is the number of products loaded per query by ExportProducts
+const exportPageSize = 500
+
+// ExportProducts writes all active products as CSV or NDJSON, one page at a time
+func (s *productService) ExportProducts(ctx context.Context, format string, w io.Writer) error {
+	writer, err := newProductWriter(format, w)
+	if err != nil {
+		return err
+	}
+	flusher, _ := w.(interface{ Flush() })
+
+	var afterID uint64
+	for {
+		products, err := s.repo.GetPage(ctx, afterID, exportPageSize)
+		if err != nil {
+			return err
+		}
+		s.setImageURLs(products)
+		for i := range products {
+			if err := writer.Write(&products[i]); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if len(products) < exportPageSize {
+			return nil
+		}
+		afterID = products[len(products)-1].ProductID
+	}
+}
+
+// validateProduct checks the editable fields of a product
+func validateProduct(product *Product) error {
+	if product.Name == "" {
+		return fmt.Errorf("product name is required")
+	}
+	if product.Price.IsNegative() {
+		return fmt.Errorf("product price must be non-negative")
+	}
+	if product.Price.Currency == "" {
+		product.Price.Currency = money.DefaultCurrency
+	}
+	seen := map[string]bool{product.Price.Currency: true}
+	for _, price := range product.Prices {
+		if price.IsNegative() {
+			return fmt.Errorf("product price must be non-negative")
+		}
+		if seen[price.Currency] {
+			return fmt.Errorf("product has more than one price in %s", price.Currency)
+		}
+		seen[price.Currency] = true
+	}
+	return nil
+}
+
+// resolveCategory looks up the product's category by ID, or else by slug or name, and sets both fields
+func (s *productService) resolveCategory(ctx context.Context, product *Product) error {
+	var found *category.Category
+	var err error
+	switch {
+	case product.CategoryID != 0:
+		found, err = s.categories.GetCategory(ctx, product.CategoryID)
+	case strings.TrimSpace(product.Category) != "":
+		found, err = s.categories.GetCategoryBySlug(ctx, product.Category)
+	default:
+		return fmt.Errorf("product category is required")
+	}
+	if err != nil {
+		if err.Error() == "category not found" {
+			return fmt.Errorf("product category must be an existing category")
+		}
+		return err
+	}
+	product.CategoryID = found.CategoryID
+	product.Category = found.Name
+	return nil
 }
 
 // GetAllProducts retrieves all products
 func (s *productService) GetAllProducts(ctx context.Context) ([]Product, error) {
-	return s.repo.GetAll(ctx)
+	products, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.setImageURLs(products)
+	return products, nil
 }
 
 // GetProductByID retrieves a specific product by ID
@@ -64,26 +277,756 @@ func (s *productService) GetProductByID(ctx context.Context, productID uint64) (
 	if productID == 0 {
 		return nil, fmt.Errorf("valid product ID is required")
 	}
-	return s.repo.GetByID(ctx, productID)
+	product, err := s.repo.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range product.Images {
+		s.setImageURL(&product.Images[i])
+	}
+	return product, nil
 }
 
-// GetProductsByCategory retrieves products by category
-func (s *productService) GetProductsByCategory(ctx context.Context, category string) ([]Product, error) {
-	if category == "" {
-		return nil, fmt.Errorf("category is required")
+// setImageURLs fills in the URLs of the products' images from their storage keys
+func (s *productService) setImageURLs(products []Product) {
+	for i := range products {
+		for j := range products[i].Images {
+			s.setImageURL(&products[i].Images[j])
+		}
 	}
-	return s.repo.GetByCategory(ctx, category)
+}
+
+// setImageURL fills in the URLs of one image
+func (s *productService) setImageURL(image *Image) {
+	image.URL = s.mediaBaseURL + "/" + image.Key
+	image.ThumbnailURL = s.mediaBaseURL + "/" + image.ThumbnailKey
+}
+
+// GetProductsByCategory retrieves the products of a category
+func (s *productService) GetProductsByCategory(ctx context.Context, slug string, includeDescendants bool) (*category.Category, []Product, error) {
+	found, err := s.categories.GetCategoryBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	categoryIDs := []uint64{found.CategoryID}
+	if includeDescendants {
+		if categoryIDs, err = s.categories.Descendants(ctx, found.CategoryID); err != nil {
+			return nil, nil, err
+		}
+	}
+	products, err := s.repo.GetByCategoryIDs(ctx, categoryIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.setImageURLs(products)
+	return found, products, nil
+}
+
+// Search page sizes
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchProducts runs a full-text search with filters, sorting and cursor pagination
+func (s *productService) SearchProducts(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	query := SearchQuery{
+		Text:    strings.Join(searchTokens(req.Query), " "),
+		InStock: req.InStock,
+		Sort:    req.Sort,
+		Limit:   req.Limit,
+	}
+
+	if strings.TrimSpace(req.Category) != "" {
+		found, err := s.categories.GetCategoryBySlug(ctx, req.Category)
+		if err != nil {
+			if err.Error() == "category not found" {
+				return nil, fmt.Errorf("invalid category '%s'", req.Category)
+			}
+			return nil, err
+		}
+		if query.CategoryIDs, err = s.categories.Descendants(ctx, found.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	switch query.Sort {
+	case "":
+		query.Sort = SortName
+		if query.Text != "" {
+			query.Sort = SortRelevance
+		}
+	case SortRelevance:
+		if query.Text == "" {
+			query.Sort = SortName
+		}
+	case SortName, SortPriceAsc, SortPriceDesc, SortNewest:
+	default:
+		return nil, fmt.Errorf("invalid sort '%s': must be one of relevance, name, price_asc, price_desc, newest", req.Sort)
+	}
+
+	if req.MinPrice != "" {
+		minPrice, err := money.Parse(req.MinPrice, req.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_price: %w", err)
+		}
+		query.MinPrice = &minPrice
+	}
+	if req.MaxPrice != "" {
+		maxPrice, err := money.Parse(req.MaxPrice, req.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_price: %w", err)
+		}
+		query.MaxPrice = &maxPrice
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && query.MinPrice.Cmp(*query.MaxPrice) > 0 {
+		return nil, fmt.Errorf("invalid price range: min_price must not exceed max_price")
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+	if query.Limit > maxSearchLimit {
+		query.Limit = maxSearchLimit
+	}
+	if req.Cursor != "" {
+		offset, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		query.Offset = offset
+	}
+
+	// Fetch one extra product to know whether there is another page
+	page := query
+	page.Limit = query.Limit + 1
+	products, facetRows, err := s.repo.Search(ctx, page)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setImageURLs(products)
+	result := &SearchResult{Products: products}
+	if len(products) > query.Limit {
+		result.Products = products[:query.Limit]
+		result.NextCursor = encodeCursor(query.Offset + query.Limit)
+	}
+	if result.Products == nil {
+		result.Products = []Product{}
+	}
+	result.Facets, result.Total = buildFacets(facetRows, query)
+	return result, nil
+}
+
+// buildFacets aggregates facet rows; each facet applies every filter except its own
+func buildFacets(rows []FacetRow, query SearchQuery) (Facets, int) {
+	facets := Facets{Categories: []FacetCount{}}
+	total := 0
+	selected := map[uint64]bool{}
+	for _, id := range query.CategoryIDs {
+		selected[id] = true
+	}
+	type facetKey struct {
+		id   uint64
+		name string
+	}
+	categories := map[facetKey]int{}
+	for _, row := range rows {
+		stockMatches := query.InStock == nil || row.InStock == *query.InStock
+		categoryMatches := len(selected) == 0 || selected[row.CategoryID]
+		if stockMatches {
+			categories[facetKey{row.CategoryID, row.Category}] += row.Count
+		}
+		if categoryMatches {
+			if row.InStock {
+				facets.InStock += row.Count
+			} else {
+				facets.OutOfStock += row.Count
+			}
+		}
+		if stockMatches && categoryMatches {
+			total += row.Count
+		}
+	}
+	for key, count := range categories {
+		facets.Categories = append(facets.Categories, FacetCount{Value: key.name, CategoryID: key.id, Count: count})
+	}
+	sort.Slice(facets.Categories, func(i, j int) bool {
+		if facets.Categories[i].Count != facets.Categories[j].Count {
+			return facets.Categories[i].Count > facets.Categories[j].Count
+		}
+		return facets.Categories[i].Value < facets.Categories[j].Value
+	})
+	return facets, total
+}
+
+// encodeCursor returns an opaque cursor for the page starting at offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset of a cursor made by encodeCursor
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(string(data))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return offset, nil
 }
 
 // UpdateProductStock updates the stock status of a product
+// Subscribers to back-in-stock alerts are notified when an out of stock product comes back in stock
 func (s *productService) UpdateProductStock(ctx context.Context, productID uint64, inStock bool) error {
-	if productID == 0 {
-		return fmt.Errorf("valid product ID is required")
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return err
 	}
-	return s.repo.UpdateStock(ctx, productID, inStock)
+	if err := s.repo.UpdateStock(ctx, productID, inStock); err != nil {
+		return err
+	}
+	previous := *product
+	product.InStock = inStock
+	s.watchStock(ctx, &previous, product)
+	return nil
+}
+
+// watchStock tells the stock watcher when an update brought a product back in stock
+func (s *productService) watchStock(ctx context.Context, previous, product *Product) {
+	if !previous.Available() && product.Available() {
+		s.stock.BackInStock(ctx, product)
+	}
+}
+
+// watchVariantStock reloads a product after a change of its variants and tells the stock watcher when the
+// change brought it back in stock; the change is already stored, so a failed reload only loses the alert
+func (s *productService) watchVariantStock(ctx context.Context, previous *Product) {
+	product, err := s.getActiveProduct(ctx, previous.ProductID)
+	if err != nil {
+		log.Printf("Failed to reload product %d to check its stock: %v", previous.ProductID, err)
+		return
+	}
+	s.watchStock(ctx, previous, product)
+}
+
+// UpdateProduct replaces all editable fields of a product
+func (s *productService) UpdateProduct(ctx context.Context, productID uint64, req CreateProductRequest) (*Product, error) {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *product
+	product.Name = req.Name
+	product.Description = req.Description
+	product.Price = req.Price
+	product.Prices = req.Prices
+	product.Category = req.Category
+	product.CategoryID = req.CategoryID
+	product.InStock = req.InStock
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+	if err := s.resolveCategory(ctx, product); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, product); err != nil {
+		return nil, err
+	}
+	if !samePrices(&previous, product) {
+		if err := s.recordPriceUpdate(ctx, &previous, product); err != nil {
+			return nil, err
+		}
+	}
+	s.watchStock(ctx, &previous, product)
+	return product, nil
+}
+
+// PatchProduct updates only the fields present in the request
+func (s *productService) PatchProduct(ctx context.Context, productID uint64, req PatchProductRequest) (*Product, error) {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *product
+	if req.Name != nil {
+		product.Name = *req.Name
+	}
+	if req.Description != nil {
+		product.Description = *req.Description
+	}
+	if req.Price != nil {
+		product.Price = *req.Price
+	}
+	if req.Prices != nil {
+		product.Prices = *req.Prices
+	}
+	// A category name or slug replaces the current category unless a category ID is given as well
+	if req.Category != nil {
+		product.Category = *req.Category
+		product.CategoryID = 0
+	}
+	if req.CategoryID != nil {
+		product.CategoryID = *req.CategoryID
+	}
+	if req.InStock != nil {
+		product.InStock = *req.InStock
+	}
+	if err := validateProduct(product); err != nil {
+		return nil, err
+	}
+	if err := s.resolveCategory(ctx, product); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, product); err != nil {
+		return nil, err
+	}
+	if !samePrices(&previous, product) {
+		if err := s.recordPriceUpdate(ctx, &previous, product); err != nil {
+			return nil, err
+		}
+	}
+	s.watchStock(ctx, &previous, product)
+	return product, nil
+}
+
+// recordPrices adds the current prices of new products to their price history, effective now
+func (s *productService) recordPrices(ctx context.Context, products ...*Product) error {
+	now := time.Now().UTC()
+	changes := make([]*PriceChange, len(products))
+	for i, product := range products {
+		changes[i] = newPriceChange(product, now, now)
+	}
+	if err := s.repo.CreatePriceChanges(ctx, changes); err != nil {
+		return fmt.Errorf("failed to record price history: %w", err)
+	}
+	return nil
+}
+
+// recordPriceUpdate adds the new prices of an updated product to its price history, effective now
+func (s *productService) recordPriceUpdate(ctx context.Context, previous, product *Product) error {
+	now := time.Now().UTC()
+	changes, err := s.startHistory(ctx, previous, now)
+	if err != nil {
+		return err
+	}
+	changes = append(changes, newPriceChange(product, now, now))
+	if err := s.repo.CreatePriceChanges(ctx, changes); err != nil {
+		return fmt.Errorf("failed to record price history: %w", err)
+	}
+	return nil
+}
+
+// startHistory returns the prices of a product created before price history was recorded,
+// effective from its creation, as the first entry of its history; it returns nil for products with a history
+func (s *productService) startHistory(ctx context.Context, product *Product, now time.Time) ([]*PriceChange, error) {
+	history, err := s.repo.GetPriceChanges(ctx, product.ProductID)
+	if err != nil || len(history) > 0 {
+		return nil, err
+	}
+	createdAt, err := time.Parse(time.RFC3339, product.CreatedAt)
+	if err != nil {
+		createdAt = now
+	}
+	return []*PriceChange{newPriceChange(product, createdAt.UTC(), now)}, nil
+}
+
+// newPriceChange builds an applied history entry with the current prices of a product
+func newPriceChange(product *Product, effectiveFrom, now time.Time) *PriceChange {
+	return &PriceChange{
+		ProductID:     product.ProductID,
+		Price:         product.Price,
+		Prices:        product.Prices,
+		EffectiveFrom: effectiveFrom,
+		Applied:       true,
+		CreatedAt:     now,
+	}
+}
+
+// samePrices reports whether two versions of a product have the same base and explicit prices
+func samePrices(a, b *Product) bool {
+	if a.Price != b.Price || len(a.Prices) != len(b.Prices) {
+		return false
+	}
+	for i := range a.Prices {
+		if a.Prices[i] != b.Prices[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// priceAt returns the last change of a price history that took effect by at, or nil if none did
+func priceAt(history []PriceChange, at time.Time) *PriceChange {
+	var current *PriceChange
+	for i := range history {
+		if history[i].EffectiveFrom.After(at) {
+			break
+		}
+		current = &history[i]
+	}
+	return current
+}
+
+// GetProductDetails retrieves a product with its rating
+func (s *productService) GetProductDetails(ctx context.Context, productID uint64) (*Product, error) {
+	product, err := s.GetProductByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Rating, err = s.ratings.GetRating(ctx, productID); err != nil {
+		return nil, fmt.Errorf("failed to get product rating: %w", err)
+	}
+	return product, nil
+}
+
+// GetProductAt retrieves a product with the prices of its price history at a time
+// Products without a recorded history keep their stored prices
+func (s *productService) GetProductAt(ctx context.Context, productID uint64, at time.Time) (*Product, error) {
+	product, err := s.GetProductByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := s.repo.GetPriceChanges(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if change := priceAt(history, at); change != nil {
+		product.Price = change.Price
+		product.Prices = change.Prices
+	}
+	return product, nil
+}
+
+// GetPriceHistory retrieves all price changes of a product, including scheduled ones
+func (s *productService) GetPriceHistory(ctx context.Context, productID uint64) ([]PriceChange, error) {
+	if _, err := s.GetProductByID(ctx, productID); err != nil {
+		return nil, err
+	}
+	history, err := s.repo.GetPriceChanges(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []PriceChange{}
+	}
+	return history, nil
+}
+
+// SchedulePrice validates new prices like a product update and schedules them
+func (s *productService) SchedulePrice(ctx context.Context, productID uint64, req SchedulePriceRequest) (*PriceChange, error) {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if req.EffectiveFrom.IsZero() {
+		return nil, fmt.Errorf("effective_from is required")
+	}
+	if !req.EffectiveFrom.After(now) {
+		return nil, fmt.Errorf("effective_from must be in the future")
+	}
+	scheduled := &Product{Name: product.Name, Price: req.Price, Prices: req.Prices}
+	if err := validateProduct(scheduled); err != nil {
+		return nil, err
+	}
+
+	changes, err := s.startHistory(ctx, product, now)
+	if err != nil {
+		return nil, err
+	}
+	change := &PriceChange{
+		ProductID:     productID,
+		Price:         scheduled.Price,
+		Prices:        scheduled.Prices,
+		EffectiveFrom: req.EffectiveFrom.UTC(),
+		CreatedAt:     now,
+	}
+	changes = append(changes, change)
+	if err := s.repo.CreatePriceChanges(ctx, changes); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// CancelScheduledPrice removes a price change that has not taken effect yet
+func (s *productService) CancelScheduledPrice(ctx context.Context, productID, priceChangeID uint64) error {
+	history, err := s.repo.GetPriceChanges(ctx, productID)
+	if err != nil {
+		return err
+	}
+	for _, change := range history {
+		if change.PriceChangeID != priceChangeID {
+			continue
+		}
+		if change.Applied || !change.EffectiveFrom.After(time.Now()) {
+			return fmt.Errorf("price change must not have taken effect to be cancelled")
+		}
+		return s.repo.DeletePriceChange(ctx, priceChangeID)
+	}
+	return fmt.Errorf("price change not found")
+}
+
+// ApplyScheduledPrices sets each product with due scheduled prices to the prices of its history now
+// The whole history is resolved, so a later direct price change wins over an earlier schedule
+// It returns the number of products updated
+func (s *productService) ApplyScheduledPrices(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	due, err := s.repo.GetDuePriceChanges(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	applied := 0
+	done := map[uint64]bool{}
+	for _, change := range due {
+		if done[change.ProductID] {
+			continue
+		}
+		done[change.ProductID] = true
+		history, err := s.repo.GetPriceChanges(ctx, change.ProductID)
+		if err != nil {
+			return applied, err
+		}
+		current := priceAt(history, now)
+		if current == nil {
+			continue
+		}
+		if err := s.repo.ApplyPriceChange(ctx, current, now); err != nil {
+			return applied, fmt.Errorf("failed to apply prices of product %d: %w", change.ProductID, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// DeleteProduct archives a product
+// The row is kept so orders and invoices that reference the product stay valid
+func (s *productService) DeleteProduct(ctx context.Context, productID uint64) error {
+	if _, err := s.getActiveProduct(ctx, productID); err != nil {
+		return err
+	}
+	return s.repo.Archive(ctx, productID)
+}
+
+// GetVariant retrieves a variant by ID, including archived variants
+func (s *productService) GetVariant(ctx context.Context, variantID uint64) (*Variant, error) {
+	if variantID == 0 {
+		return nil, fmt.Errorf("valid variant ID is required")
+	}
+	return s.repo.GetVariant(ctx, variantID)
+}
+
+// AddVariant adds a variant to a product
+func (s *productService) AddVariant(ctx context.Context, productID uint64, req VariantRequest) (*Variant, error) {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	variant := newVariant(req, time.Now().Format(time.RFC3339))
+	variant.ProductID = productID
+	if err := s.validateVariant(ctx, variant); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateVariant(ctx, variant); err != nil {
+		return nil, err
+	}
+	s.watchVariantStock(ctx, product)
+	return variant, nil
+}
+
+// UpdateVariant replaces the SKU, attributes, price and stock of a variant
+func (s *productService) UpdateVariant(ctx context.Context, productID, variantID uint64, req VariantRequest) (*Variant, error) {
+	product, existing, err := s.getActiveVariant(ctx, productID, variantID)
+	if err != nil {
+		return nil, err
+	}
+	variant := newVariant(req, existing.CreatedAt)
+	variant.VariantID = existing.VariantID
+	variant.ProductID = existing.ProductID
+	if err := s.validateVariant(ctx, variant); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateVariant(ctx, variant); err != nil {
+		return nil, err
+	}
+	s.watchVariantStock(ctx, product)
+	return variant, nil
+}
+
+// DeleteVariant archives a variant so order items that reference it stay valid
+// Archiving the last out of stock variants can bring a product back in stock
+func (s *productService) DeleteVariant(ctx context.Context, productID, variantID uint64) error {
+	product, _, err := s.getActiveVariant(ctx, productID, variantID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.ArchiveVariant(ctx, variantID); err != nil {
+		return err
+	}
+	s.watchVariantStock(ctx, product)
+	return nil
+}
+
+// AddImage stores an image and its thumbnail, then records them as the product's last image
+func (s *productService) AddImage(ctx context.Context, productID uint64, data []byte) (*Image, error) {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	processed, err := processImage(data)
+	if err != nil {
+		return nil, err
+	}
+	token, err := newImageToken()
+	if err != nil {
+		return nil, err
+	}
+
+	image := &Image{
+		ProductID:    productID,
+		ContentType:  processed.ContentType,
+		Size:         int64(len(data)),
+		Width:        processed.Width,
+		Height:       processed.Height,
+		Position:     len(product.Images),
+		CreatedAt:    time.Now().Format(time.RFC3339),
+		Key:          fmt.Sprintf("products/%d/%s%s", productID, token, processed.Extension),
+		ThumbnailKey: fmt.Sprintf("products/%d/%s_thumb%s", productID, token, processed.ThumbnailExtension),
+	}
+	if err := s.images.Put(ctx, image.Key, bytes.NewReader(data), image.Size, image.ContentType); err != nil {
+		return nil, fmt.Errorf("failed to store image: %w", err)
+	}
+	thumbnailSize := int64(len(processed.Thumbnail))
+	if err := s.images.Put(ctx, image.ThumbnailKey, bytes.NewReader(processed.Thumbnail), thumbnailSize, processed.ThumbnailContentType); err != nil {
+		s.deleteImageFiles(ctx, image.Key)
+		return nil, fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+	if err := s.repo.CreateImage(ctx, image); err != nil {
+		s.deleteImageFiles(ctx, image.Key, image.ThumbnailKey)
+		return nil, err
+	}
+	s.setImageURL(image)
+	return image, nil
+}
+
+// DeleteImage removes an image from a product and deletes its files
+func (s *productService) DeleteImage(ctx context.Context, productID, imageID uint64) error {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return err
+	}
+	for _, image := range product.Images {
+		if image.ImageID != imageID {
+			continue
+		}
+		if err := s.repo.DeleteImage(ctx, imageID); err != nil {
+			return err
+		}
+		s.deleteImageFiles(ctx, image.Key, image.ThumbnailKey)
+		return nil
+	}
+	return fmt.Errorf("image not found")
+}
+
+// deleteImageFiles removes stored files; a failure only leaves an unused file behind, so it is logged
+func (s *productService) deleteImageFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := s.images.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete image file %s: %v", key, err)
+		}
+	}
+}
+
+// newVariant builds a variant from a request; variants are in stock unless stated otherwise
+func newVariant(req VariantRequest, createdAt string) *Variant {
+	variant := &Variant{
+		SKU:        strings.TrimSpace(req.SKU),
+		Attributes: req.Attributes,
+		Price:      req.Price,
+		InStock:    true,
+		CreatedAt:  createdAt,
+	}
+	if req.InStock != nil {
+		variant.InStock = *req.InStock
+	}
+	return variant
+}
+
+// validateVariant checks a variant's fields and that its SKU is not used by another variant
+func (s *productService) validateVariant(ctx context.Context, variant *Variant) error {
+	if variant.SKU == "" {
+		return fmt.Errorf("variant SKU is required")
+	}
+	if variant.Price != nil {
+		if variant.Price.IsNegative() {
+			return fmt.Errorf("variant price must be non-negative")
+		}
+		if variant.Price.Currency == "" {
+			variant.Price.Currency = money.DefaultCurrency
+		}
+	}
+	existing, err := s.repo.GetVariantBySKU(ctx, variant.SKU)
+	if err != nil && err.Error() != "variant not found" {
+		return err
+	}
+	if existing != nil && existing.VariantID != variant.VariantID {
+		return fmt.Errorf("variant with SKU '%s' already exists", variant.SKU)
+	}
+	return nil
+}
+
+// getActiveVariant retrieves an active product and one of its variants that has not been archived
+func (s *productService) getActiveVariant(ctx context.Context, productID, variantID uint64) (*Product, *Variant, error) {
+	product, err := s.getActiveProduct(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	variant, ok := product.Variant(variantID)
+	if !ok {
+		return nil, nil, fmt.Errorf("variant not found")
+	}
+	return product, variant, nil
+}
+
+// getActiveProduct retrieves a product that has not been archived
+func (s *productService) getActiveProduct(ctx context.Context, productID uint64) (*Product, error) {
+	product, err := s.GetProductByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if product.Archived {
+		return nil, fmt.Errorf("product not found")
+	}
+	return product, nil
 }
 
 // InitializeSampleProducts creates sample products if none exist
 func (s *productService) InitializeSampleProducts(ctx context.Context) error {
 	return s.repo.SeedSampleProducts(ctx)
+}
+
+// MigrateCategories finds or creates a category for each free-text category and assigns its products to it
+// Names that only differ in case or punctuation share a slug, so their products end up in one category
+// It returns the number of free-text categories migrated
+func (s *productService) MigrateCategories(ctx context.Context) (int, error) {
+	names, err := s.repo.GetUncategorized(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, name := range names {
+		found, err := s.categories.EnsureCategory(ctx, name)
+		if err != nil {
+			return i, fmt.Errorf("failed to migrate category '%s': %w", name, err)
+		}
+		if err := s.repo.AssignCategory(ctx, name, found.CategoryID, found.Name); err != nil {
+			return i, fmt.Errorf("failed to migrate category '%s': %w", name, err)
+		}
+	}
+	return len(names), nil
 }
\ No newline at end of file
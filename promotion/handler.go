@@ -0,0 +1,132 @@
+package promotion
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+)
+
+// Handler handles HTTP requests for promotion administration
+type Handler struct {
+	service PromotionService
+	admins  auth.AdminList
+}
+
+// NewHandler creates a new promotion handler
+func NewHandler(service PromotionService, admins auth.AdminList) *Handler {
+	return &Handler{
+		service: service,
+		admins:  admins,
+	}
+}
+
+// RegisterRoutes registers all promotion routes (admin only)
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	mux.Handle("POST /admin/promotions", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleCreatePromotion())))
+	mux.Handle("GET /admin/promotions", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleListPromotions())))
+	mux.Handle("GET /admin/promotions/{id}", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleGetPromotion())))
+	mux.Handle("PUT /admin/promotions/{id}", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleUpdatePromotion())))
+	mux.Handle("DELETE /admin/promotions/{id}", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleDeletePromotion())))
+}
+
+// handleCreatePromotion handles POST /admin/promotions
+func (h *Handler) handleCreatePromotion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PromotionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		promotion, err := h.service.CreatePromotion(r.Context(), req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create promotion")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, promotion)
+	}
+}
+
+// handleListPromotions handles GET /admin/promotions
+func (h *Handler) handleListPromotions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		promotions, err := h.service.ListPromotions(r.Context())
+		if err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch promotions")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"promotions": promotions,
+			"count":      len(promotions),
+		})
+	}
+}
+
+// handleGetPromotion handles GET /admin/promotions/{id}
+func (h *Handler) handleGetPromotion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		promotionID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid promotion ID")
+			return
+		}
+
+		promotion, err := h.service.GetPromotion(r.Context(), promotionID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to fetch promotion")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, promotion)
+	}
+}
+
+// handleUpdatePromotion handles PUT /admin/promotions/{id}
+func (h *Handler) handleUpdatePromotion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		promotionID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid promotion ID")
+			return
+		}
+
+		var req PromotionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		promotion, err := h.service.UpdatePromotion(r.Context(), promotionID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to update promotion")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, promotion)
+	}
+}
+
+// handleDeletePromotion handles DELETE /admin/promotions/{id}
+func (h *Handler) handleDeletePromotion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		promotionID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid promotion ID")
+			return
+		}
+
+		if err := h.service.DeletePromotion(r.Context(), promotionID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to delete promotion")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Promotion deleted successfully",
+		})
+	}
+}
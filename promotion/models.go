@@ -0,0 +1,150 @@
+package promotion // Package promotion manages coupons and automatic promotions and computes order discounts.
+
+import (
+	"context"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// Promotion types
+const (
+	TypePercentage = "percentage"  // Percent off eligible lines
+	TypeFixed      = "fixed"       // Fixed amount off eligible lines, split pro rata
+	TypeBuyXGetY   = "buy_x_get_y" // For every BuyQuantity units bought, GetQuantity more are free
+)
+
+// Promotion is a discount rule
+// Promotions with a Code are coupons that customers must enter; promotions without one apply automatically
+type Promotion struct {
+	PromotionID  uint64       `json:"promotion_id"`
+	Code         string       `json:"code,omitempty"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description,omitempty"`
+	Type         string       `json:"type"`
+	Percent      money.Rate   `json:"percent,omitempty"`      // percentage: 0.15 for 15% off
+	Amount       *money.Money `json:"amount,omitempty"`       // fixed: amount off the order
+	MinSubtotal  *money.Money `json:"min_subtotal,omitempty"` // minimum eligible amount before the promotion applies
+	Category     string       `json:"category,omitempty"`     // restricts the promotion to a product category
+	ProductID    uint64       `json:"product_id,omitempty"`   // restricts the promotion to a single product
+	BuyQuantity  int          `json:"buy_quantity,omitempty"`
+	GetQuantity  int          `json:"get_quantity,omitempty"`
+	StartsAt     *time.Time   `json:"starts_at,omitempty"`
+	EndsAt       *time.Time   `json:"ends_at,omitempty"`
+	UsageLimit   int          `json:"usage_limit,omitempty"`    // total redemptions allowed, 0 for unlimited
+	PerUserLimit int          `json:"per_user_limit,omitempty"` // redemptions allowed per user, 0 for unlimited
+	Active       bool         `json:"active"`
+	CreatedAt    string       `json:"created_at"`
+}
+
+// currency returns the currency of the promotion's amounts, empty when it has none
+func (p *Promotion) currency() string {
+	if p.Amount != nil {
+		return p.Amount.Currency
+	}
+	if p.MinSubtotal != nil {
+		return p.MinSubtotal.Currency
+	}
+	return ""
+}
+
+// Discount is a promotion applied to an order
+type Discount struct {
+	PromotionID uint64      `json:"promotion_id"`
+	Code        string      `json:"code,omitempty"`
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Amount      money.Money `json:"amount"`
+}
+
+// Repository defines the interface for promotion data operations
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *Promotion) error
+	Update(ctx context.Context, promotion *Promotion) error
+	Delete(ctx context.Context, promotionID uint64) error
+	GetByID(ctx context.Context, promotionID uint64) (*Promotion, error)
+	GetByCode(ctx context.Context, code string) (*Promotion, error)
+	GetAll(ctx context.Context) ([]Promotion, error)
+	// GetAutomatic returns the active promotions that have no coupon code
+	GetAutomatic(ctx context.Context) ([]Promotion, error)
+	// Redeem records one use of a promotion by a user, enforcing its usage limits
+	Redeem(ctx context.Context, promotionID, userID uint64) (uint64, error)
+	DeleteRedemption(ctx context.Context, redemptionID uint64) error
+	CountRedemptions(ctx context.Context, promotionID, userID uint64) (total int, byUser int, err error)
+}
+
+// PromotionRequest represents the request to create or update a promotion
+type PromotionRequest struct {
+	Code         string       `json:"code"`
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Type         string       `json:"type"`
+	Percent      money.Rate   `json:"percent"`
+	Amount       *money.Money `json:"amount"`
+	MinSubtotal  *money.Money `json:"min_subtotal"`
+	Category     string       `json:"category"`
+	ProductID    uint64       `json:"product_id"`
+	BuyQuantity  int          `json:"buy_quantity"`
+	GetQuantity  int          `json:"get_quantity"`
+	StartsAt     *time.Time   `json:"starts_at"`
+	EndsAt       *time.Time   `json:"ends_at"`
+	UsageLimit   int          `json:"usage_limit"`
+	PerUserLimit int          `json:"per_user_limit"`
+	Active       *bool        `json:"active"` // defaults to true
+}
+
+// Line is an order line that promotions may discount
+type Line struct {
+	ProductID uint64
+	Category  string
+	Quantity  int
+	UnitPrice money.Money
+	Amount    money.Money // UnitPrice * Quantity
+}
+
+// ApplyRequest describes an order to apply promotions to
+type ApplyRequest struct {
+	UserID   uint64
+	Currency string
+	Codes    []string
+	Lines    []Line
+	At       time.Time
+}
+
+// Result is the outcome of applying promotions
+// LineDiscounts holds the total discount of each line, in the same order as the request lines
+type Result struct {
+	Discounts     []Discount
+	LineDiscounts []money.Money
+	Total         money.Money
+}
+
+// amounts returns the amount columns of a promotion; unset amounts are stored as zero
+func amounts(p *Promotion) (amount, minSubtotal money.Money) {
+	currency := p.currency()
+	amount, minSubtotal = money.Zero(currency), money.Zero(currency)
+	if p.Amount != nil {
+		amount = *p.Amount
+	}
+	if p.MinSubtotal != nil {
+		minSubtotal = *p.MinSubtotal
+	}
+	return amount, minSubtotal
+}
+
+// setAmounts restores the percent and amounts of a scanned promotion
+func setAmounts(p *Promotion, percent string, amount, minSubtotal money.Money, currency string) error {
+	rate, err := money.ParseRate(percent)
+	if err != nil {
+		return err
+	}
+	p.Percent = rate
+	money.WithCurrency(currency, &amount, &minSubtotal)
+	if p.Type == TypeFixed {
+		p.Amount = &amount
+	}
+	if !minSubtotal.IsZero() {
+		p.MinSubtotal = &minSubtotal
+	}
+	return nil
+}
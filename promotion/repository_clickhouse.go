@@ -0,0 +1,230 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// ClickHouseRepository implements PromotionRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db  *sql.DB
+	ids idgen.Generator
+}
+
+// NewClickHouseRepository creates a new ClickHouse promotion repository
+// ClickHouse has no auto-increment, so IDs come from the shared ID generator
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) PromotionRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
+}
+
+// clickHousePromotionColumns lists the promotion columns in scan order
+const clickHousePromotionColumns = `promotion_id, code, name, description, type, toString(percent), amount, min_subtotal, currency,
+	category, product_id, buy_quantity, get_quantity, starts_at, ends_at, usage_limit, per_user_limit, active, created_at`
+
+// ensurePromotionTables creates the promotions and promotion_redemptions tables if they don't exist
+func (r *ClickHouseRepository) ensurePromotionTables(ctx context.Context) error {
+	queries := []string{`
+		CREATE TABLE IF NOT EXISTS promotions (
+			promotion_id UInt64,
+			code String,
+			name String,
+			description String,
+			type String,
+			percent Decimal(9, 6),
+			amount Decimal(18, 2),
+			min_subtotal Decimal(18, 2),
+			currency String,
+			category String,
+			product_id UInt64,
+			buy_quantity Int32,
+			get_quantity Int32,
+			starts_at Nullable(DateTime64(3)),
+			ends_at Nullable(DateTime64(3)),
+			usage_limit Int32,
+			per_user_limit Int32,
+			active Bool,
+			created_at String
+		) ENGINE = MergeTree()
+		ORDER BY promotion_id
+	`, `
+		CREATE TABLE IF NOT EXISTS promotion_redemptions (
+			redemption_id UInt64,
+			promotion_id UInt64,
+			user_id UInt64,
+			redeemed_at DateTime64(3)
+		) ENGINE = MergeTree()
+		ORDER BY (promotion_id, redemption_id)
+	`}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ClickHouseRepository) Create(ctx context.Context, promotion *Promotion) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	promotion.PromotionID = r.ids.NextID()
+	query := `
+		INSERT INTO promotions (promotion_id, code, name, description, type, percent, amount, min_subtotal, currency, category, product_id,
+			buy_quantity, get_quantity, starts_at, ends_at, usage_limit, per_user_limit, active, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	amount, minSubtotal := amounts(promotion)
+	_, err := r.db.ExecContext(ctx, query,
+		promotion.PromotionID, promotion.Code, promotion.Name, promotion.Description, promotion.Type, promotion.Percent.String(),
+		amount, minSubtotal, promotion.currency(), promotion.Category, promotion.ProductID,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.StartsAt, promotion.EndsAt,
+		promotion.UsageLimit, promotion.PerUserLimit, promotion.Active, promotion.CreatedAt,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) Update(ctx context.Context, promotion *Promotion) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		ALTER TABLE promotions UPDATE code = ?, name = ?, description = ?, type = ?, percent = toDecimal32(?, 6), amount = toDecimal64(?, 2),
+			min_subtotal = toDecimal64(?, 2), currency = ?, category = ?, product_id = ?, buy_quantity = ?, get_quantity = ?,
+			starts_at = ?, ends_at = ?, usage_limit = ?, per_user_limit = ?, active = ?
+		WHERE promotion_id = ?`
+	amount, minSubtotal := amounts(promotion)
+	_, err := r.db.ExecContext(ctx, query,
+		promotion.Code, promotion.Name, promotion.Description, promotion.Type, promotion.Percent.String(),
+		amount.String(), minSubtotal.String(), promotion.currency(), promotion.Category, promotion.ProductID,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.StartsAt, promotion.EndsAt,
+		promotion.UsageLimit, promotion.PerUserLimit, promotion.Active, promotion.PromotionID,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) Delete(ctx context.Context, promotionID uint64) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE promotions DELETE WHERE promotion_id = ?", promotionID)
+	return err
+}
+
+func (r *ClickHouseRepository) GetByID(ctx context.Context, promotionID uint64) (*Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHousePromotionColumns + " FROM promotions WHERE promotion_id = ? LIMIT 1"
+	return r.scanPromotion(r.db.QueryRowContext(ctx, query, promotionID))
+}
+
+func (r *ClickHouseRepository) GetByCode(ctx context.Context, code string) (*Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHousePromotionColumns + " FROM promotions WHERE code = ? AND code != '' LIMIT 1"
+	return r.scanPromotion(r.db.QueryRowContext(ctx, query, code))
+}
+
+func (r *ClickHouseRepository) GetAll(ctx context.Context) ([]Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryPromotions(ctx, "SELECT "+clickHousePromotionColumns+" FROM promotions ORDER BY promotion_id")
+}
+
+func (r *ClickHouseRepository) GetAutomatic(ctx context.Context) ([]Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryPromotions(ctx, "SELECT "+clickHousePromotionColumns+" FROM promotions WHERE code = '' AND active ORDER BY promotion_id")
+}
+
+// Redeem checks the usage limits and records a redemption
+// ClickHouse has no row locks, so two checkouts racing for the last use may both succeed
+func (r *ClickHouseRepository) Redeem(ctx context.Context, promotionID, userID uint64) (uint64, error) {
+	promotion, err := r.GetByID(ctx, promotionID)
+	if err != nil {
+		return 0, err
+	}
+	total, byUser, err := r.CountRedemptions(ctx, promotionID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if (promotion.UsageLimit > 0 && total >= promotion.UsageLimit) || (promotion.PerUserLimit > 0 && byUser >= promotion.PerUserLimit) {
+		return 0, fmt.Errorf("usage limit reached")
+	}
+
+	redemptionID := r.ids.NextID()
+	query := "INSERT INTO promotion_redemptions (redemption_id, promotion_id, user_id, redeemed_at) VALUES (?, ?, ?, ?)"
+	if _, err := r.db.ExecContext(ctx, query, redemptionID, promotionID, userID, time.Now().UTC()); err != nil {
+		return 0, err
+	}
+	return redemptionID, nil
+}
+
+func (r *ClickHouseRepository) DeleteRedemption(ctx context.Context, redemptionID uint64) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE promotion_redemptions DELETE WHERE redemption_id = ?", redemptionID)
+	return err
+}
+
+func (r *ClickHouseRepository) CountRedemptions(ctx context.Context, promotionID, userID uint64) (int, int, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return 0, 0, err
+	}
+	var total, byUser uint64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT count(), countIf(user_id = ?)
+		FROM promotion_redemptions WHERE promotion_id = ?`, userID, promotionID).Scan(&total, &byUser)
+	return int(total), int(byUser), err
+}
+
+// queryPromotions runs a query returning promotion rows
+func (r *ClickHouseRepository) queryPromotions(ctx context.Context, query string, args ...interface{}) ([]Promotion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []Promotion
+	for rows.Next() {
+		promotion, err := r.scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *promotion)
+	}
+	return promotions, nil
+}
+
+// scanPromotion scans a promotion from a *sql.Row or *sql.Rows
+func (r *ClickHouseRepository) scanPromotion(row interface{ Scan(...interface{}) error }) (*Promotion, error) {
+	var p Promotion
+	var percent, currency string
+	var amount, minSubtotal money.Money
+	var buyQuantity, getQuantity, usageLimit, perUserLimit int32
+	err := row.Scan(
+		&p.PromotionID, &p.Code, &p.Name, &p.Description, &p.Type, &percent, &amount, &minSubtotal, &currency,
+		&p.Category, &p.ProductID, &buyQuantity, &getQuantity, &p.StartsAt, &p.EndsAt, &usageLimit, &perUserLimit, &p.Active, &p.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		return nil, err
+	}
+	p.BuyQuantity, p.GetQuantity = int(buyQuantity), int(getQuantity)
+	p.UsageLimit, p.PerUserLimit = int(usageLimit), int(perUserLimit)
+	if err := setAmounts(&p, percent, amount, minSubtotal, currency); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
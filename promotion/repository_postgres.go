@@ -0,0 +1,250 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// PostgresRepository implements PromotionRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL promotion repository
+func NewPostgresRepository(db *sql.DB) PromotionRepository {
+	return &PostgresRepository{db: db}
+}
+
+// promotionColumns lists the promotion columns in scan order
+const promotionColumns = `promotion_id, code, name, description, type, percent::text, amount, min_subtotal, currency,
+	category, product_id, buy_quantity, get_quantity, starts_at, ends_at, usage_limit, per_user_limit, active, created_at`
+
+// ensurePromotionTables creates the promotions and promotion_redemptions tables if they don't exist
+func (r *PostgresRepository) ensurePromotionTables(ctx context.Context) error {
+	queries := []string{`
+		CREATE TABLE IF NOT EXISTS promotions (
+			promotion_id SERIAL PRIMARY KEY,
+			code TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			type TEXT NOT NULL,
+			percent NUMERIC(9,6) NOT NULL DEFAULT 0,
+			amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+			min_subtotal DECIMAL(10,2) NOT NULL DEFAULT 0,
+			currency TEXT NOT NULL DEFAULT '',
+			category TEXT NOT NULL DEFAULT '',
+			product_id BIGINT NOT NULL DEFAULT 0,
+			buy_quantity INT NOT NULL DEFAULT 0,
+			get_quantity INT NOT NULL DEFAULT 0,
+			starts_at TIMESTAMPTZ,
+			ends_at TIMESTAMPTZ,
+			usage_limit INT NOT NULL DEFAULT 0,
+			per_user_limit INT NOT NULL DEFAULT 0,
+			active BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		// Coupon codes are unique; automatic promotions have no code
+		"CREATE UNIQUE INDEX IF NOT EXISTS promotions_code_key ON promotions (code) WHERE code <> ''",
+		`
+		CREATE TABLE IF NOT EXISTS promotion_redemptions (
+			redemption_id SERIAL PRIMARY KEY,
+			promotion_id BIGINT NOT NULL REFERENCES promotions(promotion_id) ON DELETE CASCADE,
+			user_id BIGINT NOT NULL,
+			redeemed_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Create(ctx context.Context, promotion *Promotion) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO promotions (code, name, description, type, percent, amount, min_subtotal, currency, category, product_id,
+			buy_quantity, get_quantity, starts_at, ends_at, usage_limit, per_user_limit, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18) RETURNING promotion_id`
+	amount, minSubtotal := amounts(promotion)
+	return r.db.QueryRowContext(ctx, query,
+		promotion.Code, promotion.Name, promotion.Description, promotion.Type, promotion.Percent.String(),
+		amount, minSubtotal, promotion.currency(), promotion.Category, promotion.ProductID,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.StartsAt, promotion.EndsAt,
+		promotion.UsageLimit, promotion.PerUserLimit, promotion.Active, promotion.CreatedAt,
+	).Scan(&promotion.PromotionID)
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, promotion *Promotion) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		UPDATE promotions SET code = $1, name = $2, description = $3, type = $4, percent = $5, amount = $6, min_subtotal = $7,
+			currency = $8, category = $9, product_id = $10, buy_quantity = $11, get_quantity = $12, starts_at = $13, ends_at = $14,
+			usage_limit = $15, per_user_limit = $16, active = $17
+		WHERE promotion_id = $18`
+	amount, minSubtotal := amounts(promotion)
+	_, err := r.db.ExecContext(ctx, query,
+		promotion.Code, promotion.Name, promotion.Description, promotion.Type, promotion.Percent.String(),
+		amount, minSubtotal, promotion.currency(), promotion.Category, promotion.ProductID,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.StartsAt, promotion.EndsAt,
+		promotion.UsageLimit, promotion.PerUserLimit, promotion.Active, promotion.PromotionID,
+	)
+	return err
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, promotionID uint64) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "DELETE FROM promotions WHERE promotion_id = $1", promotionID)
+	return err
+}
+
+func (r *PostgresRepository) GetByID(ctx context.Context, promotionID uint64) (*Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE promotion_id = $1"
+	return r.scanPromotion(r.db.QueryRowContext(ctx, query, promotionID))
+}
+
+func (r *PostgresRepository) GetByCode(ctx context.Context, code string) (*Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + promotionColumns + " FROM promotions WHERE code = $1 AND code <> ''"
+	return r.scanPromotion(r.db.QueryRowContext(ctx, query, code))
+}
+
+func (r *PostgresRepository) GetAll(ctx context.Context) ([]Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryPromotions(ctx, "SELECT "+promotionColumns+" FROM promotions ORDER BY promotion_id")
+}
+
+func (r *PostgresRepository) GetAutomatic(ctx context.Context) ([]Promotion, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return nil, err
+	}
+	return r.queryPromotions(ctx, "SELECT "+promotionColumns+" FROM promotions WHERE code = '' AND active ORDER BY promotion_id")
+}
+
+// Redeem locks the promotion row so concurrent checkouts cannot exceed its usage limits
+func (r *PostgresRepository) Redeem(ctx context.Context, promotionID, userID uint64) (uint64, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var usageLimit, perUserLimit int
+	err = tx.QueryRowContext(ctx, "SELECT usage_limit, per_user_limit FROM promotions WHERE promotion_id = $1 FOR UPDATE", promotionID).
+		Scan(&usageLimit, &perUserLimit)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("promotion not found")
+		}
+		return 0, err
+	}
+
+	var total, byUser int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+		FROM promotion_redemptions WHERE promotion_id = $1`, promotionID, userID).Scan(&total, &byUser)
+	if err != nil {
+		return 0, err
+	}
+	if (usageLimit > 0 && total >= usageLimit) || (perUserLimit > 0 && byUser >= perUserLimit) {
+		return 0, fmt.Errorf("usage limit reached")
+	}
+
+	var redemptionID uint64
+	err = tx.QueryRowContext(ctx, "INSERT INTO promotion_redemptions (promotion_id, user_id) VALUES ($1, $2) RETURNING redemption_id", promotionID, userID).
+		Scan(&redemptionID)
+	if err != nil {
+		return 0, err
+	}
+	return redemptionID, tx.Commit()
+}
+
+func (r *PostgresRepository) DeleteRedemption(ctx context.Context, redemptionID uint64) error {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "DELETE FROM promotion_redemptions WHERE redemption_id = $1", redemptionID)
+	return err
+}
+
+func (r *PostgresRepository) CountRedemptions(ctx context.Context, promotionID, userID uint64) (int, int, error) {
+	if err := r.ensurePromotionTables(ctx); err != nil {
+		return 0, 0, err
+	}
+	var total, byUser int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE user_id = $2)
+		FROM promotion_redemptions WHERE promotion_id = $1`, promotionID, userID).Scan(&total, &byUser)
+	return total, byUser, err
+}
+
+// queryPromotions runs a query returning promotion rows
+func (r *PostgresRepository) queryPromotions(ctx context.Context, query string, args ...interface{}) ([]Promotion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promotions []Promotion
+	for rows.Next() {
+		promotion, err := r.scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *promotion)
+	}
+	return promotions, nil
+}
+
+// scanPromotion scans a promotion from a *sql.Row or *sql.Rows
+func (r *PostgresRepository) scanPromotion(row interface{ Scan(...interface{}) error }) (*Promotion, error) {
+	var p Promotion
+	var percent, currency string
+	var amount, minSubtotal money.Money
+	var startsAt, endsAt sql.NullTime
+	var createdAt time.Time
+	err := row.Scan(
+		&p.PromotionID, &p.Code, &p.Name, &p.Description, &p.Type, &percent, &amount, &minSubtotal, &currency,
+		&p.Category, &p.ProductID, &p.BuyQuantity, &p.GetQuantity, &startsAt, &endsAt, &p.UsageLimit, &p.PerUserLimit, &p.Active, &createdAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("promotion not found")
+		}
+		return nil, err
+	}
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	p.CreatedAt = createdAt.Format(time.RFC3339)
+	if err := setAmounts(&p, percent, amount, minSubtotal, currency); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,386 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// PromotionService defines the business logic interface for promotions
+type PromotionService interface {
+	CreatePromotion(ctx context.Context, req PromotionRequest) (*Promotion, error)
+	UpdatePromotion(ctx context.Context, promotionID uint64, req PromotionRequest) (*Promotion, error)
+	DeletePromotion(ctx context.Context, promotionID uint64) error
+	GetPromotion(ctx context.Context, promotionID uint64) (*Promotion, error)
+	ListPromotions(ctx context.Context) ([]Promotion, error)
+	// Apply computes the discounts of an order from automatic promotions and the given coupon codes
+	Apply(ctx context.Context, req ApplyRequest) (*Result, error)
+	// Redeem records the use of applied discounts and returns redemption IDs for Release
+	Redeem(ctx context.Context, userID uint64, discounts []Discount) ([]uint64, error)
+	// Release undoes redemptions, e.g. when the order could not be saved
+	Release(ctx context.Context, redemptionIDs []uint64)
+}
+
+// promotionService implements the PromotionService interface
+type promotionService struct {
+	repo PromotionRepository
+}
+
+// NewPromotionService creates a new promotion service
+func NewPromotionService(repo PromotionRepository) PromotionService {
+	return &promotionService{repo: repo}
+}
+
+// CreatePromotion validates and creates a promotion
+func (s *promotionService) CreatePromotion(ctx context.Context, req PromotionRequest) (*Promotion, error) {
+	promotion, err := s.build(ctx, 0, req)
+	if err != nil {
+		return nil, err
+	}
+	promotion.CreatedAt = time.Now().Format(time.RFC3339)
+	if err := s.repo.Create(ctx, promotion); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// UpdatePromotion replaces the settings of an existing promotion
+func (s *promotionService) UpdatePromotion(ctx context.Context, promotionID uint64, req PromotionRequest) (*Promotion, error) {
+	existing, err := s.GetPromotion(ctx, promotionID)
+	if err != nil {
+		return nil, err
+	}
+	promotion, err := s.build(ctx, promotionID, req)
+	if err != nil {
+		return nil, err
+	}
+	promotion.PromotionID = promotionID
+	promotion.CreatedAt = existing.CreatedAt
+	if err := s.repo.Update(ctx, promotion); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// DeletePromotion removes a promotion; orders keep a copy of the discounts they received
+func (s *promotionService) DeletePromotion(ctx context.Context, promotionID uint64) error {
+	if _, err := s.GetPromotion(ctx, promotionID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, promotionID)
+}
+
+// GetPromotion retrieves a promotion by ID
+func (s *promotionService) GetPromotion(ctx context.Context, promotionID uint64) (*Promotion, error) {
+	if promotionID == 0 {
+		return nil, fmt.Errorf("valid promotion ID is required")
+	}
+	return s.repo.GetByID(ctx, promotionID)
+}
+
+// ListPromotions retrieves all promotions
+func (s *promotionService) ListPromotions(ctx context.Context) ([]Promotion, error) {
+	return s.repo.GetAll(ctx)
+}
+
+// build validates a request and turns it into a promotion
+func (s *promotionService) build(ctx context.Context, promotionID uint64, req PromotionRequest) (*Promotion, error) {
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+	if req.Name == "" {
+		return nil, fmt.Errorf("promotion name is required")
+	}
+
+	switch req.Type {
+	case TypePercentage:
+		if req.Percent <= 0 || req.Percent > money.MustParseRate("1") {
+			return nil, fmt.Errorf("percent must be between 0 and 1 for percentage promotions")
+		}
+	case TypeFixed:
+		if req.Amount == nil || req.Amount.IsZero() || req.Amount.IsNegative() {
+			return nil, fmt.Errorf("positive amount is required for fixed promotions")
+		}
+	case TypeBuyXGetY:
+		if req.BuyQuantity <= 0 || req.GetQuantity <= 0 {
+			return nil, fmt.Errorf("positive buy_quantity and get_quantity are required for buy_x_get_y promotions")
+		}
+	default:
+		return nil, fmt.Errorf("promotion type must be one of %s, %s or %s", TypePercentage, TypeFixed, TypeBuyXGetY)
+	}
+	if req.MinSubtotal != nil {
+		if req.MinSubtotal.IsNegative() {
+			return nil, fmt.Errorf("min_subtotal must be non-negative")
+		}
+		if req.Amount != nil && req.Amount.Currency != req.MinSubtotal.Currency {
+			return nil, fmt.Errorf("amount and min_subtotal must be in the same currency")
+		}
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return nil, fmt.Errorf("ends_at must be after starts_at")
+	}
+	if req.UsageLimit < 0 || req.PerUserLimit < 0 {
+		return nil, fmt.Errorf("usage limits must be non-negative")
+	}
+
+	// Coupon codes are unique
+	if code != "" {
+		if existing, err := s.repo.GetByCode(ctx, code); err == nil && existing.PromotionID != promotionID {
+			return nil, fmt.Errorf("coupon code '%s' already exists", code)
+		}
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	promotion := &Promotion{
+		Code:         code,
+		Name:         req.Name,
+		Description:  req.Description,
+		Type:         req.Type,
+		Category:     req.Category,
+		ProductID:    req.ProductID,
+		MinSubtotal:  req.MinSubtotal,
+		StartsAt:     req.StartsAt,
+		EndsAt:       req.EndsAt,
+		UsageLimit:   req.UsageLimit,
+		PerUserLimit: req.PerUserLimit,
+		Active:       active,
+	}
+	// Only keep the settings of the promotion's type
+	switch req.Type {
+	case TypePercentage:
+		promotion.Percent = req.Percent
+	case TypeFixed:
+		promotion.Amount = req.Amount
+	case TypeBuyXGetY:
+		promotion.BuyQuantity = req.BuyQuantity
+		promotion.GetQuantity = req.GetQuantity
+	}
+	return promotion, nil
+}
+
+// Apply computes the discounts of an order
+// Automatic promotions apply first, then coupons in the order given. Each promotion discounts
+// what is left of a line after earlier promotions, so a line never drops below zero.
+// Automatic promotions that don't fit the order are skipped; coupons that don't fit are an error.
+func (s *promotionService) Apply(ctx context.Context, req ApplyRequest) (*Result, error) {
+	if req.At.IsZero() {
+		req.At = time.Now()
+	}
+	result := &Result{
+		LineDiscounts: make([]money.Money, len(req.Lines)),
+		Total:         money.Zero(req.Currency),
+	}
+	for i := range result.LineDiscounts {
+		result.LineDiscounts[i] = money.Zero(req.Currency)
+	}
+
+	automatic, err := s.repo.GetAutomatic(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load promotions: %w", err)
+	}
+	for i := range automatic {
+		// An automatic promotion that doesn't fit the order is simply not applied
+		var skipped *notApplicableError
+		if err := s.applyPromotion(ctx, &automatic[i], req, result); err != nil && !errors.As(err, &skipped) {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, code := range req.Codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+
+		promotion, err := s.repo.GetByCode(ctx, code)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return nil, fmt.Errorf("coupon '%s' not found", code)
+			}
+			return nil, err
+		}
+		if err := s.applyPromotion(ctx, promotion, req, result); err != nil {
+			var invalid *notApplicableError
+			if errors.As(err, &invalid) {
+				return nil, fmt.Errorf("coupon '%s' is not valid: %w", code, err)
+			}
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// notApplicableError tells why a promotion doesn't fit an order, as opposed to a failure to check it
+type notApplicableError struct {
+	reason string
+}
+
+func (e *notApplicableError) Error() string {
+	return e.reason
+}
+
+// notApplicable formats a notApplicableError
+func notApplicable(format string, args ...interface{}) error {
+	return &notApplicableError{reason: fmt.Sprintf(format, args...)}
+}
+
+// applyPromotion checks that a promotion is valid for the order and adds its discount to the result
+// It returns a notApplicableError when the promotion doesn't fit the order, and other errors when it can't be checked
+func (s *promotionService) applyPromotion(ctx context.Context, p *Promotion, req ApplyRequest, result *Result) error {
+	if !p.Active {
+		return notApplicable("promotion is not active")
+	}
+	if p.StartsAt != nil && req.At.Before(*p.StartsAt) {
+		return notApplicable("promotion has not started yet")
+	}
+	if p.EndsAt != nil && !req.At.Before(*p.EndsAt) {
+		return notApplicable("promotion has expired")
+	}
+	if currency := p.currency(); currency != "" && currency != req.Currency {
+		return notApplicable("promotion only applies to orders in %s", currency)
+	}
+	if p.UsageLimit > 0 || p.PerUserLimit > 0 {
+		total, byUser, err := s.repo.CountRedemptions(ctx, p.PromotionID, req.UserID)
+		if err != nil {
+			return fmt.Errorf("failed to count promotion redemptions: %w", err)
+		}
+		if (p.UsageLimit > 0 && total >= p.UsageLimit) || (p.PerUserLimit > 0 && byUser >= p.PerUserLimit) {
+			return notApplicable("usage limit reached")
+		}
+	}
+
+	// Find the lines the promotion applies to and what is left of them
+	remaining := make([]money.Money, len(req.Lines))
+	eligibleTotal := money.Zero(req.Currency)
+	var eligible []int
+	for i, line := range req.Lines {
+		remaining[i] = line.Amount.Sub(result.LineDiscounts[i])
+		if p.Category != "" && !strings.EqualFold(p.Category, line.Category) {
+			continue
+		}
+		if p.ProductID != 0 && p.ProductID != line.ProductID {
+			continue
+		}
+		eligible = append(eligible, i)
+		eligibleTotal = eligibleTotal.Add(remaining[i])
+	}
+	if len(eligible) == 0 || eligibleTotal.IsZero() {
+		return notApplicable("no items in the order qualify")
+	}
+	if p.MinSubtotal != nil {
+		cmp, err := eligibleTotal.CheckedCmp(*p.MinSubtotal)
+		if err != nil {
+			return notApplicable("promotion only applies to orders in %s", p.MinSubtotal.Currency)
+		}
+		if cmp < 0 {
+			return notApplicable("order must be at least %s", p.MinSubtotal.Display())
+		}
+	}
+
+	discounts := make([]money.Money, len(req.Lines))
+	for i := range discounts {
+		discounts[i] = money.Zero(req.Currency)
+	}
+	switch p.Type {
+	case TypePercentage:
+		for _, i := range eligible {
+			discounts[i] = remaining[i].MulRate(p.Percent)
+		}
+	case TypeFixed:
+		shares := allocate(money.Min(*p.Amount, eligibleTotal), eligible, remaining)
+		for _, i := range eligible {
+			discounts[i] = shares[i]
+		}
+	case TypeBuyXGetY:
+		for _, i := range eligible {
+			line := req.Lines[i]
+			free := line.Quantity / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+			discounts[i] = money.Min(line.UnitPrice.Mul(int64(free)), remaining[i])
+		}
+	}
+
+	total := money.Zero(req.Currency)
+	for i := range discounts {
+		total = total.Add(discounts[i])
+	}
+	if total.IsZero() {
+		return notApplicable("no items in the order qualify")
+	}
+	for i := range discounts {
+		result.LineDiscounts[i] = result.LineDiscounts[i].Add(discounts[i])
+	}
+	result.Total = result.Total.Add(total)
+	result.Discounts = append(result.Discounts, Discount{
+		PromotionID: p.PromotionID,
+		Code:        p.Code,
+		Name:        p.Name,
+		Type:        p.Type,
+		Amount:      total,
+	})
+	return nil
+}
+
+// allocate splits an amount over lines in proportion to their weights
+// Shares are rounded down to the minor unit and the leftover units go to the largest remainders,
+// so the shares always add up to the amount exactly
+func allocate(amount money.Money, lines []int, weights []money.Money) map[int]money.Money {
+	var totalWeight int64
+	for _, i := range lines {
+		totalWeight += weights[i].Amount
+	}
+
+	shares := make(map[int]money.Money, len(lines))
+	remainders := make(map[int]int64, len(lines))
+	allocated := int64(0)
+	for _, i := range lines {
+		product := amount.Amount * weights[i].Amount
+		shares[i] = money.New(product/totalWeight, amount.Currency)
+		remainders[i] = product % totalWeight
+		allocated += product / totalWeight
+	}
+
+	order := append([]int(nil), lines...)
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for k := 0; allocated < amount.Amount; k++ {
+		i := order[k%len(order)]
+		shares[i] = shares[i].Add(money.New(1, amount.Currency))
+		allocated++
+	}
+	return shares
+}
+
+// Redeem records the use of each applied discount
+// If any promotion has reached its limit in the meantime, the earlier redemptions are released
+func (s *promotionService) Redeem(ctx context.Context, userID uint64, discounts []Discount) ([]uint64, error) {
+	var redemptionIDs []uint64
+	for _, discount := range discounts {
+		redemptionID, err := s.repo.Redeem(ctx, discount.PromotionID, userID)
+		if err != nil {
+			s.Release(ctx, redemptionIDs)
+			if discount.Code != "" {
+				return nil, fmt.Errorf("coupon '%s' is not valid: %w", discount.Code, err)
+			}
+			return nil, fmt.Errorf("promotion '%s' is not valid: %w", discount.Name, err)
+		}
+		redemptionIDs = append(redemptionIDs, redemptionID)
+	}
+	return redemptionIDs, nil
+}
+
+// Release undoes redemptions; failures are logged since the order itself was not placed
+func (s *promotionService) Release(ctx context.Context, redemptionIDs []uint64) {
+	for _, redemptionID := range redemptionIDs {
+		if err := s.repo.DeleteRedemption(ctx, redemptionID); err != nil {
+			log.Printf("Failed to release promotion redemption %d: %v", redemptionID, err)
+		}
+	}
+}
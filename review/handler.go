@@ -0,0 +1,166 @@
+package review
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// Handler handles HTTP requests for product reviews
+type Handler struct {
+	service     ReviewService
+	userService user.UserService
+	admins      auth.AdminList
+}
+
+// NewHandler creates a new review handler
+func NewHandler(service ReviewService, userService user.UserService, admins auth.AdminList) *Handler {
+	return &Handler{
+		service:     service,
+		userService: userService,
+		admins:      admins,
+	}
+}
+
+// RegisterRoutes registers the review routes of products and the admin moderation routes
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	// Public routes (no authentication required)
+	mux.HandleFunc("GET /products/{id}/reviews", h.handleGetProductReviews())
+
+	// Protected routes (authentication required)
+	mux.Handle("POST /products/{id}/reviews", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleCreateReview())))
+
+	// Admin routes
+	mux.Handle("GET /admin/reviews", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleListReviews())))
+	mux.Handle("PUT /admin/reviews/{id}/status", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleModerateReview())))
+	mux.Handle("DELETE /admin/reviews/{id}", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleDeleteReview())))
+}
+
+// handleGetProductReviews handles GET /products/{id}/reviews, listing approved reviews newest first
+func (h *Handler) handleGetProductReviews() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+
+		reviews, rating, err := h.service.GetProductReviews(r.Context(), productID)
+		if err != nil {
+			helper.RespondError(w, http.StatusInternalServerError, "Failed to fetch reviews")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"reviews": reviews,
+			"rating":  rating,
+			"count":   len(reviews),
+		})
+	}
+}
+
+// handleCreateReview handles POST /products/{id}/reviews (protected)
+// Body: {"rating":5,"title":"Great laptop","body":"..."}; the review is shown once approved
+func (h *Handler) handleCreateReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		username, ok := r.Context().Value(auth.UsernameContextKey).(string)
+		if !ok || username == "" {
+			helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+			return
+		}
+		user, err := h.userService.GetUserByUsername(r.Context(), username)
+		if err != nil || user == nil {
+			helper.RespondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+
+		var req CreateReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		review, err := h.service.CreateReview(r.Context(), productID, user.UserID, username, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create review", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, review)
+	}
+}
+
+// handleListReviews handles GET /admin/reviews?status=pending (admin only)
+func (h *Handler) handleListReviews() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviews, err := h.service.ListReviews(r.Context(), r.URL.Query().Get("status"))
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to fetch reviews", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"reviews": reviews,
+			"count":   len(reviews),
+		})
+	}
+}
+
+// handleModerateReview handles PUT /admin/reviews/{id}/status (admin only)
+// Body: {"status":"approved"} or {"status":"rejected"}
+func (h *Handler) handleModerateReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid review ID")
+			return
+		}
+		var req ModerateReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		review, err := h.service.ModerateReview(r.Context(), reviewID, req.Status)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to moderate review", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, review)
+	}
+}
+
+// handleDeleteReview handles DELETE /admin/reviews/{id} (admin only)
+func (h *Handler) handleDeleteReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reviewID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid review ID")
+			return
+		}
+
+		if err := h.service.DeleteReview(r.Context(), reviewID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to delete review", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Review deleted successfully",
+		})
+	}
+}
+
+// errorRules answer the review service errors the shared rules don't; only buyers of delivered orders may review
+var errorRules = []helper.ErrorRule{
+	{Fragment: "delivered orders", Status: http.StatusForbidden},
+}
@@ -0,0 +1,54 @@
+package review
+
+import (
+	"context"
+)
+
+// Review statuses
+// New reviews wait for moderation; only approved reviews are shown and count towards the rating
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// Review is a customer's rating of a product they received
+type Review struct {
+	ReviewID  uint64 `json:"review_id"`
+	ProductID uint64 `json:"product_id"`
+	UserID    uint64 `json:"user_id"`
+	Username  string `json:"username"`
+	Rating    int    `json:"rating"` // 1 to 5 stars
+	Title     string `json:"title,omitempty"`
+	Body      string `json:"body,omitempty"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Repository defines the interface for review data operations
+type ReviewRepository interface {
+	Create(ctx context.Context, review *Review) error
+	GetByID(ctx context.Context, reviewID uint64) (*Review, error)
+	// GetByUserAndProduct returns a user's review of a product; users review each product once
+	GetByUserAndProduct(ctx context.Context, userID, productID uint64) (*Review, error)
+	// GetByProduct returns the reviews of a product with a status, newest first
+	GetByProduct(ctx context.Context, productID uint64, status string) ([]Review, error)
+	// GetByStatus returns all reviews with a status, oldest first, for moderation
+	GetByStatus(ctx context.Context, status string) ([]Review, error)
+	UpdateStatus(ctx context.Context, reviewID uint64, status string) error
+	Delete(ctx context.Context, reviewID uint64) error
+	// GetRatingTotals returns the number of approved reviews of a product and the sum of their ratings
+	GetRatingTotals(ctx context.Context, productID uint64) (count int, sum int, err error)
+}
+
+// CreateReviewRequest represents the request to review a product
+type CreateReviewRequest struct {
+	Rating int    `json:"rating"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+// ModerateReviewRequest represents an admin's decision on a review
+type ModerateReviewRequest struct {
+	Status string `json:"status"` // approved or rejected
+}
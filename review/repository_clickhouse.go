@@ -0,0 +1,152 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+)
+
+// ClickHouseRepository implements ReviewRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db  *sql.DB
+	ids idgen.Generator
+}
+
+// NewClickHouseRepository creates a new ClickHouse review repository
+// ClickHouse has no auto-increment, so IDs come from the shared ID generator
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) ReviewRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
+}
+
+// clickHouseReviewColumns lists the review columns in scan order
+const clickHouseReviewColumns = "review_id, product_id, user_id, username, rating, title, body, status, created_at"
+
+// ensureReviewsTable creates the reviews table if it doesn't exist
+// ClickHouse has no unique constraints; one review per user and product is checked by the service
+func (r *ClickHouseRepository) ensureReviewsTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS reviews (
+			review_id UInt64,
+			product_id UInt64,
+			user_id UInt64,
+			username String,
+			rating Int32,
+			title String,
+			body String,
+			status String,
+			created_at String
+		) ENGINE = MergeTree()
+		ORDER BY (product_id, review_id)
+	`
+	_, err := r.db.ExecContext(ctx, query)
+	return err
+}
+
+func (r *ClickHouseRepository) Create(ctx context.Context, review *Review) error {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return err
+	}
+	review.ReviewID = r.ids.NextID()
+	query := "INSERT INTO reviews (" + clickHouseReviewColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := r.db.ExecContext(ctx, query, review.ReviewID, review.ProductID, review.UserID, review.Username, int32(review.Rating), review.Title, review.Body, review.Status, review.CreatedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) GetByID(ctx context.Context, reviewID uint64) (*Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.getOne(ctx, "SELECT "+clickHouseReviewColumns+" FROM reviews WHERE review_id = ? LIMIT 1", reviewID)
+}
+
+func (r *ClickHouseRepository) GetByUserAndProduct(ctx context.Context, userID, productID uint64) (*Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.getOne(ctx, "SELECT "+clickHouseReviewColumns+" FROM reviews WHERE user_id = ? AND product_id = ? LIMIT 1", userID, productID)
+}
+
+func (r *ClickHouseRepository) GetByProduct(ctx context.Context, productID uint64, status string) ([]Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHouseReviewColumns + " FROM reviews WHERE product_id = ? AND status = ? ORDER BY created_at DESC, review_id DESC"
+	return r.getMany(ctx, query, productID, status)
+}
+
+func (r *ClickHouseRepository) GetByStatus(ctx context.Context, status string) ([]Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + clickHouseReviewColumns + " FROM reviews WHERE status = ? ORDER BY created_at, review_id"
+	return r.getMany(ctx, query, status)
+}
+
+func (r *ClickHouseRepository) UpdateStatus(ctx context.Context, reviewID uint64, status string) error {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE reviews UPDATE status = ? WHERE review_id = ?", status, reviewID)
+	return err
+}
+
+func (r *ClickHouseRepository) Delete(ctx context.Context, reviewID uint64) error {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE reviews DELETE WHERE review_id = ?", reviewID)
+	return err
+}
+
+func (r *ClickHouseRepository) GetRatingTotals(ctx context.Context, productID uint64) (int, int, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return 0, 0, err
+	}
+	var count uint64
+	var sum int64
+	query := "SELECT count(), sum(rating) FROM reviews WHERE product_id = ? AND status = ?"
+	if err := r.db.QueryRowContext(ctx, query, productID, StatusApproved).Scan(&count, &sum); err != nil {
+		return 0, 0, err
+	}
+	return int(count), int(sum), nil
+}
+
+// getOne runs a query for a single review
+func (r *ClickHouseRepository) getOne(ctx context.Context, query string, args ...interface{}) (*Review, error) {
+	var review Review
+	var rating int32
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&review.ReviewID, &review.ProductID, &review.UserID, &review.Username, &rating, &review.Title, &review.Body, &review.Status, &review.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("review not found")
+		}
+		return nil, err
+	}
+	review.Rating = int(rating)
+	return &review, nil
+}
+
+// getMany runs a query for a list of reviews
+func (r *ClickHouseRepository) getMany(ctx context.Context, query string, args ...interface{}) ([]Review, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []Review
+	for rows.Next() {
+		var review Review
+		var rating int32
+		if err := rows.Scan(&review.ReviewID, &review.ProductID, &review.UserID, &review.Username, &rating, &review.Title, &review.Body, &review.Status, &review.CreatedAt); err != nil {
+			return nil, err
+		}
+		review.Rating = int(rating)
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
@@ -0,0 +1,159 @@
+package review
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresRepository implements ReviewRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL review repository
+func NewPostgresRepository(db *sql.DB) ReviewRepository {
+	return &PostgresRepository{db: db}
+}
+
+// reviewColumns lists the review columns in scan order
+const reviewColumns = "review_id, product_id, user_id, username, rating, title, body, status, created_at"
+
+// ensureReviewsTable creates the reviews table if it doesn't exist
+func (r *PostgresRepository) ensureReviewsTable(ctx context.Context) error {
+	query := `
+		CREATE TABLE IF NOT EXISTS reviews (
+			review_id SERIAL PRIMARY KEY,
+			product_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			username TEXT NOT NULL,
+			rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+			title TEXT NOT NULL DEFAULT '',
+			body TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			UNIQUE (product_id, user_id)
+		)`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews (status)")
+	return err
+}
+
+func (r *PostgresRepository) Create(ctx context.Context, review *Review) error {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO reviews (product_id, user_id, username, rating, title, body, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING review_id`
+	return r.db.QueryRowContext(ctx, query, review.ProductID, review.UserID, review.Username, review.Rating, review.Title, review.Body, review.Status, review.CreatedAt).Scan(&review.ReviewID)
+}
+
+func (r *PostgresRepository) GetByID(ctx context.Context, reviewID uint64) (*Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.getOne(ctx, "SELECT "+reviewColumns+" FROM reviews WHERE review_id = $1", reviewID)
+}
+
+func (r *PostgresRepository) GetByUserAndProduct(ctx context.Context, userID, productID uint64) (*Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	return r.getOne(ctx, "SELECT "+reviewColumns+" FROM reviews WHERE user_id = $1 AND product_id = $2", userID, productID)
+}
+
+func (r *PostgresRepository) GetByProduct(ctx context.Context, productID uint64, status string) ([]Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE product_id = $1 AND status = $2 ORDER BY created_at DESC, review_id DESC"
+	return r.getMany(ctx, query, productID, status)
+}
+
+func (r *PostgresRepository) GetByStatus(ctx context.Context, status string) ([]Review, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + reviewColumns + " FROM reviews WHERE status = $1 ORDER BY created_at, review_id"
+	return r.getMany(ctx, query, status)
+}
+
+func (r *PostgresRepository) UpdateStatus(ctx context.Context, reviewID uint64, status string) error {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "UPDATE reviews SET status = $1 WHERE review_id = $2", status, reviewID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("review not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) Delete(ctx context.Context, reviewID uint64) error {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM reviews WHERE review_id = $1", reviewID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("review not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetRatingTotals(ctx context.Context, productID uint64) (int, int, error) {
+	if err := r.ensureReviewsTable(ctx); err != nil {
+		return 0, 0, err
+	}
+	var count, sum int
+	query := "SELECT COUNT(*), COALESCE(SUM(rating), 0) FROM reviews WHERE product_id = $1 AND status = $2"
+	err := r.db.QueryRowContext(ctx, query, productID, StatusApproved).Scan(&count, &sum)
+	return count, sum, err
+}
+
+// getOne runs a query for a single review
+func (r *PostgresRepository) getOne(ctx context.Context, query string, args ...interface{}) (*Review, error) {
+	var review Review
+	var createdAt time.Time
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&review.ReviewID, &review.ProductID, &review.UserID, &review.Username, &review.Rating, &review.Title, &review.Body, &review.Status, &createdAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("review not found")
+		}
+		return nil, err
+	}
+	review.CreatedAt = createdAt.Format(time.RFC3339)
+	return &review, nil
+}
+
+// getMany runs a query for a list of reviews
+func (r *PostgresRepository) getMany(ctx context.Context, query string, args ...interface{}) ([]Review, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []Review
+	for rows.Next() {
+		var review Review
+		var createdAt time.Time
+		if err := rows.Scan(&review.ReviewID, &review.ProductID, &review.UserID, &review.Username, &review.Rating, &review.Title, &review.Body, &review.Status, &createdAt); err != nil {
+			return nil, err
+		}
+		review.CreatedAt = createdAt.Format(time.RFC3339)
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
@@ -0,0 +1,191 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rajindersingh041/go-auth-sessions/order"
+	"github.com/rajindersingh041/go-auth-sessions/product"
+)
+
+// Review text limits
+const (
+	maxTitleLength = 200
+	maxBodyLength  = 5000
+)
+
+// ReviewService defines the business logic interface for product reviews
+// It also provides the ratings shown on products
+type ReviewService interface {
+	// CreateReview adds a review for moderation; the user must have received the product in a delivered order
+	CreateReview(ctx context.Context, productID, userID uint64, username string, req CreateReviewRequest) (*Review, error)
+	// GetProductReviews returns the approved reviews of a product and its rating
+	GetProductReviews(ctx context.Context, productID uint64) ([]Review, *product.Rating, error)
+	// ListReviews returns the reviews with a status for moderation, pending by default
+	ListReviews(ctx context.Context, status string) ([]Review, error)
+	ModerateReview(ctx context.Context, reviewID uint64, status string) (*Review, error)
+	DeleteReview(ctx context.Context, reviewID uint64) error
+	GetRating(ctx context.Context, productID uint64) (*product.Rating, error)
+}
+
+// reviewService implements the ReviewService interface
+type reviewService struct {
+	repo   ReviewRepository
+	orders order.OrderRepository
+}
+
+// NewReviewService creates a new review service
+// Orders are checked so that only customers who received a product can review it
+func NewReviewService(repo ReviewRepository, orders order.OrderRepository) ReviewService {
+	return &reviewService{
+		repo:   repo,
+		orders: orders,
+	}
+}
+
+// CreateReview validates and creates a pending review
+// A rejected review can be replaced by a new one; otherwise users review each product once
+func (s *reviewService) CreateReview(ctx context.Context, productID, userID uint64, username string, req CreateReviewRequest) (*Review, error) {
+	if productID == 0 || userID == 0 {
+		return nil, fmt.Errorf("valid product and user IDs are required")
+	}
+	review := &Review{
+		ProductID: productID,
+		UserID:    userID,
+		Username:  username,
+		Rating:    req.Rating,
+		Title:     strings.TrimSpace(req.Title),
+		Body:      strings.TrimSpace(req.Body),
+		Status:    StatusPending,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := validateReview(review); err != nil {
+		return nil, err
+	}
+
+	delivered, err := s.orders.HasDeliveredProduct(ctx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if !delivered {
+		return nil, fmt.Errorf("review must be for a product from one of your delivered orders")
+	}
+
+	existing, err := s.repo.GetByUserAndProduct(ctx, userID, productID)
+	if err != nil && err.Error() != "review not found" {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.Status != StatusRejected {
+			return nil, fmt.Errorf("review of this product already exists")
+		}
+		if err := s.repo.Delete(ctx, existing.ReviewID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// validateReview checks the fields a customer provides
+func validateReview(review *Review) error {
+	if review.Rating < 1 || review.Rating > 5 {
+		return fmt.Errorf("review rating must be between 1 and 5")
+	}
+	if utf8.RuneCountInString(review.Title) > maxTitleLength {
+		return fmt.Errorf("review title must not be longer than %d characters", maxTitleLength)
+	}
+	if utf8.RuneCountInString(review.Body) > maxBodyLength {
+		return fmt.Errorf("review body must not be longer than %d characters", maxBodyLength)
+	}
+	return nil
+}
+
+// GetProductReviews retrieves the approved reviews of a product with its rating
+func (s *reviewService) GetProductReviews(ctx context.Context, productID uint64) ([]Review, *product.Rating, error) {
+	reviews, err := s.repo.GetByProduct(ctx, productID, StatusApproved)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reviews == nil {
+		reviews = []Review{}
+	}
+	rating, err := s.GetRating(ctx, productID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reviews, rating, nil
+}
+
+// ListReviews retrieves reviews by status for moderation
+func (s *reviewService) ListReviews(ctx context.Context, status string) ([]Review, error) {
+	if status == "" {
+		status = StatusPending
+	}
+	if err := validateStatus(status); err != nil {
+		return nil, err
+	}
+	reviews, err := s.repo.GetByStatus(ctx, status)
+	if err != nil {
+		return nil, err
+	}
+	if reviews == nil {
+		reviews = []Review{}
+	}
+	return reviews, nil
+}
+
+// ModerateReview approves or rejects a review; decisions can be revised later
+func (s *reviewService) ModerateReview(ctx context.Context, reviewID uint64, status string) (*Review, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if status != StatusApproved && status != StatusRejected {
+		return nil, fmt.Errorf("review status must be approved or rejected")
+	}
+	review, err := s.repo.GetByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateStatus(ctx, reviewID, status); err != nil {
+		return nil, err
+	}
+	review.Status = status
+	return review, nil
+}
+
+// DeleteReview removes a review, e.g. one with abusive content
+func (s *reviewService) DeleteReview(ctx context.Context, reviewID uint64) error {
+	if _, err := s.repo.GetByID(ctx, reviewID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, reviewID)
+}
+
+// GetRating returns the average of a product's approved ratings, rounded to one decimal
+func (s *reviewService) GetRating(ctx context.Context, productID uint64) (*product.Rating, error) {
+	count, sum, err := s.repo.GetRatingTotals(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	rating := &product.Rating{Count: count}
+	if count > 0 {
+		rating.Average = math.Round(float64(sum)/float64(count)*10) / 10
+	}
+	return rating, nil
+}
+
+// validateStatus checks a review status filter
+func validateStatus(status string) error {
+	switch status {
+	case StatusPending, StatusApproved, StatusRejected:
+		return nil
+	default:
+		return fmt.Errorf("invalid review status '%s'", status)
+	}
+}
@@ -0,0 +1,237 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// Handler handles HTTP requests for subscriptions
+type Handler struct {
+	service     SubscriptionService
+	userService user.UserService
+	admins      auth.AdminList
+}
+
+// NewHandler creates a new subscription handler
+// admins may manage the subscriptions of all users and bill due periods on demand
+func NewHandler(service SubscriptionService, userService user.UserService, admins auth.AdminList) *Handler {
+	return &Handler{
+		service:     service,
+		userService: userService,
+		admins:      admins,
+	}
+}
+
+// RegisterRoutes registers the subscription routes
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	mux.Handle("POST /subscriptions", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleCreate())))
+	mux.Handle("GET /subscriptions", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleList())))
+	mux.Handle("GET /subscriptions/{id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGet())))
+	mux.Handle("PUT /subscriptions/{id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleChangePlan())))
+	mux.Handle("POST /subscriptions/{id}/pause", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleTransition(h.service.Pause, "Failed to pause subscription"))))
+	mux.Handle("POST /subscriptions/{id}/resume", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleTransition(h.service.Resume, "Failed to resume subscription"))))
+	mux.Handle("POST /subscriptions/{id}/cancel", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleTransition(h.service.Cancel, "Failed to cancel subscription"))))
+	mux.Handle("GET /subscriptions/{id}/runs", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetRuns())))
+
+	// Admin routes
+	mux.Handle("POST /admin/subscriptions/run", auth.WithAdminAuth(jwtManager, h.admins, http.HandlerFunc(h.handleRunDue())))
+}
+
+// handleCreate handles POST /subscriptions
+// Body: {"items":[{"product_id":1,"quantity":2}],"interval":"month","interval_count":1,"start_date":"2025-07-01T00:00:00Z"}
+func (h *Handler) handleCreate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+		var req CreateSubscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		sub, err := h.service.CreateSubscription(r.Context(), user.UserID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create subscription", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, sub)
+	}
+}
+
+// handleList handles GET /subscriptions, listing the subscriptions of the current user
+func (h *Handler) handleList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := h.currentUser(w, r)
+		if !ok {
+			return
+		}
+
+		subs, err := h.service.GetSubscriptionsByUserID(r.Context(), user.UserID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to retrieve subscriptions", errorRules...)
+			return
+		}
+		if subs == nil {
+			subs = []Subscription{}
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"subscriptions": subs,
+			"count":         len(subs),
+		})
+	}
+}
+
+// handleGet handles GET /subscriptions/{id}
+func (h *Handler) handleGet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := h.ownedSubscription(w, r)
+		if !ok {
+			return
+		}
+		helper.RespondJSON(w, http.StatusOK, sub)
+	}
+}
+
+// handleChangePlan handles PUT /subscriptions/{id}
+// Body: {"items":[{"product_id":1,"quantity":3}],"interval":"week","prorate":true}
+func (h *Handler) handleChangePlan() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := h.ownedSubscription(w, r)
+		if !ok {
+			return
+		}
+		var req ChangePlanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		sub, run, err := h.service.ChangePlan(r.Context(), sub.SubscriptionID, req)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to change subscription", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"subscription": sub,
+			"proration":    run,
+		})
+	}
+}
+
+// handleTransition handles POST /subscriptions/{id}/pause, /resume and /cancel
+func (h *Handler) handleTransition(transition func(ctx context.Context, subscriptionID uint64) (*Subscription, error), fallback string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := h.ownedSubscription(w, r)
+		if !ok {
+			return
+		}
+
+		sub, err := transition(r.Context(), sub.SubscriptionID)
+		if err != nil {
+			helper.RespondServiceError(w, err, fallback, errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, sub)
+	}
+}
+
+// handleGetRuns handles GET /subscriptions/{id}/runs, listing the billed periods with their orders and invoices
+func (h *Handler) handleGetRuns() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := h.ownedSubscription(w, r)
+		if !ok {
+			return
+		}
+
+		runs, err := h.service.GetRuns(r.Context(), sub.SubscriptionID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to retrieve subscription runs", errorRules...)
+			return
+		}
+		if runs == nil {
+			runs = []Run{}
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"subscription_id": sub.SubscriptionID,
+			"runs":            runs,
+			"count":           len(runs),
+		})
+	}
+}
+
+// handleRunDue handles POST /admin/subscriptions/run, billing due periods without waiting for the scheduler
+func (h *Handler) handleRunDue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		billed, err := h.service.RunDue(r.Context(), time.Now())
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to bill due subscriptions", errorRules...)
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"billed": billed,
+		})
+	}
+}
+
+// ownedSubscription loads the subscription of the request path, writing an error response when it doesn't
+// exist or belongs to another user; other users' subscriptions are reported as missing, so their IDs aren't revealed
+func (h *Handler) ownedSubscription(w http.ResponseWriter, r *http.Request) (*Subscription, bool) {
+	subscriptionID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		helper.RespondError(w, http.StatusBadRequest, "Invalid subscription ID")
+		return nil, false
+	}
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	sub, err := h.service.GetSubscription(r.Context(), subscriptionID)
+	if err == nil && sub.UserID != user.UserID && !h.admins.IsAdmin(user.Username) {
+		helper.RespondError(w, http.StatusNotFound, "subscription not found")
+		return nil, false
+	}
+	if err != nil {
+		helper.RespondServiceError(w, err, "Failed to retrieve subscription", errorRules...)
+		return nil, false
+	}
+	return sub, true
+}
+
+// currentUser resolves the authenticated user, writing an error response when that fails
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	username, ok := r.Context().Value(auth.UsernameContextKey).(string)
+	if !ok || username == "" {
+		helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+		return nil, false
+	}
+	u, err := h.userService.GetUserByUsername(r.Context(), username)
+	if err != nil || u == nil {
+		helper.RespondError(w, http.StatusNotFound, "User not found")
+		return nil, false
+	}
+	return u, true
+}
+
+// errorRules answer the subscription service errors the shared rules don't; items that can't be subscribed to are
+// bad requests, even when their product is not found, and operations that don't fit the subscription's status
+// ("subscription ... must ...") are conflicts
+var errorRules = []helper.ErrorRule{
+	{Fragment: "item ", Status: http.StatusBadRequest},
+	{Fragment: "subscription must", Status: http.StatusConflict},
+}
@@ -0,0 +1,124 @@
+package subscription
+
+import (
+	"context"
+	"time"
+)
+
+// Subscription statuses
+const (
+	StatusActive    = "active"
+	StatusPaused    = "paused"    // no periods are billed until resumed
+	StatusCancelled = "cancelled" // final
+)
+
+// Billing intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// Run kinds
+const (
+	KindPeriod    = "period"    // the goods of a billing period
+	KindProration = "proration" // goods added by a plan change for the rest of the current period
+)
+
+// Run statuses
+// A run places its order first and then invoices it; a retried run never places a second order
+const (
+	RunPending  = "pending"  // claimed, no order placed yet
+	RunOrdered  = "ordered"  // order placed, invoice not created yet
+	RunInvoiced = "invoiced" // done
+	RunFailed   = "failed"   // retried after a delay
+	RunSkipped  = "skipped"  // given up after too many failed attempts
+)
+
+// Subscription orders the same products on a fixed schedule
+// Periods are billed in advance: on the start of each period an order for the items is placed and invoiced
+type Subscription struct {
+	SubscriptionID uint64    `json:"subscription_id"`
+	UserID         uint64    `json:"user_id"`
+	Items          []Item    `json:"items"`
+	Interval       string    `json:"interval"`       // "day", "week", "month" or "year"
+	IntervalCount  int       `json:"interval_count"` // e.g. 2 with "week" for every other week
+	Region         string    `json:"region,omitempty"`
+	Currency       string    `json:"currency,omitempty"`
+	Status         string    `json:"status"`
+	StartDate      time.Time `json:"start_date"`
+
+	// Periods are counted from Anchor: period n starts Interval*IntervalCount*n after it
+	// Anchor is the start date; it moves to the next period when the interval changes,
+	// and to the resume date when a paused subscription missed a period
+	Anchor    time.Time `json:"anchor"`
+	Cycle     int       `json:"cycle"`       // number of the next period to bill
+	NextRunAt time.Time `json:"next_run_at"` // start of the next period to bill
+
+	PausedAt    *time.Time `json:"paused_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Item is a product ordered every period
+type Item struct {
+	ProductID uint64 `json:"product_id"`
+	VariantID uint64 `json:"variant_id,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Run is the billing of one period, or of a proration, with the order and invoice it created
+type Run struct {
+	RunID          uint64    `json:"run_id"`
+	SubscriptionID uint64    `json:"subscription_id"`
+	Kind           string    `json:"kind"` // "period" or "proration"
+	PeriodStart    time.Time `json:"period_start"`
+	PeriodEnd      time.Time `json:"period_end"`
+	Items          []Item    `json:"items"`
+	OrderID        uint64    `json:"order_id,omitempty"`
+	InvoiceID      uint64    `json:"invoice_id,omitempty"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	Error          string    `json:"error,omitempty"` // reason of the last failed attempt
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Repository defines the interface for subscription data operations
+type SubscriptionRepository interface {
+	Create(ctx context.Context, sub *Subscription) error
+	GetByID(ctx context.Context, subscriptionID uint64) (*Subscription, error)
+	// GetByUserID returns the subscriptions of a user, newest first
+	GetByUserID(ctx context.Context, userID uint64) ([]Subscription, error)
+	// GetDue returns the active subscriptions with a period starting at or before now, most overdue first
+	GetDue(ctx context.Context, now time.Time) ([]Subscription, error)
+	Update(ctx context.Context, sub *Subscription) error
+	// CreateRun claims the billing of a period; a run for the same subscription, kind and period start "already exists"
+	CreateRun(ctx context.Context, run *Run) error
+	// GetRun returns the run of a period, or nil if it wasn't billed yet
+	GetRun(ctx context.Context, subscriptionID uint64, kind string, periodStart time.Time) (*Run, error)
+	UpdateRun(ctx context.Context, run *Run) error
+	// GetRuns returns the runs of a subscription, newest first
+	GetRuns(ctx context.Context, subscriptionID uint64) ([]Run, error)
+}
+
+// CreateSubscriptionRequest represents the request to subscribe to products
+type CreateSubscriptionRequest struct {
+	Items         []Item     `json:"items"`
+	Interval      string     `json:"interval"`
+	IntervalCount int        `json:"interval_count"` // defaults to 1
+	StartDate     *time.Time `json:"start_date"`     // defaults to now; the first period is billed then
+	Region        string     `json:"region,omitempty"`
+	Currency      string     `json:"currency,omitempty"`
+}
+
+// ChangePlanRequest changes the items or interval of a subscription
+// Omitted fields are kept; a new interval applies from the next period
+type ChangePlanRequest struct {
+	Items         []Item `json:"items,omitempty"`
+	Interval      string `json:"interval,omitempty"`
+	IntervalCount int    `json:"interval_count,omitempty"`
+	Prorate       *bool  `json:"prorate,omitempty"` // defaults to true
+}
@@ -0,0 +1,263 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/idgen"
+)
+
+// ClickHouseRepository implements SubscriptionRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db  *sql.DB
+	ids idgen.Generator
+}
+
+// NewClickHouseRepository creates a new ClickHouse subscription repository
+// ClickHouse has no auto-increment, so subscription and run IDs come from the shared ID generator
+func NewClickHouseRepository(db *sql.DB, ids idgen.Generator) SubscriptionRepository {
+	return &ClickHouseRepository{db: db, ids: ids}
+}
+
+// ensureSubscriptionTables creates the subscriptions and subscription_runs tables if they don't exist
+// Every change inserts a new version of the row, and reads use FINAL, so the scheduler never sees
+// a subscription or run before its last change. Unset pause and cancel times are stored as the epoch.
+func (r *ClickHouseRepository) ensureSubscriptionTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS subscriptions (
+			subscription_id UInt64,
+			user_id UInt64,
+			items String,
+			billing_interval String,
+			interval_count Int64,
+			region String DEFAULT '',
+			currency String DEFAULT '',
+			status String,
+			start_date DateTime64(3),
+			anchor DateTime64(3),
+			cycle Int64,
+			next_run_at DateTime64(3),
+			paused_at DateTime64(3),
+			cancelled_at DateTime64(3),
+			created_at DateTime64(3),
+			updated_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(updated_at)
+		ORDER BY subscription_id`,
+		`CREATE TABLE IF NOT EXISTS subscription_runs (
+			run_id UInt64,
+			subscription_id UInt64,
+			kind String,
+			period_start DateTime64(3),
+			period_end DateTime64(3),
+			items String,
+			order_id UInt64 DEFAULT 0,
+			invoice_id UInt64 DEFAULT 0,
+			status String,
+			attempts Int64 DEFAULT 0,
+			error String DEFAULT '',
+			created_at DateTime64(3),
+			updated_at DateTime64(3)
+		) ENGINE = ReplacingMergeTree(updated_at)
+		ORDER BY (subscription_id, kind, period_start)`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ClickHouseRepository) Create(ctx context.Context, sub *Subscription) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	sub.SubscriptionID = r.ids.NextID()
+	return r.insertSubscription(ctx, sub)
+}
+
+// insertSubscription inserts a version of a subscription
+func (r *ClickHouseRepository) insertSubscription(ctx context.Context, sub *Subscription) error {
+	itemsJSON, err := json.Marshal(sub.Items)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO subscriptions (" + subscriptionColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query,
+		sub.SubscriptionID, sub.UserID, string(itemsJSON), sub.Interval, int64(sub.IntervalCount), sub.Region, sub.Currency, sub.Status,
+		sub.StartDate, sub.Anchor, int64(sub.Cycle), sub.NextRunAt, orEpoch(sub.PausedAt), orEpoch(sub.CancelledAt), sub.CreatedAt, sub.UpdatedAt,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) GetByID(ctx context.Context, subscriptionID uint64) (*Subscription, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	subs, err := r.querySubscriptions(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions FINAL WHERE subscription_id = ?", subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, fmt.Errorf("subscription not found")
+	}
+	return &subs[0], nil
+}
+
+func (r *ClickHouseRepository) GetByUserID(ctx context.Context, userID uint64) ([]Subscription, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	return r.querySubscriptions(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions FINAL WHERE user_id = ? ORDER BY created_at DESC, subscription_id DESC", userID)
+}
+
+func (r *ClickHouseRepository) GetDue(ctx context.Context, now time.Time) ([]Subscription, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions FINAL WHERE status = ? AND next_run_at <= ? ORDER BY next_run_at, subscription_id"
+	return r.querySubscriptions(ctx, query, StatusActive, now)
+}
+
+func (r *ClickHouseRepository) Update(ctx context.Context, sub *Subscription) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	return r.insertSubscription(ctx, sub)
+}
+
+// CreateRun checks for an existing run first; without unique constraints in ClickHouse,
+// only one instance may run the scheduler
+func (r *ClickHouseRepository) CreateRun(ctx context.Context, run *Run) error {
+	existing, err := r.GetRun(ctx, run.SubscriptionID, run.Kind, run.PeriodStart)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("run of period %s already exists", run.PeriodStart.Format(time.RFC3339))
+	}
+	run.RunID = r.ids.NextID()
+	return r.insertRun(ctx, run)
+}
+
+// insertRun inserts a version of a run
+func (r *ClickHouseRepository) insertRun(ctx context.Context, run *Run) error {
+	itemsJSON, err := json.Marshal(run.Items)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO subscription_runs (" + runColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query,
+		run.RunID, run.SubscriptionID, run.Kind, run.PeriodStart, run.PeriodEnd, string(itemsJSON), run.OrderID, run.InvoiceID,
+		run.Status, int64(run.Attempts), run.Error, run.CreatedAt, run.UpdatedAt,
+	)
+	return err
+}
+
+func (r *ClickHouseRepository) GetRun(ctx context.Context, subscriptionID uint64, kind string, periodStart time.Time) (*Run, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + runColumns + " FROM subscription_runs FINAL WHERE subscription_id = ? AND kind = ? AND period_start = ?"
+	runs, err := r.queryRuns(ctx, query, subscriptionID, kind, periodStart)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+func (r *ClickHouseRepository) UpdateRun(ctx context.Context, run *Run) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	return r.insertRun(ctx, run)
+}
+
+func (r *ClickHouseRepository) GetRuns(ctx context.Context, subscriptionID uint64) ([]Run, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + runColumns + " FROM subscription_runs FINAL WHERE subscription_id = ? ORDER BY period_start DESC, run_id DESC"
+	return r.queryRuns(ctx, query, subscriptionID)
+}
+
+// querySubscriptions runs a query selecting subscriptionColumns
+func (r *ClickHouseRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var sub Subscription
+		var itemsJSON string
+		var intervalCount, cycle int64
+		var pausedAt, cancelledAt time.Time
+		err := rows.Scan(
+			&sub.SubscriptionID, &sub.UserID, &itemsJSON, &sub.Interval, &intervalCount, &sub.Region, &sub.Currency, &sub.Status,
+			&sub.StartDate, &sub.Anchor, &cycle, &sub.NextRunAt, &pausedAt, &cancelledAt, &sub.CreatedAt, &sub.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(itemsJSON), &sub.Items); err != nil {
+			return nil, err
+		}
+		sub.IntervalCount = int(intervalCount)
+		sub.Cycle = int(cycle)
+		sub.PausedAt = fromEpoch(pausedAt)
+		sub.CancelledAt = fromEpoch(cancelledAt)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// queryRuns runs a query selecting runColumns
+func (r *ClickHouseRepository) queryRuns(ctx context.Context, query string, args ...interface{}) ([]Run, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var run Run
+		var itemsJSON string
+		var attempts int64
+		err := rows.Scan(
+			&run.RunID, &run.SubscriptionID, &run.Kind, &run.PeriodStart, &run.PeriodEnd, &itemsJSON, &run.OrderID, &run.InvoiceID,
+			&run.Status, &attempts, &run.Error, &run.CreatedAt, &run.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(itemsJSON), &run.Items); err != nil {
+			return nil, err
+		}
+		run.Attempts = int(attempts)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// orEpoch stores an unset time as the epoch
+func orEpoch(t *time.Time) time.Time {
+	if t == nil {
+		return time.Unix(0, 0).UTC()
+	}
+	return *t
+}
+
+// fromEpoch reads the epoch back as an unset time
+func fromEpoch(t time.Time) *time.Time {
+	if t.Unix() <= 0 {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,278 @@
+package subscription
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PostgresRepository implements SubscriptionRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL subscription repository
+func NewPostgresRepository(db *sql.DB) SubscriptionRepository {
+	return &PostgresRepository{db: db}
+}
+
+// ensureSubscriptionTables creates the subscriptions and subscription_runs tables if they don't exist
+// The unique period of a run keeps two instances from billing the same period
+func (r *PostgresRepository) ensureSubscriptionTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS subscriptions (
+			subscription_id SERIAL PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			items JSONB NOT NULL,
+			billing_interval VARCHAR(10) NOT NULL,
+			interval_count INT NOT NULL,
+			region TEXT NOT NULL DEFAULT '',
+			currency VARCHAR(3) NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL,
+			start_date TIMESTAMPTZ NOT NULL,
+			anchor TIMESTAMPTZ NOT NULL,
+			cycle INT NOT NULL DEFAULT 0,
+			next_run_at TIMESTAMPTZ NOT NULL,
+			paused_at TIMESTAMPTZ,
+			cancelled_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_subscriptions_user ON subscriptions (user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_subscriptions_due ON subscriptions (status, next_run_at)",
+		`CREATE TABLE IF NOT EXISTS subscription_runs (
+			run_id SERIAL PRIMARY KEY,
+			subscription_id BIGINT NOT NULL REFERENCES subscriptions(subscription_id),
+			kind VARCHAR(20) NOT NULL,
+			period_start TIMESTAMPTZ NOT NULL,
+			period_end TIMESTAMPTZ NOT NULL,
+			items JSONB NOT NULL,
+			order_id BIGINT NOT NULL DEFAULT 0,
+			invoice_id BIGINT NOT NULL DEFAULT 0,
+			status VARCHAR(20) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (subscription_id, kind, period_start)
+		)`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscriptionColumns are the columns read by scanSubscription, in both databases
+const subscriptionColumns = "subscription_id, user_id, items, billing_interval, interval_count, region, currency, status, start_date, anchor, cycle, next_run_at, paused_at, cancelled_at, created_at, updated_at"
+
+// runColumns are the columns read by scanRun, in both databases
+const runColumns = "run_id, subscription_id, kind, period_start, period_end, items, order_id, invoice_id, status, attempts, error, created_at, updated_at"
+
+func (r *PostgresRepository) Create(ctx context.Context, sub *Subscription) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	itemsJSON, err := json.Marshal(sub.Items)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO subscriptions (user_id, items, billing_interval, interval_count, region, currency, status, start_date, anchor, cycle, next_run_at, paused_at, cancelled_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING subscription_id`
+	return r.db.QueryRowContext(ctx, query,
+		sub.UserID, itemsJSON, sub.Interval, sub.IntervalCount, sub.Region, sub.Currency, sub.Status,
+		sub.StartDate, sub.Anchor, sub.Cycle, sub.NextRunAt, sub.PausedAt, sub.CancelledAt, sub.CreatedAt, sub.UpdatedAt,
+	).Scan(&sub.SubscriptionID)
+}
+
+func (r *PostgresRepository) GetByID(ctx context.Context, subscriptionID uint64) (*Subscription, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	subs, err := r.querySubscriptions(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE subscription_id = $1", subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) == 0 {
+		return nil, fmt.Errorf("subscription not found")
+	}
+	return &subs[0], nil
+}
+
+func (r *PostgresRepository) GetByUserID(ctx context.Context, userID uint64) ([]Subscription, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	return r.querySubscriptions(ctx, "SELECT "+subscriptionColumns+" FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC, subscription_id DESC", userID)
+}
+
+func (r *PostgresRepository) GetDue(ctx context.Context, now time.Time) ([]Subscription, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + subscriptionColumns + " FROM subscriptions WHERE status = $1 AND next_run_at <= $2 ORDER BY next_run_at, subscription_id"
+	return r.querySubscriptions(ctx, query, StatusActive, now)
+}
+
+func (r *PostgresRepository) Update(ctx context.Context, sub *Subscription) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	itemsJSON, err := json.Marshal(sub.Items)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE subscriptions SET items = $1, billing_interval = $2, interval_count = $3, status = $4, anchor = $5, cycle = $6,
+			next_run_at = $7, paused_at = $8, cancelled_at = $9, updated_at = $10
+		WHERE subscription_id = $11`
+	result, err := r.db.ExecContext(ctx, query,
+		itemsJSON, sub.Interval, sub.IntervalCount, sub.Status, sub.Anchor, sub.Cycle,
+		sub.NextRunAt, sub.PausedAt, sub.CancelledAt, sub.UpdatedAt, sub.SubscriptionID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateRun(ctx context.Context, run *Run) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	itemsJSON, err := json.Marshal(run.Items)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO subscription_runs (subscription_id, kind, period_start, period_end, items, order_id, invoice_id, status, attempts, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (subscription_id, kind, period_start) DO NOTHING RETURNING run_id`
+	err = r.db.QueryRowContext(ctx, query,
+		run.SubscriptionID, run.Kind, run.PeriodStart, run.PeriodEnd, itemsJSON, run.OrderID, run.InvoiceID,
+		run.Status, run.Attempts, run.Error, run.CreatedAt, run.UpdatedAt,
+	).Scan(&run.RunID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("run of period %s already exists", run.PeriodStart.Format(time.RFC3339))
+	}
+	return err
+}
+
+func (r *PostgresRepository) GetRun(ctx context.Context, subscriptionID uint64, kind string, periodStart time.Time) (*Run, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + runColumns + " FROM subscription_runs WHERE subscription_id = $1 AND kind = $2 AND period_start = $3"
+	runs, err := r.queryRuns(ctx, query, subscriptionID, kind, periodStart)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+func (r *PostgresRepository) UpdateRun(ctx context.Context, run *Run) error {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return err
+	}
+	query := `
+		UPDATE subscription_runs SET order_id = $1, invoice_id = $2, status = $3, attempts = $4, error = $5, updated_at = $6
+		WHERE run_id = $7`
+	_, err := r.db.ExecContext(ctx, query, run.OrderID, run.InvoiceID, run.Status, run.Attempts, run.Error, run.UpdatedAt, run.RunID)
+	return err
+}
+
+func (r *PostgresRepository) GetRuns(ctx context.Context, subscriptionID uint64) ([]Run, error) {
+	if err := r.ensureSubscriptionTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT " + runColumns + " FROM subscription_runs WHERE subscription_id = $1 ORDER BY period_start DESC, run_id DESC"
+	return r.queryRuns(ctx, query, subscriptionID)
+}
+
+// querySubscriptions runs a query selecting subscriptionColumns
+func (r *PostgresRepository) querySubscriptions(ctx context.Context, query string, args ...interface{}) ([]Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var itemsJSON []byte
+		sub, err := scanSubscription(rows, &itemsJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(itemsJSON, &sub.Items); err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+// queryRuns runs a query selecting runColumns
+func (r *PostgresRepository) queryRuns(ctx context.Context, query string, args ...interface{}) ([]Run, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var itemsJSON []byte
+		run, err := scanRun(rows, &itemsJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(itemsJSON, &run.Items); err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// scanSubscription reads a PostgreSQL row of subscriptionColumns; the items JSON is left in itemsJSON for the caller to decode
+func scanSubscription(rows *sql.Rows, itemsJSON interface{}) (*Subscription, error) {
+	var sub Subscription
+	var pausedAt, cancelledAt sql.NullTime
+	err := rows.Scan(
+		&sub.SubscriptionID, &sub.UserID, itemsJSON, &sub.Interval, &sub.IntervalCount, &sub.Region, &sub.Currency, &sub.Status,
+		&sub.StartDate, &sub.Anchor, &sub.Cycle, &sub.NextRunAt, &pausedAt, &cancelledAt, &sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if pausedAt.Valid {
+		sub.PausedAt = &pausedAt.Time
+	}
+	if cancelledAt.Valid {
+		sub.CancelledAt = &cancelledAt.Time
+	}
+	return &sub, nil
+}
+
+// scanRun reads a PostgreSQL row of runColumns; the items JSON is left in itemsJSON for the caller to decode
+func scanRun(rows *sql.Rows, itemsJSON interface{}) (*Run, error) {
+	var run Run
+	err := rows.Scan(
+		&run.RunID, &run.SubscriptionID, &run.Kind, &run.PeriodStart, &run.PeriodEnd, itemsJSON, &run.OrderID, &run.InvoiceID,
+		&run.Status, &run.Attempts, &run.Error, &run.CreatedAt, &run.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
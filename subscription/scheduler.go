@@ -0,0 +1,31 @@
+package subscription
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RunScheduler bills due subscription periods every interval until ctx is cancelled
+// Periods missed while the service was down are billed on the first ticks after it starts again
+// It is meant to be started in its own goroutine
+func RunScheduler(ctx context.Context, service SubscriptionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			billed, err := service.RunDue(ctx, time.Now())
+			if err != nil {
+				log.Printf("Subscription scheduler: failed to bill due subscriptions: %v", err)
+				continue
+			}
+			if billed > 0 {
+				log.Printf("Subscription scheduler: billed %d subscription periods", billed)
+			}
+		}
+	}
+}
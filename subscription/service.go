@@ -0,0 +1,552 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/invoice"
+	"github.com/rajindersingh041/go-auth-sessions/order"
+	"github.com/rajindersingh041/go-auth-sessions/product"
+	"github.com/rajindersingh041/go-auth-sessions/tax"
+)
+
+const (
+	// maxItems limits the number of items of a subscription
+	maxItems = 50
+	// maxIntervalCount limits how many intervals a period spans
+	maxIntervalCount = 100
+	// maxCatchUpPeriods limits how many missed periods of one subscription are billed per run of the scheduler
+	maxCatchUpPeriods = 12
+	// maxAttempts is how often a run is tried before it is skipped
+	maxAttempts = 5
+	// retryDelay is the time between attempts of a failed run
+	retryDelay = time.Hour
+)
+
+// SubscriptionService defines the interface for subscription business logic
+type SubscriptionService interface {
+	// CreateSubscription subscribes a user to products; the first period is billed on the start date
+	CreateSubscription(ctx context.Context, userID uint64, req CreateSubscriptionRequest) (*Subscription, error)
+	GetSubscription(ctx context.Context, subscriptionID uint64) (*Subscription, error)
+	GetSubscriptionsByUserID(ctx context.Context, userID uint64) ([]Subscription, error)
+	// GetRuns returns the billed periods and prorations of a subscription, newest first
+	GetRuns(ctx context.Context, subscriptionID uint64) ([]Run, error)
+	// ChangePlan changes the items or interval of a subscription
+	// Added quantities are ordered at once for the rest of the current period, unless proration is turned off;
+	// the returned run is nil when nothing was prorated
+	ChangePlan(ctx context.Context, subscriptionID uint64, req ChangePlanRequest) (*Subscription, *Run, error)
+	// Pause stops billing until the subscription is resumed
+	Pause(ctx context.Context, subscriptionID uint64) (*Subscription, error)
+	// Resume continues billing; periods that started while paused are not billed
+	Resume(ctx context.Context, subscriptionID uint64) (*Subscription, error)
+	Cancel(ctx context.Context, subscriptionID uint64) (*Subscription, error)
+	// RunDue bills the periods of active subscriptions that started at or before now, including periods
+	// missed while the service was down, and returns the number of periods billed
+	RunDue(ctx context.Context, now time.Time) (int, error)
+}
+
+// subscriptionService implements SubscriptionService
+type subscriptionService struct {
+	repo           SubscriptionRepository
+	productService product.ProductService
+	orderService   order.OrderService
+	invoiceService invoice.InvoiceService
+	taxCalculator  tax.TaxCalculator
+}
+
+// NewSubscriptionService creates a new subscription service
+// Orders and invoices of subscriptions are created through the order and invoice services, like any other;
+// the tax calculator only checks regions up front, so renewals don't fail on a region it doesn't know
+func NewSubscriptionService(repo SubscriptionRepository, productService product.ProductService, orderService order.OrderService, invoiceService invoice.InvoiceService, taxCalculator tax.TaxCalculator) SubscriptionService {
+	return &subscriptionService{
+		repo:           repo,
+		productService: productService,
+		orderService:   orderService,
+		invoiceService: invoiceService,
+		taxCalculator:  taxCalculator,
+	}
+}
+
+// now returns the current time as stored by both databases, so period starts compare equal after a round trip
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func (s *subscriptionService) CreateSubscription(ctx context.Context, userID uint64, req CreateSubscriptionRequest) (*Subscription, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	items, err := s.validateItems(ctx, req.Items)
+	if err != nil {
+		return nil, err
+	}
+	interval, count, err := validateInterval(req.Interval, req.IntervalCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.taxCalculator.ValidateRegion(strings.TrimSpace(req.Region)); err != nil {
+		return nil, err
+	}
+
+	current := now()
+	start := current
+	if req.StartDate != nil {
+		// Allow for clock skew of clients starting a subscription right away
+		if req.StartDate.Before(current.Add(-time.Minute)) {
+			return nil, fmt.Errorf("start_date must not be in the past")
+		}
+		if req.StartDate.After(current) {
+			start = req.StartDate.UTC().Truncate(time.Millisecond)
+		}
+	}
+
+	sub := &Subscription{
+		UserID:        userID,
+		Items:         items,
+		Interval:      interval,
+		IntervalCount: count,
+		Region:        strings.TrimSpace(req.Region),
+		Currency:      strings.ToUpper(strings.TrimSpace(req.Currency)),
+		Status:        StatusActive,
+		StartDate:     start,
+		Anchor:        start,
+		NextRunAt:     start,
+		CreatedAt:     current,
+		UpdatedAt:     current,
+	}
+	if err := s.repo.Create(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	// Subscriptions starting now are billed right away; a failed first period is retried by the scheduler
+	if !sub.NextRunAt.After(current) {
+		if _, err := s.catchUp(ctx, sub, current); err != nil {
+			log.Printf("Subscription %d: failed to bill first period: %v", sub.SubscriptionID, err)
+		}
+	}
+	return sub, nil
+}
+
+func (s *subscriptionService) GetSubscription(ctx context.Context, subscriptionID uint64) (*Subscription, error) {
+	if subscriptionID == 0 {
+		return nil, fmt.Errorf("valid subscription ID is required")
+	}
+	return s.repo.GetByID(ctx, subscriptionID)
+}
+
+func (s *subscriptionService) GetSubscriptionsByUserID(ctx context.Context, userID uint64) ([]Subscription, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	return s.repo.GetByUserID(ctx, userID)
+}
+
+func (s *subscriptionService) GetRuns(ctx context.Context, subscriptionID uint64) ([]Run, error) {
+	if _, err := s.GetSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRuns(ctx, subscriptionID)
+}
+
+// ChangePlan applies new items at once and a new interval from the next period
+// Increased quantities are prorated in goods: the added quantity times the part of the current period
+// that is left, rounded, is ordered and invoiced now. Decreases take effect with the next period.
+func (s *subscriptionService) ChangePlan(ctx context.Context, subscriptionID uint64, req ChangePlanRequest) (*Subscription, *Run, error) {
+	sub, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sub.Status == StatusCancelled {
+		return nil, nil, fmt.Errorf("subscription is already cancelled")
+	}
+	if len(req.Items) == 0 && req.Interval == "" && req.IntervalCount == 0 {
+		return nil, nil, fmt.Errorf("items or interval are required")
+	}
+
+	oldItems := sub.Items
+	if len(req.Items) > 0 {
+		if sub.Items, err = s.validateItems(ctx, req.Items); err != nil {
+			return nil, nil, err
+		}
+	}
+	if req.Interval != "" || req.IntervalCount != 0 {
+		interval, count := req.Interval, req.IntervalCount
+		if interval == "" {
+			interval = sub.Interval
+		}
+		if count == 0 {
+			count = sub.IntervalCount
+		}
+		if interval, count, err = validateInterval(interval, count); err != nil {
+			return nil, nil, err
+		}
+		// The next period is the first of the new interval
+		if interval != sub.Interval || count != sub.IntervalCount {
+			sub.Interval, sub.IntervalCount = interval, count
+			sub.Anchor, sub.Cycle = sub.NextRunAt, 0
+		}
+	}
+
+	current := now()
+	var run *Run
+	if sub.Status == StatusActive && (req.Prorate == nil || *req.Prorate) {
+		if run, err = s.prorationRun(ctx, sub, oldItems, current); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sub.UpdatedAt = current
+	if err := s.repo.Update(ctx, sub); err != nil {
+		return nil, nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	// A failed proration is reported on the run and not retried; the plan change stands
+	if run != nil {
+		if err := s.repo.CreateRun(ctx, run); err != nil {
+			return nil, nil, fmt.Errorf("failed to record proration: %w", err)
+		}
+		if _, err := s.process(ctx, sub, run, current); err != nil {
+			return nil, nil, fmt.Errorf("failed to bill proration: %w", err)
+		}
+	}
+	return sub, run, nil
+}
+
+// prorationRun returns the run ordering the added quantities for the rest of the current period,
+// or nil when the current period wasn't billed or nothing is left to prorate
+func (s *subscriptionService) prorationRun(ctx context.Context, sub *Subscription, oldItems []Item, current time.Time) (*Run, error) {
+	runs, err := s.repo.GetRuns(ctx, sub.SubscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	var period *Run
+	for i := range runs {
+		if runs[i].Kind == KindPeriod {
+			period = &runs[i]
+			break
+		}
+	}
+	if period == nil || period.OrderID == 0 || current.Before(period.PeriodStart) || !current.Before(period.PeriodEnd) {
+		return nil, nil
+	}
+
+	left := period.PeriodEnd.Sub(current).Milliseconds()
+	length := period.PeriodEnd.Sub(period.PeriodStart).Milliseconds()
+	previous := make(map[[2]uint64]int, len(oldItems))
+	for _, item := range oldItems {
+		previous[[2]uint64{item.ProductID, item.VariantID}] = item.Quantity
+	}
+	var items []Item
+	for _, item := range sub.Items {
+		added := item.Quantity - previous[[2]uint64{item.ProductID, item.VariantID}]
+		if added <= 0 {
+			continue
+		}
+		// Round half up: (added * left / length) + 1/2
+		quantity := int((int64(added)*left*2 + length) / (2 * length))
+		if quantity > 0 {
+			items = append(items, Item{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: quantity})
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return &Run{
+		SubscriptionID: sub.SubscriptionID,
+		Kind:           KindProration,
+		PeriodStart:    current,
+		PeriodEnd:      period.PeriodEnd,
+		Items:          items,
+		Status:         RunPending,
+		CreatedAt:      current,
+		UpdatedAt:      current,
+	}, nil
+}
+
+func (s *subscriptionService) Pause(ctx context.Context, subscriptionID uint64) (*Subscription, error) {
+	sub, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != StatusActive {
+		return nil, fmt.Errorf("subscription must be active to be paused")
+	}
+	current := now()
+	sub.Status = StatusPaused
+	sub.PausedAt = &current
+	sub.UpdatedAt = current
+	if err := s.repo.Update(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to pause subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// Resume restarts the schedule from now when a period was missed while paused, so the customer
+// gets a full period from the day of resuming; otherwise the schedule continues unchanged
+func (s *subscriptionService) Resume(ctx context.Context, subscriptionID uint64) (*Subscription, error) {
+	sub, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status != StatusPaused {
+		return nil, fmt.Errorf("subscription must be paused to be resumed")
+	}
+	current := now()
+	sub.Status = StatusActive
+	sub.PausedAt = nil
+	sub.UpdatedAt = current
+	if sub.NextRunAt.Before(current) {
+		sub.Anchor, sub.Cycle, sub.NextRunAt = current, 0, current
+	}
+	if err := s.repo.Update(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to resume subscription: %w", err)
+	}
+
+	if !sub.NextRunAt.After(current) {
+		if _, err := s.catchUp(ctx, sub, current); err != nil {
+			log.Printf("Subscription %d: failed to bill period after resuming: %v", sub.SubscriptionID, err)
+		}
+	}
+	return sub, nil
+}
+
+func (s *subscriptionService) Cancel(ctx context.Context, subscriptionID uint64) (*Subscription, error) {
+	sub, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == StatusCancelled {
+		return nil, fmt.Errorf("subscription is already cancelled")
+	}
+	current := now()
+	sub.Status = StatusCancelled
+	sub.PausedAt = nil
+	sub.CancelledAt = &current
+	sub.UpdatedAt = current
+	if err := s.repo.Update(ctx, sub); err != nil {
+		return nil, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+	return sub, nil
+}
+
+// RunDue bills due periods subscription by subscription; failures of one subscription don't stop the others
+func (s *subscriptionService) RunDue(ctx context.Context, at time.Time) (int, error) {
+	at = at.UTC().Truncate(time.Millisecond)
+	subs, err := s.repo.GetDue(ctx, at)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get due subscriptions: %w", err)
+	}
+
+	billed := 0
+	for i := range subs {
+		n, err := s.catchUp(ctx, &subs[i], at)
+		billed += n
+		if err != nil {
+			log.Printf("Subscription %d: failed to bill period: %v", subs[i].SubscriptionID, err)
+		}
+	}
+	return billed, nil
+}
+
+// catchUp bills the periods of a subscription that started at or before now, oldest first
+// Each missed period gets its own order and invoice; after downtime at most maxCatchUpPeriods are billed
+// per call and the rest follow on the next ones. It stops at a failed period, which is retried later.
+func (s *subscriptionService) catchUp(ctx context.Context, sub *Subscription, at time.Time) (int, error) {
+	billed := 0
+	for billed < maxCatchUpPeriods && sub.Status == StatusActive && !sub.NextRunAt.After(at) {
+		done, err := s.billPeriod(ctx, sub, at)
+		if err != nil || !done {
+			return billed, err
+		}
+		billed++
+	}
+	return billed, nil
+}
+
+// billPeriod bills the period starting at NextRunAt and moves the subscription on to the next period
+// It reports false while the period is waiting for a retry
+func (s *subscriptionService) billPeriod(ctx context.Context, sub *Subscription, at time.Time) (bool, error) {
+	start := sub.NextRunAt
+	end := periodStart(sub.Anchor, sub.Interval, sub.IntervalCount, sub.Cycle+1)
+
+	run, err := s.repo.GetRun(ctx, sub.SubscriptionID, KindPeriod, start)
+	if err != nil {
+		return false, err
+	}
+	if run == nil {
+		run = &Run{
+			SubscriptionID: sub.SubscriptionID,
+			Kind:           KindPeriod,
+			PeriodStart:    start,
+			PeriodEnd:      end,
+			Items:          sub.Items,
+			Status:         RunPending,
+			CreatedAt:      at,
+			UpdatedAt:      at,
+		}
+		if err := s.repo.CreateRun(ctx, run); err != nil {
+			return false, err
+		}
+	}
+
+	done, err := s.process(ctx, sub, run, at)
+	if err != nil || !done {
+		return false, err
+	}
+
+	sub.Cycle++
+	sub.NextRunAt = end
+	sub.UpdatedAt = at
+	if err := s.repo.Update(ctx, sub); err != nil {
+		return false, fmt.Errorf("failed to advance subscription: %w", err)
+	}
+	return true, nil
+}
+
+// process places the order of a run and invoices it, picking up where a failed attempt stopped
+// It reports whether the run is finished, i.e. invoiced or skipped after too many failed attempts
+func (s *subscriptionService) process(ctx context.Context, sub *Subscription, run *Run, at time.Time) (bool, error) {
+	switch run.Status {
+	case RunInvoiced, RunSkipped:
+		return true, nil
+	case RunFailed:
+		if at.Before(run.UpdatedAt.Add(retryDelay)) {
+			return false, nil
+		}
+	}
+
+	if run.OrderID == 0 {
+		items := make([]order.OrderItemRequest, len(run.Items))
+		for i, item := range run.Items {
+			items[i] = order.OrderItemRequest{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+		}
+		ord, err := s.orderService.CreateOrder(ctx, sub.UserID, order.CreateOrderRequest{
+			Items:    items,
+			Region:   sub.Region,
+			Currency: sub.Currency,
+		})
+		if err != nil {
+			return s.fail(ctx, run, at, err)
+		}
+		run.OrderID = ord.OrderID
+		run.Status = RunOrdered
+		run.UpdatedAt = at
+		if err := s.repo.UpdateRun(ctx, run); err != nil {
+			return false, err
+		}
+	}
+
+	// Invoicing an order is idempotent, so a retry after a failure here never invoices twice
+	inv, err := s.invoiceService.CreateInvoiceFromOrder(ctx, run.OrderID, invoice.Address{})
+	if err != nil {
+		return s.fail(ctx, run, at, err)
+	}
+	run.InvoiceID = inv.InvoiceID
+	run.Status = RunInvoiced
+	run.Error = ""
+	run.UpdatedAt = at
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// fail records a failed attempt of a run and reports whether it was given up
+func (s *subscriptionService) fail(ctx context.Context, run *Run, at time.Time, cause error) (bool, error) {
+	run.Attempts++
+	run.Error = cause.Error()
+	run.Status = RunFailed
+	if run.Attempts >= maxAttempts {
+		run.Status = RunSkipped
+	}
+	run.UpdatedAt = at
+	if err := s.repo.UpdateRun(ctx, run); err != nil {
+		return false, err
+	}
+	log.Printf("Subscription %d: %s run of %s %s after attempt %d: %v",
+		run.SubscriptionID, run.Kind, run.PeriodStart.Format(time.RFC3339), run.Status, run.Attempts, cause)
+	return run.Status == RunSkipped, nil
+}
+
+// validateItems checks that the items can be ordered, as far as that doesn't change until they are
+// Stock and prices are checked when each period is ordered
+func (s *subscriptionService) validateItems(ctx context.Context, items []Item) ([]Item, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("at least one product is required")
+	}
+	if len(items) > maxItems {
+		return nil, fmt.Errorf("invalid items: at most %d products are allowed", maxItems)
+	}
+	seen := make(map[[2]uint64]bool, len(items))
+	for i, item := range items {
+		if item.ProductID == 0 || item.Quantity <= 0 {
+			return nil, fmt.Errorf("item %d: valid product ID and positive quantity are required", i+1)
+		}
+		key := [2]uint64{item.ProductID, item.VariantID}
+		if seen[key] {
+			return nil, fmt.Errorf("item %d: product is listed more than once", i+1)
+		}
+		seen[key] = true
+
+		prod, err := s.productService.GetProductByID(ctx, item.ProductID)
+		if err != nil || prod.Archived {
+			return nil, fmt.Errorf("item %d: product not found", i+1)
+		}
+		if item.VariantID != 0 {
+			if _, ok := prod.Variant(item.VariantID); !ok {
+				return nil, fmt.Errorf("item %d: variant %d of product '%s' not found", i+1, item.VariantID, prod.Name)
+			}
+		} else if len(prod.Variants) > 0 {
+			return nil, fmt.Errorf("item %d: a variant of product '%s' is required", i+1, prod.Name)
+		}
+	}
+	return items, nil
+}
+
+// validateInterval normalizes an interval and its count, which defaults to 1
+func validateInterval(interval string, count int) (string, int, error) {
+	interval = strings.ToLower(strings.TrimSpace(interval))
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+	case "":
+		return "", 0, fmt.Errorf("interval is required")
+	default:
+		return "", 0, fmt.Errorf("invalid interval %q: must be day, week, month or year", interval)
+	}
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 || count > maxIntervalCount {
+		return "", 0, fmt.Errorf("invalid interval_count: must be between 1 and %d", maxIntervalCount)
+	}
+	return interval, count, nil
+}
+
+// periodStart returns the start of period n of a schedule
+// Monthly and yearly periods keep the day of the anchor, or the last day of shorter months, in UTC
+func periodStart(anchor time.Time, interval string, count, n int) time.Time {
+	anchor = anchor.UTC()
+	switch interval {
+	case IntervalDay:
+		return anchor.AddDate(0, 0, count*n)
+	case IntervalWeek:
+		return anchor.AddDate(0, 0, 7*count*n)
+	case IntervalYear:
+		return addMonths(anchor, 12*count*n)
+	default:
+		return addMonths(anchor, count*n)
+	}
+}
+
+// addMonths adds months to t, clamping the day to the end of the resulting month
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
@@ -0,0 +1,51 @@
+package tax // Package tax calculates sales tax for order lines by region and product category.
+
+import (
+	"context"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// TaxCalculator defines the interface for tax calculation
+// Implementations decide rates, exemptions and whether prices include tax
+type TaxCalculator interface {
+	Calculate(ctx context.Context, req Request) (*Result, error)
+	// ValidateRegion checks that a region is one the calculator has rules for; empty means the default region
+	ValidateRegion(region string) error
+}
+
+// Line is a single priced line to be taxed
+type Line struct {
+	ProductID uint64
+	Category  string
+	Quantity  int
+	Amount    money.Money // line amount as priced (unit price * quantity)
+}
+
+// Request is a set of lines taxed together for one region
+type Request struct {
+	Region   string
+	Currency string
+	Lines    []Line
+}
+
+// Breakdown describes the tax applied to one line
+type Breakdown struct {
+	Rule      string      `json:"rule"`
+	Rate      money.Rate  `json:"rate"`
+	Inclusive bool        `json:"inclusive"`
+	Exempt    bool        `json:"exempt,omitempty"`
+	Net       money.Money `json:"net"`   // amount excluding tax
+	Tax       money.Money `json:"tax"`   // tax amount
+	Gross     money.Money `json:"gross"` // amount including tax
+}
+
+// Result is the outcome of a tax calculation
+// Lines are in the same order as the request lines
+type Result struct {
+	Region string      `json:"region"`
+	Lines  []Breakdown `json:"lines"`
+	Net    money.Money `json:"net"`
+	Tax    money.Money `json:"tax"`
+	Gross  money.Money `json:"gross"`
+}
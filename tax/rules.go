@@ -0,0 +1,198 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rajindersingh041/go-auth-sessions/money"
+)
+
+// Rule is a tax rule matched by region and product category
+// An empty Region or Category matches any value
+type Rule struct {
+	Name      string     `json:"name"`
+	Region    string     `json:"region"`
+	Category  string     `json:"category"`
+	Rate      money.Rate `json:"rate"`      // e.g. 0.2 for 20%
+	Exempt    bool       `json:"exempt"`    // exempt lines carry no tax regardless of rate
+	Inclusive *bool      `json:"inclusive"` // overrides Config.PricesIncludeTax when set
+}
+
+// Config holds the rules for the rule-based calculator
+type Config struct {
+	DefaultRegion    string     `json:"default_region"`
+	DefaultRate      money.Rate `json:"default_rate"`
+	PricesIncludeTax bool       `json:"prices_include_tax"`
+	Rules            []Rule     `json:"rules"`
+}
+
+// DefaultConfig is used when no rules file is configured
+// It keeps the historical behaviour: a flat 10% added on top of prices
+func DefaultConfig() Config {
+	return Config{DefaultRate: money.MustParseRate("0.1")}
+}
+
+// LoadConfig reads tax rules from a JSON file
+// An empty path returns DefaultConfig
+func LoadConfig(path string) (Config, error) {
+	if path == "" {
+		return DefaultConfig(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read tax rules: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse tax rules: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// validate checks that all configured rates are sensible
+func (c Config) validate() error {
+	one := money.MustParseRate("1")
+	if c.DefaultRate < 0 || c.DefaultRate >= one {
+		return fmt.Errorf("invalid default tax rate: %v", c.DefaultRate)
+	}
+	for i, rule := range c.Rules {
+		if rule.Rate < 0 || rule.Rate >= one {
+			return fmt.Errorf("tax rule %d (%s): invalid rate %v", i+1, rule.Name, rule.Rate)
+		}
+	}
+	return nil
+}
+
+// RuleBasedCalculator implements TaxCalculator using region and category rules
+type RuleBasedCalculator struct {
+	cfg Config
+}
+
+// NewRuleBasedCalculator creates a new rule-based tax calculator
+func NewRuleBasedCalculator(cfg Config) TaxCalculator {
+	return &RuleBasedCalculator{cfg: cfg}
+}
+
+// Calculate taxes each line with the most specific matching rule
+func (c *RuleBasedCalculator) Calculate(ctx context.Context, req Request) (*Result, error) {
+	if err := c.ValidateRegion(req.Region); err != nil {
+		return nil, err
+	}
+	region := req.Region
+	if region == "" {
+		region = c.cfg.DefaultRegion
+	}
+
+	result := &Result{
+		Region: region,
+		Net:    money.Zero(req.Currency),
+		Tax:    money.Zero(req.Currency),
+		Gross:  money.Zero(req.Currency),
+	}
+	for _, line := range req.Lines {
+		if line.Amount.IsNegative() {
+			return nil, fmt.Errorf("tax: line amount must be non-negative")
+		}
+		breakdown := c.apply(c.match(region, line.Category), line.Amount)
+		result.Lines = append(result.Lines, breakdown)
+		result.Net = result.Net.Add(breakdown.Net)
+		result.Tax = result.Tax.Add(breakdown.Tax)
+		result.Gross = result.Gross.Add(breakdown.Gross)
+	}
+	return result, nil
+}
+
+// ValidateRegion rejects regions other than the default region and those of the rules, so an order can't
+// pick an untaxed region; a configuration without regions taxes every region alike and accepts any
+func (c *RuleBasedCalculator) ValidateRegion(region string) error {
+	if region == "" {
+		region = c.cfg.DefaultRegion
+	}
+	regions := c.cfg.regions()
+	if len(regions) == 0 || containsFold(regions, region) {
+		return nil
+	}
+	return fmt.Errorf("tax region %q is not valid, it must be one of %s", region, strings.Join(regions, ", "))
+}
+
+// regions lists the configured regions: the default region and those of the rules
+func (c Config) regions() []string {
+	var regions []string
+	if c.DefaultRegion != "" {
+		regions = append(regions, c.DefaultRegion)
+	}
+	for _, rule := range c.Rules {
+		if rule.Region != "" && !containsFold(regions, rule.Region) {
+			regions = append(regions, rule.Region)
+		}
+	}
+	return regions
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// match finds the most specific rule for a region and category
+// A rule matching on category beats one matching on region only; ties go to the first rule in the file
+func (c *RuleBasedCalculator) match(region, category string) Rule {
+	best := Rule{Name: "default", Rate: c.cfg.DefaultRate}
+	bestScore := -1
+	for _, rule := range c.cfg.Rules {
+		if rule.Region != "" && !strings.EqualFold(rule.Region, region) {
+			continue
+		}
+		if rule.Category != "" && !strings.EqualFold(rule.Category, category) {
+			continue
+		}
+		score := 0
+		if rule.Category != "" {
+			score += 2
+		}
+		if rule.Region != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = rule, score
+		}
+	}
+	return best
+}
+
+// apply computes the tax of one line amount under a rule
+// Tax is rounded to the minor unit per line
+func (c *RuleBasedCalculator) apply(rule Rule, amount money.Money) Breakdown {
+	inclusive := c.cfg.PricesIncludeTax
+	if rule.Inclusive != nil {
+		inclusive = *rule.Inclusive
+	}
+	rate := rule.Rate
+	if rule.Exempt {
+		rate = 0
+	}
+
+	b := Breakdown{Rule: rule.Name, Rate: rate, Inclusive: inclusive, Exempt: rule.Exempt}
+	if inclusive {
+		// Price already contains the tax: split it out
+		b.Gross = amount
+		b.Net = amount.ExcludeRate(rate)
+		b.Tax = b.Gross.Sub(b.Net)
+	} else {
+		b.Net = amount
+		b.Tax = amount.MulRate(rate)
+		b.Gross = b.Net.Add(b.Tax)
+	}
+	return b
+}
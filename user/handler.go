@@ -44,7 +44,7 @@ func (h *Handler) handleRegister() http.HandlerFunc {
 				helper.RespondError(w, http.StatusConflict, err.Error())
 				return
 			}
-			if err.Error() == "username and password are required" {
+			if err.Error() == "username and password are required" || err.Error() == "invalid email address" {
 				helper.RespondError(w, http.StatusBadRequest, err.Error())
 				return
 			}
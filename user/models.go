@@ -19,7 +19,8 @@ type User struct {
 // This allows for better control over request lifecycles
 // and resource management in database operations.
 type UserRepository interface {
-	Create(ctx context.Context, username, passwordHash string) error
+	// Create stores a new user; email may be empty
+	Create(ctx context.Context, username, passwordHash, email string) error
 	FindByUsername(ctx context.Context, username string) (*User, error)
 	FindByID(ctx context.Context, userID uint64) (*User, error)
 	FindUserID(ctx context.Context, username string) (uint64, error)
@@ -30,6 +31,7 @@ type UserRepository interface {
 type CreateUserRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email"` // optional, used for notifications such as back-in-stock alerts
 }
 
 // LoginRequest represents the request to login
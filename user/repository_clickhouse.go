@@ -17,9 +17,9 @@ func NewClickHouseRepository(db *sql.DB) UserRepository {
 	return &ClickHouseRepository{db: db}
 }
 
-func (r *ClickHouseRepository) Create(ctx context.Context, username, passwordHash string) error {
-	query := "INSERT INTO users (username, password_hash) VALUES (?, ?)"
-	_, err := r.db.ExecContext(ctx, query, username, passwordHash)
+func (r *ClickHouseRepository) Create(ctx context.Context, username, passwordHash, email string) error {
+	query := "INSERT INTO users (username, password_hash, email) VALUES (?, ?, ?)"
+	_, err := r.db.ExecContext(ctx, query, username, passwordHash, email)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -28,11 +28,12 @@ func (r *ClickHouseRepository) Create(ctx context.Context, username, passwordHas
 
 func (r *ClickHouseRepository) FindByUsername(ctx context.Context, username string) (*User, error) {
 	var user User
-	query := "SELECT user_id, username, password_hash FROM users WHERE username = ? LIMIT 1"
+	query := "SELECT user_id, username, password_hash, email FROM users WHERE username = ? LIMIT 1"
 	err := r.db.QueryRowContext(ctx, query, username).Scan(
 		&user.UserID,
 		&user.Username,
 		&user.PasswordHash,
+		&user.EmailID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -45,11 +46,12 @@ func (r *ClickHouseRepository) FindByUsername(ctx context.Context, username stri
 
 func (r *ClickHouseRepository) FindByID(ctx context.Context, userID uint64) (*User, error) {
 	var user User
-	query := "SELECT user_id, username, password_hash FROM users WHERE user_id = ? LIMIT 1"
+	query := "SELECT user_id, username, password_hash, email FROM users WHERE user_id = ? LIMIT 1"
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(
 		&user.UserID,
 		&user.Username,
 		&user.PasswordHash,
+		&user.EmailID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
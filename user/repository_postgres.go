@@ -23,16 +23,19 @@ func (r *PostgresRepository) ensureUsersTable(ctx context.Context) error {
 		username TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL
 	)`
-	_, err := r.db.ExecContext(ctx, query)
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT ''")
 	return err
 }
 
-func (r *PostgresRepository) Create(ctx context.Context, username, passwordHash string) error {
+func (r *PostgresRepository) Create(ctx context.Context, username, passwordHash, email string) error {
 	if err := r.ensureUsersTable(ctx); err != nil {
 		return err
 	}
-	query := "INSERT INTO users (username, password_hash) VALUES ($1, $2)"
-	_, err := r.db.ExecContext(ctx, query, username, passwordHash)
+	query := "INSERT INTO users (username, password_hash, email) VALUES ($1, $2, $3)"
+	_, err := r.db.ExecContext(ctx, query, username, passwordHash, email)
 	return err
 }
 
@@ -41,8 +44,8 @@ func (r *PostgresRepository) FindByUsername(ctx context.Context, username string
 		return nil, err
 	}
 	var user User
-	query := "SELECT user_id, username, password_hash FROM users WHERE username = $1 LIMIT 1"
-	err := r.db.QueryRowContext(ctx, query, username).Scan(&user.UserID, &user.Username, &user.PasswordHash)
+	query := "SELECT user_id, username, password_hash, email FROM users WHERE username = $1 LIMIT 1"
+	err := r.db.QueryRowContext(ctx, query, username).Scan(&user.UserID, &user.Username, &user.PasswordHash, &user.EmailID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -57,8 +60,8 @@ func (r *PostgresRepository) FindByID(ctx context.Context, userID uint64) (*User
 		return nil, err
 	}
 	var user User
-	query := "SELECT user_id, username, password_hash FROM users WHERE user_id = $1 LIMIT 1"
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(&user.UserID, &user.Username, &user.PasswordHash)
+	query := "SELECT user_id, username, password_hash, email FROM users WHERE user_id = $1 LIMIT 1"
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&user.UserID, &user.Username, &user.PasswordHash, &user.EmailID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -3,6 +3,8 @@ package user
 import (
 	"context"
 	"fmt"
+	"net/mail"
+	"strings"
 
 	"github.com/rajindersingh041/go-auth-sessions/auth"
 )
@@ -36,6 +38,14 @@ func (s *userService) CreateUser(ctx context.Context, req CreateUserRequest) err
 	if req.Username == "" || req.Password == "" {
 		return fmt.Errorf("username and password are required")
 	}
+	email := strings.TrimSpace(req.Email)
+	if email != "" {
+		address, err := mail.ParseAddress(email)
+		if err != nil || address.Name != "" {
+			return fmt.Errorf("invalid email address")
+		}
+		email = address.Address
+	}
 
 	// Check if user already exists
 	exists, err := s.repo.UserExists(ctx, req.Username)
@@ -53,7 +63,7 @@ func (s *userService) CreateUser(ctx context.Context, req CreateUserRequest) err
 	}
 
 	// Create user
-	return s.repo.Create(ctx, req.Username, hashedPassword)
+	return s.repo.Create(ctx, req.Username, hashedPassword, email)
 }
 
 // AuthenticateUser authenticates a user and returns user info if successful
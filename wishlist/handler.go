@@ -0,0 +1,187 @@
+package wishlist
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/rajindersingh041/go-auth-sessions/auth"
+	"github.com/rajindersingh041/go-auth-sessions/helper"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// Handler handles HTTP requests for wishlists and back-in-stock alerts
+type Handler struct {
+	service     WishlistService
+	userService user.UserService
+}
+
+// NewHandler creates a new wishlist handler
+func NewHandler(service WishlistService, userService user.UserService) *Handler {
+	return &Handler{
+		service:     service,
+		userService: userService,
+	}
+}
+
+// RegisterRoutes registers the wishlist and stock alert routes; all of them require authentication
+func (h *Handler) RegisterRoutes(mux *http.ServeMux, jwtManager auth.JWTManager) {
+	mux.Handle("GET /wishlist", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetWishlist())))
+	mux.Handle("POST /wishlist/items", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleAddItem())))
+	mux.Handle("DELETE /wishlist/items/{product_id}", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleRemoveItem())))
+	mux.Handle("GET /wishlist/stock-alerts", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleGetStockAlerts())))
+	mux.Handle("POST /products/{id}/stock-alert", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleSubscribeStockAlert())))
+	mux.Handle("DELETE /products/{id}/stock-alert", auth.WithJWTAuth(jwtManager, http.HandlerFunc(h.handleUnsubscribeStockAlert())))
+}
+
+// handleGetWishlist handles GET /wishlist
+func (h *Handler) handleGetWishlist() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		items, err := h.service.GetWishlist(r.Context(), userID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to fetch wishlist")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"items": items,
+			"count": len(items),
+		})
+	}
+}
+
+// handleAddItem handles POST /wishlist/items
+// Body: {"product_id":3}
+func (h *Handler) handleAddItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+		var req AddItemRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		item, err := h.service.AddItem(r.Context(), userID, req.ProductID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to add product to wishlist")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, item)
+	}
+}
+
+// handleRemoveItem handles DELETE /wishlist/items/{product_id}
+func (h *Handler) handleRemoveItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("product_id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.RemoveItem(r.Context(), userID, productID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to remove product from wishlist")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Product removed from wishlist",
+		})
+	}
+}
+
+// handleGetStockAlerts handles GET /wishlist/stock-alerts, listing the products the user waits for
+func (h *Handler) handleGetStockAlerts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		alerts, err := h.service.GetStockAlerts(r.Context(), userID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to fetch stock alerts")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]interface{}{
+			"stock_alerts": alerts,
+			"count":        len(alerts),
+		})
+	}
+}
+
+// handleSubscribeStockAlert handles POST /products/{id}/stock-alert ("notify me when back in stock")
+func (h *Handler) handleSubscribeStockAlert() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		alert, err := h.service.SubscribeStockAlert(r.Context(), userID, productID)
+		if err != nil {
+			helper.RespondServiceError(w, err, "Failed to create stock alert")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusCreated, alert)
+	}
+}
+
+// handleUnsubscribeStockAlert handles DELETE /products/{id}/stock-alert
+func (h *Handler) handleUnsubscribeStockAlert() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		productID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			helper.RespondError(w, http.StatusBadRequest, "Invalid product ID")
+			return
+		}
+		userID, ok := h.currentUserID(w, r)
+		if !ok {
+			return
+		}
+
+		if err := h.service.UnsubscribeStockAlert(r.Context(), userID, productID); err != nil {
+			helper.RespondServiceError(w, err, "Failed to remove stock alert")
+			return
+		}
+
+		helper.RespondJSON(w, http.StatusOK, map[string]string{
+			"message": "Stock alert removed",
+		})
+	}
+}
+
+// currentUserID resolves the authenticated user, writing an error response when that fails
+func (h *Handler) currentUserID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	username, ok := r.Context().Value(auth.UsernameContextKey).(string)
+	if !ok || username == "" {
+		helper.RespondError(w, http.StatusUnauthorized, "User not authenticated")
+		return 0, false
+	}
+	user, err := h.userService.GetUserByUsername(r.Context(), username)
+	if err != nil || user == nil {
+		helper.RespondError(w, http.StatusNotFound, "User not found")
+		return 0, false
+	}
+	return user.UserID, true
+}
@@ -0,0 +1,42 @@
+package wishlist
+
+import (
+	"context"
+
+	"github.com/rajindersingh041/go-auth-sessions/product"
+)
+
+// Item is a product saved to a user's wishlist
+type Item struct {
+	UserID    uint64           `json:"-"`
+	ProductID uint64           `json:"product_id"`
+	AddedAt   string           `json:"added_at"`
+	Product   *product.Product `json:"product,omitempty"` // current product details, filled in by the service
+}
+
+// StockAlert is a user's request to be notified when an out of stock product is back in stock
+// Alerts are removed once the user has been notified
+type StockAlert struct {
+	UserID    uint64 `json:"-"`
+	ProductID uint64 `json:"product_id"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Repository defines the interface for wishlist and stock alert data operations
+type WishlistRepository interface {
+	AddItem(ctx context.Context, item *Item) error
+	// GetItems returns the items of a user's wishlist, newest first
+	GetItems(ctx context.Context, userID uint64) ([]Item, error)
+	RemoveItem(ctx context.Context, userID, productID uint64) error
+	CreateAlert(ctx context.Context, alert *StockAlert) error
+	// GetAlerts returns a user's stock alerts, newest first
+	GetAlerts(ctx context.Context, userID uint64) ([]StockAlert, error)
+	// GetAlertsByProduct returns the stock alerts of a product, oldest first
+	GetAlertsByProduct(ctx context.Context, productID uint64) ([]StockAlert, error)
+	DeleteAlert(ctx context.Context, userID, productID uint64) error
+}
+
+// AddItemRequest represents the request to save a product to the wishlist
+type AddItemRequest struct {
+	ProductID uint64 `json:"product_id"`
+}
@@ -0,0 +1,77 @@
+package wishlist
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/rajindersingh041/go-auth-sessions/notify"
+	"github.com/rajindersingh041/go-auth-sessions/product"
+	"github.com/rajindersingh041/go-auth-sessions/user"
+)
+
+// BackInStockNotifier sends the stock alerts of products that come back in stock
+// It implements product.StockWatcher, so the product service can call it without depending on wishlists
+type BackInStockNotifier struct {
+	repo     WishlistRepository
+	users    user.UserService
+	notifier notify.Notifier
+}
+
+// NewBackInStockNotifier creates a notifier for the stock alerts in repo
+func NewBackInStockNotifier(repo WishlistRepository, users user.UserService, notifier notify.Notifier) *BackInStockNotifier {
+	return &BackInStockNotifier{
+		repo:     repo,
+		users:    users,
+		notifier: notifier,
+	}
+}
+
+// BackInStock notifies the product's subscribers in the background, so stock updates don't wait for emails
+func (n *BackInStockNotifier) BackInStock(ctx context.Context, p *product.Product) {
+	go n.notifySubscribers(context.WithoutCancel(ctx), *p)
+}
+
+// notifySubscribers notifies every subscriber of a product and removes their alerts
+// Alerts whose notification failed are kept, so the user is notified the next time the product is back in stock
+func (n *BackInStockNotifier) notifySubscribers(ctx context.Context, p product.Product) {
+	alerts, err := n.repo.GetAlertsByProduct(ctx, p.ProductID)
+	if err != nil {
+		log.Printf("Failed to load stock alerts of product %d: %v", p.ProductID, err)
+		return
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	sent := 0
+	for _, alert := range alerts {
+		if err := n.notifySubscriber(ctx, alert, &p); err != nil {
+			log.Printf("Failed to send stock alert of product %d to user %d: %v", p.ProductID, alert.UserID, err)
+			continue
+		}
+		if err := n.repo.DeleteAlert(ctx, alert.UserID, alert.ProductID); err != nil {
+			log.Printf("Failed to remove stock alert of product %d for user %d: %v", p.ProductID, alert.UserID, err)
+		}
+		sent++
+	}
+	log.Printf("Sent %d of %d stock alerts for product %d", sent, len(alerts), p.ProductID)
+}
+
+// notifySubscriber tells one user that a product is back in stock
+func (n *BackInStockNotifier) notifySubscriber(ctx context.Context, alert StockAlert, p *product.Product) error {
+	u, err := n.users.GetUserByID(ctx, alert.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return fmt.Errorf("user not found")
+	}
+	return n.notifier.Notify(ctx, notify.Notification{
+		Username: u.Username,
+		Email:    u.EmailID,
+		Subject:  fmt.Sprintf("%s is back in stock", p.Name),
+		Body: fmt.Sprintf("Hi %s,\n\n%s is back in stock. Order it soon, before it sells out again.\n\nYou asked us to let you know; this was a one-time alert.",
+			u.Username, p.Name),
+	})
+}
@@ -0,0 +1,157 @@
+package wishlist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ClickHouseRepository implements WishlistRepository for ClickHouse database
+type ClickHouseRepository struct {
+	db *sql.DB
+}
+
+// NewClickHouseRepository creates a new ClickHouse wishlist repository
+func NewClickHouseRepository(db *sql.DB) WishlistRepository {
+	return &ClickHouseRepository{db: db}
+}
+
+// ensureWishlistTables creates the wishlist_items and stock_alerts tables if they don't exist
+// ClickHouse has no unique constraints, so inserts check for an existing row first
+func (r *ClickHouseRepository) ensureWishlistTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS wishlist_items (
+			user_id UInt64,
+			product_id UInt64,
+			added_at String
+		) ENGINE = MergeTree()
+		ORDER BY (user_id, product_id)`,
+		`CREATE TABLE IF NOT EXISTS stock_alerts (
+			user_id UInt64,
+			product_id UInt64,
+			created_at String
+		) ENGINE = MergeTree()
+		ORDER BY (product_id, user_id)`,
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ClickHouseRepository) AddItem(ctx context.Context, item *Item) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	exists, err := r.exists(ctx, "wishlist_items", item.UserID, item.ProductID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("wishlist item already exists")
+	}
+	query := "INSERT INTO wishlist_items (user_id, product_id, added_at) VALUES (?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query, item.UserID, item.ProductID, item.AddedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) GetItems(ctx context.Context, userID uint64) ([]Item, error) {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT user_id, product_id, added_at FROM wishlist_items WHERE user_id = ? ORDER BY added_at DESC, product_id DESC"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Item
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.UserID, &item.ProductID, &item.AddedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *ClickHouseRepository) RemoveItem(ctx context.Context, userID, productID uint64) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE wishlist_items DELETE WHERE user_id = ? AND product_id = ?", userID, productID)
+	return err
+}
+
+func (r *ClickHouseRepository) CreateAlert(ctx context.Context, alert *StockAlert) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	exists, err := r.exists(ctx, "stock_alerts", alert.UserID, alert.ProductID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("stock alert already exists")
+	}
+	query := "INSERT INTO stock_alerts (user_id, product_id, created_at) VALUES (?, ?, ?)"
+	_, err = r.db.ExecContext(ctx, query, alert.UserID, alert.ProductID, alert.CreatedAt)
+	return err
+}
+
+func (r *ClickHouseRepository) GetAlerts(ctx context.Context, userID uint64) ([]StockAlert, error) {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT user_id, product_id, created_at FROM stock_alerts WHERE user_id = ? ORDER BY created_at DESC, product_id DESC"
+	return r.getAlerts(ctx, query, userID)
+}
+
+func (r *ClickHouseRepository) GetAlertsByProduct(ctx context.Context, productID uint64) ([]StockAlert, error) {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT user_id, product_id, created_at FROM stock_alerts WHERE product_id = ? ORDER BY created_at, user_id"
+	return r.getAlerts(ctx, query, productID)
+}
+
+func (r *ClickHouseRepository) DeleteAlert(ctx context.Context, userID, productID uint64) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, "ALTER TABLE stock_alerts DELETE WHERE user_id = ? AND product_id = ?", userID, productID)
+	return err
+}
+
+// exists reports whether a table has a row for a user and product
+func (r *ClickHouseRepository) exists(ctx context.Context, table string, userID, productID uint64) (bool, error) {
+	var count uint64
+	query := "SELECT count() FROM " + table + " WHERE user_id = ? AND product_id = ?"
+	if err := r.db.QueryRowContext(ctx, query, userID, productID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// getAlerts runs a query for a list of stock alerts
+func (r *ClickHouseRepository) getAlerts(ctx context.Context, query string, args ...interface{}) ([]StockAlert, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []StockAlert
+	for rows.Next() {
+		var alert StockAlert
+		if err := rows.Scan(&alert.UserID, &alert.ProductID, &alert.CreatedAt); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,162 @@
+package wishlist
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresRepository implements WishlistRepository for PostgreSQL database
+type PostgresRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresRepository creates a new PostgreSQL wishlist repository
+func NewPostgresRepository(db *sql.DB) WishlistRepository {
+	return &PostgresRepository{db: db}
+}
+
+// ensureWishlistTables creates the wishlist_items and stock_alerts tables if they don't exist
+func (r *PostgresRepository) ensureWishlistTables(ctx context.Context) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS wishlist_items (
+			user_id BIGINT NOT NULL,
+			product_id BIGINT NOT NULL,
+			added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, product_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS stock_alerts (
+			user_id BIGINT NOT NULL,
+			product_id BIGINT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, product_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS idx_stock_alerts_product_id ON stock_alerts (product_id)",
+	}
+	for _, query := range queries {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresRepository) AddItem(ctx context.Context, item *Item) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	query := "INSERT INTO wishlist_items (user_id, product_id, added_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	result, err := r.db.ExecContext(ctx, query, item.UserID, item.ProductID, item.AddedAt)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("wishlist item already exists")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetItems(ctx context.Context, userID uint64) ([]Item, error) {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT user_id, product_id, added_at FROM wishlist_items WHERE user_id = $1 ORDER BY added_at DESC, product_id DESC"
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []Item
+	for rows.Next() {
+		var item Item
+		var addedAt time.Time
+		if err := rows.Scan(&item.UserID, &item.ProductID, &addedAt); err != nil {
+			return nil, err
+		}
+		item.AddedAt = addedAt.Format(time.RFC3339)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *PostgresRepository) RemoveItem(ctx context.Context, userID, productID uint64) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM wishlist_items WHERE user_id = $1 AND product_id = $2", userID, productID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("wishlist item not found")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) CreateAlert(ctx context.Context, alert *StockAlert) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	query := "INSERT INTO stock_alerts (user_id, product_id, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	result, err := r.db.ExecContext(ctx, query, alert.UserID, alert.ProductID, alert.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("stock alert already exists")
+	}
+	return nil
+}
+
+func (r *PostgresRepository) GetAlerts(ctx context.Context, userID uint64) ([]StockAlert, error) {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT user_id, product_id, created_at FROM stock_alerts WHERE user_id = $1 ORDER BY created_at DESC, product_id DESC"
+	return r.getAlerts(ctx, query, userID)
+}
+
+func (r *PostgresRepository) GetAlertsByProduct(ctx context.Context, productID uint64) ([]StockAlert, error) {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return nil, err
+	}
+	query := "SELECT user_id, product_id, created_at FROM stock_alerts WHERE product_id = $1 ORDER BY created_at, user_id"
+	return r.getAlerts(ctx, query, productID)
+}
+
+func (r *PostgresRepository) DeleteAlert(ctx context.Context, userID, productID uint64) error {
+	if err := r.ensureWishlistTables(ctx); err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, "DELETE FROM stock_alerts WHERE user_id = $1 AND product_id = $2", userID, productID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return fmt.Errorf("stock alert not found")
+	}
+	return nil
+}
+
+// getAlerts runs a query for a list of stock alerts
+func (r *PostgresRepository) getAlerts(ctx context.Context, query string, args ...interface{}) ([]StockAlert, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []StockAlert
+	for rows.Next() {
+		var alert StockAlert
+		var createdAt time.Time
+		if err := rows.Scan(&alert.UserID, &alert.ProductID, &createdAt); err != nil {
+			return nil, err
+		}
+		alert.CreatedAt = createdAt.Format(time.RFC3339)
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
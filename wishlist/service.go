@@ -0,0 +1,149 @@
+package wishlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rajindersingh041/go-auth-sessions/product"
+)
+
+// WishlistService defines the business logic interface for wishlists and back-in-stock alerts
+type WishlistService interface {
+	// GetWishlist returns a user's wishlist with the current details of each product
+	GetWishlist(ctx context.Context, userID uint64) ([]Item, error)
+	AddItem(ctx context.Context, userID, productID uint64) (*Item, error)
+	RemoveItem(ctx context.Context, userID, productID uint64) error
+	GetStockAlerts(ctx context.Context, userID uint64) ([]StockAlert, error)
+	// SubscribeStockAlert asks for a notification when an out of stock product is back in stock
+	SubscribeStockAlert(ctx context.Context, userID, productID uint64) (*StockAlert, error)
+	UnsubscribeStockAlert(ctx context.Context, userID, productID uint64) error
+}
+
+// wishlistService implements the WishlistService interface
+type wishlistService struct {
+	repo     WishlistRepository
+	products product.ProductService
+}
+
+// NewWishlistService creates a new wishlist service
+func NewWishlistService(repo WishlistRepository, products product.ProductService) WishlistService {
+	return &wishlistService{
+		repo:     repo,
+		products: products,
+	}
+}
+
+// GetWishlist returns the wishlist with each product's prices at this moment
+// Products that were deleted since they were saved are left out
+func (s *wishlistService) GetWishlist(ctx context.Context, userID uint64) ([]Item, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	items, err := s.repo.GetItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	wishlist := []Item{}
+	for _, item := range items {
+		p, err := s.products.GetProductAt(ctx, item.ProductID, now)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				continue
+			}
+			return nil, err
+		}
+		if p.Archived {
+			continue
+		}
+		item.Product = p
+		wishlist = append(wishlist, item)
+	}
+	return wishlist, nil
+}
+
+// AddItem saves a product to the wishlist; each product is saved once
+func (s *wishlistService) AddItem(ctx context.Context, userID, productID uint64) (*Item, error) {
+	p, err := s.getActiveProduct(ctx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	item := &Item{
+		UserID:    userID,
+		ProductID: productID,
+		AddedAt:   time.Now().Format(time.RFC3339),
+	}
+	if err := s.repo.AddItem(ctx, item); err != nil {
+		return nil, err
+	}
+	item.Product = p
+	return item, nil
+}
+
+// RemoveItem removes a product from the wishlist
+func (s *wishlistService) RemoveItem(ctx context.Context, userID, productID uint64) error {
+	if userID == 0 || productID == 0 {
+		return fmt.Errorf("valid user and product IDs are required")
+	}
+	return s.repo.RemoveItem(ctx, userID, productID)
+}
+
+// GetStockAlerts returns the products a user waits for
+func (s *wishlistService) GetStockAlerts(ctx context.Context, userID uint64) ([]StockAlert, error) {
+	if userID == 0 {
+		return nil, fmt.Errorf("valid user ID is required")
+	}
+	alerts, err := s.repo.GetAlerts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if alerts == nil {
+		alerts = []StockAlert{}
+	}
+	return alerts, nil
+}
+
+// SubscribeStockAlert creates a stock alert for an out of stock product
+func (s *wishlistService) SubscribeStockAlert(ctx context.Context, userID, productID uint64) (*StockAlert, error) {
+	p, err := s.getActiveProduct(ctx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Available() {
+		return nil, fmt.Errorf("product is already in stock")
+	}
+	alert := &StockAlert{
+		UserID:    userID,
+		ProductID: productID,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	if err := s.repo.CreateAlert(ctx, alert); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// UnsubscribeStockAlert removes a stock alert
+func (s *wishlistService) UnsubscribeStockAlert(ctx context.Context, userID, productID uint64) error {
+	if userID == 0 || productID == 0 {
+		return fmt.Errorf("valid user and product IDs are required")
+	}
+	return s.repo.DeleteAlert(ctx, userID, productID)
+}
+
+// getActiveProduct returns a product that is not deleted
+func (s *wishlistService) getActiveProduct(ctx context.Context, userID, productID uint64) (*product.Product, error) {
+	if userID == 0 || productID == 0 {
+		return nil, fmt.Errorf("valid user and product IDs are required")
+	}
+	p, err := s.products.GetProductByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Archived {
+		return nil, fmt.Errorf("product not found")
+	}
+	return p, nil
+}